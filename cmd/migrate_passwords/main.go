@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"merch_service/internal/service"
+	"merch_service/internal/storage"
+	"merch_service/internal/storage/postgres"
+)
+
+// Одноразовая миграция паролей, сохраненных в открытом виде, в хеши argon2id.
+// Запускать из корня проекта (как и сервер):
+//
+//	go run ./cmd/migrate_passwords
+//
+// Пользователи, которые не попали под миграцию, все равно получат хеш
+// при следующем входе (см. UserService.Login)
+func main() {
+	db := storage.InitDB()
+	defer db.Close()
+
+	userService := service.NewUserService(
+		postgres.NewUserStorage(db),
+		postgres.NewPurchaseStorage(db),
+		postgres.NewCoinsStorage(db),
+		postgres.NewTransactionStorage(db),
+		postgres.NewTxManager(db),
+		postgres.NewLedgerStorage(db),
+		postgres.NewSessionStorage(db),
+		postgres.NewRefreshTokenStorage(db),
+	)
+
+	migrated, err := userService.MigratePasswords(context.Background())
+	if err != nil {
+		log.Fatalf("миграция паролей прервана после %d пользователей: %v", migrated, err)
+	}
+
+	log.Printf("Миграция паролей завершена, обновлено пользователей: %d", migrated)
+}
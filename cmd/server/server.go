@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"merch_service/configs"
 	"merch_service/internal/handlers"
+	"merch_service/internal/models"
+	"merch_service/internal/notify"
 	"merch_service/internal/server"
 	"merch_service/internal/service"
 	"merch_service/internal/storage"
+	"merch_service/internal/storage/local"
 	"merch_service/internal/storage/postgres"
+	"time"
 )
 
 func main() {
@@ -17,18 +25,94 @@ func main() {
 	transactionStorage := postgres.NewTransactionStorage(db)
 	coinsStorage := postgres.NewCoinsStorage(db)
 	purchaseStorage := postgres.NewPurchaseStorage(db)
+	refreshStorage := postgres.NewRefreshTokenStorage(db)
+	sessionStorage := postgres.NewSessionStorage(db)
+	ledgerStorage := postgres.NewLedgerStorage(db)
+	idempotencyStorage := postgres.NewIdempotencyStorage(db)
+	orderStorage := postgres.NewOrderStorage(db)
+	cartStorage := postgres.NewCartStorage(db)
+	variantStorage := postgres.NewVariantStorage(db)
+	imageStorage := postgres.NewImageStorage(db)
+	promoStorage := postgres.NewPromoStorage(db)
+	reservationStorage := postgres.NewReservationStorage(db)
+	scheduleStorage := postgres.NewScheduleStorage(db)
+	coinRequestStorage := postgres.NewCoinRequestStorage(db)
+	transferFlagStorage := postgres.NewTransferFlagStorage(db)
+	grantStorage := postgres.NewGrantStorage(db)
+	wishlistStorage := postgres.NewWishlistStorage(db)
+	notificationStorage := postgres.NewNotificationStorage(db)
+	txManager := postgres.NewTxManager(db)
+
+	// Файлы изображений лежат на диске рядом с сервером (см. docker-compose.yml)
+	blobStorage := local.NewBlobStorage("media")
+
+	// Внешний канал уведомлений подключается после загрузки конфига (см. ниже)
+	restockNotifier := service.NewRestockNotifier(notificationStorage, variantStorage, nil)
 
 	// Инициализация сервисов
-	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage)
-	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage)
-	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage)
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, transactionStorage, txManager, ledgerStorage, sessionStorage, refreshStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, cartStorage, variantStorage, imageStorage, promoStorage, reservationStorage, restockNotifier)
+	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, txManager, ledgerStorage, scheduleStorage, coinRequestStorage, transferFlagStorage)
+	authService := service.NewAuthService(refreshStorage, sessionStorage, userStorage, txManager)
+	ledgerService := service.NewLedgerService(ledgerStorage, userStorage, coinsStorage, txManager, grantStorage)
+	idempotencyService := service.NewIdempotencyService(idempotencyStorage, userStorage)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, restockNotifier)
+	mediaService := service.NewMediaService(imageStorage, merchStorage, blobStorage)
+	promoService := service.NewPromoService(promoStorage, merchStorage, orderStorage)
+	notificationService := service.NewNotificationService(wishlistStorage, notificationStorage, userStorage, variantStorage)
+
+	// Текст для поиска, заполненный миграцией, приводится к тому же виду, что и в приложении
+	if n, err := merchService.RefreshSearchText(context.Background()); err != nil {
+		log.Printf("не удалось пересчитать текст для поиска по каталогу: %v", err)
+	} else if n > 0 {
+		log.Printf("пересчитан текст для поиска по каталогу: %d товаров", n)
+	}
 
 	// Инициализация хендлеров
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, authService)
 	merchHandler := handlers.NewMerchHandler(merchService)
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	authHandler := handlers.NewAuthHandler(authService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	idempotencyHandler := handlers.NewIdempotencyHandler(idempotencyService)
+	orderHandler := handlers.NewOrderHandler(orderService)
+	mediaHandler := handlers.NewMediaHandler(mediaService)
+	promoHandler := handlers.NewPromoHandler(promoService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
 
 	// Эти серивисы передаются в Server
-	serv := server.NewMerchServer(userHandler, transactionHandler, merchHandler, "")
+	serv := server.NewMerchServer(userHandler, transactionHandler, merchHandler, authHandler, ledgerHandler, idempotencyHandler, orderHandler, mediaHandler, promoHandler, notificationHandler, "")
+
+	// Недоставленные во вебхук уведомления отправляются повторно в фоне
+	if url := serv.Config().NotifyWebhook; url != "" {
+		restockNotifier.Notifier = notify.NewWebhook(url)
+
+		retry := time.Duration(serv.Config().NotifyRetry) * time.Second
+		go restockNotifier.DeliverNotifications(context.Background(), retry)
+	}
+
+	// Лимиты переводов и поиск подозрительных переводов (см. models.TransferLimits)
+	transactionService.Limits = serv.Config().TransferLimits
+
+	// Первый администратор из конфига, иначе в свежей базе некому выдать права.
+	// Пароль задается переменной окружения ADMIN_PASSWORD (см. configs.AdminConfig),
+	// без него сервер запускается без создания администратора
+	admin := serv.Config().Admin
+	err := userService.BootstrapAdmin(context.Background(), admin.Login, admin.Password)
+	switch {
+	case errors.Is(err, models.ErrEmptyUserPassword):
+		log.Printf("администратор не создан: пароль не задан (переменная окружения %s)", configs.AdminPasswordEnv)
+	case err != nil:
+		log.Fatalln("не удалось создать администратора:", err)
+	}
+
+	// Истекшие брони закрываются в фоне, монеты возвращаются владельцам
+	sweep := time.Duration(serv.Config().ReservationSweep) * time.Second
+	go merchService.SweepReservations(context.Background(), sweep)
+
+	// Запланированные переводы выполняются в фоне (см. TransactionService.RunSchedules)
+	schedule := time.Duration(serv.Config().ScheduleSweep) * time.Second
+	go transactionService.ScheduleTransfers(context.Background(), schedule)
+
 	serv.Start()
 }
@@ -1,9 +1,41 @@
 package configs
 
+import "merch_service/internal/models"
+
+// AdminPasswordEnv - переменная окружения с паролем первого администратора
+const AdminPasswordEnv = "ADMIN_PASSWORD"
+
 type ServerConfig struct {
 	Host          string `yaml:"host"`
 	Port          int    `yaml:"port"`
 	Secret        string `yaml:"secret"` // Секретный ключ. Для простоты храним секретный ключ в структуре;
 	RefreshSecret string `yaml:"refresh"`
 	ExpTimeout    int64  `yaml:"exptimeout"` // Время жизни токена. Задается в секундах
+
+	RefreshExpTimeout int64 `yaml:"refreshexptimeout"` // Время жизни refresh токена. Задается в секундах
+
+	IdempotencyTTL int64 `yaml:"idempotencyttl"` // Время хранения ответов по Idempotency-Key. Задается в секундах
+
+	Admin AdminConfig `yaml:"admin"` // Первый администратор, создается при запуске сервера
+
+	NotifyWebhook string `yaml:"notifywebhook"` // URL вебхука для уведомлений. Если пустой, уведомления только во внутреннем ящике
+	NotifyRetry   int64  `yaml:"notifyretry"`   // Период повторной отправки недоставленных уведомлений. Задается в секундах
+
+	ReservationTTL   int64 `yaml:"reservationttl"`   // Срок удержания товара в наличии. Задается в секундах
+	PreorderTTL      int64 `yaml:"preorderttl"`      // Срок предзаказа. Задается в секундах
+	ReservationSweep int64 `yaml:"reservationsweep"` // Период закрытия истекших броней. Задается в секундах
+
+	ScheduleSweep int64 `yaml:"schedulesweep"` // Период выполнения запланированных переводов. Задается в секундах
+
+	CoinRequestTTL int64 `yaml:"coinrequestttl"` // Срок ответа на запрос монет. Задается в секундах
+
+	TransferLimits models.TransferLimits `yaml:"transferlimits"` // Лимиты исходящих переводов, 0 - без ограничения
+}
+
+// AdminConfig - учетная запись первого администратора.
+// Если login пустой, администратор не создается. Пароль в репозитории не хранится:
+// он берется из переменной окружения AdminPasswordEnv, если она задана
+type AdminConfig struct {
+	Login    string `yaml:"login"`
+	Password string `yaml:"pass"`
 }
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"merch_service/configs"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AuthHandler - структура мост, для связывания уровня хендлеров
+// с сервисом токенов и сессий
+type AuthHandler struct {
+	aServ service.AuthServiceInterface
+}
+
+// NewAuthHandler - конуструирует *AuthHandler по AuthServiceInterface
+func NewAuthHandler(aServ service.AuthServiceInterface) *AuthHandler {
+	return &AuthHandler{aServ}
+}
+
+// RefreshHandler - обработчик, обменивающий refresh токен на новую пару токенов.
+// Refresh токен одноразовый, в ответе возвращается новый (см. AuthService.Refresh)
+func (ah *AuthHandler) RefreshHandler(config *configs.ServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := DefaultResponse()
+
+		var req models.RefreshRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil || req.Refresh == "" {
+			response.ErrorCode = http.StatusBadRequest
+			response.Message = InvalidAppDataError
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		tokenID, err := ParseRefreshToken(config, req.Refresh)
+		if err != nil {
+			log.Println("refreshHandler: невалидный refresh токен:", err)
+			if errors.Is(err, models.ErrRefreshTokenExpired) {
+				abortUnauthorized(c, TokenExpiredError, "refresh token expired")
+				return
+			}
+			abortUnauthorized(c, RefreshInvalidError, "")
+			return
+		}
+
+		rt, err := ah.aServ.Refresh(c, tokenID, refreshTTL(config))
+
+		switch {
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			abortUnauthorized(c, RefreshReusedError, "refresh token reused")
+			return
+		case errors.Is(err, models.ErrRefreshTokenExpired):
+			abortUnauthorized(c, TokenExpiredError, "refresh token expired")
+			return
+		case errors.Is(err, models.ErrRefreshTokenNotFound),
+			errors.Is(err, models.ErrRefreshTokenRevoked):
+			abortUnauthorized(c, RefreshInvalidError, "")
+			return
+		case err != nil:
+			log.Printf("refreshHandler error: %v", err)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		sendTokenPair(c, config, ah.aServ, rt, RefreshOK)
+	}
+}
+
+// LogoutHandler - завершает текущую сессию и все сессии того же входа
+func (ah *AuthHandler) LogoutHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	jti, _ := info["jti"].(string)
+
+	if err := ah.aServ.Logout(c, jti); err != nil {
+		log.Printf("logoutHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = LogoutOK
+	c.JSON(http.StatusOK, response)
+}
+
+// LogoutAllHandler - завершает все сессии пользователя на всех устройствах
+func (ah *AuthHandler) LogoutAllHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	if err := ah.aServ.LogoutAll(c, login); err != nil {
+		log.Printf("logoutAllHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = LogoutAllOK
+	c.JSON(http.StatusOK, response)
+}
+
+// SessionsHandler - (админ) возвращает активные сессии пользователя :login
+func (ah *AuthHandler) SessionsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	sessions, err := ah.aServ.Sessions(c, c.Param("login"))
+
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = UserNotFoundError
+		c.JSON(http.StatusNotFound, response)
+		return
+	case err != nil:
+		log.Printf("sessionsHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = SessionsOK
+	response.Data = sessions
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSessionHandler - (админ) завершает сессию :id пользователя :login
+func (ah *AuthHandler) RevokeSessionHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	err := ah.aServ.RevokeSession(c, c.Param("login"), c.Param("id"))
+
+	switch {
+	case errors.Is(err, models.ErrSessionNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = SessionNotFoundError
+		c.JSON(http.StatusNotFound, response)
+		return
+	case err != nil:
+		log.Printf("revokeSessionHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = SessionKillOK
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAllSessionsHandler - (админ) завершает все сессии пользователя :login
+func (ah *AuthHandler) RevokeAllSessionsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	err := ah.aServ.LogoutAll(c, c.Param("login"))
+
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = UserNotFoundError
+		c.JSON(http.StatusNotFound, response)
+		return
+	case err != nil:
+		log.Printf("revokeAllSessionsHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = LogoutAllOK
+	c.JSON(http.StatusOK, response)
+}
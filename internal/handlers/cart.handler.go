@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"merch_service/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// cartError - отвечает клиенту на ошибку работы с корзиной
+func cartError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPromoOrderError(err):
+		promoOrderError(c, err)
+	case errors.Is(err, models.ErrInvalidCount):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrMerchNotFound),
+		errors.Is(err, models.ErrInvalidMerchID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MerchNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrVariantRequired):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = VariantRequiredError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrVariantNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = VariantNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrCartItemNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = CartItemNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrEmptyCart):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = EmptyCartError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrNotEnoughMerch):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = NotEnoughMerchError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrNotEnoughCoins):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = NotEnoughCoinsError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrPurchaseLimitExceeded):
+		response.ErrorCode = http.StatusConflict
+		response.Message = PurchaseLimitError
+		c.JSON(http.StatusConflict, response)
+	default:
+		log.Printf("cartError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// CartHandler - возвращает корзину пользователя с суммами по текущим ценам
+func (mh *MerchHandler) CartHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cart, err := mh.mServ.Cart(c, login)
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CartOK
+	response.Data = cart
+	c.JSON(http.StatusOK, response)
+}
+
+// AddToCartHandler - добавляет мерч в корзину, тело как у /merch/buy
+func (mh *MerchHandler) AddToCartHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.PurchaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cart, err := mh.mServ.AddToCart(c, login, req.Item, req.Variant, req.Count)
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CartUpdateOK
+	response.Data = cart
+	c.JSON(http.StatusOK, response)
+}
+
+// SetCartItemHandler - задает количество мерча :id в корзине.
+// Вариант мерча передается артикулом в query параметре variant
+func (mh *MerchHandler) SetCartItemHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.CartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cart, err := mh.mServ.SetCartItem(c, login, id, c.Query("variant"), req.Count)
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CartUpdateOK
+	response.Data = cart
+	c.JSON(http.StatusOK, response)
+}
+
+// RemoveFromCartHandler - убирает мерч :id (вариант из query параметра variant) из корзины
+func (mh *MerchHandler) RemoveFromCartHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cart, err := mh.mServ.RemoveFromCart(c, login, id, c.Query("variant"))
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CartUpdateOK
+	response.Data = cart
+	c.JSON(http.StatusOK, response)
+}
+
+// CheckoutHandler - покупает всё содержимое корзины одним заказом.
+// Тело запроса необязательно: {"promo": "..."} применяет промокод
+func (mh *MerchHandler) CheckoutHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	result, err := mh.mServ.Checkout(c, login, req.Promo)
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CheckoutOK
+	response.Data = result
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"merch_service/configs"
+	"merch_service/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// coinRequestError - отвечает клиенту на ошибку работы с запросами монет.
+// Ошибки повода и сообщения - те же, что и у обычного перевода (см. transferError)
+func coinRequestError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPageQueryError(err),
+		errors.Is(err, models.ErrInvalidDirection),
+		errors.Is(err, models.ErrInvalidCoinRequestStatus):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidCoinRequestFilterError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrInvalidAmount),
+		errors.Is(err, models.ErrSameSenderReceiver):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidCoinRequestError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrNotEnoughCoins):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = NotEnoughCoinsError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrUserNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = UserNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrCoinRequestNotFound),
+		errors.Is(err, models.ErrInvalidCoinRequestID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = CoinRequestNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrCoinRequestNotPending):
+		response.ErrorCode = http.StatusConflict
+		response.Message = CoinRequestNotPendingError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrCoinRequestExists):
+		response.ErrorCode = http.StatusConflict
+		response.Message = CoinRequestExistsError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrTooManyCoinRequests):
+		response.ErrorCode = http.StatusTooManyRequests
+		response.Message = TooManyCoinRequestsError
+		c.JSON(http.StatusTooManyRequests, response)
+	default:
+		transferError(c, err)
+	}
+}
+
+// RequestCoinsHandler - просит монеты у другого пользователя.
+// Тело запроса - {"payer","amount","category","message"}, повод и сообщение необязательны.
+// Запрос ждет ответа coinrequestttl секунд из конфига
+func (th *TransactionHandler) RequestCoinsHandler(config *configs.ServerConfig) gin.HandlerFunc {
+	ttl := time.Duration(config.CoinRequestTTL) * time.Second
+
+	return func(c *gin.Context) {
+		response := DefaultResponse()
+
+		var req models.AskCoinsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ErrorCode = http.StatusBadRequest
+			response.Message = InvalidAppDataError
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		info := c.Keys["claims"].(jwt.MapClaims)
+		login := info["log"].(string)
+
+		cr, err := th.tServ.RequestCoins(c, login, &req, ttl)
+		if err != nil {
+			coinRequestError(c, err)
+			return
+		}
+
+		response.ErrorCode = http.StatusOK
+		response.Message = CoinAskOK
+		response.Data = cr
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// CoinRequestsHandler - возвращает страницу запросов монет пользователя.
+// Query параметры:
+//   - direction - sent (отправленные пользователем), received (полученные) или all (по умолчанию)
+//   - status - pending, accepted, declined или expired (по умолчанию все)
+//   - limit, cursor, from, to - параметры страницы (см. parsePageQuery)
+func (th *TransactionHandler) CoinRequestsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidCoinRequestFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	filter := &models.CoinRequestFilter{
+		Direction: models.TransferDirection(c.DefaultQuery("direction", string(models.TransferAll))),
+		Status:    models.CoinRequestStatus(c.Query("status")),
+		PageQuery: *q,
+	}
+
+	list, err := th.tServ.CoinRequests(c, login, filter)
+	if err != nil {
+		coinRequestError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CoinRequestsOK
+	response.Data = list
+	c.JSON(http.StatusOK, response)
+}
+
+// CoinRequestHandler - возвращает запрос монет :id, отправленный или полученный пользователем
+func (th *TransactionHandler) CoinRequestHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cr, err := th.tServ.CoinRequest(c, login, id)
+	if err != nil {
+		coinRequestError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CoinRequestOK
+	response.Data = cr
+	c.JSON(http.StatusOK, response)
+}
+
+// AcceptCoinRequestHandler - принимает полученный запрос монет :id и переводит монеты
+func (th *TransactionHandler) AcceptCoinRequestHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cr, err := th.tServ.AcceptCoinRequest(c, login, id)
+	if err != nil {
+		coinRequestError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CoinAcceptOK
+	response.Data = cr
+	c.JSON(http.StatusOK, response)
+}
+
+// DeclineCoinRequestHandler - отклоняет полученный запрос монет :id
+func (th *TransactionHandler) DeclineCoinRequestHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	cr, err := th.tServ.DeclineCoinRequest(c, login, id)
+	if err != nil {
+		coinRequestError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = CoinDeclineOK
+	response.Data = cr
+	c.JSON(http.StatusOK, response)
+}
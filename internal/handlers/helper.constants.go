@@ -1,26 +1,163 @@
 package handlers
 
 const (
-	AuthError           = "отказано в доступе"
-	TokenGenError       = "ошибка генерации токена"
-	UserExistsError     = "пользователь существует"
-	UserNotFoundError   = "пользователя с таким логином не существует"
-	WrongPassError      = "неверный пароль" // Нужен ли он вообще (см handlers.go)
-	InternalServerError = "ошибка на сервере"
-	InvalidAppDataError = "неверный формат данных в запросе"
-	NotEnoughMerchError = "недостаточно товара на складе"
-	NotEnoughCoinsError = "недостаточно монет для покупки"
+	AuthError               = "отказано в доступе"
+	ForbiddenError          = "недостаточно прав"
+	TokenExpiredError       = "срок действия токена истек"
+	RefreshInvalidError     = "refresh токен недействителен"
+	RefreshReusedError      = "refresh токен использован повторно, войдите заново"
+	SessionRevokedError     = "сессия завершена, войдите заново"
+	SessionNotFoundError    = "сессия не найдена"
+	TokenGenError           = "ошибка генерации токена"
+	UserExistsError         = "пользователь существует"
+	UserNotFoundError       = "пользователя с таким логином не существует"
+	WrongPassError          = "неверный пароль" // Нужен ли он вообще (см handlers.go)
+	InternalServerError     = "ошибка на сервере"
+	InvalidAppDataError     = "неверный формат данных в запросе"
+	NotEnoughMerchError     = "недостаточно товара на складе"
+	PurchaseLimitError      = "заказ превышает лимит покупки этого товара: на заказ, в месяц или всего на пользователя"
+	NotEnoughCoinsError     = "недостаточно монет для покупки"
+	InvalidRoleError        = "такой роли не существует"
+	LastAdminError          = "нельзя снять роль с последнего администратора"
+	InvalidMerchError       = "имя товара не может быть пустым, цена, количество и лимиты покупки - отрицательными, а категория - длиннее 64 символов"
+	MerchExistsError        = "товар с таким именем уже существует"
+	MerchNotFoundError      = "такого товара не существует"
+	LedgerMismatchError     = "журнал монет не сходится"
+	InvalidGrantBatchError  = "в пакете начислений есть ошибочные строки, монеты не начислены (см. rows)"
+	EmptyGrantBatchError    = "пакет начислений пуст"
+	GrantBatchTooLargeError = "пакет начислений не может быть больше 1000 строк и 1 МиБ"
+	GrantCSVError           = "CSV пакета начислений не читается: нужны колонки login, amount и reason"
+	InvalidFilterError      = "неверные параметры списка: limit от 1 до 100, cursor из прошлого ответа, from не позже to, direction - sent, received или all"
+	InvalidMerchFilterError = "неверные параметры каталога: min_price и max_price - неотрицательные числа, min_price не больше max_price, in_stock - true или false, sort - price, -price, name, -name или popularity, cursor из прошлого ответа с тем же sort"
+
+	InvalidIdempotencyKeyError = "Idempotency-Key должен быть от 1 до 255 видимых ASCII символов"
+	IdempotencyKeyReusedError  = "Idempotency-Key уже использован с другим запросом"
+	IdempotencyInProgressError = "запрос с этим Idempotency-Key еще выполняется, повторите позже"
+	RequestTooLargeError       = "тело запроса слишком большое"
+
+	CartItemNotFoundError = "такого товара нет в корзине"
+	EmptyCartError        = "корзина пуста"
+
+	VariantRequiredError = "у товара есть варианты, укажите артикул варианта"
+	VariantNotFoundError = "такого варианта товара не существует"
+	VariantExistsError   = "вариант с таким артикулом уже существует"
+	InvalidVariantError  = "артикул варианта не может быть пустым, а цена и количество - отрицательными"
+
+	ImageTooLargeError    = "изображение не может быть больше 5 МиБ и 4096x4096 пикселей"
+	UnsupportedImageError = "изображение должно быть файлом JPEG или PNG в поле image"
+	ImageExistsError      = "это изображение уже загружено для товара"
+	ImageNotFoundError    = "такого изображения не существует"
+	MediaNotFoundError    = "такого файла не существует"
+
+	PromoNotFoundError      = "такого промокода не существует"
+	PromoInactiveError      = "промокод еще не действует или уже истек"
+	PromoNotApplicableError = "промокод не распространяется на товары заказа"
+	PromoExhaustedError     = "лимит использования промокода исчерпан"
+	PromoUserLimitError     = "вы уже использовали этот промокод максимальное число раз"
+	PromoExistsError        = "такой промокод уже существует"
+	SaleNotFoundError       = "такой распродажи не существует"
+	InvalidPromoError       = "нужны код промокода или название распродажи, скидка percent от 1 до 100 или fixed больше нуля, неотрицательные лимиты и окончание позже начала"
+
+	WishlistItemNotFoundError = "этого товара нет в списке желаний"
+	NotificationNotFoundError = "такого уведомления не существует"
+
+	TransferNotFoundError = "такого перевода не существует"
+	TransferRepliedError  = "на перевод уже ответили"
+	TransferCategoryError = "повод перевода может быть thanks, quest_reward, bet или gift"
+	TransferMessageError  = "сообщение должно быть непустым текстом не длиннее 280 символов"
+	TransferReactionError = "реакция может быть like, heart, party, laugh или wow"
+
+	TransferTooLargeError   = "сумма перевода больше разрешенной для одного перевода"
+	TransferLimitError      = "перевод превышает лимит исходящих переводов за сутки или за неделю"
+	NewAccountCooldownError = "новый аккаунт пока не может отправлять монеты, попробуйте позже"
+	SuspiciousTransferError = "перевод похож на мошеннический и отклонен, обратитесь к администратору"
+
+	TransferFlagNotFoundError      = "такой пометки перевода не существует"
+	TransferFlagClosedError        = "подозрительный перевод уже проверен"
+	InvalidTransferFlagError       = "решение по пометке может быть dismissed или confirmed"
+	InvalidTransferFlagFilterError = "неверные параметры списка: limit от 1 до 100, cursor из прошлого ответа, from не позже to, status - open, dismissed или confirmed"
+
+	ScheduleNotFoundError  = "такого запланированного перевода не существует"
+	ScheduleNotActiveError = "запланированный перевод уже выполнен или отменен"
+	InvalidScheduleError   = "нужны другой получатель, amount больше нуля, rule - once, weekly или monthly и start_at в будущем"
+
+	CoinRequestNotFoundError      = "такого запроса монет не существует"
+	CoinRequestNotPendingError    = "на запрос монет уже ответили или он истек"
+	CoinRequestExistsError        = "запрос монет этому пользователю уже ждет ответа"
+	TooManyCoinRequestsError      = "слишком много запросов монет: не больше 20 ожидающих ответа и 50 в сутки"
+	InvalidCoinRequestError       = "нужны другой пользователь payer и amount больше нуля"
+	InvalidCoinRequestFilterError = "неверные параметры списка: limit от 1 до 100, cursor из прошлого ответа, from не позже to, direction - sent, received или all, status - pending, accepted, declined или expired"
+
+	ReservationNotFoundError  = "такой брони не существует"
+	ReservationNotActiveError = "бронь уже подтверждена, отменена или истекла"
+
+	OrderNotFoundError      = "такого заказа не существует"
+	OrderTransitionError    = "заказ нельзя перевести в этот статус"
+	InvalidOrderStatusError = "статус заказа может быть placed, confirmed, ready_for_pickup, delivered или cancelled"
 )
 
 const (
-	RegistrationOK = "регистрация успешна"
-	TokensOK       = "токены успешно созданы"
-	RefreshOK      = "токен авторизаций обновлен"
-	MerchListOK    = "список товаров"
-	HistoryCoinsOK = "история кошелька"
-	HistoryPurchOK = "история покупок"
-	TransferOK     = "перевод монет успешен"
-	PurchaseOK     = "покупка успешна"
+	RegistrationOK   = "регистрация успешна"
+	TokensOK         = "токены успешно созданы"
+	RefreshOK        = "токен авторизаций обновлен"
+	MerchListOK      = "список товаров"
+	HistoryCoinsOK   = "история кошелька"
+	HistoryPurchOK   = "история покупок"
+	HistoryTransOK   = "история переводов"
+	TransferOK       = "перевод монет успешен"
+	PurchaseOK       = "покупка успешна"
+	LogoutOK         = "выход выполнен"
+	LogoutAllOK      = "все сессии завершены"
+	SessionsOK       = "список сессий"
+	SessionKillOK    = "сессия завершена"
+	UsersOK          = "список пользователей"
+	RoleOK           = "роль назначена"
+	MerchCreateOK    = "товар добавлен"
+	MerchUpdateOK    = "товар изменен"
+	MerchDeleteOK    = "товар удален"
+	ReconcileOK      = "журнал монет сходится"
+	GrantOK          = "монеты начислены из казны"
+	GrantDryRunOK    = "пакет начислений проверен, монеты не начислены"
+	CartOK           = "корзина"
+	CartUpdateOK     = "корзина изменена"
+	CheckoutOK       = "заказ оформлен"
+	OrdersOK         = "список заказов"
+	OrderOK          = "заказ"
+	OrderStatusOK    = "статус заказа изменен"
+	OrderCancelOK    = "заказ отменен, монеты возвращены"
+	VariantCreateOK  = "вариант товара добавлен"
+	VariantUpdateOK  = "вариант товара изменен"
+	VariantDeleteOK  = "вариант товара удален"
+	ImageUploadOK    = "изображение товара загружено"
+	ImageDeleteOK    = "изображение товара удалено"
+	PromoCreateOK    = "промокод добавлен"
+	PromosOK         = "список промокодов"
+	PromoDeleteOK    = "промокод удален"
+	SaleCreateOK     = "распродажа добавлена"
+	SalesOK          = "список распродаж"
+	SaleDeleteOK     = "распродажа удалена"
+	WishlistOK       = "список желаний"
+	WishlistAddOK    = "товар в списке желаний"
+	WishlistDelOK    = "товар убран из списка желаний"
+	NotificationsOK  = "список уведомлений"
+	NotifyReadOK     = "уведомление прочитано"
+	ReserveOK        = "товар забронирован"
+	ReservationsOK   = "список броней"
+	ReserveConfirmOK = "бронь подтверждена, заказ оформлен"
+	ReserveCancelOK  = "бронь отменена, монеты возвращены"
+	TransferReactOK  = "реакция на перевод сохранена"
+	TransferReplyOK  = "ответ на перевод сохранен"
+	ScheduleOK       = "перевод запланирован"
+	SchedulesOK      = "список запланированных переводов"
+	ScheduleRunsOK   = "срабатывания запланированного перевода"
+	ScheduleCancelOK = "запланированный перевод отменен"
+	CoinAskOK        = "запрос монет отправлен"
+	CoinRequestsOK   = "список запросов монет"
+	CoinRequestOK    = "запрос монет"
+	CoinAcceptOK     = "запрос монет принят, монеты переведены"
+	CoinDeclineOK    = "запрос монет отклонен"
+	TransferFlagsOK  = "список подозрительных переводов"
+	FlagReviewOK     = "подозрительный перевод проверен"
 )
 
 // Для централизованного контроля за API и для избежания очепяток
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"merch_service/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Форматы дат в query параметрах from/to
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = time.RFC3339
+)
+
+// parseDateParam - разбирает дату из query параметра name.
+// Принимает 2006-01-02 или RFC3339, пустой параметр дает нулевое время.
+// Если endOfDay и передана только дата, возвращается начало следующего дня,
+// чтобы граница "to=2025-01-31" включала весь день
+func parseDateParam(c *gin.Context, name string, endOfDay bool) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	return time.Parse(dateTimeLayout, value)
+}
+
+// parseDateRange - разбирает период из query параметров from и to
+func parseDateRange(c *gin.Context) (from, to time.Time, err error) {
+	if from, err = parseDateParam(c, "from", false); err != nil {
+		return
+	}
+	to, err = parseDateParam(c, "to", true)
+	return
+}
+
+// parsePageQuery - разбирает query параметры страницы:
+//   - limit - размер страницы (по умолчанию models.DefaultPageLimit)
+//   - cursor - next_cursor из предыдущего ответа
+//   - from, to - период (см. parseDateRange)
+//
+// Ограничения значений проверяет сервис (см. models.PageQuery.Normalize)
+func parsePageQuery(c *gin.Context) (*models.PageQuery, error) {
+	q := &models.PageQuery{}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, err
+		}
+		q.Limit = n
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := models.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.After = after
+	}
+
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		return nil, err
+	}
+	q.From, q.To = from, to
+
+	return q, nil
+}
+
+// parseMerchFilter - разбирает query параметры каталога:
+//   - q - текст для поиска в имени и тегах
+//   - category, tag - категория и тег
+//   - min_price, max_price - диапазон цены
+//   - in_stock - только товары в наличии (true/false)
+//   - sort - порядок: price, -price, name, -name, popularity (по умолчанию по id)
+//   - limit, cursor - параметры страницы (см. parsePageQuery)
+//
+// Ограничения значений проверяет сервис (см. models.MerchFilter.Normalize)
+func parseMerchFilter(c *gin.Context) (*models.MerchFilter, error) {
+	q, err := parsePageQuery(c)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &models.MerchFilter{
+		Search:    c.Query("q"),
+		Category:  c.Query("category"),
+		Tag:       c.Query("tag"),
+		Sort:      models.MerchSort(c.Query("sort")),
+		PageQuery: *q,
+	}
+
+	if filter.MinPrice, err = parseIntParam(c, "min_price"); err != nil {
+		return nil, err
+	}
+	if filter.MaxPrice, err = parseIntParam(c, "max_price"); err != nil {
+		return nil, err
+	}
+
+	if inStock := c.Query("in_stock"); inStock != "" {
+		if filter.InStock, err = strconv.ParseBool(inStock); err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+// parseIntParam - разбирает число из query параметра name, пустой параметр дает nil
+func parseIntParam(c *gin.Context, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// isPageQueryError - ошибка в параметрах страницы, которую нужно вернуть клиенту как 400
+func isPageQueryError(err error) bool {
+	return errors.Is(err, models.ErrInvalidLimit) ||
+		errors.Is(err, models.ErrInvalidCursor) ||
+		errors.Is(err, models.ErrInvalidDateRange)
+}
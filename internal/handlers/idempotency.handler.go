@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"merch_service/configs"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Заголовки идемпотентных запросов
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// IdempotencyHandler - структура мост, для связывания уровня хендлеров
+// с сервисом ключей идемпотентности
+type IdempotencyHandler struct {
+	iServ service.IdempotencyServiceInterface
+}
+
+// NewIdempotencyHandler - конуструирует *IdempotencyHandler по IdempotencyServiceInterface
+func NewIdempotencyHandler(iServ service.IdempotencyServiceInterface) *IdempotencyHandler {
+	return &IdempotencyHandler{iServ}
+}
+
+// recordingWriter - копирует тело ответа хендлера, чтобы сохранить его под ключом
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent - делает запрос с заголовком Idempotency-Key идемпотентным.
+// Ответ первого запроса сохраняется на idempotencyttl секунд (см. configs.ServerConfig),
+// повтор с тем же ключом и телом получает его без повторного выполнения
+// (с заголовком Idempotent-Replayed: true), повтор с другим телом получает 422.
+// Ответы 5xx не сохраняются: такой запрос можно повторить с тем же ключом.
+// Тело запроса с ключом читается целиком, поэтому ограничено maxBody байтами
+// (больше - 413). Запросы без заголовка выполняются как обычно. Применяется после AuthRequired
+func (ih *IdempotencyHandler) Idempotent(config *configs.ServerConfig, maxBody int64) gin.HandlerFunc {
+	ttl := time.Duration(config.IdempotencyTTL) * time.Second
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		info := c.Keys["claims"].(jwt.MapClaims)
+		login := info["log"].(string)
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abortIdempotency(c, http.StatusRequestEntityTooLarge, RequestTooLargeError)
+				return
+			}
+			abortIdempotency(c, http.StatusBadRequest, InvalidAppDataError)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := service.HashRequest(c.Request.Method, c.FullPath(), body)
+		record, err := ih.iServ.Begin(c, login, key, hash, ttl)
+		switch {
+		case errors.Is(err, models.ErrInvalidIdempotencyKey):
+			abortIdempotency(c, http.StatusBadRequest, InvalidIdempotencyKeyError)
+			return
+		case errors.Is(err, models.ErrIdempotencyKeyReused):
+			abortIdempotency(c, http.StatusUnprocessableEntity, IdempotencyKeyReusedError)
+			return
+		case errors.Is(err, models.ErrIdempotencyInProgress):
+			abortIdempotency(c, http.StatusConflict, IdempotencyInProgressError)
+			return
+		case err != nil:
+			log.Printf("idempotent error: %v", err)
+			abortIdempotency(c, http.StatusInternalServerError, InternalServerError)
+			return
+		}
+
+		if record.Completed() {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", record.Response)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if status := writer.Status(); status < http.StatusInternalServerError {
+			err = ih.iServ.Complete(c, record, status, writer.body.Bytes())
+		} else {
+			err = ih.iServ.Abort(c, record)
+		}
+
+		if err != nil {
+			log.Printf("idempotent error: %v", err)
+		}
+	}
+}
+
+// abortIdempotency - отвечает ошибкой и прерывает выполнение хендлеров
+func abortIdempotency(c *gin.Context, code int, msg string) {
+	c.JSON(code, gin.H{
+		error_code: code,
+		message:    msg,
+		data:       struct{}{},
+	})
+	c.Abort()
+}
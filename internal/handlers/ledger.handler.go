@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LedgerHandler - структура мост, для связывания уровня хендлеров
+// с сервисом журнала монет
+type LedgerHandler struct {
+	lServ service.LedgerServiceInterface
+}
+
+// NewLedgerHandler - конуструирует *LedgerHandler по LedgerServiceInterface
+func NewLedgerHandler(lServ service.LedgerServiceInterface) *LedgerHandler {
+	return &LedgerHandler{lServ}
+}
+
+// ReconcileHandler - (админ) сверяет журнал монет.
+// Отчет сверки возвращается в поле data, если журнал не сходится,
+// отвечает 409 с тем же отчетом
+func (lh *LedgerHandler) ReconcileHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	report, err := lh.lServ.Reconcile(c)
+	if err != nil {
+		log.Printf("reconcileHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.Data = report
+	if !report.OK() {
+		response.ErrorCode = http.StatusConflict
+		response.Message = LedgerMismatchError
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ReconcileOK
+	c.JSON(http.StatusOK, response)
+}
+
+// grantError - отвечает клиенту на ошибку пакета начислений.
+// Отчет по строкам (если он есть) возвращается в поле data
+func grantError(c *gin.Context, report *models.GrantReport, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrInvalidGrantBatch):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidGrantBatchError
+		response.Data = report
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrEmptyGrantBatch):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = EmptyGrantBatchError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrGrantBatchTooLarge):
+		response.ErrorCode = http.StatusRequestEntityTooLarge
+		response.Message = GrantBatchTooLargeError
+		c.JSON(http.StatusRequestEntityTooLarge, response)
+	case errors.Is(err, models.ErrInvalidGrantCSV):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = GrantCSVError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrUserNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = UserNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	default:
+		log.Printf("grantHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// GrantHandler - (админ) начисляет монеты пакетом из казны.
+// Пакет передается в CSV (Content-Type: text/csv, колонки login, amount, reason,
+// заголовок необязателен) или в JSON {"rows":[{"login","amount","reason"}]}.
+// С параметром dry_run=true только проверяет пакет. Отчет по строкам
+// возвращается в поле data, если хоть одна строка ошибочна - с кодом 400
+func (lh *LedgerHandler) GrantHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			response.ErrorCode = http.StatusBadRequest
+			response.Message = InvalidAppDataError
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, models.MaxGrantBodySize)
+
+	var rows []models.GrantRow
+	var err error
+	if c.ContentType() == "text/csv" {
+		rows, err = models.ParseGrantCSV(c.Request.Body)
+	} else {
+		var req models.GrantRequest
+		if err = c.ShouldBindJSON(&req); err == nil {
+			rows = req.Numbered()
+		}
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			grantError(c, nil, models.ErrGrantBatchTooLarge)
+		case errors.Is(err, models.ErrInvalidGrantCSV), errors.Is(err, models.ErrGrantBatchTooLarge):
+			grantError(c, nil, err)
+		default:
+			response.ErrorCode = http.StatusBadRequest
+			response.Message = InvalidAppDataError
+			c.JSON(http.StatusBadRequest, response)
+		}
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	report, err := lh.lServ.Grant(c, login, rows, dryRun)
+	if err != nil {
+		grantError(c, report, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = GrantOK
+	if dryRun {
+		response.Message = GrantDryRunOK
+	}
+	response.Data = report
+	c.JSON(http.StatusOK, response)
+}
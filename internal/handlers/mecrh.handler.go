@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"errors"
+	"log"
 	"merch_service/internal/models"
 	"merch_service/internal/service"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -22,12 +24,33 @@ func NewMerchHandler(mServ service.MerchServiceInterface) *MerchHandler {
 }
 
 // MerchListHanlder - функция обработчик, отвечающий за возврат списка мерча
+// Принимает параметры поиска, фильтров, порядка и страницы (см. parseMerchFilter)
 func (mh *MerchHandler) MerchListHandler(c *gin.Context) {
 	response := DefaultResponse()
 
-	merchlist, err := mh.mServ.MerchList(c)
-
+	filter, err := parseMerchFilter(c)
 	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidMerchFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	merchlist, err := mh.mServ.MerchList(c, filter)
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case errors.Is(err, models.ErrInvalidMerchSort),
+		errors.Is(err, models.ErrInvalidPriceRange):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidMerchFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case err != nil:
 		c.JSON(http.StatusOK, response)
 		return
 	}
@@ -55,9 +78,17 @@ func (mh *MerchHandler) BuyMerchHandler(c *gin.Context) {
 	info := c.Keys["claims"].(jwt.MapClaims)
 	login := info["log"].(string)
 
-	coins, err := mh.mServ.Buy(c, login, req.Item, req.Count)
+	coins, err := mh.mServ.Buy(c, login, req.Item, req.Variant, req.Promo, req.Count)
 
 	switch {
+	case isPromoOrderError(err):
+		promoOrderError(c, err)
+		return
+	case errors.Is(err, models.ErrInvalidCount):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
 	case errors.Is(err, models.ErrNotEnoughMerch):
 		response.ErrorCode = http.StatusBadRequest
 		response.Message = NotEnoughMerchError
@@ -68,6 +99,21 @@ func (mh *MerchHandler) BuyMerchHandler(c *gin.Context) {
 		response.Message = NotEnoughCoinsError
 		c.JSON(http.StatusBadRequest, response)
 		return
+	case errors.Is(err, models.ErrPurchaseLimitExceeded):
+		response.ErrorCode = http.StatusConflict
+		response.Message = PurchaseLimitError
+		c.JSON(http.StatusConflict, response)
+		return
+	case errors.Is(err, models.ErrVariantRequired):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = VariantRequiredError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case errors.Is(err, models.ErrVariantNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = VariantNotFoundError
+		c.JSON(http.StatusNotFound, response)
+		return
 	case err != nil:
 		response.Message = err.Error()
 		c.JSON(http.StatusInternalServerError, response)
@@ -82,3 +128,277 @@ func (mh *MerchHandler) BuyMerchHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// merchIDParam - читает id товара из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func merchIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// merchAdminError - отвечает клиенту на ошибку изменения каталога
+func merchAdminError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrEmptyMerchName),
+		errors.Is(err, models.ErrNegativePrice),
+		errors.Is(err, models.ErrNegativeStock),
+		errors.Is(err, models.ErrCategoryTooLong),
+		errors.Is(err, models.ErrInvalidLimits):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidMerchError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrMerchExists):
+		response.ErrorCode = http.StatusConflict
+		response.Message = MerchExistsError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrMerchNotFound),
+		errors.Is(err, models.ErrInvalidMerchID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MerchNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	default:
+		log.Printf("merchAdminError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// CreateMerchHandler - (админ) добавляет товар в каталог
+func (mh *MerchHandler) CreateMerchHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.MerchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	item, err := mh.mServ.CreateMerch(c, &req)
+	if err != nil {
+		merchAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusCreated
+	response.Message = MerchCreateOK
+	response.Data = item
+	c.JSON(http.StatusCreated, response)
+}
+
+// ReplaceMerchHandler - (админ) заменяет товар :id целиком
+func (mh *MerchHandler) ReplaceMerchHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.MerchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	item, err := mh.mServ.ReplaceMerch(c, id, &req)
+	if err != nil {
+		merchAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = MerchUpdateOK
+	response.Data = item
+	c.JSON(http.StatusOK, response)
+}
+
+// PatchMerchHandler - (админ) меняет переданные поля товара :id
+func (mh *MerchHandler) PatchMerchHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.MerchPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	item, err := mh.mServ.PatchMerch(c, id, &req)
+	if err != nil {
+		merchAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = MerchUpdateOK
+	response.Data = item
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteMerchHandler - (админ) убирает товар :id из каталога
+func (mh *MerchHandler) DeleteMerchHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := mh.mServ.DeleteMerch(c, id); err != nil {
+		merchAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = MerchDeleteOK
+	c.JSON(http.StatusOK, response)
+}
+
+// variantIDParam - читает id варианта из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func variantIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("vid"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// variantAdminError - отвечает клиенту на ошибку изменения вариантов товара
+func variantAdminError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrEmptyVariantSku),
+		errors.Is(err, models.ErrNegativePrice),
+		errors.Is(err, models.ErrNegativeStock):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidVariantError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrVariantExists):
+		response.ErrorCode = http.StatusConflict
+		response.Message = VariantExistsError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrMerchNotFound),
+		errors.Is(err, models.ErrInvalidMerchID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MerchNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrVariantNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = VariantNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	default:
+		log.Printf("variantAdminError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// CreateVariantHandler - (админ) добавляет вариант товара :id
+func (mh *MerchHandler) CreateVariantHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	variant, err := mh.mServ.CreateVariant(c, id, &req)
+	if err != nil {
+		variantAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusCreated
+	response.Message = VariantCreateOK
+	response.Data = variant
+	c.JSON(http.StatusCreated, response)
+}
+
+// ReplaceVariantHandler - (админ) заменяет вариант :vid товара :id целиком
+func (mh *MerchHandler) ReplaceVariantHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	vid, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	variant, err := mh.mServ.ReplaceVariant(c, id, vid, &req)
+	if err != nil {
+		variantAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = VariantUpdateOK
+	response.Data = variant
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteVariantHandler - (админ) убирает вариант :vid товара :id из каталога
+func (mh *MerchHandler) DeleteVariantHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	vid, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := mh.mServ.DeleteVariant(c, id, vid); err != nil {
+		variantAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = VariantDeleteOK
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mediaCacheControl - файлы под ключом не меняются (ключ строится по содержимому),
+// поэтому клиенты и прокси могут хранить их сколько угодно
+const mediaCacheControl = "public, max-age=31536000, immutable"
+
+// multipartOverhead - запас на заголовки multipart сверх размера изображения
+const multipartOverhead = 64 << 10
+
+// MediaHandler - структура мост, для связывания уровня хендлеров
+// с сервисом изображений и файлов
+type MediaHandler struct {
+	mdServ service.MediaServiceInterface
+}
+
+// NewMediaHandler - конуструирует *MediaHandler по MediaServiceInterface
+func NewMediaHandler(mdServ service.MediaServiceInterface) *MediaHandler {
+	return &MediaHandler{mdServ}
+}
+
+// imageIDParam - читает id изображения из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func imageIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("iid"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// mediaError - отвечает клиенту на ошибку работы с изображениями
+func mediaError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrImageTooLarge):
+		response.ErrorCode = http.StatusRequestEntityTooLarge
+		response.Message = ImageTooLargeError
+		c.JSON(http.StatusRequestEntityTooLarge, response)
+	case errors.Is(err, models.ErrUnsupportedImageType):
+		response.ErrorCode = http.StatusUnsupportedMediaType
+		response.Message = UnsupportedImageError
+		c.JSON(http.StatusUnsupportedMediaType, response)
+	case errors.Is(err, models.ErrInvalidImage):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = UnsupportedImageError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrImageExists):
+		response.ErrorCode = http.StatusConflict
+		response.Message = ImageExistsError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrMerchNotFound),
+		errors.Is(err, models.ErrInvalidMerchID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MerchNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrImageNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = ImageNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrBlobNotFound),
+		errors.Is(err, models.ErrInvalidBlobKey):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MediaNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	default:
+		log.Printf("mediaError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// UploadImageHandler - (админ) загружает изображение товара :id.
+// Файл передается в поле image формы multipart/form-data
+func (mh *MediaHandler) UploadImageHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	merchID, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, models.MaxImageSize+multipartOverhead)
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			mediaError(c, models.ErrImageTooLarge)
+			return
+		}
+
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = UnsupportedImageError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if header.Size > models.MaxImageSize {
+		mediaError(c, models.ErrImageTooLarge)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, models.MaxImageSize+1))
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+
+	image, err := mh.mdServ.UploadImage(c, merchID, data)
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusCreated
+	response.Message = ImageUploadOK
+	response.Data = image
+	c.JSON(http.StatusCreated, response)
+}
+
+// DeleteImageHandler - (админ) удаляет изображение :iid товара :id
+func (mh *MediaHandler) DeleteImageHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	merchID, ok := merchIDParam(c)
+	if !ok {
+		return
+	}
+
+	imageID, ok := imageIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := mh.mdServ.DeleteImage(c, merchID, imageID); err != nil {
+		mediaError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ImageDeleteOK
+	c.JSON(http.StatusOK, response)
+}
+
+// MediaFileHandler - отдает файл хранилища по ключу из URL изображения.
+// Ответ кешируется надолго (см. mediaCacheControl), а ETag и Last-Modified
+// позволяют ответить 304 на условный запрос. Range запросы тоже поддерживаются
+func (mh *MediaHandler) MediaFileHandler(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	file, modTime, err := mh.mdServ.OpenMedia(c, key)
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+	defer file.Close()
+
+	name := path.Base(key)
+	c.Header("Cache-Control", mediaCacheControl)
+	c.Header("ETag", `"`+strings.TrimSuffix(name, path.Ext(name))+`"`)
+	http.ServeContent(c.Writer, c.Request, name, modTime, file)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"merch_service/configs"
 	"merch_service/internal/models"
+	"merch_service/internal/service"
 	"net/http"
 	"time"
 
@@ -12,7 +13,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthRequired(config *configs.ServerConfig) gin.HandlerFunc {
+// AuthRequired - проверяет токен авторизации и его сессию.
+// Токены отозванных сессий (см. /auth/logout) отклоняются, даже если их срок не истек
+func (ah *AuthHandler) AuthRequired(config *configs.ServerConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		log.Println("Авторизация...")
 		tokenString := c.GetHeader("Authorization")
@@ -28,43 +31,87 @@ func AuthRequired(config *configs.ServerConfig) gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			// Пересылаем новый токен, если старый больше не валиден
+			// Истекший токен клиент должен обменять на новый через /auth/refresh,
+			// поэтому отличаем этот случай от прочих ошибок авторизации
 			if errors.Is(err, jwt.ErrTokenExpired) {
-				log.Println("token expired, resending...")
-				ResendToken(c, config, token)
+				log.Println("token expired")
+				abortUnauthorized(c, TokenExpiredError, "token expired")
 				return
 			}
 
-			log.Println("ошибка при переотправке токена", err)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				error_code: http.StatusUnauthorized,
-				message:    AuthError,
-				data:       struct{}{},
-			})
-			c.Abort()
+			log.Println("ошибка при проверке токена", err)
+			abortUnauthorized(c, AuthError, "")
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			abortUnauthorized(c, AuthError, "")
+			return
+		}
+		log.Println("Claims:", claims)
+
+		jti, _ := claims["jti"].(string)
+		if err := ah.aServ.CheckSession(c, jti); err != nil {
+			if errors.Is(err, models.ErrSessionRevoked) {
+				abortUnauthorized(c, SessionRevokedError, "session revoked")
+				return
+			}
+
+			log.Println("ошибка при проверке сессии", err)
+			abortUnauthorized(c, AuthError, "")
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			log.Println("Claims:", claims)
-			// Сораняем claims в мапу gin.Context (для дальнейшего использования обработчиком)
-			c.Set("claims", claims)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				error_code: http.StatusUnauthorized,
-				message:    AuthError,
+		// Сораняем claims в мапу gin.Context (для дальнейшего использования обработчиком)
+		c.Set("claims", claims)
+
+		// Если авторизация успешна, вызвваем обработчик
+		log.Println("Авторизация успешна, идем в хендлер...")
+		c.Next()
+	}
+}
+
+// RequirePermission - пропускает только пользователей, роль которых (claim "role")
+// имеет право perm (см. models.Role.Can). Применяется после AuthRequired
+func RequirePermission(perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := c.Keys["claims"].(jwt.MapClaims)
+		role, _ := info["role"].(string)
+
+		if !models.Role(role).Can(perm) {
+			log.Printf("нет права %s у роли %q", perm, role)
+			c.JSON(http.StatusForbidden, gin.H{
+				error_code: http.StatusForbidden,
+				message:    ForbiddenError,
 				data:       struct{}{},
 			})
 			c.Abort()
 			return
 		}
-		// Если авторизация успешна, вызвваем обработчик
-		log.Println("Авторизация успешна, идем в хендлер...")
+
 		c.Next()
 	}
 }
 
-// SendToken возвращает JWT токены для авторизации (с таймаутом) и для обновления первого
+// abortUnauthorized - отвечает 401 и прерывает выполнение хендлеров.
+// Непустой description дублируется в заголовке WWW-Authenticate
+// (см. [RFC 6750](https://datatracker.ietf.org/doc/html/rfc6750#section-3))
+func abortUnauthorized(c *gin.Context, msg string, description string) {
+	if description != "" {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+description+`"`)
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{
+		error_code: http.StatusUnauthorized,
+		message:    msg,
+		data:       struct{}{},
+	})
+	c.Abort()
+}
+
+// SendToken возвращает JWT токены для авторизации (с таймаутом) и для обновления первого.
+// Refresh токен открывает новое семейство токенов (см. AuthService.Refresh)
 // В случае успешной генерации пользователь получит JSON формата:
 //
 //		{
@@ -75,19 +122,38 @@ func AuthRequired(config *configs.ServerConfig) gin.HandlerFunc {
 //		  	"refresh": aaa.bbb.ccc,
 //			}
 //		}
-func SendToken(c *gin.Context, config *configs.ServerConfig, json *models.LoginRequest) {
-	// TODO стоит ли передавать в CreateToken объект LoginRequest
-	// полученный в RegHandler?
-	// На данный момент снова парсим json
-	// var json LoginRequest
-	// if err := c.ShouldBindJSON(&json); err != nil {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-	// 	return
-	// }
+func SendToken(c *gin.Context, config *configs.ServerConfig, aServ service.AuthServiceInterface, json *models.LoginRequest) {
+	rt, err := aServ.IssueRefresh(c, json.Login, refreshTTL(config))
+	if err != nil {
+		log.Println("ошибка сохранения refresh токена:", err.Error()) // FOR DEBUG ONLY
+		c.JSON(http.StatusInternalServerError, gin.H{
+			error_code: http.StatusInternalServerError,
+			message:    TokenGenError,
+			data:       struct{}{},
+		})
+		return
+	}
+
+	sendTokenPair(c, config, aServ, rt, TokensOK)
+}
+
+// sendTokenPair - открывает сессию для refresh токена rt,
+// подписывает пару токенов и отправляет их клиенту
+func sendTokenPair(c *gin.Context, config *configs.ServerConfig, aServ service.AuthServiceInterface, rt *models.RefreshToken, msg string) {
+	session, err := aServ.NewSession(c, rt, time.Second*time.Duration(config.ExpTimeout))
+	if err != nil {
+		log.Println("ошибка создания сессии:", err.Error()) // FOR DEBUG ONLY
+		c.JSON(http.StatusInternalServerError, gin.H{
+			error_code: http.StatusInternalServerError,
+			message:    TokenGenError,
+			data:       struct{}{},
+		})
+		return
+	}
 
 	// JWT магия
 	// см [jwt](https://jwt.io/introduction)
-	tokenString, err := JwtToken(config, json)
+	tokenString, err := JwtToken(config, session)
 
 	if err != nil {
 		log.Println("ошибка генерации токена:", err.Error()) // FOR DEBUG ONLY
@@ -99,7 +165,7 @@ func SendToken(c *gin.Context, config *configs.ServerConfig, json *models.LoginR
 		return
 	}
 
-	refreshTokenString, err := RefreshToken(config, json)
+	refreshTokenString, err := RefreshToken(config, rt)
 
 	if err != nil {
 		log.Println("ошибка генерации токена:", err.Error()) // FOR DEBUG ONLY
@@ -113,7 +179,7 @@ func SendToken(c *gin.Context, config *configs.ServerConfig, json *models.LoginR
 
 	c.JSON(http.StatusOK, gin.H{
 		error_code: http.StatusOK,
-		message:    TokensOK,
+		message:    msg,
 		data: gin.H{
 			refresh: refreshTokenString,
 			token:   tokenString,
@@ -121,11 +187,14 @@ func SendToken(c *gin.Context, config *configs.ServerConfig, json *models.LoginR
 	})
 }
 
-// JwtToken - генерирует токен авторизации с сроком истечения указанным в config
-func JwtToken(config *configs.ServerConfig, json *models.LoginRequest) (string, error) {
+// JwtToken - генерирует токен авторизации сессии session.
+// Срок истечения совпадает со сроком сессии (см. config.ExpTimeout)
+func JwtToken(config *configs.ServerConfig, session *models.Session) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"log": json.Login,
-		"exp": time.Now().Add(time.Second * time.Duration(config.ExpTimeout)).Unix(),
+		"log":  session.Login,
+		"jti":  session.Id,
+		"role": session.Role,
+		"exp":  session.ExpiresAt.Unix(),
 	})
 
 	tokenString, err := token.SignedString([]byte(config.Secret))
@@ -133,12 +202,15 @@ func JwtToken(config *configs.ServerConfig, json *models.LoginRequest) (string,
 	return tokenString, err
 }
 
-// RefreshToken - генерирует refresh токен
-func RefreshToken(config *configs.ServerConfig, json *models.LoginRequest) (string, error) {
-	// TODO: Каким должен быть body рефреш токена?
+// RefreshToken - генерирует refresh токен. jti и fam ссылаются на запись
+// в хранилище refresh токенов, по ним токен проверяется при обмене
+func RefreshToken(config *configs.ServerConfig, rt *models.RefreshToken) (string, error) {
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"log": json.Login,
+		"log": rt.Login,
 		"typ": "refresh",
+		"jti": rt.Id,
+		"fam": rt.FamilyId,
+		"exp": rt.ExpiresAt.Unix(),
 	})
 
 	refreshTokenString, err := refreshToken.SignedString([]byte(config.RefreshSecret))
@@ -146,34 +218,37 @@ func RefreshToken(config *configs.ServerConfig, json *models.LoginRequest) (stri
 	return refreshTokenString, err
 }
 
-// ResendToken - отправляет новый токен авторизации в случае истечения срока старого токена
-func ResendToken(c *gin.Context, config *configs.ServerConfig, expiredToken *jwt.Token) {
-	var login string
-	if claims, ok := expiredToken.Claims.(jwt.MapClaims); ok {
-		log.Println("Claims:", claims)
-		login = claims["log"].(string) // Здесь точно string!
+// ParseRefreshToken - проверяет подпись и срок refresh токена
+// и возвращает его идентификатор (jti)
+func ParseRefreshToken(config *configs.ServerConfig, tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, http.ErrAbortHandler
+		}
+		return []byte(config.RefreshSecret), nil
+	})
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return "", models.ErrRefreshTokenExpired
+		}
+		return "", err
 	}
 
-	newToken, err := JwtToken(config, &models.LoginRequest{Login: login})
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["typ"] != "refresh" {
+		return "", jwt.ErrTokenInvalidClaims
+	}
 
-	if err != nil {
-		log.Println("ошибка генерации токена:", err.Error()) // FOR DEBUG ONLY
-		c.JSON(http.StatusInternalServerError, gin.H{
-			error_code: http.StatusInternalServerError,
-			message:    TokenGenError,
-			data:       struct{}{},
-		})
-		return
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return "", jwt.ErrTokenInvalidClaims
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		error_code: http.StatusUnauthorized,
-		message:    RefreshOK,
-		data: gin.H{
-			token: newToken,
-		},
-	})
+	return jti, nil
+}
 
-	// Отменяем выполнение хендлеров
-	c.Abort()
+// refreshTTL - время жизни refresh токена из config
+func refreshTTL(config *configs.ServerConfig) time.Duration {
+	return time.Second * time.Duration(config.RefreshExpTimeout)
 }
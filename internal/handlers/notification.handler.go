@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// NotificationHandler - структура мост, для связывания уровня хендлеров
+// с сервисом списков желаний и уведомлений
+type NotificationHandler struct {
+	nServ service.NotificationServiceInterface
+}
+
+// NewNotificationHandler - конуструирует *NotificationHandler по NotificationServiceInterface
+func NewNotificationHandler(nServ service.NotificationServiceInterface) *NotificationHandler {
+	return &NotificationHandler{nServ}
+}
+
+// notificationIDParam - читает id товара или уведомления из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func notificationIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// notificationError - отвечает клиенту на ошибку работы со списком желаний и уведомлениями
+func notificationError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrMerchNotFound),
+		errors.Is(err, models.ErrInvalidMerchID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MerchNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrWishlistItemNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = WishlistItemNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrNotificationNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = NotificationNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	default:
+		log.Printf("notificationError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// WishlistHandler - возвращает список желаний пользователя
+func (nh *NotificationHandler) WishlistHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	items, err := nh.nServ.Wishlist(c, login)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = WishlistOK
+	response.Data = items
+	c.JSON(http.StatusOK, response)
+}
+
+// AddToWishlistHandler - добавляет товар :id в список желаний.
+// Тело запроса необязательно: {"notify": true} подписывает на появление товара в наличии
+func (nh *NotificationHandler) AddToWishlistHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := notificationIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.WishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	items, err := nh.nServ.AddToWishlist(c, login, id, req.Notify)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = WishlistAddOK
+	response.Data = items
+	c.JSON(http.StatusOK, response)
+}
+
+// RemoveFromWishlistHandler - убирает товар :id из списка желаний
+func (nh *NotificationHandler) RemoveFromWishlistHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := notificationIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	items, err := nh.nServ.RemoveFromWishlist(c, login, id)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = WishlistDelOK
+	response.Data = items
+	c.JSON(http.StatusOK, response)
+}
+
+// NotificationsHandler - возвращает страницу уведомлений пользователя.
+// Принимает параметры страницы limit, cursor, from и to (см. parsePageQuery)
+func (nh *NotificationHandler) NotificationsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	notes, err := nh.nServ.Notifications(c, login, q)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = NotificationsOK
+	response.Data = notes
+	c.JSON(http.StatusOK, response)
+}
+
+// ReadNotificationHandler - отмечает уведомление :id прочитанным
+func (nh *NotificationHandler) ReadNotificationHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := notificationIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	if err := nh.nServ.ReadNotification(c, login, id); err != nil {
+		notificationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = NotifyReadOK
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OrderHandler - структура мост, для связывания уровня хендлеров
+// с сервисом заказов
+type OrderHandler struct {
+	oServ service.OrderServiceInterface
+}
+
+// NewOrderHandler - конуструирует *OrderHandler по OrderServiceInterface
+func NewOrderHandler(oServ service.OrderServiceInterface) *OrderHandler {
+	return &OrderHandler{oServ}
+}
+
+// orderIDParam - читает id заказа из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func orderIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// orderError - отвечает клиенту на ошибку работы с заказами
+func orderError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrInvalidOrderStatus):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidOrderStatusError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrOrderNotFound),
+		errors.Is(err, models.ErrInvalidOrderID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = OrderNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrOrderTransition):
+		response.ErrorCode = http.StatusConflict
+		response.Message = OrderTransitionError
+		c.JSON(http.StatusConflict, response)
+	default:
+		log.Printf("orderError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// OrdersHandler - возвращает страницу заказов пользователя со статусами.
+// Принимает параметры страницы limit, cursor, from и to (см. parsePageQuery)
+func (oh *OrderHandler) OrdersHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	orders, err := oh.oServ.Orders(c, login, q)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = OrdersOK
+	response.Data = orders
+	c.JSON(http.StatusOK, response)
+}
+
+// OrderByIDHandler - возвращает заказ :id пользователя со статусом и строками
+func (oh *OrderHandler) OrderByIDHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	order, err := oh.oServ.Order(c, login, id)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = OrderOK
+	response.Data = order
+	c.JSON(http.StatusOK, response)
+}
+
+// AllOrdersHandler - (админ) возвращает страницу заказов всех пользователей.
+// Query параметры:
+//   - status - статус заказов (по умолчанию любой)
+//   - limit, cursor, from, to - параметры страницы (см. parsePageQuery)
+func (oh *OrderHandler) AllOrdersHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	filter := &models.OrderFilter{
+		Status:    models.OrderStatus(c.Query("status")),
+		PageQuery: *q,
+	}
+
+	orders, err := oh.oServ.AllOrders(c, filter)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = OrdersOK
+	response.Data = orders
+	c.JSON(http.StatusOK, response)
+}
+
+// AdvanceOrderHandler - (админ) переводит заказ :id в статус из тела запроса
+func (oh *OrderHandler) AdvanceOrderHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.OrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	order, err := oh.oServ.AdvanceOrder(c, id, req.Status)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = OrderStatusOK
+	response.Data = order
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelOrderHandler - отменяет еще не выданный заказ :id пользователя.
+// Монеты возвращаются на баланс, товар - на склад
+func (oh *OrderHandler) CancelOrderHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	order, err := oh.oServ.CancelOrder(c, login, id)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = OrderCancelOK
+	response.Data = order
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminCancelOrderHandler - (админ) отменяет заказ :id, в том числе выданный
+func (oh *OrderHandler) AdminCancelOrderHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	order, err := oh.oServ.AdminCancelOrder(c, id)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = OrderCancelOK
+	response.Data = order
+	c.JSON(http.StatusOK, response)
+}
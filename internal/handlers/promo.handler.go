@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromoHandler - структура мост, для связывания уровня хендлеров
+// с сервисом промокодов и распродаж
+type PromoHandler struct {
+	pServ service.PromoServiceInterface
+}
+
+// NewPromoHandler - конуструирует *PromoHandler по PromoServiceInterface
+func NewPromoHandler(pServ service.PromoServiceInterface) *PromoHandler {
+	return &PromoHandler{pServ}
+}
+
+// isPromoOrderError - ошибка применения промокода к заказу
+func isPromoOrderError(err error) bool {
+	return errors.Is(err, models.ErrPromoNotFound) ||
+		errors.Is(err, models.ErrPromoInactive) ||
+		errors.Is(err, models.ErrPromoNotApplicable) ||
+		errors.Is(err, models.ErrPromoExhausted) ||
+		errors.Is(err, models.ErrPromoUserLimit)
+}
+
+// promoOrderError - отвечает клиенту на ошибку применения промокода
+// при покупке или оформлении корзины (см. isPromoOrderError)
+func promoOrderError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrPromoNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = PromoNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrPromoInactive):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = PromoInactiveError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrPromoNotApplicable):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = PromoNotApplicableError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrPromoUserLimit):
+		response.ErrorCode = http.StatusConflict
+		response.Message = PromoUserLimitError
+		c.JSON(http.StatusConflict, response)
+	default:
+		response.ErrorCode = http.StatusConflict
+		response.Message = PromoExhaustedError
+		c.JSON(http.StatusConflict, response)
+	}
+}
+
+// promoAdminError - отвечает клиенту на ошибку управления промокодами и распродажами
+func promoAdminError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrEmptyPromoCode),
+		errors.Is(err, models.ErrEmptySaleName),
+		errors.Is(err, models.ErrInvalidDiscount),
+		errors.Is(err, models.ErrInvalidPromoLimit),
+		errors.Is(err, models.ErrInvalidPromoWindow):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidPromoError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrPromoExists):
+		response.ErrorCode = http.StatusConflict
+		response.Message = PromoExistsError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrMerchNotFound),
+		errors.Is(err, models.ErrInvalidMerchID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = MerchNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrPromoNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = PromoNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrSaleNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = SaleNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	default:
+		log.Printf("promoAdminError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// promoIDParam - читает id промокода или распродажи из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func promoIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// CreatePromoHandler - (админ) добавляет промокод из тела запроса
+func (ph *PromoHandler) CreatePromoHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	promo, err := ph.pServ.CreatePromo(c, &req)
+	if err != nil {
+		promoAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusCreated
+	response.Message = PromoCreateOK
+	response.Data = promo
+	c.JSON(http.StatusCreated, response)
+}
+
+// PromosHandler - (админ) возвращает промокоды с числом использований
+func (ph *PromoHandler) PromosHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	promos, err := ph.pServ.Promos(c)
+	if err != nil {
+		promoAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = PromosOK
+	response.Data = promos
+	c.JSON(http.StatusOK, response)
+}
+
+// DeletePromoHandler - (админ) удаляет промокод :id
+func (ph *PromoHandler) DeletePromoHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := promoIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ph.pServ.DeletePromo(c, id); err != nil {
+		promoAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = PromoDeleteOK
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateSaleHandler - (админ) добавляет распродажу из тела запроса
+func (ph *PromoHandler) CreateSaleHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.SaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	sale, err := ph.pServ.CreateSale(c, &req)
+	if err != nil {
+		promoAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusCreated
+	response.Message = SaleCreateOK
+	response.Data = sale
+	c.JSON(http.StatusCreated, response)
+}
+
+// SalesHandler - (админ) возвращает распродажи
+func (ph *PromoHandler) SalesHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	sales, err := ph.pServ.Sales(c)
+	if err != nil {
+		promoAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = SalesOK
+	response.Data = sales
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteSaleHandler - (админ) удаляет распродажу :id
+func (ph *PromoHandler) DeleteSaleHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := promoIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ph.pServ.DeleteSale(c, id); err != nil {
+		promoAdminError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = SaleDeleteOK
+	c.JSON(http.StatusOK, response)
+}
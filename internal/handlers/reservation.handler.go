@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"merch_service/configs"
+	"merch_service/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// reservationIDParam - читает id брони из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func reservationIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// reservationError - отвечает клиенту на ошибку работы с бронями.
+// Ошибки товара, остатка и монет - те же, что и у корзины (см. cartError)
+func reservationError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrReservationNotFound),
+		errors.Is(err, models.ErrInvalidReservationID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = ReservationNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrReservationNotActive):
+		response.ErrorCode = http.StatusConflict
+		response.Message = ReservationNotActiveError
+		c.JSON(http.StatusConflict, response)
+	default:
+		cartError(c, err)
+	}
+}
+
+// ReserveHandler - бронирует товар: удерживает его в наличии на reservationttl секунд
+// или оформляет предзаказ на preorderttl секунд (см. configs.ServerConfig).
+// Стоимость брони сразу списывается и возвращается при отмене или истечении
+func (mh *MerchHandler) ReserveHandler(config *configs.ServerConfig) gin.HandlerFunc {
+	holdTTL := time.Duration(config.ReservationTTL) * time.Second
+	preorderTTL := time.Duration(config.PreorderTTL) * time.Second
+
+	return func(c *gin.Context) {
+		response := DefaultResponse()
+
+		var req models.ReservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ErrorCode = http.StatusBadRequest
+			response.Message = InvalidAppDataError
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		info := c.Keys["claims"].(jwt.MapClaims)
+		login := info["log"].(string)
+
+		ttl := holdTTL
+		if req.Preorder {
+			ttl = preorderTTL
+		}
+
+		res, err := mh.mServ.Reserve(c, login, &req, ttl)
+		if err != nil {
+			reservationError(c, err)
+			return
+		}
+
+		response.ErrorCode = http.StatusOK
+		response.Message = ReserveOK
+		response.Data = res
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ReservationsHandler - возвращает страницу броней пользователя.
+// Принимает параметры страницы limit, cursor, from и to (см. parsePageQuery)
+func (mh *MerchHandler) ReservationsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	list, err := mh.mServ.Reservations(c, login, q)
+	if err != nil {
+		reservationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ReservationsOK
+	response.Data = list
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmReservationHandler - превращает бронь :id в заказ
+func (mh *MerchHandler) ConfirmReservationHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := reservationIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	order, err := mh.mServ.ConfirmReservation(c, login, id)
+	if err != nil {
+		reservationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ReserveConfirmOK
+	response.Data = order
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelReservationHandler - отменяет бронь :id и возвращает монеты
+func (mh *MerchHandler) CancelReservationHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := reservationIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	res, err := mh.mServ.CancelReservation(c, login, id)
+	if err != nil {
+		reservationError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ReserveCancelOK
+	response.Data = res
+	c.JSON(http.StatusOK, response)
+}
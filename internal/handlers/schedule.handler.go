@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"merch_service/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// scheduleError - отвечает клиенту на ошибку работы с запланированными переводами.
+// Ошибки повода и сообщения - те же, что и у обычного перевода (см. transferError)
+func scheduleError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrInvalidScheduleRule),
+		errors.Is(err, models.ErrScheduleInPast),
+		errors.Is(err, models.ErrInvalidAmount),
+		errors.Is(err, models.ErrSameSenderReceiver):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidScheduleError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrUserNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = UserNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrScheduleNotFound),
+		errors.Is(err, models.ErrInvalidScheduleID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = ScheduleNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrScheduleNotActive):
+		response.ErrorCode = http.StatusConflict
+		response.Message = ScheduleNotActiveError
+		c.JSON(http.StatusConflict, response)
+	default:
+		transferError(c, err)
+	}
+}
+
+// ScheduleHandler - планирует перевод монет: разовый (once) или повторяющийся
+// каждую неделю (weekly) или месяц (monthly), начиная со start_at
+func (th *TransactionHandler) ScheduleHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	st, err := th.tServ.Schedule(c, login, &req)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ScheduleOK
+	response.Data = st
+	c.JSON(http.StatusOK, response)
+}
+
+// SchedulesHandler - возвращает страницу запланированных переводов пользователя.
+// Принимает параметры страницы limit, cursor, from и to (см. parsePageQuery)
+func (th *TransactionHandler) SchedulesHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	list, err := th.tServ.Schedules(c, login, q)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = SchedulesOK
+	response.Data = list
+	c.JSON(http.StatusOK, response)
+}
+
+// ScheduleRunsHandler - возвращает страницу срабатываний запланированного перевода :id:
+// выполненных и пропущенных с причиной
+func (th *TransactionHandler) ScheduleRunsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	runs, err := th.tServ.ScheduleRuns(c, login, id, q)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ScheduleRunsOK
+	response.Data = runs
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelScheduleHandler - отменяет запланированный перевод :id
+func (th *TransactionHandler) CancelScheduleHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	st, err := th.tServ.CancelSchedule(c, login, id)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = ScheduleCancelOK
+	response.Data = st
+	c.JSON(http.StatusOK, response)
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"errors"
+	"log"
 	"merch_service/internal/models"
 	"merch_service/internal/service"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -21,7 +23,82 @@ func NewTransactionHandler(tServ service.TransactionServiceInterface) *Transacti
 	return &TransactionHandler{tServ}
 }
 
-// TransferHandler - функция обработчик переводов монет
+// transferIDParam - читает id перевода из пути. При неудаче
+// сам отвечает клиенту 400 и возвращает false
+func transferIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response := DefaultResponse()
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+// isTransferNoteError - ошибка повода, сообщения, реакции или ответа перевода
+func isTransferNoteError(err error) bool {
+	return errors.Is(err, models.ErrInvalidTransferCategory) ||
+		errors.Is(err, models.ErrInvalidTransferMessage) ||
+		errors.Is(err, models.ErrTransferMessageTooLong) ||
+		errors.Is(err, models.ErrInvalidTransferReaction) ||
+		errors.Is(err, models.ErrEmptyTransferReply)
+}
+
+// transferError - отвечает клиенту на ошибку сообщения, реакции или ответа перевода,
+// а также на отказ лимитов переводов (см. models.TransferLimits)
+func transferError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case errors.Is(err, models.ErrInvalidTransferCategory):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = TransferCategoryError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrInvalidTransferMessage),
+		errors.Is(err, models.ErrTransferMessageTooLong),
+		errors.Is(err, models.ErrEmptyTransferReply):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = TransferMessageError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrInvalidTransferReaction):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = TransferReactionError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrTransferNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = TransferNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrTransferReplied):
+		response.ErrorCode = http.StatusConflict
+		response.Message = TransferRepliedError
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrTransferTooLarge):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = TransferTooLargeError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrDailyTransferLimit),
+		errors.Is(err, models.ErrWeeklyTransferLimit):
+		response.ErrorCode = http.StatusTooManyRequests
+		response.Message = TransferLimitError
+		c.JSON(http.StatusTooManyRequests, response)
+	case errors.Is(err, models.ErrNewAccountCooldown):
+		response.ErrorCode = http.StatusForbidden
+		response.Message = NewAccountCooldownError
+		c.JSON(http.StatusForbidden, response)
+	case errors.Is(err, models.ErrSuspiciousTransfer):
+		response.ErrorCode = http.StatusForbidden
+		response.Message = SuspiciousTransferError
+		c.JSON(http.StatusForbidden, response)
+	default:
+		log.Printf("transferError: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+// TransferHandler - функция обработчик переводов монет.
+// Поля category и message тела запроса необязательны
 func (th *TransactionHandler) TransferHandler(c *gin.Context) {
 	response := DefaultResponse()
 	var req models.TransactionRequest
@@ -36,7 +113,7 @@ func (th *TransactionHandler) TransferHandler(c *gin.Context) {
 	info := c.Keys["claims"].(jwt.MapClaims)
 	sender := info["log"].(string)
 
-	err := th.tServ.Send(c, sender, req.Reciever, req.Amount)
+	err := th.tServ.Send(c, sender, req.Reciever, req.Amount, req.TransferNote)
 
 	switch {
 	case errors.Is(err, models.ErrNotEnoughCoins):
@@ -44,6 +121,9 @@ func (th *TransactionHandler) TransferHandler(c *gin.Context) {
 		response.Message = NotEnoughCoinsError
 		c.JSON(http.StatusBadRequest, response)
 		return
+	case isTransferNoteError(err), models.IsTransferLimitError(err):
+		transferError(c, err)
+		return
 	case err != nil:
 		response.Message = err.Error()
 		c.JSON(http.StatusInternalServerError, response)
@@ -54,3 +134,67 @@ func (th *TransactionHandler) TransferHandler(c *gin.Context) {
 	response.Message = TransferOK
 	c.JSON(http.StatusOK, response)
 }
+
+// ReactHandler - ставит реакцию получателя на перевод :id ({"reaction": ""} снимает ее)
+func (th *TransactionHandler) ReactHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.TransferReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	entry, err := th.tServ.React(c, login, id, req.Reaction)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = TransferReactOK
+	response.Data = entry
+	c.JSON(http.StatusOK, response)
+}
+
+// ReplyHandler - сохраняет ответ получателя на перевод :id
+func (th *TransactionHandler) ReplyHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.TransferReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	entry, err := th.tServ.Reply(c, login, id, req.Message)
+	if err != nil {
+		transferError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = TransferReplyOK
+	response.Data = entry
+	c.JSON(http.StatusOK, response)
+}
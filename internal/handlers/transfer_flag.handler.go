@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"merch_service/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// transferFlagError - отвечает клиенту на ошибку работы с пометками подозрительных переводов
+func transferFlagError(c *gin.Context, err error) {
+	response := DefaultResponse()
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidTransferFlagFilterError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrInvalidTransferFlagStatus):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidTransferFlagError
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, models.ErrTransferFlagNotFound),
+		errors.Is(err, models.ErrInvalidTransferFlagID):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = TransferFlagNotFoundError
+		c.JSON(http.StatusNotFound, response)
+	case errors.Is(err, models.ErrTransferFlagClosed):
+		response.ErrorCode = http.StatusConflict
+		response.Message = TransferFlagClosedError
+		c.JSON(http.StatusConflict, response)
+	default:
+		transferError(c, err)
+	}
+}
+
+// TransferFlagsHandler - возвращает страницу пометок подозрительных переводов.
+// Query параметры:
+//   - status - open, dismissed или confirmed (по умолчанию все)
+//   - limit, cursor, from, to - параметры страницы (см. parsePageQuery)
+func (th *TransactionHandler) TransferFlagsHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidTransferFlagFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	status := models.TransferFlagStatus(c.Query("status"))
+	if status != "" && !status.Valid() {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidTransferFlagFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	list, err := th.tServ.TransferFlags(c, &models.TransferFlagFilter{Status: status, PageQuery: *q})
+	if err != nil {
+		transferFlagError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = TransferFlagsOK
+	response.Data = list
+	c.JSON(http.StatusOK, response)
+}
+
+// ReviewTransferFlagHandler - закрывает пометку :id решением администратора.
+// Тело запроса - {"status"}: dismissed (перевод обычный) или confirmed (мошеннический)
+func (th *TransactionHandler) ReviewTransferFlagHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	id, ok := transferIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReviewFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	flag, err := th.tServ.ReviewTransferFlag(c, login, id, req.Status)
+	if err != nil {
+		transferFlagError(c, err)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = FlagReviewOK
+	response.Data = flag
+	c.JSON(http.StatusOK, response)
+}
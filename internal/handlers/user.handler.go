@@ -16,24 +16,40 @@ import (
 // с пользовательским сервисом
 type UserHandler struct {
 	uServ service.UserServiceInterface
+	aServ service.AuthServiceInterface
 }
 
-// NewUserHandler - конуструирует *UserHandler по UserServiceInterface
-func NewUserHandler(uServ service.UserServiceInterface) *UserHandler {
-	return &UserHandler{uServ}
+// NewUserHandler - конуструирует *UserHandler по UserServiceInterface и AuthServiceInterface
+func NewUserHandler(uServ service.UserServiceInterface, aServ service.AuthServiceInterface) *UserHandler {
+	return &UserHandler{uServ, aServ}
 }
 
 // CoinsHistoryHandler - функция обработчик, отвечающий на запрос историй кошелька пользователя
-// В случае успеха, в ответе возвращает историю кошелька в поле data
+// Принимает параметры страницы limit, cursor, from, to (см. parsePageQuery).
+// В случае успеха, в ответе возвращает страницу истории кошелька в поле data
 func (uh *UserHandler) CoinsHistoryHandler(c *gin.Context) {
 	response := DefaultResponse()
 
 	info := c.Keys["claims"].(jwt.MapClaims)
 	login := info["log"].(string)
 
-	coinsHist, err := uh.uServ.CoinsHistory(c, login)
-
+	q, err := parsePageQuery(c)
 	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	coinsHist, err := uh.uServ.CoinsHistory(c, login, q)
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case err != nil:
 		c.JSON(http.StatusOK, response)
 		return
 	}
@@ -45,16 +61,31 @@ func (uh *UserHandler) CoinsHistoryHandler(c *gin.Context) {
 }
 
 // PurchaseHistoryHandler - функция обработчик, отвечающий на запрос покупок пользователя
-// В случае успеха, в ответе возвращает список покупок в поле data
+// Принимает параметры страницы limit, cursor, from, to (см. parsePageQuery).
+// В случае успеха, в ответе возвращает страницу покупок в поле data
 func (uh *UserHandler) PurchaseHistoryHandler(c *gin.Context) {
 	response := DefaultResponse()
 
 	info := c.Keys["claims"].(jwt.MapClaims)
 	login := info["log"].(string)
 
-	pHist, err := uh.uServ.PurchaseHistory(c, login)
-
+	q, err := parsePageQuery(c)
 	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	pHist, err := uh.uServ.PurchaseHistory(c, login, q)
+
+	switch {
+	case isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case err != nil:
 		c.JSON(http.StatusOK, response)
 		return
 	}
@@ -65,6 +96,51 @@ func (uh *UserHandler) PurchaseHistoryHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// TransferHistoryHandler - функция обработчик, отвечающий на запрос переводов пользователя.
+// Query параметры:
+//   - direction - sent, received или all (по умолчанию)
+//   - limit, cursor, from, to - параметры страницы (см. parsePageQuery)
+//
+// В случае успеха, в ответе возвращает страницу переводов в поле data
+func (uh *UserHandler) TransferHistoryHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	info := c.Keys["claims"].(jwt.MapClaims)
+	login := info["log"].(string)
+
+	q, err := parsePageQuery(c)
+	if err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	filter := &models.TransferFilter{
+		Direction: models.TransferDirection(c.DefaultQuery("direction", string(models.TransferAll))),
+		PageQuery: *q,
+	}
+
+	tHist, err := uh.uServ.TransferHistory(c, login, filter)
+
+	switch {
+	case errors.Is(err, models.ErrInvalidDirection), isPageQueryError(err):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidFilterError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case err != nil:
+		log.Printf("transferHistoryHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = HistoryTransOK
+	response.Data = tHist
+	c.JSON(http.StatusOK, response)
+}
+
 // RegHandler - обработчик, отвечающий за регистрацию пользователя
 func (uh *UserHandler) RegHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -122,6 +198,7 @@ func (uh *UserHandler) LoginHandler(config *configs.ServerConfig) gin.HandlerFun
 		case errors.Is(err, models.ErrWrongPassword):
 			response.ErrorCode = http.StatusBadRequest
 			response.Message = WrongPassError
+			c.JSON(http.StatusBadRequest, response)
 			return
 		case errors.Is(err, models.ErrUserNotFound):
 			response.ErrorCode = http.StatusBadRequest
@@ -133,6 +210,64 @@ func (uh *UserHandler) LoginHandler(config *configs.ServerConfig) gin.HandlerFun
 			return
 		}
 
-		SendToken(c, config, &req)
+		SendToken(c, config, uh.aServ, &req)
 	}
 }
+
+// UsersHandler - (админ) возвращает список пользователей
+func (uh *UserHandler) UsersHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	users, err := uh.uServ.Users(c)
+	if err != nil {
+		log.Printf("usersHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = UsersOK
+	response.Data = users
+	c.JSON(http.StatusOK, response)
+}
+
+// SetRoleHandler - (админ) назначает роль пользователю :login и завершает все его сессии
+func (uh *UserHandler) SetRoleHandler(c *gin.Context) {
+	response := DefaultResponse()
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidAppDataError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err := uh.uServ.SetRole(c, c.Param("login"), req.Role)
+
+	switch {
+	case errors.Is(err, models.ErrInvalidUserRole):
+		response.ErrorCode = http.StatusBadRequest
+		response.Message = InvalidRoleError
+		c.JSON(http.StatusBadRequest, response)
+		return
+	case errors.Is(err, models.ErrUserNotFound):
+		response.ErrorCode = http.StatusNotFound
+		response.Message = UserNotFoundError
+		c.JSON(http.StatusNotFound, response)
+		return
+	case errors.Is(err, models.ErrLastAdmin):
+		response.ErrorCode = http.StatusConflict
+		response.Message = LastAdminError
+		c.JSON(http.StatusConflict, response)
+		return
+	case err != nil:
+		log.Printf("setRoleHandler error: %v", err)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response.ErrorCode = http.StatusOK
+	response.Message = RoleOK
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MaxCategoryLength - максимальная длина категории товара в символах
+const MaxCategoryLength = 64
+
+// MerchSort - порядок товаров в каталоге
+type MerchSort string
+
+// Порядки каталога. При равных ключах товары идут по id
+const (
+	MerchSortID        MerchSort = ""           // по id (по умолчанию)
+	MerchSortPrice     MerchSort = "price"      // от дешевых к дорогим
+	MerchSortPriceDesc MerchSort = "-price"     // от дорогих к дешевым
+	MerchSortName      MerchSort = "name"       // по имени
+	MerchSortNameDesc  MerchSort = "-name"      // по имени в обратном порядке
+	MerchSortPopular   MerchSort = "popularity" // от самых покупаемых
+)
+
+// Valid - проверяет, что порядок известен
+func (s MerchSort) Valid() bool {
+	switch s {
+	case MerchSortID, MerchSortPrice, MerchSortPriceDesc, MerchSortName, MerchSortNameDesc, MerchSortPopular:
+		return true
+	}
+	return false
+}
+
+// Desc - обратный ли порядок ключа сортировки
+func (s MerchSort) Desc() bool {
+	return s == MerchSortPriceDesc || s == MerchSortNameDesc || s == MerchSortPopular
+}
+
+// numericKey - числовой ли ключ сортировки (цена, число продаж)
+func (s MerchSort) numericKey() bool {
+	return s == MerchSortPrice || s == MerchSortPriceDesc || s == MerchSortPopular
+}
+
+// Cursor - позиция товара в каталоге с порядком s:
+// id и значение ключа сортировки
+func (s MerchSort) Cursor(item *Item) *Cursor {
+	cursor := &Cursor{Id: item.Id}
+	switch s {
+	case MerchSortPrice, MerchSortPriceDesc:
+		cursor.Key = strconv.Itoa(item.Price)
+	case MerchSortName, MerchSortNameDesc:
+		cursor.Key = item.Name
+	case MerchSortPopular:
+		cursor.Key = strconv.Itoa(item.Sold)
+	}
+	return cursor
+}
+
+// MerchFilter - фильтр, порядок и страница каталога.
+// Search ищет подстроку в имени и тегах без учета регистра и разницы е/ё (см. MerchSearchText),
+// Category и Tag сравниваются без учета регистра.
+// Цены ограничивают базовую цену товара, nil - без ограничения.
+// InStock оставляет товары в наличии (для товаров с вариантами - хотя бы один вариант)
+type MerchFilter struct {
+	Search   string
+	Category string
+	Tag      string
+	MinPrice *int
+	MaxPrice *int
+	InStock  bool
+	Sort     MerchSort
+	PageQuery
+}
+
+// Normalize - проверяет фильтр и страницу и приводит строки фильтра к виду,
+// в котором они хранятся у товара
+func (f *MerchFilter) Normalize() error {
+	if err := f.PageQuery.Normalize(); err != nil {
+		return err
+	}
+
+	if !f.Sort.Valid() {
+		return ErrInvalidMerchSort
+	}
+
+	if (f.MinPrice != nil && *f.MinPrice < 0) || (f.MaxPrice != nil && *f.MaxPrice < 0) ||
+		(f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice) {
+		return ErrInvalidPriceRange
+	}
+
+	// Курсор другого порядка к этому каталогу не подходит
+	if f.After != nil {
+		if f.Sort == MerchSortID && f.After.Key != "" {
+			return ErrInvalidCursor
+		}
+		if f.Sort.numericKey() {
+			if _, err := strconv.Atoi(f.After.Key); err != nil {
+				return ErrInvalidCursor
+			}
+		}
+	}
+
+	f.Search = FoldText(f.Search)
+	f.Category = NormalizeLabel(f.Category)
+	f.Tag = NormalizeLabel(f.Tag)
+	return nil
+}
+
+// AfterKey - числовое значение ключа сортировки из курсора.
+// Вызывается после Normalize, поэтому ключ уже проверен
+func (f *MerchFilter) AfterKey() int {
+	key, _ := strconv.Atoi(f.After.Key)
+	return key
+}
+
+// FoldText - приводит строку к виду для поиска без учета регистра:
+// нижний регистр (в том числе кириллица), ё как е, без пробелов по краям
+func FoldText(s string) string {
+	return strings.ReplaceAll(NormalizeLabel(s), "ё", "е")
+}
+
+// MerchSearchText - текст, по которому ищется товар: имя и теги (см. FoldText)
+func MerchSearchText(item *Item) string {
+	return FoldText(strings.Join(append([]string{item.Name}, item.Tags...), " "))
+}
+
+// NormalizeLabel - категория или тег в том виде, в котором они хранятся:
+// нижний регистр без пробелов по краям
+func NormalizeLabel(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// NormalizeTags - теги товара без пустых и повторов (см. NormalizeLabel),
+// в порядке первого упоминания
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeLabel(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+const (
+	// MaxPendingCoinRequests - сколько ожидающих запросов монет может быть у одного пользователя
+	MaxPendingCoinRequests = 20
+
+	// MaxDailyCoinRequests - сколько запросов монет пользователь может создать за сутки,
+	// в том числе уже отклоненных
+	MaxDailyCoinRequests = 50
+)
+
+// CoinRequestStatus - статус запроса монет
+type CoinRequestStatus string
+
+const (
+	CoinRequestPending  CoinRequestStatus = "pending"  // Ждет ответа плательщика
+	CoinRequestAccepted CoinRequestStatus = "accepted" // Принят, монеты переведены переводом TransferId
+	CoinRequestDeclined CoinRequestStatus = "declined" // Отклонен плательщиком
+	CoinRequestExpired  CoinRequestStatus = "expired"  // Плательщик не ответил до ExpiresAt
+)
+
+// Valid - проверяет, что статус известен
+func (s CoinRequestStatus) Valid() bool {
+	switch s {
+	case CoinRequestPending, CoinRequestAccepted, CoinRequestDeclined, CoinRequestExpired:
+		return true
+	}
+	return false
+}
+
+// CoinRequest - запрос Amount монет пользователя Requester у пользователя Payer
+// с поводом и сообщением. Принятый запрос выполняется обычным переводом от Payer
+// к Requester с теми же поводом и сообщением. Монеты до ответа не удерживаются,
+// поэтому истекший запрос не хранится отдельным статусом, а считается по ExpiresAt
+// (см. StatusAt)
+type CoinRequest struct {
+	Id          int    `json:"id"`
+	RequesterId int    `json:"-"`
+	Requester   string `json:"requester"`
+	PayerId     int    `json:"-"`
+	Payer       string `json:"payer"`
+	Amount      int    `json:"amount"`
+	TransferNote
+	Status     CoinRequestStatus `json:"status"`
+	TransferId int               `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+}
+
+// StatusAt - статус запроса в момент at: ожидающий запрос после ExpiresAt истек
+func (r *CoinRequest) StatusAt(at time.Time) CoinRequestStatus {
+	if r.Status == CoinRequestPending && !at.Before(r.ExpiresAt) {
+		return CoinRequestExpired
+	}
+	return r.Status
+}
+
+// Cursor - позиция запроса в списке, запросы упорядочены по дате создания
+func (r *CoinRequest) Cursor() *Cursor {
+	return &Cursor{Date: r.CreatedAt, Id: r.Id}
+}
+
+// CoinRequestFilter - фильтр и страница запросов монет пользователя.
+// Direction - отправленные (sent) пользователем, полученные (received) или все,
+// Status - только запросы в этом статусе (пустой - все)
+type CoinRequestFilter struct {
+	Direction TransferDirection
+	Status    CoinRequestStatus
+	PageQuery
+}
+
+// CoinRequestCounts - счетчики запросов пользователя для защиты от спама
+type CoinRequestCounts struct {
+	Pending        int // Ожидающие ответа запросы пользователя
+	PendingToPayer int // Из них - к тому же плательщику
+	Created        int // Созданные с начала окна
+}
+
+// AskCoinsRequest - запрос монет у пользователя payer. Повод category и сообщение message необязательны
+type AskCoinsRequest struct {
+	Payer  string `json:"payer"`
+	Amount int    `json:"amount"`
+	TransferNote
+}
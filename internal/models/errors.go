@@ -8,13 +8,117 @@ var (
 	ErrNoSuchMerch    = errors.New("товара по запросу не существует")
 	ErrNotEnoughMerch = errors.New("на складе нет столько товара")
 	ErrNotEnoughCoins = errors.New("недостаточно монет для покупки")
+	ErrInvalidCount   = errors.New("количество товара должно быть положительным")
+	ErrEmptyCart      = errors.New("корзина пуста")
+
+	ErrVariantRequired = errors.New("у товара есть варианты, нужно выбрать вариант")
+
+	ErrInvalidMerchSort  = errors.New("порядок каталога может быть price, -price, name, -name или popularity")
+	ErrInvalidPriceRange = errors.New("цены фильтра не могут быть отрицательными, min_price не больше max_price")
+
+	ErrPromoInactive      = errors.New("промокод еще не действует или уже истек")
+	ErrPromoNotApplicable = errors.New("промокод не распространяется на товары заказа")
+	ErrPromoExhausted     = errors.New("промокод использован максимальное число раз")
+	ErrPromoUserLimit     = errors.New("пользователь уже использовал промокод максимальное число раз")
+
+	ErrPurchaseLimitExceeded = errors.New("заказ превышает лимит покупки товара одним пользователем")
+)
+
+// Для MediaService
+var (
+	ErrImageTooLarge        = errors.New("изображение не может быть больше 5 МиБ и 4096x4096 пикселей")
+	ErrUnsupportedImageType = errors.New("изображение может быть только JPEG или PNG")
+	ErrInvalidImage         = errors.New("не удалось прочитать изображение")
 )
 
 // Для UserService
 var (
 	ErrWrongPassword = errors.New("неверный пароль")
 	ErrUserExists    = errors.New("пользователь с таким логином уже существует")
+	ErrLastAdmin     = errors.New("нельзя снять роль с последнего администратора")
+
+	ErrInvalidPasswordHash = errors.New("некорректный формат хеша пароля")
+
+	ErrInvalidDirection = errors.New("направление перевода может быть sent, received или all")
+)
+
+// Для сообщений к переводам в TransactionService
+var (
+	ErrInvalidTransferCategory = errors.New("повод перевода может быть thanks, quest_reward, bet или gift")
+	ErrInvalidTransferMessage  = errors.New("сообщение к переводу должно быть в UTF-8")
+	ErrTransferMessageTooLong  = errors.New("сообщение к переводу не может быть длиннее 280 символов")
+	ErrInvalidTransferReaction = errors.New("реакция может быть like, heart, party, laugh или wow")
+	ErrEmptyTransferReply      = errors.New("ответ на перевод не может быть пустым")
 )
+
+// Для запланированных переводов в TransactionService
+var (
+	ErrInvalidScheduleRule = errors.New("правило перевода может быть once, weekly или monthly")
+	ErrScheduleInPast      = errors.New("первый запланированный перевод должен быть в будущем")
+	ErrScheduleNotActive   = errors.New("запланированный перевод уже выполнен или отменен")
+)
+
+// Для запросов монет в TransactionService
+var (
+	ErrCoinRequestNotPending = errors.New("на запрос монет уже ответили или он истек")
+	ErrCoinRequestExists     = errors.New("запрос монет этому пользователю уже ждет ответа")
+	ErrTooManyCoinRequests   = errors.New("слишком много запросов монет, дождитесь ответа на уже отправленные")
+)
+
+// Для лимитов переводов в TransactionService (см. TransferLimits)
+var (
+	ErrNewAccountCooldown  = errors.New("новый аккаунт пока не может отправлять монеты")
+	ErrTransferTooLarge    = errors.New("сумма перевода больше разрешенной для одного перевода")
+	ErrDailyTransferLimit  = errors.New("перевод превышает лимит исходящих переводов за сутки")
+	ErrWeeklyTransferLimit = errors.New("перевод превышает лимит исходящих переводов за неделю")
+	ErrSuspiciousTransfer  = errors.New("перевод похож на мошеннический и отклонен")
+	ErrTransferFlagClosed  = errors.New("подозрительный перевод уже проверен")
+)
+
+// Для начислений из казны в LedgerService
+var (
+	ErrEmptyGrantBatch    = errors.New("пакет начислений пуст")
+	ErrGrantBatchTooLarge = errors.New("в пакете начислений не может быть больше 1000 строк")
+	ErrInvalidGrantCSV    = errors.New("не удалось прочитать CSV пакета начислений")
+	ErrInvalidGrantRow    = errors.New("строка должна содержать login, amount и reason")
+	ErrInvalidGrantAmount = errors.New("amount должен быть целым числом от 1 до 1000000")
+	ErrInvalidGrantReason = errors.New("reason не может быть пустым или длиннее 255 символов")
+	ErrInvalidGrantBatch  = errors.New("в пакете начислений есть ошибочные строки")
+)
+
+// Для списков с пагинацией (см. PageQuery)
+var (
+	ErrInvalidCursor    = errors.New("некорректный курсор страницы")
+	ErrInvalidLimit     = errors.New("размер страницы должен быть от 1 до 100")
+	ErrInvalidDateRange = errors.New("начало периода позже его конца")
+)
+
+// Для AuthService
+var (
+	ErrRefreshTokenExpired = errors.New("срок действия refresh токена истек")
+	ErrRefreshTokenReused  = errors.New("refresh токен уже был использован, сессия отозвана")
+	ErrRefreshTokenRevoked = errors.New("refresh токен отозван")
+	ErrSessionRevoked      = errors.New("сессия завершена")
+)
+
+// Для OrderService
+var (
+	ErrInvalidOrderStatus = errors.New("статус заказа может быть placed, confirmed, ready_for_pickup, delivered или cancelled")
+	ErrOrderTransition    = errors.New("заказ нельзя перевести в этот статус")
+)
+
+// Для брони в MerchService
+var (
+	ErrReservationNotActive = errors.New("бронь уже подтверждена, отменена или истекла")
+)
+
+// Для IdempotencyService
+var (
+	ErrInvalidIdempotencyKey = errors.New("ключ идемпотентности должен быть от 1 до 255 видимых ASCII символов")
+	ErrIdempotencyKeyReused  = errors.New("ключ идемпотентности уже использован с другим запросом")
+	ErrIdempotencyInProgress = errors.New("запрос с этим ключом идемпотентности еще выполняется")
+)
+
 //
 // StorageErrorsBlock
 //
@@ -27,6 +131,7 @@ var (
 	ErrEmptyUserLogin    = errors.New("логин пользователя не может быть пустым")
 	ErrEmptyUserPassword = errors.New("пароль пользователя не может быть пустым")
 	ErrNegativeUserCoins = errors.New("количество монет пользователя не может быть отрицательным")
+	ErrInvalidUserRole   = errors.New("такой роли пользователя не существует")
 )
 
 // Для MerchStorage
@@ -37,6 +142,100 @@ var (
 	ErrEmptyMerchName = errors.New("имя мерча не может быть пустым")
 	ErrNegativePrice  = errors.New("цена мерча не может быть отрицательной")
 	ErrNegativeStock  = errors.New("количество мерча не может быть отрицательным")
+	ErrMerchExists    = errors.New("мерч с таким именем уже существует")
+
+	ErrCategoryTooLong = errors.New("категория мерча не может быть длиннее 64 символов")
+	ErrInvalidLimits   = errors.New("лимиты покупки мерча не могут быть отрицательными")
+)
+
+// Для VariantStorage
+var (
+	ErrVariantNotFound = errors.New("такого варианта товара нет в бд")
+	ErrEmptyVariant    = errors.New("вариант товара не может быть nill")
+	ErrEmptyVariantSku = errors.New("артикул варианта не может быть пустым")
+	ErrVariantExists   = errors.New("вариант с таким артикулом уже существует")
+)
+
+// Для ImageStorage
+var (
+	ErrImageNotFound = errors.New("такого изображения нет в бд")
+	ErrEmptyImage    = errors.New("изображение не может быть nill")
+	ErrImageExists   = errors.New("это изображение уже загружено для товара")
+)
+
+// Для BlobStorage
+var (
+	ErrBlobNotFound   = errors.New("такого файла нет в хранилище")
+	ErrInvalidBlobKey = errors.New("некорректный ключ файла в хранилище")
+)
+
+// Для WishlistStorage
+var (
+	ErrWishlistItemNotFound = errors.New("этого мерча нет в списке желаний")
+)
+
+// Для NotificationStorage
+var (
+	ErrNotificationNotFound = errors.New("такого уведомления нет в бд")
+)
+
+// Для ReservationStorage
+var (
+	ErrReservationNotFound      = errors.New("такой брони нет в бд")
+	ErrEmptyReservation         = errors.New("бронь не может быть nill")
+	ErrInvalidReservationID     = errors.New("id брони не может быть отрицательным")
+	ErrInvalidReservationStatus = errors.New("такого статуса брони не существует")
+)
+
+// Для ScheduleStorage
+var (
+	ErrScheduleNotFound      = errors.New("такого запланированного перевода нет в бд")
+	ErrEmptySchedule         = errors.New("запланированный перевод не может быть nill")
+	ErrInvalidScheduleID     = errors.New("id запланированного перевода не может быть отрицательным")
+	ErrInvalidScheduleStatus = errors.New("такого статуса запланированного перевода не существует")
+	ErrScheduleRunExists     = errors.New("срабатывание запланированного перевода уже записано")
+)
+
+// Для CoinRequestStorage
+var (
+	ErrCoinRequestNotFound      = errors.New("такого запроса монет нет в бд")
+	ErrEmptyCoinRequest         = errors.New("запрос монет не может быть nill")
+	ErrInvalidCoinRequestID     = errors.New("id запроса монет не может быть отрицательным")
+	ErrInvalidCoinRequestStatus = errors.New("статус запроса монет может быть pending, accepted, declined или expired")
+)
+
+// Для TransferFlagStorage
+var (
+	ErrTransferFlagNotFound      = errors.New("такой пометки перевода нет в бд")
+	ErrEmptyTransferFlag         = errors.New("пометка перевода должна содержать перевод и хотя бы одну причину")
+	ErrInvalidTransferFlagID     = errors.New("id пометки перевода не может быть отрицательным")
+	ErrInvalidTransferFlagStatus = errors.New("статус пометки перевода может быть open, dismissed или confirmed")
+)
+
+// Для PromoStorage
+var (
+	ErrPromoNotFound      = errors.New("такого промокода нет в бд")
+	ErrEmptyPromo         = errors.New("промокод не может быть nill")
+	ErrEmptyPromoCode     = errors.New("промокод не может быть пустым")
+	ErrPromoExists        = errors.New("такой промокод уже существует")
+	ErrInvalidDiscount    = errors.New("скидка может быть percent от 1 до 100 или fixed больше нуля")
+	ErrInvalidPromoLimit  = errors.New("лимиты использования промокода не могут быть отрицательными")
+	ErrInvalidPromoWindow = errors.New("окончание акции должно быть позже ее начала")
+	ErrSaleNotFound       = errors.New("такой распродажи нет в бд")
+	ErrEmptySale          = errors.New("распродажа не может быть nill")
+	ErrEmptySaleName      = errors.New("название распродажи не может быть пустым")
+)
+
+// Для RefreshTokenStorage
+var (
+	ErrRefreshTokenNotFound = errors.New("такого refresh токена нет в бд")
+	ErrEmptyRefreshToken    = errors.New("refresh токен не может быть nill")
+)
+
+// Для SessionStorage
+var (
+	ErrSessionNotFound = errors.New("такой сессии нет в бд")
+	ErrEmptySession    = errors.New("сессия не может быть nill")
 )
 
 // Для TransactionStorage
@@ -49,4 +248,33 @@ var (
 	ErrInvalidAmount      = errors.New("amount транзакции не может быть отрицательным")
 	ErrSameSenderReceiver = errors.New("получатель и отправитель одинаковые")
 	ErrInsufficientCoins  = errors.New("у отправителя не хватает монет для отправки")
-)
\ No newline at end of file
+	ErrTransferNotFound   = errors.New("такого перевода не существует")
+	ErrTransferReplied    = errors.New("на перевод уже ответили")
+)
+
+// Для LedgerStorage
+var (
+	ErrEmptyJournalEntry = errors.New("запись журнала должна содержать причину и хотя бы две проводки")
+	ErrUnbalancedEntry   = errors.New("сумма проводок записи журнала должна быть равна нулю")
+	ErrInvalidPosting    = errors.New("проводка должна иметь ненулевую сумму и ровно один счет")
+	ErrAccountNotFound   = errors.New("такого счета нет в журнале")
+)
+
+// Для IdempotencyStorage
+var (
+	ErrEmptyIdempotencyRecord = errors.New("ключ идемпотентности не может быть nill")
+	ErrIdempotencyNotFound    = errors.New("такого ключа идемпотентности нет в бд")
+	ErrIdempotencyKeyExists   = errors.New("ключ идемпотентности уже существует")
+)
+
+// Для CartStorage
+var (
+	ErrCartItemNotFound = errors.New("такого товара нет в корзине")
+)
+
+// Для OrderStorage
+var (
+	ErrEmptyOrder     = errors.New("заказ должен содержать хотя бы одну строку")
+	ErrOrderNotFound  = errors.New("такого заказа нет в бд")
+	ErrInvalidOrderID = errors.New("id заказа не может быть отрицательным")
+)
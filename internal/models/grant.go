@@ -0,0 +1,144 @@
+package models
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxGrantRows - максимальное число строк в одном пакете начислений
+	MaxGrantRows = 1000
+
+	// MaxGrantAmount - максимальное начисление по одной строке пакета
+	MaxGrantAmount = 1_000_000
+
+	// MaxGrantReasonLen - максимальная длина причины начисления в символах
+	MaxGrantReasonLen = 255
+
+	// MaxGrantBodySize - максимальный размер тела запроса с пакетом начислений (CSV или JSON)
+	MaxGrantBodySize = 1 << 20
+)
+
+// GrantRow - строка пакета начислений: Amount монет пользователю Login по причине Reason.
+// Line - номер строки во входных данных (для CSV - с учетом заголовка), Error - почему
+// строка не может быть начислена
+type GrantRow struct {
+	Line   int    `json:"line"`
+	Login  string `json:"login"`
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+
+	UserId int `json:"-"`
+	Id     int `json:"-"` // id строки в БД после начисления
+}
+
+// Validate - проверяет строку без обращения к БД: логин, сумму и причину.
+// Пробелы по краям логина и причины убираются
+func (r *GrantRow) Validate() error {
+	r.Login = strings.TrimSpace(r.Login)
+	r.Reason = strings.TrimSpace(r.Reason)
+
+	if r.Login == "" {
+		return ErrInvalidGrantRow
+	}
+	if r.Amount <= 0 || r.Amount > MaxGrantAmount {
+		return ErrInvalidGrantAmount
+	}
+	if r.Reason == "" || !utf8.ValidString(r.Reason) || utf8.RuneCountInString(r.Reason) > MaxGrantReasonLen {
+		return ErrInvalidGrantReason
+	}
+	return nil
+}
+
+// GrantRequest - пакет начислений в JSON
+type GrantRequest struct {
+	Rows []GrantRow `json:"rows"`
+}
+
+// Numbered - строки запроса, пронумерованные по порядку с единицы.
+// Номера и ошибки, пришедшие в запросе, не учитываются
+func (r *GrantRequest) Numbered() []GrantRow {
+	rows := make([]GrantRow, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = GrantRow{Line: i + 1, Login: row.Login, Amount: row.Amount, Reason: row.Reason}
+	}
+	return rows
+}
+
+// GrantBatch - пакет начислений, выполненный администратором AdminId из казны
+type GrantBatch struct {
+	Id        int
+	AdminId   int
+	Total     int
+	Rows      []*GrantRow
+	CreatedAt time.Time
+}
+
+// GrantReport - результат проверки или выполнения пакета начислений.
+// Id - id выполненного пакета, у пробного запуска (DryRun) и пакета с ошибками его нет
+type GrantReport struct {
+	Id      int        `json:"id,omitempty"`
+	DryRun  bool       `json:"dry_run"`
+	Applied bool       `json:"applied"`
+	Valid   int        `json:"valid"`
+	Invalid int        `json:"invalid"`
+	Total   int        `json:"total"` // Сумма монет в корректных строках
+	Rows    []GrantRow `json:"rows"`
+}
+
+// ParseGrantCSV - читает пакет начислений в CSV с колонками login, amount и reason.
+// Первая строка с login в первой колонке считается заголовком. Строки с неверным
+// числом колонок или нецелой суммой не прерывают разбор, а получают Error
+// и попадают в отчет. Нечитаемый CSV возвращает ErrInvalidGrantCSV,
+// ошибки чтения r возвращаются как есть
+func ParseGrantCSV(r io.Reader) ([]GrantRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows := make([]GrantRow, 0)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, ErrInvalidGrantCSV
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Пустые строки CSV пропускаются, поэтому номер берется у reader
+		line, _ := reader.FieldPos(0)
+
+		if first && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "login") {
+			continue
+		}
+
+		if len(rows) >= MaxGrantRows {
+			return nil, ErrGrantBatchTooLarge
+		}
+
+		row := GrantRow{Line: line}
+		if len(record) != 3 {
+			row.Error = ErrInvalidGrantRow.Error()
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Login, row.Reason = record[0], record[2]
+		row.Amount, err = strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			row.Error = ErrInvalidGrantAmount.Error()
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import "time"
+
+// SignupBonus - монеты, которые начисляются новому пользователю при регистрации
+const SignupBonus = 1000
+
+// LedgerReason - причина проводки в журнале монет
+type LedgerReason string
+
+const (
+	ReasonOpening  LedgerReason = "opening"  // Перенос балансов, существовавших до журнала
+	ReasonSignup   LedgerReason = "signup"   // Начисление SignupBonus при регистрации
+	ReasonPurchase LedgerReason = "purchase" // Покупка мерча, ReferenceId - id заказа
+	ReasonTransfer LedgerReason = "transfer" // Перевод монет, ReferenceId - id перевода
+	ReasonRefund   LedgerReason = "refund"   // Возврат монет за отмененный заказ, ReferenceId - id заказа
+	ReasonReserve  LedgerReason = "reserve"  // Оплата брони, ReferenceId - id брони
+	ReasonRelease  LedgerReason = "release"  // Возврат монет за отмененную или истекшую бронь, ReferenceId - id брони
+	ReasonGrant    LedgerReason = "grant"    // Начисление администратором из казны, ReferenceId - id пакета начислений
+)
+
+// SystemAccount - системный счет журнала. В отличие от счетов
+// пользователей, баланс системного счета может быть отрицательным
+type SystemAccount string
+
+const (
+	AccountShopRevenue  SystemAccount = "shop_revenue" // Выручка магазина
+	AccountGrants       SystemAccount = "grants"       // Источник начисленных монет
+	AccountReservations SystemAccount = "reservations" // Монеты, удержанные активными бронями
+	AccountTreasury     SystemAccount = "treasury"     // Казна: источник начислений администратора
+)
+
+// Valid - проверяет, что системный счет существует
+func (a SystemAccount) Valid() bool {
+	return a == AccountShopRevenue || a == AccountGrants || a == AccountReservations || a == AccountTreasury
+}
+
+// Posting - проводка по одному счету: либо счету пользователя UserId,
+// либо системному счету System. Положительная сумма увеличивает баланс счета
+type Posting struct {
+	UserId int           `json:"user_id,omitempty"`
+	System SystemAccount `json:"system,omitempty"`
+	Amount int           `json:"amount"`
+}
+
+// JournalEntry - запись журнала. Записи только добавляются,
+// сумма проводок каждой записи равна нулю (двойная запись)
+type JournalEntry struct {
+	Id          int          `json:"id"`
+	Reason      LedgerReason `json:"reason"`
+	ReferenceId int          `json:"reference_id,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Postings    []Posting    `json:"postings"`
+}
+
+// NewTransferEntry - запись о перемещении amount монет со счета from на счет to
+func NewTransferEntry(reason LedgerReason, referenceID int, from, to Posting, amount int) *JournalEntry {
+	from.Amount = -amount
+	to.Amount = amount
+
+	return &JournalEntry{
+		Reason:      reason,
+		ReferenceId: referenceID,
+		Postings:    []Posting{from, to},
+	}
+}
+
+// Validate - проверяет, что запись сбалансирована и каждая проводка
+// относится ровно к одному существующему счету
+func (e *JournalEntry) Validate() error {
+	if e == nil {
+		return ErrEmptyJournalEntry
+	}
+
+	if e.Reason == "" || len(e.Postings) < 2 {
+		return ErrEmptyJournalEntry
+	}
+
+	sum := 0
+	for _, p := range e.Postings {
+		if p.Amount == 0 || (p.UserId > 0) == (p.System != "") || p.UserId < 0 {
+			return ErrInvalidPosting
+		}
+		if p.System != "" && !p.System.Valid() {
+			return ErrAccountNotFound
+		}
+		sum += p.Amount
+	}
+
+	if sum != 0 {
+		return ErrUnbalancedEntry
+	}
+
+	return nil
+}
+
+// BalanceMismatch - пользователь, у которого кешированный баланс
+// в merchshop.users.coins разошелся с суммой его проводок
+type BalanceMismatch struct {
+	UserId int    `json:"user_id"`
+	Login  string `json:"login"`
+	Cached int    `json:"cached"`
+	Ledger int    `json:"ledger"`
+}
+
+// LedgerReport - результат сверки журнала
+type LedgerReport struct {
+	Total             int               `json:"total"` // Сумма всех проводок, должна быть 0
+	UnbalancedEntries []int             `json:"unbalanced_entries"`
+	BalanceMismatches []BalanceMismatch `json:"balance_mismatches"`
+}
+
+// OK - журнал сходится: все записи сбалансированы
+// и кешированные балансы совпадают с журналом
+func (r *LedgerReport) OK() bool {
+	return r.Total == 0 && len(r.UnbalancedEntries) == 0 && len(r.BalanceMismatches) == 0
+}
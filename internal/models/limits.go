@@ -0,0 +1,31 @@
+package models
+
+// PurchaseLimits - ограничения покупки товара одним пользователем (0 - без ограничения):
+// PerOrder - штук в одном заказе, PerMonth - за календарный месяц, PerUser - всего.
+// Штуки всех вариантов товара считаются вместе, отмененные заказы не считаются
+type PurchaseLimits struct {
+	PerUser  int `json:"per_user,omitempty"`
+	PerMonth int `json:"per_month,omitempty"`
+	PerOrder int `json:"per_order,omitempty"`
+}
+
+// Valid - лимиты не могут быть отрицательными
+func (l PurchaseLimits) Valid() bool {
+	return l.PerUser >= 0 && l.PerMonth >= 0 && l.PerOrder >= 0
+}
+
+// Check - проверяет заказ count штук товара пользователем, который уже купил
+// bought штук, из них boughtMonth - в текущем месяце
+func (l PurchaseLimits) Check(count, bought, boughtMonth int) error {
+	if l.PerOrder > 0 && count > l.PerOrder ||
+		l.PerUser > 0 && bought+count > l.PerUser ||
+		l.PerMonth > 0 && boughtMonth+count > l.PerMonth {
+		return ErrPurchaseLimitExceeded
+	}
+	return nil
+}
+
+// ByUser - есть ли лимиты, для которых нужны прошлые покупки пользователя
+func (l PurchaseLimits) ByUser() bool {
+	return l.PerUser > 0 || l.PerMonth > 0
+}
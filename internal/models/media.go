@@ -0,0 +1,32 @@
+package models
+
+// Ограничения загружаемых изображений товаров
+const (
+	MaxImageSize      = 5 << 20 // максимальный размер файла в байтах (5 МиБ)
+	MaxImageDimension = 4096    // максимальная ширина и высота в пикселях
+	ThumbnailSize     = 256     // миниатюра вписывается в квадрат ThumbnailSize x ThumbnailSize
+)
+
+// MediaPath - путь, по которому отдаются файлы хранилища (GET /media/*key)
+const MediaPath = "/media/"
+
+// Image - изображение товара. Key и ThumbKey - ключи оригинала и миниатюры
+// в хранилище файлов (см. entities.BlobStorage), клиенту отдаются их URL (см. SetURLs)
+type Image struct {
+	Id           int    `json:"id"`
+	MerchId      int    `json:"-"`
+	Key          string `json:"-"`
+	ThumbKey     string `json:"-"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// SetURLs - заполняет URL оригинала и миниатюры по их ключам
+func (i *Image) SetURLs() {
+	i.URL = MediaPath + i.Key
+	i.ThumbnailURL = MediaPath + i.ThumbKey
+}
@@ -6,25 +6,62 @@ import "time"
 // У серверного кода есть похожие структуры для обработки данных во входящих запросах
 // (см. handlers.go:TransactionRequest,LoginRequest)
 
+// Item - товар каталога. У товара с вариантами Stock в каталоге -
+// сумма остатков вариантов, продается он только по варианту.
+// Category - категория (пустая - без категории), Tags - свободные теги в нижнем регистре.
+// Limits - лимиты покупки товара одним пользователем.
+// Sold - число проданных штук без отмененных заказов, заполняется только в каталоге,
+// как и Images - изображения товара в порядке загрузки, и Sale - лучшая идущая распродажа
+// с ценой товара по ней (SalePrice)
 type Item struct {
-	Id    int    `json:"id"`
-	Name  string `json:"name"`
-	Price int    `json:"price"`
-	Stock int    `json:"stock"`
+	Id       int            `json:"id"`
+	Name     string         `json:"name"`
+	Price    int            `json:"price"`
+	Stock    int            `json:"stock"`
+	Category string         `json:"category,omitempty"`
+	Tags     []string       `json:"tags,omitempty"`
+	Limits   PurchaseLimits `json:"limits"`
+	Sold     int            `json:"sold,omitempty"`
+	Variants []*Variant     `json:"variants,omitempty"`
+	Images   []*Image       `json:"images,omitempty"`
+
+	Sale      *Sale `json:"sale,omitempty"`
+	SalePrice int   `json:"sale_price,omitempty"`
 }
 
+// CoinsEntry - изменение баланса в истории кошелька.
+// У списаний за заказ и возвратов заполнены Reason и OrderId,
+// у оплаты брони и возврата за нее - Reason и ReservationId,
+// у переводов - Reason, TransferId, повод и сообщение перевода,
+// у начислений из казны - Reason, GrantId и причина начисления в Message
 type CoinsEntry struct {
-	Id          int
-	Date        time.Time `json:"change_date"`
-	CoinsBefore int       `json:"coins_before"`
-	CoinsAfter  int       `json:"coins_after"`
+	Id            int
+	Date          time.Time    `json:"change_date"`
+	CoinsBefore   int          `json:"coins_before"`
+	CoinsAfter    int          `json:"coins_after"`
+	Reason        LedgerReason `json:"reason,omitempty"`
+	OrderId       int          `json:"order_id,omitempty"`
+	ReservationId int          `json:"reservation_id,omitempty"`
+	TransferId    int          `json:"transfer_id,omitempty"`
+	GrantId       int          `json:"grant_id,omitempty"`
+	TransferNote
 }
 
 type User struct {
-	Id       int
-	Coins    int
-	Login    string
-	Password string
+	Id        int
+	Coins     int
+	Login     string
+	Password  string
+	Role      Role
+	CreatedAt time.Time
+}
+
+// UserInfo - данные пользователя, которые можно показывать администратору
+type UserInfo struct {
+	Id    int    `json:"id"`
+	Login string `json:"login"`
+	Coins int    `json:"coins"`
+	Role  Role   `json:"role"`
 }
 
 type TransactionEntry struct {
@@ -32,13 +69,112 @@ type TransactionEntry struct {
 	SenderID   int
 	ReceiverID int
 	Amount     int
+	Date       time.Time
+	TransferNote
+	Reaction  TransferReaction
+	Reply     string
+	RepliedAt *time.Time
+}
+
+// TransferDirection - направление перевода относительно пользователя
+type TransferDirection string
+
+const (
+	TransferAll      TransferDirection = "all"
+	TransferSent     TransferDirection = "sent"
+	TransferReceived TransferDirection = "received"
+)
+
+// Valid - проверяет, что направление известно
+func (d TransferDirection) Valid() bool {
+	switch d {
+	case TransferAll, TransferSent, TransferReceived:
+		return true
+	}
+	return false
+}
+
+// TransferEntry - перевод в истории пользователя.
+// Counterpart - логин второй стороны перевода. Повод и сообщение задает отправитель,
+// реакцию и ответ (RepliedAt - время ответа) - получатель
+type TransferEntry struct {
+	Id          int               `json:"id"`
+	Direction   TransferDirection `json:"direction"`
+	Counterpart string            `json:"counterpart"`
+	Amount      int               `json:"amount"`
+	Date        time.Time         `json:"date"`
+	TransferNote
+	Reaction  TransferReaction `json:"reaction,omitempty"`
+	Reply     string           `json:"reply,omitempty"`
+	RepliedAt *time.Time       `json:"replied_at,omitempty"`
+}
+
+// TransferFilter - фильтр и страница истории переводов
+type TransferFilter struct {
+	Direction TransferDirection
+	PageQuery
 }
 
+// PurchaseEntry - строка заказа в истории покупок вместе с текущим статусом заказа.
+// Price - фактически уплаченная цена за штуку, ListPrice - цена без скидок,
+// PromoCode - промокод заказа, если он был
 type PurchaseEntry struct {
-	Id       int
-	ItemName string
-	Count    int
-	Date     time.Time
+	Id        int
+	OrderId   int
+	ItemName  string
+	Variant   string
+	Count     int
+	Price     int
+	ListPrice int
+	PromoCode string
+	Status    OrderStatus
+	Date      time.Time
+}
+
+type RefreshToken struct {
+	Id        string
+	FamilyId  string
+	UserId    int
+	Login     string
+	Used      bool
+	Revoked   bool
+	ExpiresAt time.Time
+}
+
+type Session struct {
+	Id        string    `json:"id"`
+	FamilyId  string    `json:"-"`
+	UserId    int       `json:"-"`
+	Login     string    `json:"login"`
+	Role      Role      `json:"-"` // Не хранится, берется у пользователя при выдаче токена
+	Revoked   bool      `json:"revoked"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MaxIdempotentBodySize - максимальный размер тела идемпотентного запроса,
+// если для пути не задан свой (см. handlers.Idempotent)
+const MaxIdempotentBodySize = 64 << 10
+
+// IdempotencyRecord - ключ идемпотентности запроса пользователя.
+// RequestHash защищает от повторного использования ключа с другим запросом.
+// Пока первый запрос выполняется, StatusCode равен 0, а Response пустой.
+// LockedUntil - срок аренды незавершенного ключа: после него ключ, брошенный
+// упавшим запросом, может занять повтор того же запроса
+type IdempotencyRecord struct {
+	Key         string
+	UserId      int
+	RequestHash string
+	StatusCode  int
+	Response    []byte // Сохраненное тело ответа (JSON handlers.GeneralResponse) байт в байт
+	CreatedAt   time.Time
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed - первый запрос с ключом завершен и его ответ сохранен
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
 }
 
 // Для хендлеров
@@ -48,12 +184,53 @@ type LoginRequest struct {
 	Password string `json:"pass"`
 }
 
+// PurchaseRequest - покупка товара или добавление его в корзину.
+// Variant - артикул варианта, обязателен для товаров с вариантами.
+// Promo - необязательный промокод, только для покупки
 type PurchaseRequest struct {
-	Item  string `json:"name"`
-	Count int    `json:"count"`
+	Item    string `json:"name"`
+	Variant string `json:"variant"`
+	Promo   string `json:"promo"`
+	Count   int    `json:"count"`
+}
+
+// MerchRequest - создание (POST) и полная замена (PUT) товара администратором
+type MerchRequest struct {
+	Name     string         `json:"name"`
+	Price    int            `json:"price"`
+	Stock    int            `json:"stock"`
+	Category string         `json:"category"`
+	Tags     []string       `json:"tags"`
+	Limits   PurchaseLimits `json:"limits"`
 }
 
+// MerchPatchRequest - частичное изменение товара (PATCH),
+// nil поля остаются без изменений
+type MerchPatchRequest struct {
+	Name     *string         `json:"name"`
+	Price    *int            `json:"price"`
+	Stock    *int            `json:"stock"`
+	Category *string         `json:"category"`
+	Tags     *[]string       `json:"tags"`
+	Limits   *PurchaseLimits `json:"limits"`
+}
+
+// CartItemRequest - новое количество товара в корзине (0 убирает товар)
+type CartItemRequest struct {
+	Count int `json:"count"`
+}
+
+// TransactionRequest - перевод монет. Повод category и сообщение message необязательны
 type TransactionRequest struct {
 	Reciever string `json:"reciever"`
 	Amount   int    `json:"amount"`
+	TransferNote
+}
+
+type RefreshRequest struct {
+	Refresh string `json:"refresh"`
+}
+
+type RoleRequest struct {
+	Role Role `json:"role"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// NotificationKind - вид уведомления
+type NotificationKind string
+
+const (
+	NotificationBackInStock NotificationKind = "back_in_stock" // мерч из списка желаний снова в наличии
+)
+
+// Notification - уведомление пользователя во внутреннем ящике.
+// Login и MerchName заполняются для рассылки внешним каналам (см. entities.Notifier).
+// ReadAt - время прочтения, nil у непрочитанных
+type Notification struct {
+	Id        int              `json:"id"`
+	UserId    int              `json:"-"`
+	Login     string           `json:"login,omitempty"`
+	Kind      NotificationKind `json:"kind"`
+	MerchId   int              `json:"merch_id"`
+	MerchName string           `json:"merch_name"`
+	CreatedAt time.Time        `json:"created_at"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+}
+
+// Cursor - позиция уведомления в ящике (от новых к старым)
+func (n *Notification) Cursor() *Cursor {
+	return &Cursor{Date: n.CreatedAt, Id: n.Id}
+}
+
+// WishlistItem - мерч в списке желаний пользователя с текущими ценой и остатком.
+// Notify - подписка на уведомление, когда мерч снова появится в наличии
+type WishlistItem struct {
+	MerchId int       `json:"merch_id"`
+	Name    string    `json:"name"`
+	Price   int       `json:"price"`
+	Stock   int       `json:"stock"`
+	Notify  bool      `json:"notify"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// WishlistRequest - добавление мерча в список желаний
+type WishlistRequest struct {
+	Notify bool `json:"notify"`
+}
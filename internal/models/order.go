@@ -0,0 +1,130 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// OrderStatus - статус выдачи заказа
+type OrderStatus string
+
+const (
+	OrderPlaced         OrderStatus = "placed"           // Оплачен, ждет обработки
+	OrderConfirmed      OrderStatus = "confirmed"        // Принят офис-менеджером
+	OrderReadyForPickup OrderStatus = "ready_for_pickup" // Можно забирать
+	OrderDelivered      OrderStatus = "delivered"        // Выдан
+	OrderCancelled      OrderStatus = "cancelled"        // Отменен
+)
+
+// orderFlow - порядок статусов при выдаче заказа
+var orderFlow = []OrderStatus{OrderPlaced, OrderConfirmed, OrderReadyForPickup, OrderDelivered}
+
+// Valid - проверяет, что статус существует
+func (s OrderStatus) Valid() bool {
+	return s == OrderCancelled || slices.Contains(orderFlow, s)
+}
+
+// Final - заказ выдан или отменен, его статус больше не меняется
+func (s OrderStatus) Final() bool {
+	return s == OrderDelivered || s == OrderCancelled
+}
+
+// CanAdvanceTo - можно ли продвинуть заказ из статуса s в next.
+// Заказ двигается только вперед по orderFlow, промежуточные статусы
+// можно пропустить (например, выдать сразу после оформления).
+// В OrderCancelled заказ переводит только отмена с возвратом монет (см. OrderService.CancelOrder)
+func (s OrderStatus) CanAdvanceTo(next OrderStatus) bool {
+	from, to := slices.Index(orderFlow, s), slices.Index(orderFlow, next)
+	return !s.Final() && from >= 0 && to > from
+}
+
+// Order - заказ пользователя: одна покупка или оформленная корзина.
+// Total списывается одной записью журнала (ReasonPurchase, ReferenceId - Id заказа).
+// Discount - сумма скидок по распродажам и промокоду PromoCode, Total уже их учитывает.
+// Login - логин покупателя, заполняется при чтении заказа из хранилища
+type Order struct {
+	Id        int          `json:"id"`
+	UserId    int          `json:"-"`
+	Login     string       `json:"login,omitempty"`
+	Status    OrderStatus  `json:"status"`
+	Total     int          `json:"total"`
+	Discount  int          `json:"discount,omitempty"`
+	PromoId   int          `json:"-"`
+	PromoCode string       `json:"promo_code,omitempty"`
+	Lines     []*OrderLine `json:"lines"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Cursor - позиция заказа в списке, заказы упорядочены по дате оформления
+func (o *Order) Cursor() *Cursor {
+	return &Cursor{Date: o.CreatedAt, Id: o.Id}
+}
+
+// OrderFilter - фильтр и страница списка заказов.
+// Нулевой UserId - заказы всех пользователей, пустой Status - в любом статусе
+type OrderFilter struct {
+	UserId int
+	Status OrderStatus
+	PageQuery
+}
+
+// OrderStatusRequest - запрос на смену статуса заказа
+type OrderStatusRequest struct {
+	Status OrderStatus `json:"status" binding:"required"`
+}
+
+// OrderLine - строка заказа (запись в merchshop.purchases).
+// Price - уплаченная цена за штуку на момент покупки, ListPrice - цена без скидок.
+// SaleId - распродажа, снизившая цену строки.
+// VariantId и Variant (артикул) заполнены, если куплен вариант товара
+type OrderLine struct {
+	Id        int    `json:"id"`
+	MerchId   int    `json:"merch_id"`
+	VariantId int    `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	Variant   string `json:"variant,omitempty"`
+	Price     int    `json:"price"`
+	ListPrice int    `json:"list_price"`
+	SaleId    int    `json:"sale_id,omitempty"`
+	Count     int    `json:"count"`
+}
+
+// CartLine - строка корзины с текущими именем и ценой товара или его варианта.
+// Если идет распродажа, Price - цена по ней, а ListPrice - цена без скидки.
+// VariantId и Variant (артикул) заполнены для варианта товара
+type CartLine struct {
+	MerchId   int    `json:"merch_id"`
+	VariantId int    `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	Variant   string `json:"variant,omitempty"`
+	Price     int    `json:"price"`
+	ListPrice int    `json:"list_price,omitempty"`
+	Count     int    `json:"count"`
+	Subtotal  int    `json:"subtotal"`
+}
+
+// Cart - корзина пользователя. Total считается по текущим ценам
+// и может измениться к моменту оформления
+type Cart struct {
+	Lines []*CartLine `json:"lines"`
+	Total int         `json:"total"`
+}
+
+// NewCart - собирает корзину из строк и считает суммы
+func NewCart(lines []*CartLine) *Cart {
+	cart := &Cart{Lines: make([]*CartLine, 0, len(lines))}
+	for _, line := range lines {
+		line.Subtotal = line.Price * line.Count
+		cart.Total += line.Subtotal
+		cart.Lines = append(cart.Lines, line)
+	}
+	return cart
+}
+
+// CheckoutResult - результат оформления заказа
+type CheckoutResult struct {
+	Order   *Order      `json:"order"`
+	Balance int         `json:"balance"`
+	Removed []*CartLine `json:"removed,omitempty"` // Строки удаленных из каталога товаров, убранные из корзины
+}
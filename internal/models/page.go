@@ -0,0 +1,144 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ограничения размера страницы в списках с пагинацией
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// Cursor - позиция в списке: дата и id последней записи страницы.
+// Клиенту передается в закодированном виде (см. Encode) и не разбирается им.
+// У списков без даты (каталог мерча) Date нулевая.
+// Key - значение ключа сортировки для списков с выбором порядка (см. MerchSort)
+type Cursor struct {
+	Date time.Time
+	Id   int
+	Key  string
+}
+
+// Encode - кодирует курсор в непрозрачную для клиента строку.
+// Дата хранится с точностью до микросекунд, как в PostgreSQL
+func (c *Cursor) Encode() string {
+	var micros int64
+	if !c.Date.IsZero() {
+		micros = c.Date.UnixMicro()
+	}
+	raw := fmt.Sprintf("%d:%d", micros, c.Id)
+	if c.Key != "" {
+		raw += ":" + c.Key
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor - разбирает строку, полученную из Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Ключ сортировки может сам содержать ':', поэтому он всегда последний
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) < 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{Id: id}
+	if len(parts) == 3 {
+		cursor.Key = parts[2]
+	}
+	if micros != 0 {
+		// Даты в БД хранятся без часового пояса, pgx возвращает их в UTC
+		cursor.Date = time.UnixMicro(micros).UTC()
+	}
+	return cursor, nil
+}
+
+// PageQuery - запрос страницы списка.
+// After - курсор последней записи предыдущей страницы (nil - первая страница).
+// Нулевые From и To не ограничивают период, From включительно, To - нет
+type PageQuery struct {
+	Limit int
+	After *Cursor
+	From  time.Time
+	To    time.Time
+}
+
+// Normalize - подставляет размер страницы по умолчанию
+// и проверяет ограничения запроса
+func (q *PageQuery) Normalize() error {
+	if q.Limit == 0 {
+		q.Limit = DefaultPageLimit
+	}
+
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return ErrInvalidLimit
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return ErrInvalidDateRange
+	}
+
+	return nil
+}
+
+// Page - страница списка. NextCursor пустой на последней странице
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage - собирает страницу из не более чем limit+1 записей:
+// лишняя запись означает, что есть следующая страница,
+// курсор которой строится по последней оставшейся записи
+func NewPage[T any](items []T, limit int, cursor func(T) *Cursor) *Page[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = cursor(page.Items[limit-1]).Encode()
+	}
+
+	return page
+}
+
+// Cursor - позиция записи истории кошелька
+func (e *CoinsEntry) Cursor() *Cursor {
+	return &Cursor{Date: e.Date, Id: e.Id}
+}
+
+// Cursor - позиция записи истории покупок
+func (e *PurchaseEntry) Cursor() *Cursor {
+	return &Cursor{Date: e.Date, Id: e.Id}
+}
+
+// Cursor - позиция записи истории переводов
+func (e *TransferEntry) Cursor() *Cursor {
+	return &Cursor{Date: e.Date, Id: e.Id}
+}
+
+// Cursor - позиция товара в каталоге, упорядоченном по id
+// (для других порядков см. MerchSort.Cursor)
+func (i *Item) Cursor() *Cursor {
+	return &Cursor{Id: i.Id}
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DiscountKind - вид скидки
+type DiscountKind string
+
+const (
+	DiscountPercent DiscountKind = "percent" // процент от цены, от 1 до 100
+	DiscountFixed   DiscountKind = "fixed"   // фиксированное число монет
+)
+
+// Discount - скидка на каждую штуку товара
+type Discount struct {
+	Kind  DiscountKind `json:"kind"`
+	Value int          `json:"value"`
+}
+
+// Valid - проверяет вид и размер скидки
+func (d Discount) Valid() bool {
+	switch d.Kind {
+	case DiscountPercent:
+		return d.Value > 0 && d.Value <= 100
+	case DiscountFixed:
+		return d.Value > 0
+	}
+	return false
+}
+
+// Apply - цена штуки после скидки. Процент скидки округляется вниз,
+// фиксированная скидка не делает цену отрицательной
+func (d Discount) Apply(price int) int {
+	switch d.Kind {
+	case DiscountPercent:
+		return price - price*d.Value/100
+	case DiscountFixed:
+		return max(0, price-d.Value)
+	}
+	return price
+}
+
+// PromoCode - промокод. Нулевой MerchId - скидка на весь каталог, иначе только на этот товар.
+// StartsAt и EndsAt ограничивают срок действия (nil - без ограничения, EndsAt не включается).
+// MaxUses и MaxUsesPerUser - лимиты заказов с промокодом всего и на пользователя (0 - без лимита),
+// отмененные заказы не считаются. Uses заполняется только в списке промокодов
+type PromoCode struct {
+	Id             int        `json:"id"`
+	Code           string     `json:"code"`
+	Discount                  // вид и размер скидки
+	MerchId        int        `json:"merch_id,omitempty"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	MaxUses        int        `json:"max_uses,omitempty"`
+	MaxUsesPerUser int        `json:"max_uses_per_user,omitempty"`
+	Uses           int        `json:"uses"`
+}
+
+// ActiveAt - действует ли промокод в момент t
+func (p *PromoCode) ActiveAt(t time.Time) bool {
+	return (p.StartsAt == nil || !t.Before(*p.StartsAt)) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// AppliesTo - распространяется ли промокод на товар merchID
+func (p *PromoCode) AppliesTo(merchID int) bool {
+	return p.MerchId == 0 || p.MerchId == merchID
+}
+
+// Sale - распродажа: скидка без промокода на время [StartsAt, EndsAt).
+// Нулевой MerchId - скидка на весь каталог, иначе только на этот товар
+type Sale struct {
+	Id       int       `json:"id"`
+	Name     string    `json:"name"`
+	Discount           // вид и размер скидки
+	MerchId  int       `json:"merch_id,omitempty"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// ActiveAt - идет ли распродажа в момент t
+func (s *Sale) ActiveAt(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// AppliesTo - распространяется ли распродажа на товар merchID
+func (s *Sale) AppliesTo(merchID int) bool {
+	return s.MerchId == 0 || s.MerchId == merchID
+}
+
+// BestSale - распродажа из sales, дающая товару merchID с ценой price наименьшую цену,
+// и эта цена. Если ни одна распродажа не снижает цену, возвращает nil и price
+func BestSale(sales []*Sale, merchID, price int) (*Sale, int) {
+	var best *Sale
+	bestPrice := price
+	for _, sale := range sales {
+		if !sale.AppliesTo(merchID) {
+			continue
+		}
+		if salePrice := sale.Apply(price); salePrice < bestPrice {
+			best, bestPrice = sale, salePrice
+		}
+	}
+	return best, bestPrice
+}
+
+// NormalizePromoCode - промокод в том виде, в котором он хранится:
+// верхний регистр без пробелов по краям
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromoCodeRequest - создание промокода администратором
+type PromoCodeRequest struct {
+	Code           string       `json:"code"`
+	Kind           DiscountKind `json:"kind"`
+	Value          int          `json:"value"`
+	MerchId        int          `json:"merch_id"`
+	StartsAt       *time.Time   `json:"starts_at"`
+	EndsAt         *time.Time   `json:"ends_at"`
+	MaxUses        int          `json:"max_uses"`
+	MaxUsesPerUser int          `json:"max_uses_per_user"`
+}
+
+// SaleRequest - создание распродажи администратором
+type SaleRequest struct {
+	Name     string       `json:"name"`
+	Kind     DiscountKind `json:"kind"`
+	Value    int          `json:"value"`
+	MerchId  int          `json:"merch_id"`
+	StartsAt time.Time    `json:"starts_at"`
+	EndsAt   time.Time    `json:"ends_at"`
+}
+
+// CheckoutRequest - необязательное тело оформления корзины
+type CheckoutRequest struct {
+	Promo string `json:"promo"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// ReservationKind - вид брони
+type ReservationKind string
+
+const (
+	ReservationHold     ReservationKind = "hold"     // Удержание товара, который есть в наличии
+	ReservationPreorder ReservationKind = "preorder" // Предзаказ товара, которого еще нет на складе
+)
+
+// ReservationStatus - статус брони
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"    // Держит товар и монеты
+	ReservationConfirmed ReservationStatus = "confirmed" // Превращена в заказ OrderId
+	ReservationCancelled ReservationStatus = "cancelled" // Отменена пользователем, монеты возвращены
+	ReservationExpired   ReservationStatus = "expired"   // Истекла, монеты возвращены
+)
+
+// Reservation - бронь Count штук мерча (варианта) пользователем до ExpiresAt.
+// Активная бронь уменьшает доступный остаток (склад минус брони), но не склад:
+// товар списывается со склада только при подтверждении брони заказом.
+// Total = Price * Count списывается с пользователя при бронировании и держится
+// на счете AccountReservations до подтверждения или возврата.
+// Price - цена за штуку на момент брони с учетом распродажи SaleId, ListPrice - без скидок
+type Reservation struct {
+	Id        int               `json:"id"`
+	UserId    int               `json:"-"`
+	Kind      ReservationKind   `json:"kind"`
+	Status    ReservationStatus `json:"status"`
+	MerchId   int               `json:"merch_id"`
+	Name      string            `json:"name"`
+	VariantId int               `json:"-"`
+	Variant   string            `json:"variant,omitempty"`
+	Count     int               `json:"count"`
+	Price     int               `json:"price"`
+	ListPrice int               `json:"list_price"`
+	SaleId    int               `json:"sale_id,omitempty"`
+	Total     int               `json:"total"`
+	OrderId   int               `json:"order_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// ActiveAt - бронь еще держит товар и монеты в момент at.
+// Истекшая бронь перестает держать товар сразу, не дожидаясь очистки
+func (r *Reservation) ActiveAt(at time.Time) bool {
+	return r.Status == ReservationActive && at.Before(r.ExpiresAt)
+}
+
+// Cursor - позиция брони в списке, брони упорядочены по дате создания
+func (r *Reservation) Cursor() *Cursor {
+	return &Cursor{Date: r.CreatedAt, Id: r.Id}
+}
+
+// ReservationRequest - запрос на бронь товара Item (варианта Variant).
+// Preorder - предзаказ: товара может не быть на складе
+type ReservationRequest struct {
+	Item     string `json:"name"`
+	Variant  string `json:"variant"`
+	Count    int    `json:"count"`
+	Preorder bool   `json:"preorder"`
+}
+
+// Reserved - штуки мерча (варианта) в активных бронях
+type Reserved struct {
+	Holds     int
+	Preorders int
+}
+
+// Total - все забронированные штуки
+func (r Reserved) Total() int {
+	return r.Holds + r.Preorders
+}
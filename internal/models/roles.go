@@ -0,0 +1,59 @@
+package models
+
+// Role - роль пользователя, хранится в merchshop.users.role
+// и передается в токене авторизации (claim "role")
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Permission - право на действие, которым ограничиваются пути API
+// (см. handlers.RequirePermission)
+type Permission string
+
+const (
+	PermMerchWrite      Permission = "merch:write"
+	PermCoinsGrant      Permission = "coins:grant"
+	PermUsersRead       Permission = "users:read"
+	PermUsersWrite      Permission = "users:write"
+	PermSessionsManage  Permission = "sessions:manage"
+	PermLedgerRead      Permission = "ledger:read"
+	PermOrdersManage    Permission = "orders:manage"
+	PermPromosManage    Permission = "promos:manage"
+	PermTransfersReview Permission = "transfers:review"
+)
+
+// rolePermissions - права каждой роли.
+// Обычному пользователю доступны только пути без RequirePermission
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {},
+	RoleAdmin: {
+		PermMerchWrite,
+		PermCoinsGrant,
+		PermUsersRead,
+		PermUsersWrite,
+		PermSessionsManage,
+		PermLedgerRead,
+		PermOrdersManage,
+		PermPromosManage,
+		PermTransfersReview,
+	},
+}
+
+// Valid - проверяет, что роль существует
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can - проверяет, есть ли у роли право perm
+func (r Role) Can(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package models
+
+import "time"
+
+// ScheduleRule - правило повтора запланированного перевода
+type ScheduleRule string
+
+const (
+	ScheduleOnce    ScheduleRule = "once"    // Один раз в StartAt
+	ScheduleWeekly  ScheduleRule = "weekly"  // Каждую неделю в день недели и время StartAt
+	ScheduleMonthly ScheduleRule = "monthly" // Каждый месяц в число и время StartAt
+)
+
+// Valid - проверяет, что правило известно
+func (r ScheduleRule) Valid() bool {
+	switch r {
+	case ScheduleOnce, ScheduleWeekly, ScheduleMonthly:
+		return true
+	}
+	return false
+}
+
+// Next - первое срабатывание правила с началом start строго позже after.
+// false - срабатываний больше не будет. Ежемесячный перевод с 29-31 числом
+// в коротком месяце выполняется в последний день месяца. Время считается в UTC
+func (r ScheduleRule) Next(start, after time.Time) (time.Time, bool) {
+	start, after = start.UTC(), after.UTC()
+
+	switch r {
+	case ScheduleOnce:
+		return start, start.After(after)
+	case ScheduleWeekly:
+		const week = 7 * 24 * time.Hour
+		if start.After(after) {
+			return start, true
+		}
+		return start.Add((after.Sub(start)/week + 1) * week), true
+	case ScheduleMonthly:
+		// Начинаем с месяца перед after: в нем срабатывание еще может быть позже after
+		months := (after.Year()-start.Year())*12 + int(after.Month()-start.Month()) - 1
+		for months = max(months, 0); ; months++ {
+			if next := monthlyAt(start, months); next.After(after) {
+				return next, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// monthlyAt - срабатывание ежемесячного правила с началом start через months месяцев
+func monthlyAt(start time.Time, months int) time.Time {
+	first := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	return time.Date(first.Year(), first.Month(), min(start.Day(), lastDay),
+		start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+}
+
+// ScheduleStatus - статус запланированного перевода
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "active"    // Ждет следующего срабатывания NextRunAt
+	ScheduleCompleted ScheduleStatus = "completed" // Разовый перевод выполнен или пропущен
+	ScheduleCancelled ScheduleStatus = "cancelled" // Отменен отправителем
+)
+
+// ScheduleRunStatus - итог срабатывания запланированного перевода
+type ScheduleRunStatus string
+
+const (
+	ScheduleRunExecuted ScheduleRunStatus = "executed" // Перевод TransferId выполнен
+	ScheduleRunSkipped  ScheduleRunStatus = "skipped"  // Перевод пропущен по причине Reason
+)
+
+// ScheduleSkipReason - причина пропуска срабатывания
+type ScheduleSkipReason string
+
+const (
+	SkipNotEnoughCoins   ScheduleSkipReason = "not_enough_coins"   // У отправителя не хватило монет
+	SkipReceiverNotFound ScheduleSkipReason = "receiver_not_found" // Получателя больше нет
+	SkipTransferLimit    ScheduleSkipReason = "transfer_limit"     // Перевод не прошел лимиты отправителя
+	SkipSuspicious       ScheduleSkipReason = "suspicious"         // Перевод отклонен как подозрительный
+)
+
+// ScheduledTransfer - перевод Amount монет от SenderId к ReceiverId по правилу Rule,
+// начиная со StartAt. NextRunAt - время следующего срабатывания, у завершенного
+// и отмененного перевода его нет. LastRun - итог последнего срабатывания
+type ScheduledTransfer struct {
+	Id         int    `json:"id"`
+	SenderId   int    `json:"-"`
+	ReceiverId int    `json:"-"`
+	Receiver   string `json:"reciever"`
+	Amount     int    `json:"amount"`
+	TransferNote
+	Rule      ScheduleRule   `json:"rule"`
+	Status    ScheduleStatus `json:"status"`
+	StartAt   time.Time      `json:"start_at"`
+	NextRunAt *time.Time     `json:"next_run_at,omitempty"`
+	LastRun   *ScheduleRun   `json:"last_run,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Cursor - позиция перевода в списке, переводы упорядочены по дате создания
+func (s *ScheduledTransfer) Cursor() *Cursor {
+	return &Cursor{Date: s.CreatedAt, Id: s.Id}
+}
+
+// ScheduleRun - срабатывание запланированного перевода, назначенное на ScheduledFor.
+// Для каждого ScheduledFor срабатывание записывается не больше одного раза
+type ScheduleRun struct {
+	Id           int                `json:"id"`
+	ScheduleId   int                `json:"-"`
+	ScheduledFor time.Time          `json:"scheduled_for"`
+	Status       ScheduleRunStatus  `json:"status"`
+	Reason       ScheduleSkipReason `json:"reason,omitempty"`
+	TransferId   int                `json:"transfer_id,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+// Cursor - позиция срабатывания в списке, срабатывания упорядочены по назначенному времени
+func (r *ScheduleRun) Cursor() *Cursor {
+	return &Cursor{Date: r.ScheduledFor, Id: r.Id}
+}
+
+// ScheduleRequest - запрос на перевод Amount монет пользователю Reciever
+// по правилу Rule, начиная со StartAt
+type ScheduleRequest struct {
+	Reciever string `json:"reciever"`
+	Amount   int    `json:"amount"`
+	TransferNote
+	Rule    ScheduleRule `json:"rule"`
+	StartAt time.Time    `json:"start_at"`
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTransferMessageLen - максимальная длина сообщения к переводу и ответа на него в символах
+const MaxTransferMessageLen = 280
+
+// TransferCategory - повод перевода монет
+type TransferCategory string
+
+const (
+	TransferNoCategory  TransferCategory = ""             // Без повода
+	TransferThanks      TransferCategory = "thanks"       // Благодарность
+	TransferQuestReward TransferCategory = "quest_reward" // Награда за задание
+	TransferBet         TransferCategory = "bet"          // Выигранный спор
+	TransferGift        TransferCategory = "gift"         // Подарок
+)
+
+// Valid - проверяет, что повод известен. Пустой повод допустим
+func (c TransferCategory) Valid() bool {
+	switch c {
+	case TransferNoCategory, TransferThanks, TransferQuestReward, TransferBet, TransferGift:
+		return true
+	}
+	return false
+}
+
+// TransferReaction - реакция получателя на перевод
+type TransferReaction string
+
+const (
+	ReactionNone  TransferReaction = ""      // Реакции нет (снимает реакцию)
+	ReactionLike  TransferReaction = "like"  // 👍
+	ReactionHeart TransferReaction = "heart" // ❤️
+	ReactionParty TransferReaction = "party" // 🎉
+	ReactionLaugh TransferReaction = "laugh" // 😂
+	ReactionWow   TransferReaction = "wow"   // 😮
+)
+
+// Valid - проверяет, что реакция известна. Пустая реакция допустима
+func (r TransferReaction) Valid() bool {
+	switch r {
+	case ReactionNone, ReactionLike, ReactionHeart, ReactionParty, ReactionLaugh, ReactionWow:
+		return true
+	}
+	return false
+}
+
+// TransferNote - сообщение и повод перевода, задаются отправителем
+type TransferNote struct {
+	Category TransferCategory `json:"category,omitempty"`
+	Message  string           `json:"message,omitempty"`
+}
+
+// Normalize - проверяет повод и очищает сообщение (см. SanitizeMessage)
+func (n *TransferNote) Normalize() error {
+	if !n.Category.Valid() {
+		return ErrInvalidTransferCategory
+	}
+
+	message, err := SanitizeMessage(n.Message)
+	if err != nil {
+		return err
+	}
+	n.Message = message
+	return nil
+}
+
+// SanitizeMessage - очищает текст сообщения: убирает управляющие и невидимые
+// символы форматирования (кроме перевода строки), схлопывает пустые строки
+// и пробелы по краям. Длина очищенного текста не больше MaxTransferMessageLen символов.
+// HTML не экранируется: ответы API - JSON, экранировать при выводе должен клиент
+func SanitizeMessage(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", ErrInvalidTransferMessage
+	}
+
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, s)
+
+	lines := strings.Split(s, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" && (len(kept) == 0 || kept[len(kept)-1] == "") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	s = strings.TrimSpace(strings.Join(kept, "\n"))
+
+	if utf8.RuneCountInString(s) > MaxTransferMessageLen {
+		return "", ErrTransferMessageTooLong
+	}
+	return s, nil
+}
+
+// TransferReactionRequest - реакция получателя на перевод
+type TransferReactionRequest struct {
+	Reaction TransferReaction `json:"reaction"`
+}
+
+// TransferReplyRequest - ответ получателя на перевод
+type TransferReplyRequest struct {
+	Message string `json:"message"`
+}
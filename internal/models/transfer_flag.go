@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// TransferFlagReason - почему перевод показался подозрительным (см. TransferLimits)
+type TransferFlagReason string
+
+const (
+	FlagCircular       TransferFlagReason = "circular"        // Монеты вернулись отправителю по цепочке переводов
+	FlagSmallTransfers TransferFlagReason = "small_transfers" // Много мелких переводов одному получателю
+)
+
+// TransferFlagStatus - статус проверки подозрительного перевода
+type TransferFlagStatus string
+
+const (
+	FlagOpen      TransferFlagStatus = "open"      // Ждет проверки администратором
+	FlagDismissed TransferFlagStatus = "dismissed" // Перевод признан обычным
+	FlagConfirmed TransferFlagStatus = "confirmed" // Перевод признан мошенническим
+)
+
+// Valid - проверяет, что статус известен
+func (s TransferFlagStatus) Valid() bool {
+	switch s {
+	case FlagOpen, FlagDismissed, FlagConfirmed:
+		return true
+	}
+	return false
+}
+
+// TransferFlag - пометка выполненного перевода TransferId для проверки администратором.
+// Сам перевод пометка не отменяет
+type TransferFlag struct {
+	Id         int                  `json:"id"`
+	TransferId int                  `json:"transfer_id"`
+	SenderId   int                  `json:"-"`
+	Sender     string               `json:"sender"`
+	ReceiverId int                  `json:"-"`
+	Receiver   string               `json:"receiver"`
+	Amount     int                  `json:"amount"`
+	Reasons    []TransferFlagReason `json:"reasons"`
+	Status     TransferFlagStatus   `json:"status"`
+	ReviewerId int                  `json:"-"`
+	Reviewer   string               `json:"reviewer,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	ReviewedAt *time.Time           `json:"reviewed_at,omitempty"`
+}
+
+// Cursor - позиция пометки в списке, пометки упорядочены по дате создания
+func (f *TransferFlag) Cursor() *Cursor {
+	return &Cursor{Date: f.CreatedAt, Id: f.Id}
+}
+
+// TransferFlagFilter - фильтр и страница пометок. Status - только пометки
+// в этом статусе (пустой - все)
+type TransferFlagFilter struct {
+	Status TransferFlagStatus
+	PageQuery
+}
+
+// ReviewFlagRequest - решение администратора по пометке: dismissed или confirmed
+type ReviewFlagRequest struct {
+	Status TransferFlagStatus `json:"status"`
+}
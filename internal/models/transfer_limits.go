@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxCircularDepth - сколько переводов может быть в цепочке, по которой монеты
+// возвращаются отправителю, чтобы перевод считался круговым
+const MaxCircularDepth = 3
+
+// TransferLimits - ограничения исходящих переводов одного пользователя
+// (секция transferlimits конфига, 0 - без ограничения). Сумма за сутки и за неделю
+// считается по скользящему окну вместе с проверяемым переводом.
+//
+// Подозрительные переводы - круговые (получатель за CircularWindow уже вернул монеты
+// отправителю цепочкой до MaxCircularDepth переводов) и SmallCount-й за сутки перевод
+// не больше SmallAmount монет тому же получателю. Они помечаются для проверки
+// администратором, а с BlockSuspicious - отклоняются
+type TransferLimits struct {
+	MaxAmount          int   `yaml:"maxamount"`          // Монет в одном переводе
+	Daily              int   `yaml:"daily"`              // Монет за последние сутки
+	Weekly             int   `yaml:"weekly"`             // Монет за последние 7 дней
+	NewAccountCooldown int64 `yaml:"newaccountcooldown"` // Секунд после регистрации без исходящих переводов
+
+	CircularWindow  int64 `yaml:"circularwindow"`  // Секунд, за которые ищутся круговые цепочки
+	SmallAmount     int   `yaml:"smallamount"`     // Перевод не больше SmallAmount монет - мелкий
+	SmallCount      int   `yaml:"smallcount"`      // Мелких переводов одному получателю за сутки
+	BlockSuspicious bool  `yaml:"blocksuspicious"` // Отклонять подозрительные переводы, а не помечать
+}
+
+// Check - проверяет перевод amount монет пользователем, зарегистрированным в created,
+// который уже отправил sent монет за сутки и за неделю (без этого перевода)
+func (l TransferLimits) Check(amount int, created, at time.Time, sent TransferVelocity) error {
+	switch {
+	case l.NewAccountCooldown > 0 && at.Before(created.Add(time.Duration(l.NewAccountCooldown)*time.Second)):
+		return ErrNewAccountCooldown
+	case l.MaxAmount > 0 && amount > l.MaxAmount:
+		return ErrTransferTooLarge
+	case l.Daily > 0 && sent.Daily+amount > l.Daily:
+		return ErrDailyTransferLimit
+	case l.Weekly > 0 && sent.Weekly+amount > l.Weekly:
+		return ErrWeeklyTransferLimit
+	}
+	return nil
+}
+
+// Small - считаются ли мелкие переводы одному получателю
+func (l TransferLimits) Small() bool {
+	return l.SmallAmount > 0 && l.SmallCount > 0
+}
+
+// TransferVelocity - исходящие переводы пользователя перед новым переводом
+type TransferVelocity struct {
+	Daily           int // Монет за последние сутки
+	Weekly          int // Монет за последние 7 дней
+	SmallToReceiver int // Мелких переводов тому же получателю за сутки
+}
+
+// IsTransferLimitError - отклонен ли перевод лимитами или как подозрительный (см. TransferLimits)
+func IsTransferLimitError(err error) bool {
+	return errors.Is(err, ErrNewAccountCooldown) ||
+		errors.Is(err, ErrTransferTooLarge) ||
+		errors.Is(err, ErrDailyTransferLimit) ||
+		errors.Is(err, ErrWeeklyTransferLimit) ||
+		errors.Is(err, ErrSuspiciousTransfer)
+}
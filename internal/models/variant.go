@@ -0,0 +1,28 @@
+package models
+
+// Variant - вариант товара (например, размер или цвет) со своим остатком.
+// Price - цена варианта, nil - продается по цене товара
+type Variant struct {
+	Id         int               `json:"id"`
+	MerchId    int               `json:"-"`
+	Sku        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *int              `json:"price,omitempty"`
+	Stock      int               `json:"stock"`
+}
+
+// PriceFor - цена варианта товара item
+func (v *Variant) PriceFor(item *Item) int {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return item.Price
+}
+
+// VariantRequest - создание (POST) и замена (PUT) варианта товара администратором
+type VariantRequest struct {
+	Sku        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *int              `json:"price"`
+	Stock      int               `json:"stock"`
+}
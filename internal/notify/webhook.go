@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+)
+
+var _ entities.Notifier = (*Webhook)(nil)
+
+// webhookTimeout - время на доставку одного запроса вебхука
+const webhookTimeout = 5 * time.Second
+
+// Webhook реализует Notifier: отправляет уведомления POST запросом с JSON телом
+// {"notifications": [...]} на заданный URL. У каждого уведомления есть
+// idempotency_key, постоянный для уведомления: по нему получатель отбрасывает
+// повторы, если уведомление отправлено еще раз
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// webhookNotification - уведомление в теле запроса вебхука
+type webhookNotification struct {
+	*models.Notification
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// NewWebhook создает вебхук, отправляющий уведомления на url
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Notify отправляет уведомления одним запросом. Ответ не 2xx считается ошибкой
+func (w *Webhook) Notify(ctx context.Context, notes []*models.Notification) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	items := make([]webhookNotification, 0, len(notes))
+	for _, note := range notes {
+		items = append(items, webhookNotification{
+			Notification:   note,
+			IdempotencyKey: fmt.Sprintf("notification-%d", note.Id),
+		})
+	}
+
+	body, err := json.Marshal(map[string]any{"notifications": items})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("вебхук ответил %s", resp.Status)
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"merch_service/configs"
 	"merch_service/internal/handlers"
+	"merch_service/internal/models"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -21,15 +22,29 @@ import (
 //   - UserHandler
 //   - MerchHandler
 //   - TransactionHandler
+//   - AuthHandler
+//   - LedgerHandler
+//   - IdempotencyHandler
+//   - OrderHandler
+//   - MediaHandler
+//   - PromoHandler
+//   - NotificationHandler
 //
 // для обработки соответстующих API запросов
 type MerchServer struct {
 	http   *http.Server
 	config *configs.ServerConfig
 
-	uHandler *handlers.UserHandler
-	mHandler *handlers.MerchHandler
-	tHandler *handlers.TransactionHandler
+	uHandler  *handlers.UserHandler
+	mHandler  *handlers.MerchHandler
+	tHandler  *handlers.TransactionHandler
+	aHandler  *handlers.AuthHandler
+	lHandler  *handlers.LedgerHandler
+	iHandler  *handlers.IdempotencyHandler
+	oHandler  *handlers.OrderHandler
+	mdHandler *handlers.MediaHandler
+	pHandler  *handlers.PromoHandler
+	nHandler  *handlers.NotificationHandler
 }
 
 func (serv *MerchServer) loadConfig(configPath string) {
@@ -49,19 +64,35 @@ func (serv *MerchServer) loadConfig(configPath string) {
 		log.Fatalln("ошибка при разборе yaml:", err.Error())
 	}
 
+	if pass := os.Getenv(configs.AdminPasswordEnv); pass != "" {
+		sc.Admin.Password = pass
+	}
+
 	serv.config = &sc
 }
 
-func NewMerchServer(u *handlers.UserHandler, t *handlers.TransactionHandler, m *handlers.MerchHandler, configPath string) *MerchServer {
+// Config - возвращает конфигурацию сервера (загружается в NewMerchServer)
+func (serv *MerchServer) Config() *configs.ServerConfig {
+	return serv.config
+}
+
+func NewMerchServer(u *handlers.UserHandler, t *handlers.TransactionHandler, m *handlers.MerchHandler, a *handlers.AuthHandler, l *handlers.LedgerHandler, i *handlers.IdempotencyHandler, o *handlers.OrderHandler, md *handlers.MediaHandler, p *handlers.PromoHandler, n *handlers.NotificationHandler, configPath string) *MerchServer {
 	router := gin.Default()
 
 	newServ := MerchServer{
 		http: &http.Server{
 			Handler: router,
 		},
-		uHandler: u,
-		tHandler: t,
-		mHandler: m,
+		uHandler:  u,
+		tHandler:  t,
+		mHandler:  m,
+		aHandler:  a,
+		lHandler:  l,
+		iHandler:  i,
+		oHandler:  o,
+		mdHandler: md,
+		pHandler:  p,
+		nHandler:  n,
 	}
 
 	// Хардоженые пути, сорян =(
@@ -79,18 +110,102 @@ func (serv *MerchServer) SetupRoutes() {
 	// --- Публичные пути START --- //
 	router.POST("/auth/register", serv.uHandler.RegHandler())
 	router.POST("/auth/login", serv.uHandler.LoginHandler(serv.config))
+	router.POST("/auth/refresh", serv.aHandler.RefreshHandler(serv.config))
+	// Изображения товаров открываются тегом <img>, без заголовка авторизации
+	router.GET("/media/*key", serv.mdHandler.MediaFileHandler)
 	// --- Публичные пути END --- //
 
 	// --- Приватные пути START --- //
 	// AuthRequired применяется только к путям в данной группе
 	authorized := router.Group("/")
-	authorized.Use(handlers.AuthRequired(serv.config))
+	authorized.Use(serv.aHandler.AuthRequired(serv.config))
 	{
+		authorized.POST("/auth/logout", serv.aHandler.LogoutHandler)
+		authorized.POST("/auth/logout-all", serv.aHandler.LogoutAllHandler)
 		authorized.GET("/merch", serv.mHandler.MerchListHandler)
-		authorized.POST("/merch/buy", serv.mHandler.BuyMerchHandler)
+		authorized.POST("/merch/buy", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.mHandler.BuyMerchHandler)
 		authorized.GET("/history/coins", serv.uHandler.CoinsHistoryHandler)
 		authorized.GET("/history/purchase", serv.uHandler.PurchaseHistoryHandler)
-		authorized.POST("/coins/transfer", serv.tHandler.TransferHandler)
+		authorized.GET("/history/transfer", serv.uHandler.TransferHistoryHandler)
+		authorized.POST("/coins/transfer", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.tHandler.TransferHandler)
+		authorized.PUT("/coins/transfer/:id/reaction", serv.tHandler.ReactHandler)
+		authorized.POST("/coins/transfer/:id/reply", serv.tHandler.ReplyHandler)
+		authorized.GET("/coins/schedules", serv.tHandler.SchedulesHandler)
+		authorized.POST("/coins/schedules", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.tHandler.ScheduleHandler)
+		authorized.GET("/coins/schedules/:id/runs", serv.tHandler.ScheduleRunsHandler)
+		authorized.POST("/coins/schedules/:id/cancel", serv.tHandler.CancelScheduleHandler)
+		authorized.GET("/coins/requests", serv.tHandler.CoinRequestsHandler)
+		authorized.POST("/coins/requests", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.tHandler.RequestCoinsHandler(serv.config))
+		authorized.GET("/coins/requests/:id", serv.tHandler.CoinRequestHandler)
+		authorized.POST("/coins/requests/:id/accept", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.tHandler.AcceptCoinRequestHandler)
+		authorized.POST("/coins/requests/:id/decline", serv.tHandler.DeclineCoinRequestHandler)
+
+		authorized.GET("/cart", serv.mHandler.CartHandler)
+		authorized.POST("/cart/items", serv.mHandler.AddToCartHandler)
+		authorized.PUT("/cart/items/:id", serv.mHandler.SetCartItemHandler)
+		authorized.DELETE("/cart/items/:id", serv.mHandler.RemoveFromCartHandler)
+		authorized.POST("/cart/checkout", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.mHandler.CheckoutHandler)
+
+		authorized.GET("/reservations", serv.mHandler.ReservationsHandler)
+		authorized.POST("/reservations", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.mHandler.ReserveHandler(serv.config))
+		authorized.POST("/reservations/:id/confirm", serv.iHandler.Idempotent(serv.config, models.MaxIdempotentBodySize), serv.mHandler.ConfirmReservationHandler)
+		authorized.POST("/reservations/:id/cancel", serv.mHandler.CancelReservationHandler)
+
+		authorized.GET("/orders", serv.oHandler.OrdersHandler)
+		authorized.GET("/orders/:id", serv.oHandler.OrderByIDHandler)
+		authorized.POST("/orders/:id/cancel", serv.oHandler.CancelOrderHandler)
+
+		authorized.GET("/wishlist", serv.nHandler.WishlistHandler)
+		authorized.PUT("/wishlist/:id", serv.nHandler.AddToWishlistHandler)
+		authorized.DELETE("/wishlist/:id", serv.nHandler.RemoveFromWishlistHandler)
+		authorized.GET("/notifications", serv.nHandler.NotificationsHandler)
+		authorized.POST("/notifications/:id/read", serv.nHandler.ReadNotificationHandler)
+	}
+
+	// Пути администратора. Каждый путь требует своего права (см. models.Permission),
+	// RequirePermission применяется после AuthRequired
+	admin := authorized.Group("/admin")
+	{
+		admin.GET("/users", handlers.RequirePermission(models.PermUsersRead), serv.uHandler.UsersHandler)
+		admin.PUT("/users/:login/role", handlers.RequirePermission(models.PermUsersWrite), serv.uHandler.SetRoleHandler)
+
+		sessions := admin.Group("/users/:login/sessions", handlers.RequirePermission(models.PermSessionsManage))
+		sessions.GET("", serv.aHandler.SessionsHandler)
+		sessions.DELETE("", serv.aHandler.RevokeAllSessionsHandler)
+		sessions.DELETE("/:id", serv.aHandler.RevokeSessionHandler)
+
+		merch := admin.Group("/merch", handlers.RequirePermission(models.PermMerchWrite))
+		merch.POST("", serv.mHandler.CreateMerchHandler)
+		merch.PUT("/:id", serv.mHandler.ReplaceMerchHandler)
+		merch.PATCH("/:id", serv.mHandler.PatchMerchHandler)
+		merch.DELETE("/:id", serv.mHandler.DeleteMerchHandler)
+		merch.POST("/:id/variants", serv.mHandler.CreateVariantHandler)
+		merch.PUT("/:id/variants/:vid", serv.mHandler.ReplaceVariantHandler)
+		merch.DELETE("/:id/variants/:vid", serv.mHandler.DeleteVariantHandler)
+		merch.POST("/:id/images", serv.mdHandler.UploadImageHandler)
+		merch.DELETE("/:id/images/:iid", serv.mdHandler.DeleteImageHandler)
+
+		admin.GET("/ledger/reconcile", handlers.RequirePermission(models.PermLedgerRead), serv.lHandler.ReconcileHandler)
+		admin.POST("/coins/grants", handlers.RequirePermission(models.PermCoinsGrant), serv.iHandler.Idempotent(serv.config, models.MaxGrantBodySize), serv.lHandler.GrantHandler)
+
+		flags := admin.Group("/transfers/flags", handlers.RequirePermission(models.PermTransfersReview))
+		flags.GET("", serv.tHandler.TransferFlagsHandler)
+		flags.POST("/:id/review", serv.tHandler.ReviewTransferFlagHandler)
+
+		orders := admin.Group("/orders", handlers.RequirePermission(models.PermOrdersManage))
+		orders.GET("", serv.oHandler.AllOrdersHandler)
+		orders.PUT("/:id/status", serv.oHandler.AdvanceOrderHandler)
+		orders.POST("/:id/cancel", serv.oHandler.AdminCancelOrderHandler)
+
+		promos := admin.Group("/promos", handlers.RequirePermission(models.PermPromosManage))
+		promos.GET("", serv.pHandler.PromosHandler)
+		promos.POST("", serv.pHandler.CreatePromoHandler)
+		promos.DELETE("/:id", serv.pHandler.DeletePromoHandler)
+
+		sales := admin.Group("/sales", handlers.RequirePermission(models.PermPromosManage))
+		sales.GET("", serv.pHandler.SalesHandler)
+		sales.POST("", serv.pHandler.CreateSaleHandler)
+		sales.DELETE("/:id", serv.pHandler.DeleteSaleHandler)
 	}
 
 	// --- Приватные пути END --- //
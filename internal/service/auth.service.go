@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"time"
+)
+
+type AuthServiceInterface interface {
+	// IssueRefresh - создает refresh токен нового семейства для пользователя
+	IssueRefresh(ctx context.Context, login string, ttl time.Duration) (*models.RefreshToken, error)
+
+	// Refresh - обменивает refresh токен на новый из того же семейства.
+	// Старый токен становится недействительным
+	Refresh(ctx context.Context, tokenID string, ttl time.Duration) (*models.RefreshToken, error)
+
+	// NewSession - создает сессию для нового токена авторизации,
+	// выданного вместе с refresh токеном rt. Id сессии - это jti токена
+	NewSession(ctx context.Context, rt *models.RefreshToken, ttl time.Duration) (*models.Session, error)
+
+	// CheckSession - проверяет, что сессия с jti существует и не отозвана
+	CheckSession(ctx context.Context, jti string) error
+
+	// Logout - завершает сессию jti вместе со всеми сессиями
+	// и refresh токенами, выданными после того же входа
+	Logout(ctx context.Context, jti string) error
+
+	// LogoutAll - завершает все сессии пользователя
+	LogoutAll(ctx context.Context, login string) error
+
+	// Sessions - возвращает активные сессии пользователя
+	Sessions(ctx context.Context, login string) ([]*models.Session, error)
+
+	// RevokeSession - завершает сессию sessionID пользователя login
+	RevokeSession(ctx context.Context, login, sessionID string) error
+}
+
+var _ AuthServiceInterface = (*AuthService)(nil)
+
+// AuthService - реализует интерфейс AuthServiceInterface
+//
+// Все сроки истечения хранятся в UTC: колонки expires_at в БД без часового пояса
+type AuthService struct {
+	RefreshStorage entities.RefreshTokenStorage
+	SessionStorage entities.SessionStorage
+	UserStorage    entities.UserStorage
+	TxManager      entities.TxManager
+}
+
+// NewAuthService - создает объект AuthService
+func NewAuthService(r entities.RefreshTokenStorage, s entities.SessionStorage, u entities.UserStorage, tx entities.TxManager) *AuthService {
+	return &AuthService{
+		RefreshStorage: r,
+		SessionStorage: s,
+		UserStorage:    u,
+		TxManager:      tx,
+	}
+}
+
+// newTokenID - генерирует случайный идентификатор токена (jti)
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IssueRefresh - проверяет существование пользователя и создает
+// для него refresh токен нового семейства (выдается при входе)
+func (a *AuthService) IssueRefresh(ctx context.Context, login string, ttl time.Duration) (*models.RefreshToken, error) {
+	user, err := a.UserStorage.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	familyID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.createRefresh(ctx, user.Id, user.Login, familyID, ttl)
+}
+
+// Refresh - ротация refresh токена:
+//   - использованный токен предъявлен повторно - значит его украли,
+//     отзываем все семейство и возвращаем ErrRefreshTokenReused
+//   - отозванный или истекший токен отклоняется
+//   - иначе токен помечается использованным и выдается новый того же семейства
+func (a *AuthService) Refresh(ctx context.Context, tokenID string, ttl time.Duration) (*models.RefreshToken, error) {
+	var (
+		next   *models.RefreshToken
+		reused bool
+	)
+
+	err := a.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		token, err := a.RefreshStorage.GetForUpdate(ctx, tokenID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case token.Revoked:
+			return models.ErrRefreshTokenRevoked
+		case token.Used:
+			// Отзыв семейства должен сохраниться, поэтому транзакцию
+			// не откатываем, а возвращаем ошибку уже после ее фиксации
+			reused = true
+			if err := a.SessionStorage.RevokeFamily(ctx, token.FamilyId); err != nil {
+				return err
+			}
+			return a.RefreshStorage.RevokeFamily(ctx, token.FamilyId)
+		case time.Now().After(token.ExpiresAt):
+			return models.ErrRefreshTokenExpired
+		}
+
+		if err := a.RefreshStorage.MarkUsed(ctx, token.Id); err != nil {
+			return err
+		}
+
+		next, err = a.createRefresh(ctx, token.UserId, token.Login, token.FamilyId, ttl)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, models.ErrRefreshTokenReused
+	}
+
+	return next, nil
+}
+
+// createRefresh - сохраняет новый refresh токен семейства familyID
+func (a *AuthService) createRefresh(ctx context.Context, userID int, login, familyID string, ttl time.Duration) (*models.RefreshToken, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.RefreshToken{
+		Id:        id,
+		FamilyId:  familyID,
+		UserId:    userID,
+		Login:     login,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	if err := a.RefreshStorage.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// NewSession - создает сессию того же семейства, что и refresh токен rt.
+// Роль берется у пользователя заново, поэтому смена роли
+// вступает в силу с ближайшим обменом refresh токена
+func (a *AuthService) NewSession(ctx context.Context, rt *models.RefreshToken, ttl time.Duration) (*models.Session, error) {
+	user, err := a.UserStorage.Get(ctx, rt.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	session := &models.Session{
+		Id:        id,
+		FamilyId:  rt.FamilyId,
+		UserId:    user.Id,
+		Login:     user.Login,
+		Role:      role,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	if err := a.SessionStorage.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// CheckSession - возвращает ErrSessionRevoked, если сессия отозвана,
+// и ErrSessionNotFound, если токен выдан не этим сервером
+func (a *AuthService) CheckSession(ctx context.Context, jti string) error {
+	session, err := a.SessionStorage.Get(ctx, jti)
+	if err != nil {
+		return err
+	}
+
+	if session.Revoked {
+		return models.ErrSessionRevoked
+	}
+
+	return nil
+}
+
+// Logout - отзывает все семейство сессии jti, иначе клиент
+// мог бы получить новый токен по refresh токену того же входа
+func (a *AuthService) Logout(ctx context.Context, jti string) error {
+	return a.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		session, err := a.SessionStorage.Get(ctx, jti)
+		if err != nil {
+			return err
+		}
+
+		return a.revokeFamily(ctx, session.FamilyId)
+	})
+}
+
+// LogoutAll - отзывает все сессии и refresh токены пользователя
+func (a *AuthService) LogoutAll(ctx context.Context, login string) error {
+	user, err := a.UserStorage.GetByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	return a.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := a.SessionStorage.RevokeAllByUser(ctx, user.Id); err != nil {
+			return err
+		}
+
+		return a.RefreshStorage.RevokeAllByUser(ctx, user.Id)
+	})
+}
+
+// Sessions - проверяет существует ли пользователь
+// и возвращает его активные сессии
+func (a *AuthService) Sessions(ctx context.Context, login string) ([]*models.Session, error) {
+	user, err := a.UserStorage.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.SessionStorage.ListActive(ctx, user.Id)
+}
+
+// RevokeSession - отзывает семейство сессии sessionID.
+// Сессия другого пользователя считается ненайденной
+func (a *AuthService) RevokeSession(ctx context.Context, login, sessionID string) error {
+	return a.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		session, err := a.SessionStorage.Get(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+
+		if session.Login != login {
+			return models.ErrSessionNotFound
+		}
+
+		return a.revokeFamily(ctx, session.FamilyId)
+	})
+}
+
+// revokeFamily - отзывает сессии и refresh токены семейства
+func (a *AuthService) revokeFamily(ctx context.Context, familyID string) error {
+	if err := a.SessionStorage.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+
+	return a.RefreshStorage.RevokeFamily(ctx, familyID)
+}
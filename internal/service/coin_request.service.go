@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"merch_service/internal/models"
+	"time"
+)
+
+// DefaultCoinRequestTTL - срок ответа на запрос монет, если он не задан
+const DefaultCoinRequestTTL = 7 * 24 * time.Hour
+
+// RequestCoins - создает запрос монет пользователя userLogin у req.Payer со сроком ответа ttl
+// (0 - DefaultCoinRequestTTL). Повод проверяется, а сообщение очищается так же,
+// как у обычного перевода (см. Send). Хватит ли у плательщика монет, проверяется
+// только при принятии запроса.
+//
+// Защита от спама: к одному плательщику может ждать ответа только один запрос
+// (ErrCoinRequestExists), всего ожидающих запросов не больше MaxPendingCoinRequests,
+// а за сутки можно создать не больше MaxDailyCoinRequests (ErrTooManyCoinRequests).
+// Строка пользователя блокируется на время проверки, чтобы параллельные запросы
+// не обошли лимиты
+func (t *TransactionService) RequestCoins(ctx context.Context, userLogin string, req *models.AskCoinsRequest, ttl time.Duration) (*models.CoinRequest, error) {
+	if req.Amount <= 0 {
+		return nil, models.ErrInvalidAmount
+	}
+
+	note := req.TransferNote
+	if err := note.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if userLogin == req.Payer {
+		return nil, models.ErrSameSenderReceiver
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultCoinRequestTTL
+	}
+
+	payer, err := t.UserStorage.GetByLogin(ctx, req.Payer)
+	if err != nil {
+		return nil, err
+	}
+
+	var cr *models.CoinRequest
+	err = t.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		requester, err := t.UserStorage.GetByLoginForUpdate(ctx, userLogin)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		counts, err := t.CoinRequestStorage.Counts(ctx, requester.Id, payer.Id, now, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+
+		if counts.PendingToPayer > 0 {
+			return models.ErrCoinRequestExists
+		}
+		if counts.Pending >= models.MaxPendingCoinRequests || counts.Created >= models.MaxDailyCoinRequests {
+			return models.ErrTooManyCoinRequests
+		}
+
+		cr = &models.CoinRequest{
+			RequesterId:  requester.Id,
+			Requester:    requester.Login,
+			PayerId:      payer.Id,
+			Payer:        payer.Login,
+			Amount:       req.Amount,
+			TransferNote: note,
+			ExpiresAt:    now.Add(ttl),
+		}
+		return t.CoinRequestStorage.Create(ctx, cr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// CoinRequests - возвращает страницу запросов монет пользователя от новых к старым:
+// отправленных им (sent), полученных (received) или всех
+func (t *TransactionService) CoinRequests(ctx context.Context, userLogin string, filter *models.CoinRequestFilter) (*models.Page[*models.CoinRequest], error) {
+	if filter == nil {
+		filter = &models.CoinRequestFilter{}
+	}
+
+	if filter.Direction == "" {
+		filter.Direction = models.TransferAll
+	}
+
+	if !filter.Direction.Valid() {
+		return nil, models.ErrInvalidDirection
+	}
+
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, models.ErrInvalidCoinRequestStatus
+	}
+
+	if err := filter.Normalize(); err != nil {
+		return nil, err
+	}
+
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.CoinRequestStorage.List(ctx, user.Id, filter, time.Now().UTC())
+}
+
+// CoinRequest - возвращает запрос монет id, в котором участвует пользователь.
+// Для остальных запрос не отличается от несуществующего
+func (t *TransactionService) CoinRequest(ctx context.Context, userLogin string, id int) (*models.CoinRequest, error) {
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	cr, err := t.CoinRequestStorage.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cr.RequesterId != user.Id && cr.PayerId != user.Id {
+		return nil, models.ErrCoinRequestNotFound
+	}
+
+	cr.Status = cr.StatusAt(time.Now().UTC())
+	return cr, nil
+}
+
+// AcceptCoinRequest - принимает запрос монет id от имени плательщика: монеты переводятся
+// тем же путем, что и в Send, с поводом и сообщением запроса, а запрос закрывается
+// со ссылкой на перевод. Все это - в одной транзакции, поэтому запрос нельзя принять дважды.
+// Если монет не хватает, возвращает ErrNotEnoughCoins, и запрос остается ожидающим
+func (t *TransactionService) AcceptCoinRequest(ctx context.Context, userLogin string, id int) (*models.CoinRequest, error) {
+	return t.resolveCoinRequest(ctx, userLogin, id, models.CoinRequestAccepted)
+}
+
+// DeclineCoinRequest - отклоняет запрос монет id от имени плательщика
+func (t *TransactionService) DeclineCoinRequest(ctx context.Context, userLogin string, id int) (*models.CoinRequest, error) {
+	return t.resolveCoinRequest(ctx, userLogin, id, models.CoinRequestDeclined)
+}
+
+// resolveCoinRequest - отвечает на ожидающий запрос монет статусом status.
+// Ответить может только плательщик, для остальных запрос не отличается от несуществующего.
+// Ответ на истекший или уже закрытый запрос возвращает ErrCoinRequestNotPending
+func (t *TransactionService) resolveCoinRequest(ctx context.Context, userLogin string, id int, status models.CoinRequestStatus) (*models.CoinRequest, error) {
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		cr, err := t.CoinRequestStorage.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if cr.PayerId != user.Id {
+			return models.ErrCoinRequestNotFound
+		}
+
+		if cr.StatusAt(time.Now().UTC()) != models.CoinRequestPending {
+			return models.ErrCoinRequestNotPending
+		}
+
+		transferID := 0
+		if status == models.CoinRequestAccepted {
+			transferID, err = t.transfer(ctx, cr.Payer, cr.Requester, cr.Amount, cr.TransferNote)
+			if err != nil {
+				return err
+			}
+		}
+
+		return t.CoinRequestStorage.Resolve(ctx, id, status, transferID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t.CoinRequest(ctx, userLogin, id)
+}
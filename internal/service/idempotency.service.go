@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"time"
+)
+
+// DefaultIdempotencyTTL - срок действия ключа идемпотентности,
+// если он не задан в конфиге (idempotencyttl)
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyLease - сколько незавершенный ключ принадлежит запросу.
+// Если запрос упал, не сохранив ответ, повтор того же запроса занимает ключ
+// после истечения аренды, а не ждет истечения всего ключа
+const DefaultIdempotencyLease = time.Minute
+
+// maxIdempotencyKeyLen - ограничение длины ключа, как в merchshop.idempotency_keys
+const maxIdempotencyKeyLen = 255
+
+type IdempotencyServiceInterface interface {
+	// Begin - резервирует ключ key за пользователем на ttl.
+	// Если запрос с этим ключом уже выполнен, возвращает его сохраненный
+	// ответ (Completed() == true), который нужно вернуть клиенту вместо выполнения
+	Begin(ctx context.Context, userLogin, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, error)
+
+	// Complete - сохраняет ответ на запрос с зарезервированным ключом
+	Complete(ctx context.Context, record *models.IdempotencyRecord, statusCode int, response []byte) error
+
+	// Abort - снимает резерв ключа, чтобы запрос можно было повторить
+	Abort(ctx context.Context, record *models.IdempotencyRecord) error
+}
+
+var _ IdempotencyServiceInterface = (*IdempotencyService)(nil)
+
+// IdempotencyService - реализует интерфейс IdempotencyServiceInterface.
+// Lease - аренда незавершенного ключа (0 - DefaultIdempotencyLease)
+type IdempotencyService struct {
+	IdempotencyStorage entities.IdempotencyStorage
+	UserStorage        entities.UserStorage
+	Lease              time.Duration
+}
+
+// NewIdempotencyService - создает объект IdempotencyService
+func NewIdempotencyService(i entities.IdempotencyStorage, u entities.UserStorage) *IdempotencyService {
+	return &IdempotencyService{
+		IdempotencyStorage: i,
+		UserStorage:        u,
+	}
+}
+
+// HashRequest - отпечаток запроса, с которым связывается ключ идемпотентности.
+// Путь входит в отпечаток, поэтому один ключ нельзя использовать на разных путях
+func HashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validateIdempotencyKey - ключ непустой и состоит из видимых ASCII символов
+func validateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLen {
+		return models.ErrInvalidIdempotencyKey
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return models.ErrInvalidIdempotencyKey
+		}
+	}
+
+	return nil
+}
+
+// Begin - пытается зарезервировать ключ. Если ключ уже занят, сверяет отпечаток
+// запроса: другой запрос с тем же ключом отклоняется, а тот же запрос получает
+// сохраненный ответ или ErrIdempotencyInProgress, если первый запрос еще выполняется.
+// Незавершенный ключ с истекшей арендой занимает повтор того же запроса
+func (i *IdempotencyService) Begin(ctx context.Context, userLogin, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	if err := validateIdempotencyKey(key); err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	user, err := i.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	lease := i.Lease
+	if lease <= 0 {
+		lease = DefaultIdempotencyLease
+	}
+
+	now := time.Now().UTC()
+	record := &models.IdempotencyRecord{
+		Key:         key,
+		UserId:      user.Id,
+		RequestHash: requestHash,
+		LockedUntil: now.Add(lease),
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	err = i.IdempotencyStorage.Create(ctx, record, now)
+	if err == nil {
+		return record, nil
+	}
+
+	if !errors.Is(err, models.ErrIdempotencyKeyExists) {
+		return nil, err
+	}
+
+	stored, err := i.IdempotencyStorage.Get(ctx, user.Id, key, now)
+	if err != nil {
+		// Ключ истек или был снят между Create и Get - считаем, что он еще занят,
+		// клиент повторит запрос
+		if errors.Is(err, models.ErrIdempotencyNotFound) {
+			return nil, models.ErrIdempotencyInProgress
+		}
+		return nil, err
+	}
+
+	if stored.RequestHash != requestHash {
+		return nil, models.ErrIdempotencyKeyReused
+	}
+
+	if !stored.Completed() {
+		if stored.LockedUntil.After(now) {
+			return nil, models.ErrIdempotencyInProgress
+		}
+
+		stored.LockedUntil = record.LockedUntil
+		if err := i.IdempotencyStorage.Takeover(ctx, stored, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return stored, nil
+}
+
+// Complete - сохраняет статус и тело ответа
+func (i *IdempotencyService) Complete(ctx context.Context, record *models.IdempotencyRecord, statusCode int, response []byte) error {
+	record.StatusCode = statusCode
+	record.Response = response
+	return i.IdempotencyStorage.Complete(ctx, record)
+}
+
+// Abort - удаляет зарезервированный ключ
+func (i *IdempotencyService) Abort(ctx context.Context, record *models.IdempotencyRecord) error {
+	return i.IdempotencyStorage.Delete(ctx, record.UserId, record.Key)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"merch_service/internal/models"
+)
+
+// imageExtensions - поддерживаемые типы изображений и расширения их файлов
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// decodeImage - проверяет размер, тип и размеры изображения и декодирует его.
+// Тип определяется по содержимому файла, а не по заголовкам клиента
+func decodeImage(data []byte) (image.Image, string, error) {
+	if len(data) > models.MaxImageSize {
+		return nil, "", models.ErrImageTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := imageExtensions[contentType]; !ok {
+		return nil, "", models.ErrUnsupportedImageType
+	}
+
+	// Размеры читаются из заголовка до декодирования, чтобы маленький файл
+	// не развернулся в огромную картинку в памяти
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", models.ErrInvalidImage
+	}
+	if config.Width > models.MaxImageDimension || config.Height > models.MaxImageDimension {
+		return nil, "", models.ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", models.ErrInvalidImage
+	}
+
+	return img, contentType, nil
+}
+
+// thumbnail - уменьшает изображение, чтобы оно вписалось в квадрат size x size,
+// с сохранением пропорций. Каждый пиксель миниатюры - среднее покрытых им пикселей.
+// Изображения меньше квадрата не увеличиваются
+func thumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	// Цвета с предумноженной альфой можно усреднять без учета прозрачности
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := y * h / th
+		y1 := max((y+1)*h/th, y0+1)
+
+		for x := 0; x < tw; x++ {
+			x0 := x * w / tw
+			x1 := max((x+1)*w/tw, x0+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	return dst
+}
+
+// encodeImage - кодирует изображение в формате contentType
+func encodeImage(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	case "image/png":
+		err = png.Encode(&buf, img)
+	default:
+		return nil, models.ErrUnsupportedImageType
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// imageKeys - ключи оригинала и миниатюры изображения мерча merchID в хранилище файлов.
+// Ключ строится по содержимому, поэтому файл под ключом никогда не меняется
+func imageKeys(merchID int, data []byte, contentType string) (string, string) {
+	sum := sha256.Sum256(data)
+	name := fmt.Sprintf("merch/%d/%s", merchID, hex.EncodeToString(sum[:16]))
+	ext := imageExtensions[contentType]
+	return name + ext, name + "_thumb" + ext
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"slices"
+)
+
+type LedgerServiceInterface interface {
+	// Reconcile - (админ) сверяет журнал монет и кешированные балансы
+	Reconcile(ctx context.Context) (*models.LedgerReport, error)
+
+	// Grant - (админ) проверяет пакет начислений и, если это не пробный запуск, начисляет монеты из казны
+	Grant(ctx context.Context, adminLogin string, rows []models.GrantRow, dryRun bool) (*models.GrantReport, error)
+}
+
+var _ LedgerServiceInterface = (*LedgerService)(nil)
+
+// LedgerService - реализует интерфейс LedgerServiceInterface
+type LedgerService struct {
+	LedgerStorage entities.LedgerStorage
+	UserStorage   entities.UserStorage
+	CoinsStorage  entities.CoinsStorage
+	TxManager     entities.TxManager
+	GrantStorage  entities.GrantStorage
+}
+
+// NewLedgerService - создает объект LedgerService
+func NewLedgerService(l entities.LedgerStorage, u entities.UserStorage, c entities.CoinsStorage, tx entities.TxManager, g entities.GrantStorage) *LedgerService {
+	return &LedgerService{
+		LedgerStorage: l,
+		UserStorage:   u,
+		CoinsStorage:  c,
+		TxManager:     tx,
+		GrantStorage:  g,
+	}
+}
+
+// Reconcile - пробрасывает сверку в хранилище журнала
+func (l *LedgerService) Reconcile(ctx context.Context) (*models.LedgerReport, error) {
+	return l.LedgerStorage.Reconcile(ctx)
+}
+
+// Grant - проверяет все строки пакета до начисления и возвращает отчет по каждой строке:
+// формат, сумму, причину и существование пользователя. Пробный запуск (dryRun) только
+// возвращает отчет. Если хоть одна строка ошибочна, ничего не начисляется и вместе
+// с отчетом возвращается ErrInvalidGrantBatch.
+//
+// Корректный пакет начисляется в одной транзакции: строки получателей блокируются
+// в порядке логинов, монеты переводятся со счета казны одной записью журнала ReasonGrant,
+// а каждая строка попадает в историю кошелька получателя
+func (l *LedgerService) Grant(ctx context.Context, adminLogin string, rows []models.GrantRow, dryRun bool) (*models.GrantReport, error) {
+	if len(rows) == 0 {
+		return nil, models.ErrEmptyGrantBatch
+	}
+
+	if len(rows) > models.MaxGrantRows {
+		return nil, models.ErrGrantBatchTooLarge
+	}
+
+	report := &models.GrantReport{DryRun: dryRun, Rows: rows}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		if row.Error == "" {
+			msg, err := l.checkGrantRow(ctx, row)
+			if err != nil {
+				return nil, err
+			}
+			row.Error = msg
+		}
+
+		if row.Error != "" {
+			report.Invalid++
+			continue
+		}
+		report.Valid++
+		report.Total += row.Amount
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if report.Invalid > 0 {
+		return report, models.ErrInvalidGrantBatch
+	}
+
+	admin, err := l.UserStorage.GetByLogin(ctx, adminLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &models.GrantBatch{AdminId: admin.Id, Total: report.Total}
+	for i := range report.Rows {
+		batch.Rows = append(batch.Rows, &report.Rows[i])
+	}
+
+	logins := make([]string, 0, len(batch.Rows))
+	for _, row := range batch.Rows {
+		logins = append(logins, row.Login)
+	}
+	slices.Sort(logins)
+	logins = slices.Compact(logins)
+
+	err = l.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		// Получатели блокируются в одном порядке, чтобы параллельные пакеты не взаимоблокировались
+		users := make(map[string]*models.User, len(logins))
+		for _, login := range logins {
+			user, err := l.UserStorage.GetByLoginForUpdate(ctx, login)
+			if err != nil {
+				return err
+			}
+			users[login] = user
+		}
+
+		for _, row := range batch.Rows {
+			row.UserId = users[row.Login].Id
+		}
+
+		if err := l.GrantStorage.Create(ctx, batch); err != nil {
+			return err
+		}
+
+		entry := &models.JournalEntry{
+			Reason:      models.ReasonGrant,
+			ReferenceId: batch.Id,
+			Postings:    []models.Posting{{System: models.AccountTreasury, Amount: -batch.Total}},
+		}
+		for _, row := range batch.Rows {
+			entry.Postings = append(entry.Postings, models.Posting{UserId: row.UserId, Amount: row.Amount})
+		}
+		if err := l.LedgerStorage.Post(ctx, entry); err != nil {
+			return err
+		}
+
+		for _, row := range batch.Rows {
+			user := users[row.Login]
+			user.Coins += row.Amount
+			if err := l.CoinsStorage.CreateForGrant(ctx, user, user.Coins-row.Amount, batch.Id, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Id = batch.Id
+	report.Applied = true
+	return report, nil
+}
+
+// checkGrantRow - проверяет строку пакета и находит получателя.
+// Возвращает текст ошибки строки для отчета (пустой - строка корректна)
+// или ошибку БД, из-за которой пакет проверить нельзя
+func (l *LedgerService) checkGrantRow(ctx context.Context, row *models.GrantRow) (string, error) {
+	if err := row.Validate(); err != nil {
+		return err.Error(), nil
+	}
+
+	user, err := l.UserStorage.GetByLogin(ctx, row.Login)
+	if errors.Is(err, models.ErrUserNotFound) {
+		return err.Error(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	row.UserId = user.Id
+	return "", nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"time"
+)
+
+type MediaServiceInterface interface {
+	// UploadImage - (админ) проверяет изображение мерча merchID,
+	// сохраняет его вместе с миниатюрой и возвращает описание с URL
+	UploadImage(ctx context.Context, merchID int, data []byte) (*models.Image, error)
+
+	// DeleteImage - (админ) удаляет изображение imageID мерча merchID вместе с файлами
+	DeleteImage(ctx context.Context, merchID, imageID int) error
+
+	// OpenMedia - открывает файл хранилища по ключу из URL изображения.
+	// Возвращает время записи файла, файл нужно закрыть
+	OpenMedia(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error)
+}
+
+var _ MediaServiceInterface = (*MediaService)(nil)
+
+// MediaService - реализует интерфейс MediaServiceInterface
+type MediaService struct {
+	ImageStorage entities.ImageStorage
+	MerchStorage entities.MerchStorage
+	BlobStorage  entities.BlobStorage
+}
+
+// NewMediaService - создает объект MediaService
+func NewMediaService(i entities.ImageStorage, m entities.MerchStorage, b entities.BlobStorage) *MediaService {
+	return &MediaService{
+		ImageStorage: i,
+		MerchStorage: m,
+		BlobStorage:  b,
+	}
+}
+
+// UploadImage - проверяет размер и тип изображения (JPEG или PNG, по содержимому),
+// строит миниатюру (см. models.ThumbnailSize) и записывает оба файла в хранилище.
+// Если описание сохранить не удалось, записанные файлы удаляются
+func (m *MediaService) UploadImage(ctx context.Context, merchID int, data []byte) (*models.Image, error) {
+	if _, err := m.MerchStorage.Get(ctx, merchID); err != nil {
+		return nil, err
+	}
+
+	img, contentType, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	thumb, err := encodeImage(thumbnail(img, models.ThumbnailSize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	key, thumbKey := imageKeys(merchID, data, contentType)
+	image := &models.Image{
+		MerchId:     merchID,
+		Key:         key,
+		ThumbKey:    thumbKey,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	if err := m.BlobStorage.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := m.BlobStorage.Put(ctx, thumbKey, bytes.NewReader(thumb)); err != nil {
+		m.deleteFiles(ctx, image)
+		return nil, err
+	}
+
+	if err := m.ImageStorage.Create(ctx, image); err != nil {
+		// Те же файлы уже принадлежат загруженному ранее изображению
+		if !errors.Is(err, models.ErrImageExists) {
+			m.deleteFiles(ctx, image)
+		}
+		return nil, err
+	}
+
+	image.SetURLs()
+	return image, nil
+}
+
+// DeleteImage - удаляет описание изображения, затем его файлы.
+// Изображение другого мерча не отличается от несуществующего
+func (m *MediaService) DeleteImage(ctx context.Context, merchID, imageID int) error {
+	image, err := m.ImageStorage.Get(ctx, imageID)
+	if err != nil {
+		return err
+	}
+
+	if image.MerchId != merchID {
+		return models.ErrImageNotFound
+	}
+
+	if err := m.ImageStorage.Delete(ctx, imageID); err != nil {
+		return err
+	}
+
+	m.deleteFiles(ctx, image)
+	return nil
+}
+
+// deleteFiles - удаляет файлы изображения. Описания у них уже нет,
+// поэтому ошибка только логируется: лишний файл никому не мешает
+func (m *MediaService) deleteFiles(ctx context.Context, image *models.Image) {
+	for _, key := range []string{image.Key, image.ThumbKey} {
+		if err := m.BlobStorage.Delete(ctx, key); err != nil {
+			log.Printf("не удалось удалить файл %s: %v", key, err)
+		}
+	}
+}
+
+// OpenMedia - пробрасывает открытие файла в хранилище файлов
+func (m *MediaService) OpenMedia(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	return m.BlobStorage.Open(ctx, key)
+}
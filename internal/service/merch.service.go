@@ -4,96 +4,887 @@ import (
 	"context"
 	"merch_service/internal/models"
 	"merch_service/internal/storage/entities"
+	"time"
 )
 
 type MerchServiceInterface interface {
-	// Buy проверяет есть ли мерч в наличии и хватает
-	// ли денег пользователю, после чего сохраняет данные
-	// и возвращает текущий баланс
-	Buy(ctx context.Context, userName, merchName string, count int) (int, error)
+	// Buy проверяет есть ли мерч (вариант variant, если задан) в наличии и хватает
+	// ли денег пользователю с учетом распродаж и промокода promo (если задан),
+	// после чего сохраняет данные и возвращает текущий баланс
+	Buy(ctx context.Context, userName, merchName, variant, promo string, count int) (int, error)
 
-	// MerchList - возвращает весь доступный для покупки мерч
-	MerchList(ctx context.Context) ([]*models.Item, error)
+	// MerchList - возвращает страницу доступного для покупки мерча,
+	// подходящего под фильтр, в выбранном порядке
+	MerchList(ctx context.Context, filter *models.MerchFilter) (*models.Page[*models.Item], error)
+
+	// CreateMerch - (админ) добавляет новый мерч в каталог
+	CreateMerch(ctx context.Context, req *models.MerchRequest) (*models.Item, error)
+
+	// ReplaceMerch - (админ) заменяет все поля мерча id
+	ReplaceMerch(ctx context.Context, id int, req *models.MerchRequest) (*models.Item, error)
+
+	// PatchMerch - (админ) меняет только переданные поля мерча id
+	PatchMerch(ctx context.Context, id int, req *models.MerchPatchRequest) (*models.Item, error)
+
+	// DeleteMerch - (админ) убирает мерч из каталога.
+	// История покупок удаленного мерча сохраняется
+	DeleteMerch(ctx context.Context, id int) error
+
+	// CreateVariant - (админ) добавляет вариант мерча merchID
+	CreateVariant(ctx context.Context, merchID int, req *models.VariantRequest) (*models.Variant, error)
+
+	// ReplaceVariant - (админ) заменяет все поля варианта variantID мерча merchID
+	ReplaceVariant(ctx context.Context, merchID, variantID int, req *models.VariantRequest) (*models.Variant, error)
+
+	// DeleteVariant - (админ) убирает вариант variantID мерча merchID из каталога
+	DeleteVariant(ctx context.Context, merchID, variantID int) error
+
+	// Cart - возвращает корзину пользователя с суммами по текущим ценам
+	Cart(ctx context.Context, userName string) (*models.Cart, error)
+
+	// AddToCart - добавляет count штук мерча (варианта variant, если задан)
+	// в корзину и возвращает её
+	AddToCart(ctx context.Context, userName, merchName, variant string, count int) (*models.Cart, error)
+
+	// SetCartItem - задает количество мерча merchID (варианта variant, если задан)
+	// в корзине (0 убирает его) и возвращает корзину
+	SetCartItem(ctx context.Context, userName string, merchID int, variant string, count int) (*models.Cart, error)
+
+	// RemoveFromCart - убирает мерч merchID (вариант variant, если задан)
+	// из корзины и возвращает её
+	RemoveFromCart(ctx context.Context, userName string, merchID int, variant string) (*models.Cart, error)
+
+	// Checkout - покупает всё содержимое корзины одним заказом
+	// с промокодом promo (если задан): либо все строки, либо ни одной
+	Checkout(ctx context.Context, userName, promo string) (*models.CheckoutResult, error)
+
+	// Reserve - бронирует мерч на ttl (0 - срок по умолчанию для вида брони)
+	// и списывает его стоимость, возвращает бронь
+	Reserve(ctx context.Context, userName string, req *models.ReservationRequest, ttl time.Duration) (*models.Reservation, error)
+
+	// Reservations - возвращает страницу броней пользователя
+	Reservations(ctx context.Context, userName string, q *models.PageQuery) (*models.Page[*models.Reservation], error)
+
+	// ConfirmReservation - превращает активную бронь пользователя в заказ
+	ConfirmReservation(ctx context.Context, userName string, id int) (*models.Order, error)
+
+	// CancelReservation - отменяет активную бронь пользователя и возвращает монеты
+	CancelReservation(ctx context.Context, userName string, id int) (*models.Reservation, error)
+
+	// ExpireReservations - закрывает истекшие брони с возвратом монет,
+	// возвращает число закрытых броней
+	ExpireReservations(ctx context.Context) (int, error)
 }
 
 var _ MerchServiceInterface = (*MerchService)(nil)
 
 // MerchService - реализует интерфейс MerchServiceInterface
 type MerchService struct {
-	MerchStorage    entities.MerchStorage
-	UserStorage     entities.UserStorage
-	PurchaseStorage entities.PurchaseStorage
-	CoinsStorage    entities.CoinsStorage
+	MerchStorage       entities.MerchStorage
+	UserStorage        entities.UserStorage
+	PurchaseStorage    entities.PurchaseStorage
+	CoinsStorage       entities.CoinsStorage
+	TxManager          entities.TxManager
+	LedgerStorage      entities.LedgerStorage
+	OrderStorage       entities.OrderStorage
+	CartStorage        entities.CartStorage
+	VariantStorage     entities.VariantStorage
+	ImageStorage       entities.ImageStorage
+	PromoStorage       entities.PromoStorage
+	ReservationStorage entities.ReservationStorage
+	Restock            *RestockNotifier
 }
 
 // NewMerchService - создает объект MerchService
-func NewMerchService(m entities.MerchStorage, u entities.UserStorage, p entities.PurchaseStorage, c entities.CoinsStorage) *MerchService {
+func NewMerchService(m entities.MerchStorage, u entities.UserStorage, p entities.PurchaseStorage, c entities.CoinsStorage, tx entities.TxManager, l entities.LedgerStorage, o entities.OrderStorage, cart entities.CartStorage, v entities.VariantStorage, i entities.ImageStorage, promo entities.PromoStorage, res entities.ReservationStorage, r *RestockNotifier) *MerchService {
 	return &MerchService{
-		MerchStorage:    m,
-		UserStorage:     u,
-		PurchaseStorage: p,
-		CoinsStorage:    c,
+		MerchStorage:       m,
+		UserStorage:        u,
+		PurchaseStorage:    p,
+		CoinsStorage:       c,
+		TxManager:          tx,
+		LedgerStorage:      l,
+		OrderStorage:       o,
+		CartStorage:        cart,
+		VariantStorage:     v,
+		ImageStorage:       i,
+		PromoStorage:       promo,
+		ReservationStorage: res,
+		Restock:            r,
 	}
 }
 
 // Buy - проверяет наличие мерча и возможность пользователя купить мерч и
+// далее совершает покупку мерча.
 //
-//	далее совершает покупку мерча
-func (m *MerchService) Buy(ctx context.Context, userName, merchName string, count int) (int, error) {
-	merch, err := m.MerchStorage.GetByName(ctx, merchName)
+// Вся покупка выполняется в одной транзакции: строки мерча и пользователя
+// блокируются до её завершения, поэтому параллельные покупки не могут
+// ни продать больше, чем есть на складе, ни списать монеты без покупки.
+// Покупка оформляется заказом из одной строки (см. placeOrder).
+// Мерч с вариантами покупается только по артикулу варианта.
+func (m *MerchService) Buy(ctx context.Context, userName, merchName, variant, promo string, count int) (int, error) {
+	if count <= 0 {
+		return -1, models.ErrInvalidCount
+	}
+
+	balance := -1
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		merch, err := m.MerchStorage.GetByNameForUpdate(ctx, merchName)
+		if err != nil {
+			return err
+		}
+
+		v, err := m.resolveVariant(ctx, merch, variant)
+		if err != nil {
+			return err
+		}
+
+		if v != nil {
+			if v, err = m.VariantStorage.GetForUpdate(ctx, v.Id); err != nil {
+				return err
+			}
+		}
+
+		_, balance, err = m.placeOrder(ctx, userName, []orderItem{{merch: merch, variant: v, count: count}}, promo)
+		return err
+	})
+
 	if err != nil {
 		return -1, err
 	}
 
-	if merch.Stock < count {
-		return -1, models.ErrNotEnoughMerch
+	return balance, nil
+}
+
+// resolveVariant - находит вариант мерча по артикулу. Для мерча без вариантов
+// и пустого артикула возвращает nil, для мерча с вариантами артикул обязателен
+func (m *MerchService) resolveVariant(ctx context.Context, merch *models.Item, sku string) (*models.Variant, error) {
+	if sku == "" {
+		variants, err := m.VariantStorage.List(ctx, []int{merch.Id})
+		if err != nil {
+			return nil, err
+		}
+		if len(variants) > 0 {
+			return nil, models.ErrVariantRequired
+		}
+		return nil, nil
 	}
 
-	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	variant, err := m.VariantStorage.GetBySku(ctx, sku)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
-	if user.Coins < merch.Price*count {
-		return -1, models.ErrNotEnoughCoins
+	if variant.MerchId != merch.Id {
+		return nil, models.ErrVariantNotFound
 	}
 
-	oldBalance := user.Coins
-	oldStock := merch.Stock
+	return variant, nil
+}
 
-	user.Coins -= merch.Price * count
-	merch.Stock -= count
+// orderItem - строка будущего заказа: заблокированный мерч,
+// заблокированный вариант (nil для мерча без вариантов) и количество
+type orderItem struct {
+	merch   *models.Item
+	variant *models.Variant
+	count   int
+}
 
-	err = m.MerchStorage.Update(ctx, merch)
+// stock - остаток строки: варианта, если он выбран, иначе мерча
+func (i orderItem) stock() int {
+	if i.variant != nil {
+		return i.variant.Stock
+	}
+	return i.merch.Stock
+}
+
+// price - цена строки за штуку без скидок с учетом цены варианта
+func (i orderItem) price() int {
+	if i.variant != nil {
+		return i.variant.PriceFor(i.merch)
+	}
+	return i.merch.Price
+}
+
+// placeOrder - оформляет заказ на уже заблокированный мерч, вызывается внутри WithinTx.
+// Проверяет доступный остаток (склад минус активные брони, см. reserved),
+// считает цены со скидками распродаж (см. models.BestSale) и промокода, блокирует промокод
+// promoCode (если задан) после мерча и до пользователя, проверяет баланс, лимиты промокода
+// и лимиты покупки мерча (см. checkPurchaseLimits),
+// уменьшает склад, сохраняет заказ и списывает его сумму одной записью журнала
+// и одной записью в истории кошелька. Возвращает заказ и новый баланс пользователя
+func (m *MerchService) placeOrder(ctx context.Context, userName string, items []orderItem, promoCode string) (*models.Order, int, error) {
+	now := time.Now()
+
+	sales, err := m.PromoStorage.ActiveSales(ctx, now)
 	if err != nil {
-		return -1, err
+		return nil, -1, err
 	}
 
-	if oldBalance != user.Coins {
-		err = m.CoinsStorage.Create(ctx, user, oldBalance)
+	var promo *models.PromoCode
+	if promoCode != "" {
+		promo, err = m.PromoStorage.GetPromoByCodeForUpdate(ctx, models.NormalizePromoCode(promoCode))
 		if err != nil {
-			return -1, err
+			return nil, -1, err
+		}
+		if !promo.ActiveAt(now) {
+			return nil, -1, models.ErrPromoInactive
+		}
+	}
+
+	order := &models.Order{Lines: make([]*models.OrderLine, 0, len(items))}
+	promoApplied := false
+	for _, item := range items {
+		reserved, err := m.reserved(ctx, item, now)
+		if err != nil {
+			return nil, -1, err
+		}
+		if item.stock()-reserved.Total() < item.count {
+			return nil, -1, models.ErrNotEnoughMerch
+		}
+
+		line := &models.OrderLine{
+			MerchId:   item.merch.Id,
+			Name:      item.merch.Name,
+			ListPrice: item.price(),
+			Count:     item.count,
+		}
+		if item.variant != nil {
+			line.VariantId = item.variant.Id
+			line.Variant = item.variant.Sku
+		}
+
+		var sale *models.Sale
+		sale, line.Price = models.BestSale(sales, line.MerchId, line.ListPrice)
+		if sale != nil {
+			line.SaleId = sale.Id
+		}
+		if promo != nil && promo.AppliesTo(line.MerchId) {
+			line.Price = promo.Apply(line.Price)
+			promoApplied = true
 		}
+
+		order.Total += line.Price * line.Count
+		order.Discount += (line.ListPrice - line.Price) * line.Count
+		order.Lines = append(order.Lines, line)
+	}
+
+	if promo != nil && !promoApplied {
+		return nil, -1, models.ErrPromoNotApplicable
 	}
 
-	err = m.UserStorage.Update(ctx, user)
+	user, err := m.UserStorage.GetByLoginForUpdate(ctx, userName)
 	if err != nil {
-		user.Coins += merch.Price * count
-		merch.Stock += count
-		return -1, err
+		return nil, -1, err
+	}
+
+	if promo != nil {
+		if err := m.checkPromoLimits(ctx, promo, user.Id); err != nil {
+			return nil, -1, err
+		}
+		order.PromoId = promo.Id
+		order.PromoCode = promo.Code
+	}
+
+	if err := m.checkPurchaseLimits(ctx, items, user.Id, now, 0); err != nil {
+		return nil, -1, err
+	}
+
+	if user.Coins < order.Total {
+		return nil, -1, models.ErrNotEnoughCoins
+	}
+
+	for _, item := range items {
+		if err := m.takeStock(ctx, item); err != nil {
+			return nil, -1, err
+		}
+	}
+
+	order.UserId = user.Id
+	if err := m.OrderStorage.Create(ctx, order); err != nil {
+		return nil, -1, err
+	}
+
+	// Бесплатный мерч не двигает монеты, а проводок с нулевой суммой не бывает
+	if order.Total > 0 {
+		entry := models.NewTransferEntry(models.ReasonPurchase, order.Id,
+			models.Posting{UserId: user.Id},
+			models.Posting{System: models.AccountShopRevenue},
+			order.Total,
+		)
+		if err := m.LedgerStorage.Post(ctx, entry); err != nil {
+			return nil, -1, err
+		}
+
+		oldBalance := user.Coins
+		user.Coins -= order.Total
+
+		if err := m.CoinsStorage.CreateForOrder(ctx, user, oldBalance, models.ReasonPurchase, order.Id); err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return order, user.Coins, nil
+}
+
+// takeStock - списывает строку заказа со склада варианта, если он выбран, иначе мерча
+func (m *MerchService) takeStock(ctx context.Context, item orderItem) error {
+	if item.variant != nil {
+		item.variant.Stock -= item.count
+		return m.VariantStorage.Update(ctx, item.variant)
+	}
+
+	item.merch.Stock -= item.count
+	return m.MerchStorage.Update(ctx, item.merch)
+}
+
+// checkPromoLimits - проверяет лимиты использования промокода, заблокированного в placeOrder,
+// поэтому параллельный заказ с тем же промокодом ждет конца транзакции
+func (m *MerchService) checkPromoLimits(ctx context.Context, promo *models.PromoCode, userID int) error {
+	total, byUser, err := m.OrderStorage.PromoUses(ctx, promo.Id, userID)
+	if err != nil {
+		return err
+	}
+
+	if promo.MaxUses > 0 && total >= promo.MaxUses {
+		return models.ErrPromoExhausted
+	}
+	if promo.MaxUsesPerUser > 0 && byUser >= promo.MaxUsesPerUser {
+		return models.ErrPromoUserLimit
+	}
+	return nil
+}
+
+// checkPurchaseLimits - проверяет лимиты покупки каждого мерча заказа. Штуки разных вариантов
+// одного мерча складываются. К прошлым покупкам добавляются активные в момент at брони
+// пользователя (кроме подтверждаемой брони excludeReservation): бронь - будущая покупка,
+// и бронями нельзя занять больше лимита. Покупки и брони читаются после блокировки
+// пользователя, поэтому параллельные заказы того же пользователя не обходят лимит
+func (m *MerchService) checkPurchaseLimits(ctx context.Context, items []orderItem, userID int, at time.Time, excludeReservation int) error {
+	counts := make(map[int]int, len(items))
+	merch := make([]*models.Item, 0, len(items))
+	for _, item := range items {
+		if _, seen := counts[item.merch.Id]; !seen {
+			merch = append(merch, item.merch)
+		}
+		counts[item.merch.Id] += item.count
+	}
+
+	for _, item := range merch {
+		var bought, boughtMonth int
+		if item.Limits.ByUser() {
+			var err error
+			bought, boughtMonth, err = m.OrderStorage.MerchBought(ctx, userID, item.Id)
+			if err != nil {
+				return err
+			}
+
+			reserved, err := m.ReservationStorage.UserReserved(ctx, userID, item.Id, at, excludeReservation)
+			if err != nil {
+				return err
+			}
+			bought += reserved
+			boughtMonth += reserved
+		}
+
+		if err := item.Limits.Check(counts[item.Id], bought, boughtMonth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MerchList - проверяет фильтр и запрос страницы, пробрасывает их ниже и ждёт страницу мерчей,
+// чтобы вернуть её вместе с вариантами, изображениями и идущими распродажами.
+// Остаток мерча с вариантами - сумма их остатков
+func (m *MerchService) MerchList(ctx context.Context, filter *models.MerchFilter) (*models.Page[*models.Item], error) {
+	if filter == nil {
+		filter = &models.MerchFilter{}
+	}
+
+	if err := filter.Normalize(); err != nil {
+		return nil, err
+	}
+
+	page, err := m.MerchStorage.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(page.Items))
+	byID := make(map[int]*models.Item, len(page.Items))
+	for _, item := range page.Items {
+		ids = append(ids, item.Id)
+		byID[item.Id] = item
+	}
+
+	variants, err := m.VariantStorage.List(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, variant := range variants {
+		item := byID[variant.MerchId]
+		if item.Variants == nil {
+			item.Stock = 0
+		}
+		item.Variants = append(item.Variants, variant)
+		item.Stock += variant.Stock
+	}
+
+	images, err := m.ImageStorage.List(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		image.SetURLs()
+		item := byID[image.MerchId]
+		item.Images = append(item.Images, image)
+	}
+
+	sales, err := m.PromoStorage.ActiveSales(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range page.Items {
+		if sale, price := models.BestSale(sales, item.Id, item.Price); sale != nil {
+			item.Sale = sale
+			item.SalePrice = price
+		}
+	}
+
+	return page, nil
+}
+
+// RefreshSearchText - пересчитывает текст для поиска по каталогу у всех мерчей
+// (см. models.MerchSearchText), возвращает число обновленных.
+// Повторный запуск безопасен - мерчи с актуальным текстом пропускаются
+func (m *MerchService) RefreshSearchText(ctx context.Context) (int, error) {
+	return m.MerchStorage.RefreshSearchText(ctx)
+}
+
+// CreateMerch - сохраняет новый мерч. Данные проверяет хранилище (пустое имя,
+// отрицательные цена, количество и лимиты покупки, длинная категория, занятое имя),
+// категория и теги приводятся к нижнему регистру
+func (m *MerchService) CreateMerch(ctx context.Context, req *models.MerchRequest) (*models.Item, error) {
+	item := &models.Item{
+		Name:     req.Name,
+		Price:    req.Price,
+		Stock:    req.Stock,
+		Category: models.NormalizeLabel(req.Category),
+		Tags:     models.NormalizeTags(req.Tags),
+		Limits:   req.Limits,
+	}
+
+	if err := m.MerchStorage.Create(ctx, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// ReplaceMerch - перезаписывает мерч id данными из запроса под блокировкой мерча.
+// Если мерч снова появился в наличии, уведомляет подписчиков (см. RestockNotifier)
+func (m *MerchService) ReplaceMerch(ctx context.Context, id int, req *models.MerchRequest) (*models.Item, error) {
+	item := &models.Item{
+		Id:       id,
+		Name:     req.Name,
+		Price:    req.Price,
+		Stock:    req.Stock,
+		Category: models.NormalizeLabel(req.Category),
+		Tags:     models.NormalizeTags(req.Tags),
+		Limits:   req.Limits,
+	}
+
+	var notes []*models.Notification
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := m.MerchStorage.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		before, err := m.Restock.before(ctx, current)
+		if err != nil {
+			return err
+		}
+
+		if err := m.MerchStorage.Update(ctx, item); err != nil {
+			return err
+		}
+
+		notes, err = m.Restock.after(ctx, item, before)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.Restock.deliver(ctx, notes)
+	return item, nil
+}
+
+// PatchMerch - читает мерч под блокировкой и меняет только переданные поля,
+// чтобы не затереть параллельные изменения остальных полей (например, stock при покупке).
+// Если мерч снова появился в наличии, уведомляет подписчиков (см. RestockNotifier)
+func (m *MerchService) PatchMerch(ctx context.Context, id int, req *models.MerchPatchRequest) (*models.Item, error) {
+	var item *models.Item
+	var notes []*models.Notification
+
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		item, err = m.MerchStorage.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		before, err := m.Restock.before(ctx, item)
+		if err != nil {
+			return err
+		}
+
+		if req.Name != nil {
+			item.Name = *req.Name
+		}
+		if req.Price != nil {
+			item.Price = *req.Price
+		}
+		if req.Stock != nil {
+			item.Stock = *req.Stock
+		}
+		if req.Category != nil {
+			item.Category = models.NormalizeLabel(*req.Category)
+		}
+		if req.Tags != nil {
+			item.Tags = models.NormalizeTags(*req.Tags)
+		}
+		if req.Limits != nil {
+			item.Limits = *req.Limits
+		}
+
+		if err := m.MerchStorage.Update(ctx, item); err != nil {
+			return err
+		}
+
+		notes, err = m.Restock.after(ctx, item, before)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.Restock.deliver(ctx, notes)
+	return item, nil
+}
+
+// DeleteMerch - мягко удаляет мерч (см. MerchStorage.Delete)
+func (m *MerchService) DeleteMerch(ctx context.Context, id int) error {
+	return m.MerchStorage.Delete(ctx, id)
+}
+
+// CreateVariant - сохраняет новый вариант мерча под блокировкой мерча. Данные проверяет
+// хранилище (пустой артикул, отрицательные цена и количество, занятый артикул).
+// Если мерч снова появился в наличии, уведомляет подписчиков (см. RestockNotifier)
+func (m *MerchService) CreateVariant(ctx context.Context, merchID int, req *models.VariantRequest) (*models.Variant, error) {
+	variant := &models.Variant{
+		MerchId:    merchID,
+		Sku:        req.Sku,
+		Attributes: req.Attributes,
+		Price:      req.Price,
+		Stock:      req.Stock,
+	}
+
+	notes, err := m.restockVariants(ctx, merchID, func(ctx context.Context) error {
+		return m.VariantStorage.Create(ctx, variant)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.Restock.deliver(ctx, notes)
+	return variant, nil
+}
+
+// ReplaceVariant - перезаписывает вариант данными из запроса.
+// Вариант другого мерча не отличается от несуществующего.
+// Если мерч снова появился в наличии, уведомляет подписчиков (см. RestockNotifier)
+func (m *MerchService) ReplaceVariant(ctx context.Context, merchID, variantID int, req *models.VariantRequest) (*models.Variant, error) {
+	var variant *models.Variant
+
+	notes, err := m.restockVariants(ctx, merchID, func(ctx context.Context) error {
+		var err error
+		variant, err = m.merchVariant(ctx, merchID, variantID)
+		if err != nil {
+			return err
+		}
+
+		variant.Sku = req.Sku
+		variant.Attributes = req.Attributes
+		variant.Price = req.Price
+		variant.Stock = req.Stock
+
+		return m.VariantStorage.Update(ctx, variant)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.Restock.deliver(ctx, notes)
+	return variant, nil
+}
+
+// restockVariants - выполняет change над вариантами мерча merchID в одной транзакции
+// под блокировкой мерча (тот же порядок блокировок, что и при покупке)
+// и возвращает уведомления, если мерч снова появился в наличии
+func (m *MerchService) restockVariants(ctx context.Context, merchID int, change func(ctx context.Context) error) ([]*models.Notification, error) {
+	var notes []*models.Notification
+
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		merch, err := m.MerchStorage.GetForUpdate(ctx, merchID)
+		if err != nil {
+			return err
+		}
+
+		before, err := m.Restock.before(ctx, merch)
+		if err != nil {
+			return err
+		}
+
+		if err := change(ctx); err != nil {
+			return err
+		}
+
+		notes, err = m.Restock.after(ctx, merch, before)
+		return err
+	})
+
+	return notes, err
+}
+
+// DeleteVariant - мягко удаляет вариант (см. VariantStorage.Delete)
+func (m *MerchService) DeleteVariant(ctx context.Context, merchID, variantID int) error {
+	return m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := m.merchVariant(ctx, merchID, variantID); err != nil {
+			return err
+		}
+
+		return m.VariantStorage.Delete(ctx, variantID)
+	})
+}
+
+// merchVariant - блокирует вариант variantID, если он принадлежит мерчу merchID
+func (m *MerchService) merchVariant(ctx context.Context, merchID, variantID int) (*models.Variant, error) {
+	variant, err := m.VariantStorage.GetForUpdate(ctx, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if variant.MerchId != merchID {
+		return nil, models.ErrVariantNotFound
+	}
+
+	return variant, nil
+}
+
+// Cart - возвращает корзину пользователя
+func (m *MerchService) Cart(ctx context.Context, userName string) (*models.Cart, error) {
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.cart(ctx, user.Id)
+}
+
+// cart - читает строки корзины и считает суммы с учетом идущих распродаж.
+// Промокод применяется только при оформлении
+func (m *MerchService) cart(ctx context.Context, userID int) (*models.Cart, error) {
+	lines, err := m.CartStorage.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sales, err := m.PromoStorage.ActiveSales(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		if sale, price := models.BestSale(sales, line.MerchId, line.Price); sale != nil {
+			line.ListPrice = line.Price
+			line.Price = price
+		}
+	}
+
+	return models.NewCart(lines), nil
+}
+
+// AddToCart - находит мерч по имени (и вариант по артикулу) и добавляет его в корзину.
+// Наличие на складе проверяется только при оформлении
+func (m *MerchService) AddToCart(ctx context.Context, userName, merchName, variant string, count int) (*models.Cart, error) {
+	if count <= 0 {
+		return nil, models.ErrInvalidCount
+	}
+
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	merch, err := m.MerchStorage.GetByName(ctx, merchName)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := m.resolveVariant(ctx, merch, variant)
+	if err != nil {
+		return nil, err
 	}
 
-	if oldStock != merch.Stock {
-		err = m.PurchaseStorage.Create(ctx, user, merch, count)
+	if err := m.CartStorage.Add(ctx, user.Id, merch.Id, variantID(v), count); err != nil {
+		return nil, err
+	}
+
+	return m.cart(ctx, user.Id)
+}
+
+// SetCartItem - задает количество мерча в корзине, нулевое количество убирает мерч
+func (m *MerchService) SetCartItem(ctx context.Context, userName string, merchID int, variant string, count int) (*models.Cart, error) {
+	if count < 0 {
+		return nil, models.ErrInvalidCount
+	}
+
+	if count == 0 {
+		return m.RemoveFromCart(ctx, userName, merchID, variant)
+	}
+
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	merch, err := m.MerchStorage.Get(ctx, merchID)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := m.resolveVariant(ctx, merch, variant)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.CartStorage.Set(ctx, user.Id, merchID, variantID(v), count); err != nil {
+		return nil, err
+	}
+
+	return m.cart(ctx, user.Id)
+}
+
+// RemoveFromCart - убирает мерч из корзины
+func (m *MerchService) RemoveFromCart(ctx context.Context, userName string, merchID int, variant string) (*models.Cart, error) {
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	vID := 0
+	if variant != "" {
+		v, err := m.VariantStorage.GetBySku(ctx, variant)
 		if err != nil {
-			return -1, err
+			return nil, err
+		}
+		if v.MerchId != merchID {
+			return nil, models.ErrVariantNotFound
 		}
+		vID = v.Id
+	}
+
+	if err := m.CartStorage.Remove(ctx, user.Id, merchID, vID); err != nil {
+		return nil, err
 	}
 
-	return user.Coins, nil
+	return m.cart(ctx, user.Id)
 }
 
-// MerchList - пробрасывает контекст ниже и ждёт слайс мерчей, чтобы вернуть его
-func (m *MerchService) MerchList(ctx context.Context) ([]*models.Item, error) {
-	return m.MerchStorage.GetList(ctx)
+// variantID - id варианта или 0 для мерча без вариантов
+func variantID(v *models.Variant) int {
+	if v == nil {
+		return 0
+	}
+	return v.Id
+}
+
+// Checkout - оформляет корзину одним заказом в одной транзакции.
+// Строки корзины блокируются первыми, затем мерч и его варианты в порядке id и пользователь,
+// как в Buy, поэтому оформление не взаимоблокируется с покупками.
+// Купленные строки убираются из корзины, цены и скидки берутся на момент оформления.
+// Строки удаленных из каталога товаров и вариантов убираются до оформления
+// (даже если оформление не удалось) и возвращаются в CheckoutResult.Removed
+func (m *MerchService) Checkout(ctx context.Context, userName, promo string) (*models.CheckoutResult, error) {
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := m.CartStorage.RemoveDeleted(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.CheckoutResult{Removed: removed}
+	err = m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		lines, err := m.CartStorage.GetForUpdate(ctx, user.Id)
+		if err != nil {
+			return err
+		}
+
+		if len(lines) == 0 {
+			return models.ErrEmptyCart
+		}
+
+		items := make([]orderItem, 0, len(lines))
+		for _, line := range lines {
+			merch, err := m.MerchStorage.GetForUpdate(ctx, line.MerchId)
+			if err != nil {
+				return err
+			}
+
+			// Строка без варианта могла попасть в корзину до появления вариантов у мерча
+			var variant *models.Variant
+			if line.VariantId != 0 {
+				variant, err = m.VariantStorage.GetForUpdate(ctx, line.VariantId)
+			} else {
+				_, err = m.resolveVariant(ctx, merch, "")
+			}
+			if err != nil {
+				return err
+			}
+
+			items = append(items, orderItem{merch: merch, variant: variant, count: line.Count})
+		}
+
+		result.Order, result.Balance, err = m.placeOrder(ctx, userName, items, promo)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			if err := m.CartStorage.Remove(ctx, user.Id, line.MerchId, line.VariantId); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"time"
+)
+
+// deliveryBatch - сколько недоставленных уведомлений отправляется одним запросом
+const deliveryBatch = 100
+
+type NotificationServiceInterface interface {
+	// Wishlist - возвращает список желаний пользователя
+	Wishlist(ctx context.Context, userName string) ([]*models.WishlistItem, error)
+
+	// AddToWishlist - добавляет мерч в список желаний или меняет подписку
+	// на его появление в наличии, возвращает список желаний
+	AddToWishlist(ctx context.Context, userName string, merchID int, notify bool) ([]*models.WishlistItem, error)
+
+	// RemoveFromWishlist - убирает мерч из списка желаний, возвращает список желаний
+	RemoveFromWishlist(ctx context.Context, userName string, merchID int) ([]*models.WishlistItem, error)
+
+	// Notifications - возвращает страницу уведомлений пользователя
+	Notifications(ctx context.Context, userName string, q *models.PageQuery) (*models.Page[*models.Notification], error)
+
+	// ReadNotification - отмечает уведомление пользователя прочитанным
+	ReadNotification(ctx context.Context, userName string, id int) error
+}
+
+var _ NotificationServiceInterface = (*NotificationService)(nil)
+
+// NotificationService - реализует интерфейс NotificationServiceInterface
+type NotificationService struct {
+	WishlistStorage     entities.WishlistStorage
+	NotificationStorage entities.NotificationStorage
+	UserStorage         entities.UserStorage
+	VariantStorage      entities.VariantStorage
+}
+
+// NewNotificationService - создает объект NotificationService
+func NewNotificationService(w entities.WishlistStorage, n entities.NotificationStorage, u entities.UserStorage, v entities.VariantStorage) *NotificationService {
+	return &NotificationService{
+		WishlistStorage:     w,
+		NotificationStorage: n,
+		UserStorage:         u,
+		VariantStorage:      v,
+	}
+}
+
+// Wishlist - возвращает список желаний. Остаток мерча с вариантами -
+// сумма остатков вариантов, как в каталоге (см. MerchService.MerchList)
+func (n *NotificationService) Wishlist(ctx context.Context, userName string) ([]*models.WishlistItem, error) {
+	user, err := n.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.wishlist(ctx, user.Id)
+}
+
+// wishlist - список желаний пользователя userID с остатками вариантов
+func (n *NotificationService) wishlist(ctx context.Context, userID int) ([]*models.WishlistItem, error) {
+	items, err := n.WishlistStorage.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(items))
+	byID := make(map[int]*models.WishlistItem, len(items))
+	for _, item := range items {
+		ids = append(ids, item.MerchId)
+		byID[item.MerchId] = item
+	}
+
+	variants, err := n.VariantStorage.List(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	withVariants := make(map[int]bool)
+	for _, variant := range variants {
+		item := byID[variant.MerchId]
+		if !withVariants[item.MerchId] {
+			withVariants[item.MerchId] = true
+			item.Stock = 0
+		}
+		item.Stock += variant.Stock
+	}
+
+	return items, nil
+}
+
+// AddToWishlist - добавляет мерч merchID в список желаний. Повторное добавление
+// только меняет подписку notify
+func (n *NotificationService) AddToWishlist(ctx context.Context, userName string, merchID int, notify bool) ([]*models.WishlistItem, error) {
+	if merchID <= 0 {
+		return nil, models.ErrInvalidMerchID
+	}
+
+	user, err := n.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := n.WishlistStorage.Add(ctx, user.Id, merchID, notify); err != nil {
+		return nil, err
+	}
+
+	return n.wishlist(ctx, user.Id)
+}
+
+// RemoveFromWishlist - убирает мерч merchID из списка желаний
+func (n *NotificationService) RemoveFromWishlist(ctx context.Context, userName string, merchID int) ([]*models.WishlistItem, error) {
+	user, err := n.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := n.WishlistStorage.Remove(ctx, user.Id, merchID); err != nil {
+		return nil, err
+	}
+
+	return n.wishlist(ctx, user.Id)
+}
+
+// Notifications - проверяет запрос страницы и возвращает уведомления
+// пользователя от новых к старым
+func (n *NotificationService) Notifications(ctx context.Context, userName string, q *models.PageQuery) (*models.Page[*models.Notification], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	user, err := n.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.NotificationStorage.List(ctx, user.Id, q)
+}
+
+// ReadNotification - отмечает уведомление id прочитанным.
+// Чужое уведомление не отличается от несуществующего
+func (n *NotificationService) ReadNotification(ctx context.Context, userName string, id int) error {
+	user, err := n.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return err
+	}
+
+	return n.NotificationStorage.MarkRead(ctx, user.Id, id)
+}
+
+// RestockNotifier - уведомляет подписчиков (см. WishlistStorage.Add), когда мерч
+// снова появляется в наличии: остаток в продаже переходит от нуля к положительному.
+// Уведомления создаются в транзакции, изменившей склад, под блокировкой строки мерча,
+// поэтому одно появление в наличии дает каждому подписчику ровно одно уведомление.
+// Внешний канал Notifier (может быть nil) вызывается после фиксации транзакции;
+// уведомления, которые он не принял, отправляются повторно (см. DeliverNotifications),
+// а доставленные отмечаются и больше не отправляются.
+// nil *RestockNotifier уведомлений не создает
+type RestockNotifier struct {
+	NotificationStorage entities.NotificationStorage
+	VariantStorage      entities.VariantStorage
+	Notifier            entities.Notifier
+}
+
+// NewRestockNotifier - создает объект RestockNotifier
+func NewRestockNotifier(n entities.NotificationStorage, v entities.VariantStorage, notifier entities.Notifier) *RestockNotifier {
+	return &RestockNotifier{
+		NotificationStorage: n,
+		VariantStorage:      v,
+		Notifier:            notifier,
+	}
+}
+
+// available - остаток мерча в продаже: у мерча с вариантами - сумма остатков вариантов
+func (r *RestockNotifier) available(ctx context.Context, merch *models.Item) (int, error) {
+	variants, err := r.VariantStorage.List(ctx, []int{merch.Id})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(variants) == 0 {
+		return merch.Stock, nil
+	}
+
+	stock := 0
+	for _, variant := range variants {
+		stock += variant.Stock
+	}
+	return stock, nil
+}
+
+// before - остаток мерча до изменения склада. Вызывается под блокировкой мерча
+func (r *RestockNotifier) before(ctx context.Context, merch *models.Item) (int, error) {
+	if r == nil {
+		return 0, nil
+	}
+	return r.available(ctx, merch)
+}
+
+// after - создает уведомления, если мерч с остатком before снова в наличии.
+// Вызывается в той же транзакции после изменения склада
+func (r *RestockNotifier) after(ctx context.Context, merch *models.Item, before int) ([]*models.Notification, error) {
+	if r == nil || before > 0 {
+		return nil, nil
+	}
+
+	stock, err := r.available(ctx, merch)
+	if err != nil || stock <= 0 {
+		return nil, err
+	}
+
+	return r.NotificationStorage.CreateBackInStock(ctx, merch.Id)
+}
+
+// deliver - рассылает сохраненные уведомления во внешний канал. Ошибка канала
+// только логируется: уведомления уже лежат во внутреннем ящике и будут
+// отправлены повторно (см. DeliverPending)
+func (r *RestockNotifier) deliver(ctx context.Context, notes []*models.Notification) {
+	if r == nil || r.Notifier == nil || len(notes) == 0 {
+		return
+	}
+
+	if err := r.send(ctx, notes); err != nil {
+		log.Printf("не удалось отправить %d уведомлений: %v", len(notes), err)
+	}
+}
+
+// send - отправляет уведомления во внешний канал и отмечает их доставленными.
+// Если канал принял уведомления, а отметка не сохранилась, они будут отправлены
+// еще раз: канал отбрасывает повторы по id уведомления (см. entities.Notifier)
+func (r *RestockNotifier) send(ctx context.Context, notes []*models.Notification) error {
+	if err := r.Notifier.Notify(ctx, notes); err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.Id)
+	}
+	return r.NotificationStorage.MarkDelivered(ctx, ids, time.Now().UTC())
+}
+
+// DeliverPending - отправляет во внешний канал все недоставленные уведомления
+// пачками по deliveryBatch и возвращает число доставленных.
+// Без внешнего канала ничего не делает
+func (r *RestockNotifier) DeliverPending(ctx context.Context) (int, error) {
+	if r == nil || r.Notifier == nil {
+		return 0, nil
+	}
+
+	delivered := 0
+	for {
+		notes, err := r.NotificationStorage.Undelivered(ctx, deliveryBatch)
+		if err != nil || len(notes) == 0 {
+			return delivered, err
+		}
+
+		if err := r.send(ctx, notes); err != nil {
+			return delivered, err
+		}
+		delivered += len(notes)
+
+		if len(notes) < deliveryBatch {
+			return delivered, nil
+		}
+	}
+}
+
+// DeliverNotifications - фоновая повторная отправка недоставленных уведомлений
+// (см. DeliverPending) каждые interval (0 - DefaultSweepInterval), пока не отменен ctx.
+// Ошибки только логируются: следующий проход повторит попытку
+func (r *RestockNotifier) DeliverNotifications(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := r.DeliverPending(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("не удалось отправить недоставленные уведомления: %v", err)
+			}
+			if n > 0 {
+				log.Printf("отправлено недоставленных уведомлений: %d", n)
+			}
+		}
+	}
+}
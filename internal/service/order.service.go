@@ -0,0 +1,310 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"slices"
+)
+
+type OrderServiceInterface interface {
+	// Orders - возвращает страницу заказов пользователя
+	Orders(ctx context.Context, userName string, q *models.PageQuery) (*models.Page[*models.Order], error)
+
+	// Order - возвращает заказ пользователя по id.
+	// Чужой заказ не отличается от несуществующего
+	Order(ctx context.Context, userName string, id int) (*models.Order, error)
+
+	// AllOrders - (админ) возвращает страницу заказов всех пользователей
+	AllOrders(ctx context.Context, filter *models.OrderFilter) (*models.Page[*models.Order], error)
+
+	// AdvanceOrder - (админ) переводит заказ в следующий статус выдачи
+	// и возвращает измененный заказ
+	AdvanceOrder(ctx context.Context, id int, status models.OrderStatus) (*models.Order, error)
+
+	// CancelOrder - отменяет еще не выданный заказ пользователя,
+	// возвращает монеты и товар на склад
+	CancelOrder(ctx context.Context, userName string, id int) (*models.Order, error)
+
+	// AdminCancelOrder - (админ) отменяет любой неотмененный заказ,
+	// в том числе выданный
+	AdminCancelOrder(ctx context.Context, id int) (*models.Order, error)
+}
+
+var _ OrderServiceInterface = (*OrderService)(nil)
+
+// OrderService - реализует интерфейс OrderServiceInterface
+type OrderService struct {
+	OrderStorage   entities.OrderStorage
+	UserStorage    entities.UserStorage
+	MerchStorage   entities.MerchStorage
+	CoinsStorage   entities.CoinsStorage
+	TxManager      entities.TxManager
+	LedgerStorage  entities.LedgerStorage
+	VariantStorage entities.VariantStorage
+	Restock        *RestockNotifier
+}
+
+// NewOrderService - создает объект OrderService
+func NewOrderService(o entities.OrderStorage, u entities.UserStorage, m entities.MerchStorage, c entities.CoinsStorage, tx entities.TxManager, l entities.LedgerStorage, v entities.VariantStorage, r *RestockNotifier) *OrderService {
+	return &OrderService{
+		OrderStorage:   o,
+		UserStorage:    u,
+		MerchStorage:   m,
+		CoinsStorage:   c,
+		TxManager:      tx,
+		LedgerStorage:  l,
+		VariantStorage: v,
+		Restock:        r,
+	}
+}
+
+// Orders - проверяет запрос страницы и существование пользователя
+// и возвращает его заказы от новых к старым
+func (o *OrderService) Orders(ctx context.Context, userName string, q *models.PageQuery) (*models.Page[*models.Order], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	user, err := o.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.OrderStorage.List(ctx, &models.OrderFilter{UserId: user.Id, PageQuery: *q})
+}
+
+// Order - возвращает заказ, если он принадлежит пользователю
+func (o *OrderService) Order(ctx context.Context, userName string, id int) (*models.Order, error) {
+	user, err := o.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := o.OrderStorage.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.UserId != user.Id {
+		return nil, models.ErrOrderNotFound
+	}
+
+	return order, nil
+}
+
+// AllOrders - проверяет фильтр и возвращает страницу заказов
+func (o *OrderService) AllOrders(ctx context.Context, filter *models.OrderFilter) (*models.Page[*models.Order], error) {
+	if filter == nil {
+		filter = &models.OrderFilter{}
+	}
+
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, models.ErrInvalidOrderStatus
+	}
+
+	if err := filter.Normalize(); err != nil {
+		return nil, err
+	}
+
+	return o.OrderStorage.List(ctx, filter)
+}
+
+// AdvanceOrder - переводит заказ в статус status.
+// Заказ блокируется, поэтому два администратора не могут одновременно
+// перевести его в разные статусы. Допустимые переходы - models.OrderStatus.CanAdvanceTo
+func (o *OrderService) AdvanceOrder(ctx context.Context, id int, status models.OrderStatus) (*models.Order, error) {
+	if !status.Valid() {
+		return nil, models.ErrInvalidOrderStatus
+	}
+
+	var order *models.Order
+	err := o.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := o.OrderStorage.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if !current.Status.CanAdvanceTo(status) {
+			return models.ErrOrderTransition
+		}
+
+		if err := o.OrderStorage.UpdateStatus(ctx, id, status); err != nil {
+			return err
+		}
+
+		order, err = o.OrderStorage.Get(ctx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// CancelOrder - отменяет заказ от имени покупателя.
+// Выданный заказ покупатель отменить не может, чужой заказ не отличается от несуществующего
+func (o *OrderService) CancelOrder(ctx context.Context, userName string, id int) (*models.Order, error) {
+	user, err := o.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.cancel(ctx, id, func(order *models.Order) error {
+		if order.UserId != user.Id {
+			return models.ErrOrderNotFound
+		}
+		if order.Status.Final() {
+			return models.ErrOrderTransition
+		}
+		return nil
+	})
+}
+
+// AdminCancelOrder - отменяет заказ от имени администратора
+func (o *OrderService) AdminCancelOrder(ctx context.Context, id int) (*models.Order, error) {
+	return o.cancel(ctx, id, func(order *models.Order) error {
+		if order.Status == models.OrderCancelled {
+			return models.ErrOrderTransition
+		}
+		return nil
+	})
+}
+
+// cancel - отменяет заказ id в одной транзакции, если его пропускает check:
+// возвращает товар на склад, сумму заказа - покупателю (запись журнала ReasonRefund
+// и запись в истории кошелька со ссылкой на заказ) и переводит заказ в OrderCancelled.
+// Блокировки берутся в порядке заказ, мерч по id, его варианты, пользователь - мерч,
+// варианты и пользователь в том же порядке, что и при покупке (см. MerchService.placeOrder).
+// Купленный вариант возвращается на склад варианта.
+// Товар или вариант, удаленный из каталога, на склад не возвращается.
+// Если товар снова появился в наличии, подписчики получают уведомление (см. RestockNotifier)
+func (o *OrderService) cancel(ctx context.Context, id int, check func(order *models.Order) error) (*models.Order, error) {
+	var order *models.Order
+	var notes []*models.Notification
+
+	err := o.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := o.OrderStorage.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := check(current); err != nil {
+			return err
+		}
+
+		lines := slices.SortedFunc(slices.Values(current.Lines), func(a, b *models.OrderLine) int {
+			return cmp.Or(cmp.Compare(a.MerchId, b.MerchId), cmp.Compare(a.VariantId, b.VariantId))
+		})
+		for len(lines) > 0 {
+			end := 1
+			for end < len(lines) && lines[end].MerchId == lines[0].MerchId {
+				end++
+			}
+
+			created, err := o.restock(ctx, lines[:end])
+			if err != nil {
+				return err
+			}
+			notes = append(notes, created...)
+
+			lines = lines[end:]
+		}
+
+		if current.Total > 0 {
+			user, err := o.UserStorage.GetByLoginForUpdate(ctx, current.Login)
+			if err != nil {
+				return err
+			}
+
+			entry := models.NewTransferEntry(models.ReasonRefund, current.Id,
+				models.Posting{System: models.AccountShopRevenue},
+				models.Posting{UserId: user.Id},
+				current.Total,
+			)
+			if err := o.LedgerStorage.Post(ctx, entry); err != nil {
+				return err
+			}
+
+			oldBalance := user.Coins
+			user.Coins += current.Total
+
+			if err := o.CoinsStorage.CreateForOrder(ctx, user, oldBalance, models.ReasonRefund, current.Id); err != nil {
+				return err
+			}
+		}
+
+		if err := o.OrderStorage.UpdateStatus(ctx, id, models.OrderCancelled); err != nil {
+			return err
+		}
+
+		order, err = o.OrderStorage.Get(ctx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	o.Restock.deliver(ctx, notes)
+	return order, nil
+}
+
+// restock - возвращает на склад строки заказа одного мерча под блокировкой мерча
+// и возвращает уведомления, если мерч снова появился в наличии
+func (o *OrderService) restock(ctx context.Context, lines []*models.OrderLine) ([]*models.Notification, error) {
+	merch, err := o.MerchStorage.GetForUpdate(ctx, lines[0].MerchId)
+	if errors.Is(err, models.ErrMerchNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := o.Restock.before(ctx, merch)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := merch.Stock
+	for _, line := range lines {
+		if line.VariantId != 0 {
+			if err := o.restockVariant(ctx, line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		merch.Stock += line.Count
+	}
+
+	if merch.Stock != stock {
+		if err := o.MerchStorage.Update(ctx, merch); err != nil {
+			return nil, err
+		}
+	}
+
+	return o.Restock.after(ctx, merch, before)
+}
+
+// restockVariant - возвращает на склад купленный вариант строки заказа,
+// если вариант еще есть в каталоге
+func (o *OrderService) restockVariant(ctx context.Context, line *models.OrderLine) error {
+	variant, err := o.VariantStorage.GetForUpdate(ctx, line.VariantId)
+	if errors.Is(err, models.ErrVariantNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	variant.Stock += line.Count
+	return o.VariantStorage.Update(ctx, variant)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"merch_service/internal/models"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// PasswordHasher - хеширование и проверка паролей
+type PasswordHasher interface {
+	// Hash - возвращает хеш пароля вместе с параметрами хеширования
+	Hash(password string) (string, error)
+
+	// Verify - сравнивает пароль с сохраненным значением.
+	// needsRehash == true, если сохраненное значение нужно пересчитать:
+	// оно записано с устаревшими параметрами или вовсе в открытом виде
+	Verify(password, encoded string) (ok bool, needsRehash bool, err error)
+
+	// IsHashed - проверяет, что значение является хешем, а не открытым паролем
+	IsHashed(encoded string) bool
+}
+
+// Argon2Params - параметры argon2id
+// см. [RFC 9106](https://www.rfc-editor.org/rfc/rfc9106.html#section-4)
+type Argon2Params struct {
+	Memory  uint32 // В КиБ
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params - рекомендованные OWASP параметры argon2id
+var DefaultArgon2Params = Argon2Params{
+	Memory:  64 * 1024,
+	Time:    1,
+	Threads: 4,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+const argon2Prefix = "$argon2id$"
+
+var _ PasswordHasher = (*Argon2Hasher)(nil)
+
+// Argon2Hasher - реализует PasswordHasher на argon2id.
+// Хеш хранится в формате PHC:
+//
+//	$argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+type Argon2Hasher struct {
+	params Argon2Params
+}
+
+// NewArgon2Hasher - создает объект Argon2Hasher
+func NewArgon2Hasher(params Argon2Params) *Argon2Hasher {
+	return &Argon2Hasher{params: params}
+}
+
+// Hash - хеширует пароль со случайной солью
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Time,
+		h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify - проверяет пароль. Пароли, сохраненные до перехода на хеширование
+// (в открытом виде), тоже принимаются, но помечаются как требующие пересчета
+func (h *Argon2Hasher) Verify(password, encoded string) (bool, bool, error) {
+	if !h.IsHashed(encoded) {
+		ok := subtle.ConstantTimeCompare([]byte(password), []byte(encoded)) == 1
+		return ok, ok, nil
+	}
+
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := params.Memory != h.params.Memory ||
+		params.Time != h.params.Time ||
+		params.Threads != h.params.Threads ||
+		params.SaltLen != h.params.SaltLen ||
+		params.KeyLen != h.params.KeyLen
+
+	return true, needsRehash, nil
+}
+
+// IsHashed - проверяет, что значение записано в формате argon2id
+func (h *Argon2Hasher) IsHashed(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2Prefix)
+}
+
+// decodeArgon2 - разбирает строку формата PHC на параметры, соль и хеш
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, models.ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, models.ErrInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, models.ErrInvalidPasswordHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, models.ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, models.ErrInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, models.ErrInvalidPasswordHash
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
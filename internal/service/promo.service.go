@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+	"strings"
+)
+
+type PromoServiceInterface interface {
+	// CreatePromo - (админ) добавляет промокод
+	CreatePromo(ctx context.Context, req *models.PromoCodeRequest) (*models.PromoCode, error)
+
+	// Promos - (админ) возвращает промокоды с числом использований
+	Promos(ctx context.Context) ([]*models.PromoCode, error)
+
+	// DeletePromo - (админ) удаляет промокод, заказы с ним сохраняются
+	DeletePromo(ctx context.Context, id int) error
+
+	// CreateSale - (админ) добавляет распродажу
+	CreateSale(ctx context.Context, req *models.SaleRequest) (*models.Sale, error)
+
+	// Sales - (админ) возвращает распродажи, в том числе прошедшие и будущие
+	Sales(ctx context.Context) ([]*models.Sale, error)
+
+	// DeleteSale - (админ) удаляет распродажу, покупки по ней сохраняются
+	DeleteSale(ctx context.Context, id int) error
+}
+
+var _ PromoServiceInterface = (*PromoService)(nil)
+
+// PromoService - реализует интерфейс PromoServiceInterface
+type PromoService struct {
+	PromoStorage entities.PromoStorage
+	MerchStorage entities.MerchStorage
+	OrderStorage entities.OrderStorage
+}
+
+// NewPromoService - создает объект PromoService
+func NewPromoService(p entities.PromoStorage, m entities.MerchStorage, o entities.OrderStorage) *PromoService {
+	return &PromoService{
+		PromoStorage: p,
+		MerchStorage: m,
+		OrderStorage: o,
+	}
+}
+
+// checkMerch - скидка на один товар требует существующего товара
+func (p *PromoService) checkMerch(ctx context.Context, merchID int) error {
+	if merchID == 0 {
+		return nil
+	}
+	_, err := p.MerchStorage.Get(ctx, merchID)
+	return err
+}
+
+// CreatePromo - сохраняет промокод в верхнем регистре. Скидку, лимиты
+// и срок действия проверяет хранилище
+func (p *PromoService) CreatePromo(ctx context.Context, req *models.PromoCodeRequest) (*models.PromoCode, error) {
+	if err := p.checkMerch(ctx, req.MerchId); err != nil {
+		return nil, err
+	}
+
+	promo := &models.PromoCode{
+		Code:           models.NormalizePromoCode(req.Code),
+		Discount:       models.Discount{Kind: req.Kind, Value: req.Value},
+		MerchId:        req.MerchId,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+	}
+
+	if err := p.PromoStorage.CreatePromo(ctx, promo); err != nil {
+		return nil, err
+	}
+
+	return promo, nil
+}
+
+// Promos - возвращает промокоды, число использований считается по неотмененным заказам
+func (p *PromoService) Promos(ctx context.Context) ([]*models.PromoCode, error) {
+	promos, err := p.PromoStorage.ListPromos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, promo := range promos {
+		if promo.Uses, _, err = p.OrderStorage.PromoUses(ctx, promo.Id, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return promos, nil
+}
+
+// DeletePromo - пробрасывает удаление промокода в хранилище
+func (p *PromoService) DeletePromo(ctx context.Context, id int) error {
+	return p.PromoStorage.DeletePromo(ctx, id)
+}
+
+// CreateSale - сохраняет распродажу. Скидку и срок проверяет хранилище
+func (p *PromoService) CreateSale(ctx context.Context, req *models.SaleRequest) (*models.Sale, error) {
+	if err := p.checkMerch(ctx, req.MerchId); err != nil {
+		return nil, err
+	}
+
+	sale := &models.Sale{
+		Name:     strings.TrimSpace(req.Name),
+		Discount: models.Discount{Kind: req.Kind, Value: req.Value},
+		MerchId:  req.MerchId,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+	}
+
+	if err := p.PromoStorage.CreateSale(ctx, sale); err != nil {
+		return nil, err
+	}
+
+	return sale, nil
+}
+
+// Sales - пробрасывает список распродаж из хранилища
+func (p *PromoService) Sales(ctx context.Context) ([]*models.Sale, error) {
+	return p.PromoStorage.ListSales(ctx)
+}
+
+// DeleteSale - пробрасывает удаление распродажи в хранилище
+func (p *PromoService) DeleteSale(ctx context.Context, id int) error {
+	return p.PromoStorage.DeleteSale(ctx, id)
+}
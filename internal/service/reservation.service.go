@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"merch_service/internal/models"
+	"time"
+)
+
+const (
+	// DefaultHoldTTL - срок удержания товара в наличии, если он не задан
+	DefaultHoldTTL = 15 * time.Minute
+
+	// DefaultPreorderTTL - срок предзаказа, если он не задан
+	DefaultPreorderTTL = 7 * 24 * time.Hour
+
+	// DefaultSweepInterval - период очистки истекших броней, если он не задан
+	DefaultSweepInterval = time.Minute
+
+	// expireBatch - сколько истекших броней закрывается за один проход очистки
+	expireBatch = 100
+)
+
+// reserved - штуки строки заказа в активных на момент at бронях
+func (m *MerchService) reserved(ctx context.Context, item orderItem, at time.Time) (models.Reserved, error) {
+	return m.ReservationStorage.Reserved(ctx, item.merch.Id, variantID(item.variant), at)
+}
+
+// Reserve - бронирует count штук мерча (варианта) по цене с учетом распродажи на момент брони.
+// Удержание (hold) требует доступного остатка: склада минус активные брони. Предзаказ (preorder)
+// можно оформить и без товара на складе, он занимает место в очереди на поступление.
+// Бронь не меняет склад, а уменьшает доступный остаток, поэтому другие покупатели
+// не могут купить забронированные штуки. Стоимость брони списывается с пользователя
+// на счет AccountReservations (запись журнала ReasonReserve и запись в истории кошелька).
+// Блокировки берутся в том же порядке, что и при покупке: мерч, вариант, пользователь.
+// Промокоды к брони не применяются
+func (m *MerchService) Reserve(ctx context.Context, userName string, req *models.ReservationRequest, ttl time.Duration) (*models.Reservation, error) {
+	if req.Count <= 0 {
+		return nil, models.ErrInvalidCount
+	}
+
+	kind := models.ReservationHold
+	if req.Preorder {
+		kind = models.ReservationPreorder
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultHoldTTL
+		if kind == models.ReservationPreorder {
+			ttl = DefaultPreorderTTL
+		}
+	}
+
+	var res *models.Reservation
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		merch, err := m.MerchStorage.GetByNameForUpdate(ctx, req.Item)
+		if err != nil {
+			return err
+		}
+
+		v, err := m.resolveVariant(ctx, merch, req.Variant)
+		if err != nil {
+			return err
+		}
+
+		if v != nil {
+			if v, err = m.VariantStorage.GetForUpdate(ctx, v.Id); err != nil {
+				return err
+			}
+		}
+
+		item := orderItem{merch: merch, variant: v, count: req.Count}
+		now := time.Now().UTC()
+
+		if kind == models.ReservationHold {
+			reserved, err := m.reserved(ctx, item, now)
+			if err != nil {
+				return err
+			}
+			if item.stock()-reserved.Total() < item.count {
+				return models.ErrNotEnoughMerch
+			}
+		}
+
+		sales, err := m.PromoStorage.ActiveSales(ctx, now)
+		if err != nil {
+			return err
+		}
+
+		res = &models.Reservation{
+			Kind:      kind,
+			MerchId:   merch.Id,
+			Name:      merch.Name,
+			Count:     item.count,
+			ListPrice: item.price(),
+			ExpiresAt: now.Add(ttl),
+		}
+		if v != nil {
+			res.VariantId = v.Id
+			res.Variant = v.Sku
+		}
+
+		var sale *models.Sale
+		sale, res.Price = models.BestSale(sales, res.MerchId, res.ListPrice)
+		if sale != nil {
+			res.SaleId = sale.Id
+		}
+
+		user, err := m.UserStorage.GetByLoginForUpdate(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if err := m.checkPurchaseLimits(ctx, []orderItem{item}, user.Id, now, 0); err != nil {
+			return err
+		}
+
+		if user.Coins < res.Price*res.Count {
+			return models.ErrNotEnoughCoins
+		}
+
+		res.UserId = user.Id
+		if err := m.ReservationStorage.Create(ctx, res); err != nil {
+			return err
+		}
+
+		if res.Total == 0 {
+			return nil
+		}
+
+		entry := models.NewTransferEntry(models.ReasonReserve, res.Id,
+			models.Posting{UserId: user.Id},
+			models.Posting{System: models.AccountReservations},
+			res.Total,
+		)
+		if err := m.LedgerStorage.Post(ctx, entry); err != nil {
+			return err
+		}
+
+		oldBalance := user.Coins
+		user.Coins -= res.Total
+
+		return m.CoinsStorage.CreateForReservation(ctx, user, oldBalance, models.ReasonReserve, res.Id)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Reservations - проверяет запрос страницы и возвращает брони пользователя от новых к старым
+func (m *MerchService) Reservations(ctx context.Context, userName string, q *models.PageQuery) (*models.Page[*models.Reservation], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.ReservationStorage.List(ctx, user.Id, q)
+}
+
+// userReservation - блокирует бронь id пользователя userName.
+// Чужая бронь не отличается от несуществующей
+func (m *MerchService) userReservation(ctx context.Context, userName string, id int) (*models.Reservation, error) {
+	user, err := m.UserStorage.GetByLogin(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := m.ReservationStorage.GetForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.UserId != user.Id {
+		return nil, models.ErrReservationNotFound
+	}
+
+	return res, nil
+}
+
+// ConfirmReservation - превращает активную неистекшую бронь в заказ по цене брони.
+// Товар списывается со склада; его должно хватать без учета чужих удержаний (предзаказы
+// подтверждаются в порядке поступления запросов). Лимиты покупки проверяются заново.
+// Удержанные монеты переходят со счета AccountReservations в выручку (запись журнала
+// ReasonPurchase со ссылкой на заказ), баланс пользователя не меняется.
+// Блокировки: бронь, мерч, вариант, пользователь
+func (m *MerchService) ConfirmReservation(ctx context.Context, userName string, id int) (*models.Order, error) {
+	var order *models.Order
+
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		res, err := m.userReservation(ctx, userName, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if !res.ActiveAt(now) {
+			return models.ErrReservationNotActive
+		}
+
+		merch, err := m.MerchStorage.GetForUpdate(ctx, res.MerchId)
+		if err != nil {
+			return err
+		}
+
+		item := orderItem{merch: merch, count: res.Count}
+		if res.VariantId != 0 {
+			if item.variant, err = m.VariantStorage.GetForUpdate(ctx, res.VariantId); err != nil {
+				return err
+			}
+		}
+
+		reserved, err := m.reserved(ctx, item, now)
+		if err != nil {
+			return err
+		}
+
+		holds := reserved.Holds
+		if res.Kind == models.ReservationHold {
+			holds -= res.Count
+		}
+		if item.stock()-holds < item.count {
+			return models.ErrNotEnoughMerch
+		}
+
+		user, err := m.UserStorage.GetByLoginForUpdate(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if err := m.checkPurchaseLimits(ctx, []orderItem{item}, user.Id, now, res.Id); err != nil {
+			return err
+		}
+
+		if err := m.takeStock(ctx, item); err != nil {
+			return err
+		}
+
+		order = &models.Order{
+			UserId:   user.Id,
+			Total:    res.Total,
+			Discount: (res.ListPrice - res.Price) * res.Count,
+			Lines: []*models.OrderLine{{
+				MerchId:   res.MerchId,
+				Name:      merch.Name,
+				VariantId: res.VariantId,
+				Variant:   res.Variant,
+				Count:     res.Count,
+				Price:     res.Price,
+				ListPrice: res.ListPrice,
+				SaleId:    res.SaleId,
+			}},
+		}
+		if err := m.OrderStorage.Create(ctx, order); err != nil {
+			return err
+		}
+
+		if order.Total > 0 {
+			entry := models.NewTransferEntry(models.ReasonPurchase, order.Id,
+				models.Posting{System: models.AccountReservations},
+				models.Posting{System: models.AccountShopRevenue},
+				order.Total,
+			)
+			if err := m.LedgerStorage.Post(ctx, entry); err != nil {
+				return err
+			}
+		}
+
+		if err := m.ReservationStorage.UpdateStatus(ctx, res.Id, models.ReservationConfirmed, order.Id); err != nil {
+			return err
+		}
+
+		order, err = m.OrderStorage.Get(ctx, order.Id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// CancelReservation - отменяет активную бронь пользователя (в том числе уже истекшую,
+// но еще не закрытую очисткой) и возвращает ее стоимость (см. release)
+func (m *MerchService) CancelReservation(ctx context.Context, userName string, id int) (*models.Reservation, error) {
+	var res *models.Reservation
+
+	err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = m.userReservation(ctx, userName, id)
+		if err != nil {
+			return err
+		}
+
+		if res.Status != models.ReservationActive {
+			return models.ErrReservationNotActive
+		}
+
+		if err := m.release(ctx, res, models.ReservationCancelled); err != nil {
+			return err
+		}
+
+		res, err = m.ReservationStorage.Get(ctx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// release - закрывает заблокированную бронь статусом status и возвращает пользователю
+// ее стоимость со счета AccountReservations (запись журнала ReasonRelease и запись
+// в истории кошелька). Склад не меняется: бронь его не уменьшала
+func (m *MerchService) release(ctx context.Context, res *models.Reservation, status models.ReservationStatus) error {
+	if err := m.ReservationStorage.UpdateStatus(ctx, res.Id, status, 0); err != nil {
+		return err
+	}
+
+	if res.Total == 0 {
+		return nil
+	}
+
+	owner, err := m.UserStorage.Get(ctx, res.UserId)
+	if err != nil {
+		return err
+	}
+
+	user, err := m.UserStorage.GetByLoginForUpdate(ctx, owner.Login)
+	if err != nil {
+		return err
+	}
+
+	entry := models.NewTransferEntry(models.ReasonRelease, res.Id,
+		models.Posting{System: models.AccountReservations},
+		models.Posting{UserId: user.Id},
+		res.Total,
+	)
+	if err := m.LedgerStorage.Post(ctx, entry); err != nil {
+		return err
+	}
+
+	oldBalance := user.Coins
+	user.Coins += res.Total
+
+	return m.CoinsStorage.CreateForReservation(ctx, user, oldBalance, models.ReasonRelease, res.Id)
+}
+
+// ExpireReservations - закрывает статусом ReservationExpired активные брони с истекшим
+// сроком и возвращает монеты. Каждая бронь закрывается в своей транзакции под блокировкой,
+// поэтому параллельное подтверждение или отмена брони не приводят к двойному возврату
+func (m *MerchService) ExpireReservations(ctx context.Context) (int, error) {
+	expired := 0
+
+	for {
+		ids, err := m.ReservationStorage.Expired(ctx, time.Now().UTC(), expireBatch)
+		if err != nil {
+			return expired, err
+		}
+
+		for _, id := range ids {
+			err := m.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+				res, err := m.ReservationStorage.GetForUpdate(ctx, id)
+				if err != nil {
+					return err
+				}
+
+				// Бронь успели подтвердить или отменить после выборки
+				if res.Status != models.ReservationActive {
+					return nil
+				}
+
+				expired++
+				return m.release(ctx, res, models.ReservationExpired)
+			})
+			if err != nil {
+				return expired, err
+			}
+		}
+
+		if len(ids) < expireBatch {
+			return expired, nil
+		}
+	}
+}
+
+// SweepReservations - фоновая очистка истекших броней (см. ExpireReservations)
+// каждые interval (0 - DefaultSweepInterval), пока не отменен ctx.
+// Ошибки очистки только логируются: следующий проход повторит попытку
+func (m *MerchService) SweepReservations(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := m.ExpireReservations(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("не удалось закрыть истекшие брони: %v", err)
+			}
+			if n > 0 {
+				log.Printf("закрыто истекших броней: %d", n)
+			}
+		}
+	}
+}
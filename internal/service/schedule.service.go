@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"merch_service/internal/models"
+	"time"
+)
+
+const (
+	// DefaultScheduleInterval - период проверки запланированных переводов, если он не задан
+	DefaultScheduleInterval = time.Minute
+
+	// scheduleBatch - сколько запланированных переводов выбирается за один запрос
+	scheduleBatch = 100
+)
+
+// Schedule - проверяет запрос и сохраняет запланированный перевод от пользователя userLogin.
+// Первое срабатывание - в StartAt, оно должно быть в будущем. Повод проверяется, а сообщение
+// очищается так же, как у обычного перевода (см. Send). Хватит ли монет, проверяется
+// только при срабатывании
+func (t *TransactionService) Schedule(ctx context.Context, userLogin string, req *models.ScheduleRequest) (*models.ScheduledTransfer, error) {
+	if req.Amount <= 0 {
+		return nil, models.ErrInvalidAmount
+	}
+
+	if !req.Rule.Valid() {
+		return nil, models.ErrInvalidScheduleRule
+	}
+
+	if !req.StartAt.After(time.Now().UTC()) {
+		return nil, models.ErrScheduleInPast
+	}
+
+	note := req.TransferNote
+	if err := note.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if userLogin == req.Reciever {
+		return nil, models.ErrSameSenderReceiver
+	}
+
+	sender, err := t.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	receiver, err := t.UserStorage.GetByLogin(ctx, req.Reciever)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &models.ScheduledTransfer{
+		SenderId:     sender.Id,
+		ReceiverId:   receiver.Id,
+		Receiver:     receiver.Login,
+		Amount:       req.Amount,
+		TransferNote: note,
+		Rule:         req.Rule,
+		StartAt:      req.StartAt.UTC(),
+	}
+	if err := t.ScheduleStorage.Create(ctx, st); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// Schedules - возвращает страницу запланированных переводов пользователя от новых к старым
+func (t *TransactionService) Schedules(ctx context.Context, userLogin string, q *models.PageQuery) (*models.Page[*models.ScheduledTransfer], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ScheduleStorage.List(ctx, user.Id, q)
+}
+
+// userSchedule - запланированный перевод id пользователя userLogin.
+// Чужой перевод не отличается от несуществующего
+func (t *TransactionService) userSchedule(ctx context.Context, userLogin string, id int) (*models.ScheduledTransfer, error) {
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := t.ScheduleStorage.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if st.SenderId != user.Id {
+		return nil, models.ErrScheduleNotFound
+	}
+	return st, nil
+}
+
+// ScheduleRuns - возвращает страницу срабатываний перевода id от поздних к ранним:
+// выполненные со ссылкой на перевод и пропущенные с причиной пропуска
+func (t *TransactionService) ScheduleRuns(ctx context.Context, userLogin string, id int, q *models.PageQuery) (*models.Page[*models.ScheduleRun], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if _, err := t.userSchedule(ctx, userLogin, id); err != nil {
+		return nil, err
+	}
+
+	return t.ScheduleStorage.Runs(ctx, id, q)
+}
+
+// CancelSchedule - отменяет активный запланированный перевод id.
+// Строка перевода блокируется, поэтому отмена и срабатывание не выполнятся одновременно
+func (t *TransactionService) CancelSchedule(ctx context.Context, userLogin string, id int) (*models.ScheduledTransfer, error) {
+	if _, err := t.userSchedule(ctx, userLogin, id); err != nil {
+		return nil, err
+	}
+
+	err := t.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		st, err := t.ScheduleStorage.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if st.Status != models.ScheduleActive {
+			return models.ErrScheduleNotActive
+		}
+
+		return t.ScheduleStorage.UpdateStatus(ctx, id, models.ScheduleCancelled, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ScheduleStorage.Get(ctx, id)
+}
+
+// RunSchedules - выполняет запланированные переводы, срабатывание которых наступило
+// к моменту at, и возвращает число срабатываний (выполненных и пропущенных).
+//
+// Каждое срабатывание - отдельная транзакция: строка перевода блокируется,
+// перевод монет, запись срабатывания и сдвиг на следующее срабатывание
+// фиксируются вместе. Поэтому срабатывание выполняется не больше одного раза,
+// в том числе если сервер перезапустился посреди прохода или запущено несколько серверов.
+// Срабатывания, пропущенные пока сервер не работал, схлопываются в одно:
+// следующее срабатывание считается от at, а не от пропущенного.
+// Время считается в UTC, в том числе границы дней и месяцев (см. models.ScheduleRule.Next)
+func (t *TransactionService) RunSchedules(ctx context.Context, at time.Time) (int, error) {
+	at = at.UTC()
+	runs := 0
+
+	for {
+		ids, err := t.ScheduleStorage.Due(ctx, at, scheduleBatch)
+		if err != nil {
+			return runs, err
+		}
+
+		for _, id := range ids {
+			err := t.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+				ran, err := t.runSchedule(ctx, id, at)
+				if ran {
+					runs++
+				}
+				return err
+			})
+			if err != nil {
+				return runs, err
+			}
+		}
+
+		if len(ids) < scheduleBatch {
+			return runs, nil
+		}
+	}
+}
+
+// runSchedule - выполняет или пропускает одно срабатывание перевода id внутри транзакции.
+// false - срабатывание уже обработано (перевод успели отменить или выполнить после выборки)
+func (t *TransactionService) runSchedule(ctx context.Context, id int, at time.Time) (bool, error) {
+	st, err := t.ScheduleStorage.GetForUpdate(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if st.Status != models.ScheduleActive || st.NextRunAt == nil || st.NextRunAt.After(at) {
+		return false, nil
+	}
+
+	run := &models.ScheduleRun{
+		ScheduleId:   st.Id,
+		ScheduledFor: *st.NextRunAt,
+		Status:       models.ScheduleRunExecuted,
+	}
+
+	sender, err := t.UserStorage.Get(ctx, st.SenderId)
+	if err != nil {
+		return false, err
+	}
+
+	receiver, err := t.UserStorage.Get(ctx, st.ReceiverId)
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		run.Status, run.Reason = models.ScheduleRunSkipped, models.SkipReceiverNotFound
+	case err != nil:
+		return false, err
+	default:
+		run.TransferId, err = t.transfer(ctx, sender.Login, receiver.Login, st.Amount, st.TransferNote)
+		switch {
+		case errors.Is(err, models.ErrNotEnoughCoins):
+			run.Status, run.Reason = models.ScheduleRunSkipped, models.SkipNotEnoughCoins
+		case errors.Is(err, models.ErrSuspiciousTransfer):
+			run.Status, run.Reason = models.ScheduleRunSkipped, models.SkipSuspicious
+		case models.IsTransferLimitError(err):
+			run.Status, run.Reason = models.ScheduleRunSkipped, models.SkipTransferLimit
+		case err != nil:
+			return false, err
+		}
+	}
+
+	if err := t.ScheduleStorage.CreateRun(ctx, run); err != nil {
+		return false, err
+	}
+
+	if next, ok := st.Rule.Next(st.StartAt, at); ok {
+		return true, t.ScheduleStorage.UpdateStatus(ctx, st.Id, models.ScheduleActive, &next)
+	}
+	return true, t.ScheduleStorage.UpdateStatus(ctx, st.Id, models.ScheduleCompleted, nil)
+}
+
+// ScheduleTransfers - фоновое выполнение запланированных переводов (см. RunSchedules)
+// каждые interval (0 - DefaultScheduleInterval), пока не отменен ctx.
+// Ошибки только логируются: следующий проход повторит попытку
+func (t *TransactionService) ScheduleTransfers(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := t.RunSchedules(ctx, time.Now().UTC())
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("не удалось выполнить запланированные переводы: %v", err)
+			}
+			if n > 0 {
+				log.Printf("обработано запланированных переводов: %d", n)
+			}
+		}
+	}
+}
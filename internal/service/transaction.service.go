@@ -4,83 +4,221 @@ import (
 	"context"
 	"merch_service/internal/models"
 	"merch_service/internal/storage/entities"
+	"slices"
+	"time"
 )
 
 type TransactionServiceInterface interface {
-	// Send - отправляет amount монет от sender к recv
-	Send(ctx context.Context, sender, recv string, amount int) error
+	// Send - отправляет amount монет от sender к recv с поводом и сообщением note
+	Send(ctx context.Context, sender, recv string, amount int, note models.TransferNote) error
+
+	// React - ставит или снимает реакцию получателя на перевод, возвращает перевод
+	React(ctx context.Context, userLogin string, id int, reaction models.TransferReaction) (*models.TransferEntry, error)
+
+	// Reply - сохраняет ответ получателя на перевод, возвращает перевод
+	Reply(ctx context.Context, userLogin string, id int, message string) (*models.TransferEntry, error)
+
+	// Schedule - планирует разовый или повторяющийся перевод от имени пользователя
+	Schedule(ctx context.Context, userLogin string, req *models.ScheduleRequest) (*models.ScheduledTransfer, error)
+
+	// Schedules - возвращает страницу запланированных переводов пользователя
+	Schedules(ctx context.Context, userLogin string, q *models.PageQuery) (*models.Page[*models.ScheduledTransfer], error)
+
+	// ScheduleRuns - возвращает страницу срабатываний запланированного перевода пользователя
+	ScheduleRuns(ctx context.Context, userLogin string, id int, q *models.PageQuery) (*models.Page[*models.ScheduleRun], error)
+
+	// CancelSchedule - отменяет запланированный перевод пользователя
+	CancelSchedule(ctx context.Context, userLogin string, id int) (*models.ScheduledTransfer, error)
+
+	// RequestCoins - просит монеты у другого пользователя, запрос ждет ответа ttl
+	RequestCoins(ctx context.Context, userLogin string, req *models.AskCoinsRequest, ttl time.Duration) (*models.CoinRequest, error)
+
+	// CoinRequests - возвращает страницу отправленных и (или) полученных запросов монет пользователя
+	CoinRequests(ctx context.Context, userLogin string, filter *models.CoinRequestFilter) (*models.Page[*models.CoinRequest], error)
+
+	// CoinRequest - возвращает запрос монет, в котором участвует пользователь
+	CoinRequest(ctx context.Context, userLogin string, id int) (*models.CoinRequest, error)
+
+	// AcceptCoinRequest - принимает полученный запрос монет и переводит монеты
+	AcceptCoinRequest(ctx context.Context, userLogin string, id int) (*models.CoinRequest, error)
+
+	// DeclineCoinRequest - отклоняет полученный запрос монет
+	DeclineCoinRequest(ctx context.Context, userLogin string, id int) (*models.CoinRequest, error)
+
+	// TransferFlags - возвращает страницу пометок подозрительных переводов
+	TransferFlags(ctx context.Context, filter *models.TransferFlagFilter) (*models.Page[*models.TransferFlag], error)
+
+	// ReviewTransferFlag - закрывает пометку подозрительного перевода решением администратора
+	ReviewTransferFlag(ctx context.Context, adminLogin string, id int, status models.TransferFlagStatus) (*models.TransferFlag, error)
 }
 
 var _ TransactionServiceInterface = (*TransactionService)(nil)
 
-// TransactionService - реализует интерфейс TransactionServiceInterface
+// TransactionService - реализует интерфейс TransactionServiceInterface.
+// Limits задаются после загрузки конфига, по умолчанию переводы не ограничены
 type TransactionService struct {
-	TransactionStorage entities.TransactionStorage
-	UserStorage        entities.UserStorage
-	CoinsStorage       entities.CoinsStorage
+	TransactionStorage  entities.TransactionStorage
+	UserStorage         entities.UserStorage
+	CoinsStorage        entities.CoinsStorage
+	TxManager           entities.TxManager
+	LedgerStorage       entities.LedgerStorage
+	ScheduleStorage     entities.ScheduleStorage
+	CoinRequestStorage  entities.CoinRequestStorage
+	TransferFlagStorage entities.TransferFlagStorage
+
+	Limits models.TransferLimits
 }
 
 // NewTransactionService - создает объект TransactionService
-func NewTransactionService(t entities.TransactionStorage, u entities.UserStorage, c entities.CoinsStorage) *TransactionService {
+func NewTransactionService(t entities.TransactionStorage, u entities.UserStorage, c entities.CoinsStorage, tx entities.TxManager, l entities.LedgerStorage, s entities.ScheduleStorage, r entities.CoinRequestStorage, f entities.TransferFlagStorage) *TransactionService {
 	return &TransactionService{
-		TransactionStorage: t,
-		UserStorage:        u,
-		CoinsStorage:       c,
+		TransactionStorage:  t,
+		UserStorage:         u,
+		CoinsStorage:        c,
+		TxManager:           tx,
+		LedgerStorage:       l,
+		ScheduleStorage:     s,
+		CoinRequestStorage:  r,
+		TransferFlagStorage: f,
 	}
 }
 
 // Send - проверяет есть ли оба переданных пользователя,
 // хватает ли денег отправителю для совершения операции,
-// и совершает операцию отправки
-func (t *TransactionService) Send(ctx context.Context, sender, recv string, amount int) error {
+// и совершает операцию отправки. Повод перевода проверяется, а сообщение
+// очищается (см. models.SanitizeMessage) до начала транзакции.
+//
+// Перевод выполняется в одной транзакции: строки обоих пользователей
+// блокируются в порядке логинов, чтобы встречные переводы не взаимоблокировались,
+// а монеты переводятся записью журнала со ссылкой на перевод
+func (t *TransactionService) Send(ctx context.Context, sender, recv string, amount int, note models.TransferNote) error {
 	if amount <= 0 {
 		return models.ErrInvalidAmount
 	}
 
+	if err := note.Normalize(); err != nil {
+		return err
+	}
+
 	if sender == recv {
 		return models.ErrSameSenderReceiver
 	}
 
-	sendUser, err := t.UserStorage.GetByLogin(ctx, sender)
-	if err != nil {
+	return t.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := t.transfer(ctx, sender, recv, amount, note)
 		return err
+	})
+}
+
+// transfer - переводит монеты внутри уже открытой транзакции и возвращает id перевода.
+// Строки обоих пользователей блокируются в порядке логинов, чтобы встречные переводы
+// не взаимоблокировались, а монеты переводятся записью журнала со ссылкой на перевод.
+// Если монет не хватает, возвращает ErrNotEnoughCoins, а если перевод не проходит
+// лимиты - ошибку лимита (см. checkLimits), ничего не записав.
+// Подозрительный перевод выполняется и помечается для проверки администратором
+func (t *TransactionService) transfer(ctx context.Context, sender, recv string, amount int, note models.TransferNote) (int, error) {
+	locked := make(map[string]*models.User, 2)
+	for _, login := range slices.Sorted(slices.Values([]string{sender, recv})) {
+		user, err := t.UserStorage.GetByLoginForUpdate(ctx, login)
+		if err != nil {
+			return 0, err
+		}
+		locked[login] = user
 	}
 
-	recvUser, err := t.UserStorage.GetByLogin(ctx, recv)
+	sendUser, recvUser := locked[sender], locked[recv]
+
+	if sendUser.Coins < amount {
+		return 0, models.ErrNotEnoughCoins
+	}
+
+	reasons, err := t.checkLimits(ctx, sendUser, recvUser, amount)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if sendUser.Coins < amount {
-		return models.ErrNotEnoughCoins
+	transferID, err := t.TransactionStorage.Create(ctx, sendUser, recvUser, amount, note)
+	if err != nil {
+		return 0, err
+	}
+
+	entry := models.NewTransferEntry(models.ReasonTransfer, transferID,
+		models.Posting{UserId: sendUser.Id},
+		models.Posting{UserId: recvUser.Id},
+		amount,
+	)
+	if err := t.LedgerStorage.Post(ctx, entry); err != nil {
+		return 0, err
 	}
 
 	sendUser.Coins -= amount
 	recvUser.Coins += amount
 
-	err = t.TransactionStorage.Create(ctx, sendUser, recvUser, amount)
-	if err != nil {
-		return err
+	if err := t.CoinsStorage.CreateForTransfer(ctx, sendUser, sendUser.Coins+amount, transferID, note); err != nil {
+		return 0, err
 	}
 
-	err = t.UserStorage.Update(ctx, sendUser)
-	if err != nil {
-		return err
+	if err := t.CoinsStorage.CreateForTransfer(ctx, recvUser, recvUser.Coins-amount, transferID, note); err != nil {
+		return 0, err
+	}
+
+	if len(reasons) > 0 {
+		flag := &models.TransferFlag{
+			TransferId: transferID,
+			SenderId:   sendUser.Id,
+			Sender:     sendUser.Login,
+			ReceiverId: recvUser.Id,
+			Receiver:   recvUser.Login,
+			Amount:     amount,
+			Reasons:    reasons,
+		}
+		if err := t.TransferFlagStorage.Create(ctx, flag); err != nil {
+			return 0, err
+		}
+	}
+
+	return transferID, nil
+}
+
+// React - ставит реакцию на перевод id. Реагировать может только получатель,
+// для остальных перевод не отличается от несуществующего
+func (t *TransactionService) React(ctx context.Context, userLogin string, id int, reaction models.TransferReaction) (*models.TransferEntry, error) {
+	if !reaction.Valid() {
+		return nil, models.ErrInvalidTransferReaction
 	}
 
-	err = t.UserStorage.Update(ctx, recvUser)
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := t.TransactionStorage.React(ctx, id, user.Id, reaction); err != nil {
+		return nil, err
 	}
 
-	err = t.CoinsStorage.Create(ctx, sendUser, sendUser.Coins+amount)
+	return t.TransactionStorage.Get(ctx, user, id)
+}
+
+// Reply - очищает ответ (см. models.SanitizeMessage) и сохраняет его к переводу id.
+// Отвечает только получатель и только один раз
+func (t *TransactionService) Reply(ctx context.Context, userLogin string, id int, message string) (*models.TransferEntry, error) {
+	message, err := models.SanitizeMessage(message)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = t.CoinsStorage.Create(ctx, recvUser, recvUser.Coins-amount)
+	if message == "" {
+		return nil, models.ErrEmptyTransferReply
+	}
+
+	user, err := t.UserStorage.GetByLogin(ctx, userLogin)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+
+	if err := t.TransactionStorage.Reply(ctx, id, user.Id, message); err != nil {
+		return nil, err
+	}
+
+	return t.TransactionStorage.Get(ctx, user, id)
 }
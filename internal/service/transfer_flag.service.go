@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"merch_service/internal/models"
+	"time"
+)
+
+// checkLimits - проверяет перевод amount монет от send к recv лимитами t.Limits
+// (см. TransferLimits.Check) и ищет признаки подозрительного перевода:
+// круговую цепочку от recv обратно к send и серию мелких переводов recv.
+// Возвращает причины пометки перевода, а с BlockSuspicious - ErrSuspiciousTransfer.
+// Вызывается под блокировкой строки отправителя, поэтому параллельные переводы
+// не обходят лимиты
+func (t *TransactionService) checkLimits(ctx context.Context, send, recv *models.User, amount int) ([]models.TransferFlagReason, error) {
+	l := t.Limits
+	now := time.Now()
+
+	var sent models.TransferVelocity
+	if l.Daily > 0 || l.Weekly > 0 || l.Small() {
+		var err error
+		sent, err = t.TransactionStorage.Velocity(ctx, send.Id, recv.Id, now, l.SmallAmount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.Check(amount, send.CreatedAt, now, sent); err != nil {
+		return nil, err
+	}
+
+	var reasons []models.TransferFlagReason
+	if l.CircularWindow > 0 {
+		since := now.Add(-time.Duration(l.CircularWindow) * time.Second)
+		found, err := t.TransactionStorage.HasChain(ctx, recv.Id, send.Id, since, models.MaxCircularDepth)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			reasons = append(reasons, models.FlagCircular)
+		}
+	}
+
+	if l.Small() && amount <= l.SmallAmount && sent.SmallToReceiver+1 >= l.SmallCount {
+		reasons = append(reasons, models.FlagSmallTransfers)
+	}
+
+	if len(reasons) > 0 && l.BlockSuspicious {
+		return nil, models.ErrSuspiciousTransfer
+	}
+
+	return reasons, nil
+}
+
+// TransferFlags - возвращает страницу пометок подозрительных переводов от новых к старым
+func (t *TransactionService) TransferFlags(ctx context.Context, filter *models.TransferFlagFilter) (*models.Page[*models.TransferFlag], error) {
+	if filter == nil {
+		filter = &models.TransferFlagFilter{}
+	}
+
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, models.ErrInvalidTransferFlagStatus
+	}
+
+	if err := filter.Normalize(); err != nil {
+		return nil, err
+	}
+
+	return t.TransferFlagStorage.List(ctx, filter)
+}
+
+// ReviewTransferFlag - закрывает открытую пометку id решением администратора adminLogin:
+// dismissed - перевод обычный, confirmed - мошеннический. Перевод при этом не отменяется.
+// Повторная проверка пометки возвращает ErrTransferFlagClosed
+func (t *TransactionService) ReviewTransferFlag(ctx context.Context, adminLogin string, id int, status models.TransferFlagStatus) (*models.TransferFlag, error) {
+	if status != models.FlagDismissed && status != models.FlagConfirmed {
+		return nil, models.ErrInvalidTransferFlagStatus
+	}
+
+	admin, err := t.UserStorage.GetByLogin(ctx, adminLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.TransferFlagStorage.Review(ctx, id, status, admin.Id); err != nil {
+		return nil, err
+	}
+
+	return t.TransferFlagStorage.Get(ctx, id)
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log"
 	"merch_service/internal/models"
 	"merch_service/internal/storage/entities"
 )
@@ -15,55 +17,135 @@ type UserServiceInterface interface {
 	// и, если не было, добавляет его и возвращает nil
 	Register(ctx context.Context, regReq *models.LoginRequest) error
 
-	// CoinsHistory - возвращает историю изменения баланса пользователя
-	CoinsHistory(ctx context.Context, userLogin string) ([]*models.CoinsEntry, error)
+	// CoinsHistory - возвращает страницу истории изменения баланса пользователя
+	CoinsHistory(ctx context.Context, userLogin string, q *models.PageQuery) (*models.Page[*models.CoinsEntry], error)
 
-	// PurchaseHistory - возвращает историю покупок
-	PurchaseHistory(ctx context.Context, userLogin string) ([]*models.PurchaseEntry, error)
+	// PurchaseHistory - возвращает страницу истории покупок
+	PurchaseHistory(ctx context.Context, userLogin string, q *models.PageQuery) (*models.Page[*models.PurchaseEntry], error)
+
+	// TransferHistory - возвращает страницу истории переводов монет
+	TransferHistory(ctx context.Context, userLogin string, filter *models.TransferFilter) (*models.Page[*models.TransferEntry], error)
+
+	// Users - возвращает список пользователей (без паролей)
+	Users(ctx context.Context) ([]*models.UserInfo, error)
+
+	// SetRole - назначает пользователю роль и завершает все его сессии
+	SetRole(ctx context.Context, userLogin string, role models.Role) error
 }
 
 var _ UserServiceInterface = (*UserService)(nil)
 
 // UserService - реализует интерфейс UserServiceInterface
 type UserService struct {
-	UserStorage     entities.UserStorage
-	PurchaseStorage entities.PurchaseStorage
-	CoinsStorage    entities.CoinsStorage
+	UserStorage        entities.UserStorage
+	PurchaseStorage    entities.PurchaseStorage
+	CoinsStorage       entities.CoinsStorage
+	TransactionStorage entities.TransactionStorage
+	TxManager          entities.TxManager
+	LedgerStorage      entities.LedgerStorage
+	SessionStorage     entities.SessionStorage
+	RefreshStorage     entities.RefreshTokenStorage
+	Hasher             PasswordHasher
 }
 
-// NewUserService - создает объект UserService
-func NewUserService(u entities.UserStorage, p entities.PurchaseStorage, c entities.CoinsStorage) *UserService {
+// NewUserService - создает объект UserService.
+// Пароли хешируются argon2id с параметрами DefaultArgon2Params
+func NewUserService(u entities.UserStorage, p entities.PurchaseStorage, c entities.CoinsStorage, t entities.TransactionStorage, tx entities.TxManager, l entities.LedgerStorage, s entities.SessionStorage, r entities.RefreshTokenStorage) *UserService {
 	return &UserService{
-		UserStorage:     u,
-		PurchaseStorage: p,
-		CoinsStorage:    c,
+		UserStorage:        u,
+		PurchaseStorage:    p,
+		CoinsStorage:       c,
+		TransactionStorage: t,
+		TxManager:          tx,
+		LedgerStorage:      l,
+		SessionStorage:     s,
+		RefreshStorage:     r,
+		Hasher:             NewArgon2Hasher(DefaultArgon2Params),
 	}
 }
 
 // Login - проверяет есть ли такой пользователь
-// и, если был возвращет nil вместо ошибки
+// и, если был возвращет nil вместо ошибки.
+// Если пароль хранится в открытом виде или с устаревшими параметрами
+// хеширования, он прозрачно перехешируется
 func (u *UserService) Login(ctx context.Context, logReq *models.LoginRequest) error {
 	user, err := u.UserStorage.GetByLogin(ctx, logReq.Login)
 	if err != nil {
 		return err
 	}
 
-	if user.Password != logReq.Password {
+	ok, needsRehash, err := u.Hasher.Verify(logReq.Password, user.Password)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
 		return models.ErrWrongPassword
 	}
 
+	if needsRehash {
+		// Ошибка перехеширования не должна мешать входу,
+		// попробуем еще раз при следующем входе
+		if err := u.rehash(ctx, user.Id, logReq.Password); err != nil {
+			log.Printf("не удалось перехешировать пароль пользователя %d: %v", user.Id, err)
+		}
+	}
+
 	return nil
 }
 
+// rehash - сохраняет пароль пользователя с текущими параметрами хеширования
+func (u *UserService) rehash(ctx context.Context, userID int, password string) error {
+	hash, err := u.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return u.UserStorage.UpdatePassword(ctx, userID, hash)
+}
+
+// MigratePasswords - одноразовая миграция: хеширует все пароли,
+// которые хранятся в открытом виде. Возвращает количество обновленных пользователей.
+// Повторный запуск безопасен - уже захешированные пароли пропускаются
+func (u *UserService) MigratePasswords(ctx context.Context) (int, error) {
+	users, err := u.UserStorage.GetList(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, user := range users {
+		if u.Hasher.IsHashed(user.Password) {
+			continue
+		}
+
+		if err := u.rehash(ctx, user.Id, user.Password); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
 // Register - проверяет не было ли такого пользователя уже
 // и, если не было, добавляет его и возвращает nil
 func (u *UserService) Register(ctx context.Context, regReq *models.LoginRequest) error {
+	if regReq.Password == "" {
+		return models.ErrEmptyUserPassword
+	}
+
 	_, err := u.UserStorage.GetByLogin(ctx, regReq.Login)
 	if err == nil {
 		return models.ErrUserExists
 	}
 
-	err = u.UserStorage.Create(ctx, &models.User{Login: regReq.Login, Password: regReq.Password})
+	hash, err := u.Hasher.Hash(regReq.Password)
+	if err != nil {
+		return err
+	}
+
+	err = u.createUser(ctx, &models.User{Login: regReq.Login, Password: hash})
 	if err != nil {
 		return err
 	}
@@ -71,32 +153,191 @@ func (u *UserService) Register(ctx context.Context, regReq *models.LoginRequest)
 	return nil
 }
 
-// CoinsHistory - проверяет существует ли переданный пользователь
-// и возвращает слайс с историей изменения баланса
-func (u *UserService) CoinsHistory(ctx context.Context, userLogin string) ([]*models.CoinsEntry, error) {
+// createUser - создает пользователя с нулевым балансом и в той же
+// транзакции начисляет ему SignupBonus записью журнала
+func (u *UserService) createUser(ctx context.Context, user *models.User) error {
+	return u.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		user.Coins = 0
+		if err := u.UserStorage.Create(ctx, user); err != nil {
+			return err
+		}
+
+		entry := models.NewTransferEntry(models.ReasonSignup, user.Id,
+			models.Posting{System: models.AccountGrants},
+			models.Posting{UserId: user.Id},
+			models.SignupBonus,
+		)
+		if err := u.LedgerStorage.Post(ctx, entry); err != nil {
+			return err
+		}
+
+		user.Coins = models.SignupBonus
+		return nil
+	})
+}
+
+// CoinsHistory - проверяет запрос страницы и существует ли переданный
+// пользователь и возвращает страницу истории изменения баланса
+func (u *UserService) CoinsHistory(ctx context.Context, userLogin string, q *models.PageQuery) (*models.Page[*models.CoinsEntry], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
 	user, err := u.UserStorage.GetByLogin(ctx, userLogin)
 	if err != nil {
 		return nil, err
 	}
 
-	coinsHistory, err := u.CoinsStorage.Get(ctx, user)
+	return u.CoinsStorage.List(ctx, user, q)
+}
+
+// PurchaseHistory - проверяет запрос страницы и существует ли переданный
+// пользователь и возвращает страницу истории покупок мерча
+func (u *UserService) PurchaseHistory(ctx context.Context, userLogin string, q *models.PageQuery) (*models.Page[*models.PurchaseEntry], error) {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	user, err := u.UserStorage.GetByLogin(ctx, userLogin)
 	if err != nil {
 		return nil, err
 	}
-	return coinsHistory, nil
+
+	return u.PurchaseStorage.List(ctx, user, q)
 }
 
-// PurchaseHistory - проверяет существует ли переданный пользователь
-// и возвращает слайс с историей покупок мерча
-func (u *UserService) PurchaseHistory(ctx context.Context, userLogin string) ([]*models.PurchaseEntry, error) {
+// TransferHistory - проверяет фильтр и существование пользователя
+// и возвращает страницу с отправленными и полученными переводами
+func (u *UserService) TransferHistory(ctx context.Context, userLogin string, filter *models.TransferFilter) (*models.Page[*models.TransferEntry], error) {
+	if filter == nil {
+		filter = &models.TransferFilter{}
+	}
+
+	if filter.Direction == "" {
+		filter.Direction = models.TransferAll
+	}
+
+	if !filter.Direction.Valid() {
+		return nil, models.ErrInvalidDirection
+	}
+
+	if err := filter.Normalize(); err != nil {
+		return nil, err
+	}
+
 	user, err := u.UserStorage.GetByLogin(ctx, userLogin)
 	if err != nil {
 		return nil, err
 	}
 
-	purchaseHistory, err := u.PurchaseStorage.Get(ctx, user)
+	return u.TransactionStorage.List(ctx, user, filter)
+}
+
+// Users - возвращает всех пользователей без паролей
+func (u *UserService) Users(ctx context.Context) ([]*models.UserInfo, error) {
+	users, err := u.UserStorage.GetList(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return purchaseHistory, nil
+
+	infos := make([]*models.UserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, &models.UserInfo{
+			Id:    user.Id,
+			Login: user.Login,
+			Coins: user.Coins,
+			Role:  user.Role,
+		})
+	}
+	return infos, nil
+}
+
+// SetRole - проверяет существует ли роль и пользователь и назначает роль.
+// Роль передается в токене авторизации (см. handlers.RequirePermission), поэтому
+// в той же транзакции отзываются все сессии и refresh токены пользователя:
+// новая роль действует после повторного входа, а старые токены не остаются в силе.
+// Снять роль с последнего администратора нельзя (ErrLastAdmin): BootstrapAdmin
+// не повышает существующих пользователей, и вернуть права было бы некому
+func (u *UserService) SetRole(ctx context.Context, userLogin string, role models.Role) error {
+	if !role.Valid() {
+		return models.ErrInvalidUserRole
+	}
+
+	return u.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		// Администраторы блокируются до пользователя, чтобы параллельные
+		// разжалования не оставили систему без администратора
+		admins, err := u.UserStorage.CountByRoleForUpdate(ctx, models.RoleAdmin)
+		if err != nil {
+			return err
+		}
+
+		user, err := u.UserStorage.GetByLoginForUpdate(ctx, userLogin)
+		if err != nil {
+			return err
+		}
+
+		if user.Role == models.RoleAdmin && role != models.RoleAdmin && admins <= 1 {
+			return models.ErrLastAdmin
+		}
+
+		if err := u.UserStorage.UpdateRole(ctx, user.Id, role); err != nil {
+			return err
+		}
+
+		if err := u.SessionStorage.RevokeAllByUser(ctx, user.Id); err != nil {
+			return err
+		}
+
+		return u.RefreshStorage.RevokeAllByUser(ctx, user.Id)
+	})
+}
+
+// BootstrapAdmin - создает первого администратора из конфига, чтобы
+// в свежем развертывании было кому выдать права остальным.
+// Администратор создается с паролем password, только если в системе нет ни одного
+// администратора и логин свободен. Существующий пользователь не повышается,
+// а разжалованный через SetRole администратор не восстанавливается при перезапуске
+func (u *UserService) BootstrapAdmin(ctx context.Context, login, password string) error {
+	if login == "" {
+		return nil
+	}
+
+	users, err := u.UserStorage.GetList(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.Role == models.RoleAdmin {
+			return nil
+		}
+	}
+
+	_, err = u.UserStorage.GetByLogin(ctx, login)
+	switch {
+	case err == nil:
+		log.Printf("администратор не создан: логин %q уже занят, существующий пользователь не повышается", login)
+		return nil
+	case !errors.Is(err, models.ErrUserNotFound):
+		return err
+	}
+
+	if password == "" {
+		return models.ErrEmptyUserPassword
+	}
+
+	hash, err := u.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return u.createUser(ctx, &models.User{Login: login, Password: hash, Role: models.RoleAdmin})
 }
@@ -0,0 +1,22 @@
+package entities
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// BlobStorage определяет контракт хранилища файлов (изображения товаров и т.д.).
+// Ключ - относительный путь вида "merch/1/abc.png" без "..", файлы под ключом не меняются
+type BlobStorage interface {
+	// Put сохраняет содержимое r под ключом key, заменяя прежнее.
+	// Возвращает ErrInvalidBlobKey для некорректного ключа.
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Open открывает файл key для чтения и возвращает время его записи.
+	// Файл нужно закрыть. Если файла нет, возвращает ErrBlobNotFound.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error)
+
+	// Delete удаляет файл key. Отсутствие файла ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// CartStorage определяет контракт для работы с корзинами пользователей
+type CartStorage interface {
+	// Get возвращает строки корзины пользователя с текущими именем и ценой товара
+	// или варианта, упорядоченные по id товара и варианта.
+	// Удаленные из каталога товары и варианты не возвращаются.
+	// Возвращает ошибку при неудаче.
+	Get(ctx context.Context, userID int) ([]*models.CartLine, error)
+
+	// GetForUpdate - то же, что Get, но блокирует строки корзины
+	// до конца транзакции. Имеет смысл только внутри TxManager.WithinTx.
+	GetForUpdate(ctx context.Context, userID int) ([]*models.CartLine, error)
+
+	// Add добавляет count штук товара (варианта variantID, 0 - без варианта)
+	// в корзину (к уже лежащим). Если товара нет в каталоге, возвращает ErrMerchNotFound,
+	// если у товара нет такого варианта - ErrVariantNotFound.
+	Add(ctx context.Context, userID, merchID, variantID, count int) error
+
+	// Set задает количество товара (варианта) в корзине.
+	// Ошибки те же, что и у Add.
+	Set(ctx context.Context, userID, merchID, variantID, count int) error
+
+	// RemoveDeleted убирает из корзины строки удаленных из каталога товаров
+	// и вариантов и возвращает их (без цены).
+	RemoveDeleted(ctx context.Context, userID int) ([]*models.CartLine, error)
+
+	// Remove убирает товар (вариант) из корзины. Если его в корзине нет,
+	// возвращает ErrCartItemNotFound.
+	Remove(ctx context.Context, userID, merchID, variantID int) error
+}
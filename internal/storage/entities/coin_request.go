@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// CoinRequestStorage определяет контракт для работы с запросами монет
+type CoinRequestStorage interface {
+	// Create сохраняет ожидающий запрос, обновляет ID, статус и дату создания запроса.
+	Create(ctx context.Context, r *models.CoinRequest) error
+
+	// Get возвращает запрос по ID с логинами обеих сторон.
+	// Если запроса нет, возвращает ErrCoinRequestNotFound.
+	Get(ctx context.Context, id int) (*models.CoinRequest, error)
+
+	// GetForUpdate возвращает запрос по ID и блокирует его строку
+	// до конца текущей транзакции (см. TxManager).
+	GetForUpdate(ctx context.Context, id int) (*models.CoinRequest, error)
+
+	// List возвращает страницу запросов пользователя от новых к старым.
+	// Статус запросов (и фильтр по нему) считается на момент at (см. CoinRequest.StatusAt).
+	List(ctx context.Context, userID int, filter *models.CoinRequestFilter, at time.Time) (*models.Page[*models.CoinRequest], error)
+
+	// Counts возвращает счетчики запросов requesterID: ожидающих на момент at,
+	// из них к payerID, и созданных начиная с since.
+	Counts(ctx context.Context, requesterID, payerID int, at, since time.Time) (models.CoinRequestCounts, error)
+
+	// Resolve закрывает запрос статусом status (accepted - с переводом transferID).
+	// Возвращает ErrCoinRequestNotFound, если запроса нет.
+	Resolve(ctx context.Context, id int, status models.CoinRequestStatus, transferID int) error
+}
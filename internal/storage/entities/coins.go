@@ -9,6 +9,25 @@ type CoinsStorage interface {
 	// Create - добавляет изменение баланса юзера в историю
 	Create(ctx context.Context, currUser *models.User, oldBalance int) error
 
+	// CreateForOrder - добавляет в историю изменение баланса, связанное с заказом orderID:
+	// списание за покупку (ReasonPurchase) или возврат за отмену (ReasonRefund)
+	CreateForOrder(ctx context.Context, currUser *models.User, oldBalance int, reason models.LedgerReason, orderID int) error
+
+	// CreateForReservation - добавляет в историю изменение баланса, связанное с бронью reservationID:
+	// оплата брони (ReasonReserve) или возврат за отмененную или истекшую бронь (ReasonRelease)
+	CreateForReservation(ctx context.Context, currUser *models.User, oldBalance int, reason models.LedgerReason, reservationID int) error
+
+	// CreateForTransfer - добавляет в историю изменение баланса, связанное с переводом transferID
+	// (ReasonTransfer) с поводом и сообщением перевода note
+	CreateForTransfer(ctx context.Context, currUser *models.User, oldBalance int, transferID int, note models.TransferNote) error
+
+	// CreateForGrant - добавляет в историю начисление (ReasonGrant) по строке row пакета grantID
+	CreateForGrant(ctx context.Context, currUser *models.User, oldBalance int, grantID int, row *models.GrantRow) error
+
 	// Get - получает слайс изменений баланса пользователя
 	Get(ctx context.Context, user *models.User) ([]*models.CoinsEntry, error)
+
+	// List - получает страницу изменений баланса пользователя,
+	// упорядоченных от новых к старым по дате и id
+	List(ctx context.Context, user *models.User, q *models.PageQuery) (*models.Page[*models.CoinsEntry], error)
 }
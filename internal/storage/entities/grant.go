@@ -0,0 +1,14 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// GrantStorage определяет контракт для работы с пакетами начислений
+type GrantStorage interface {
+	// Create сохраняет пакет начислений со всеми строками,
+	// обновляет ID и дату создания пакета и ID строк.
+	Create(ctx context.Context, b *models.GrantBatch) error
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// IdempotencyStorage определяет контракт для работы с ключами идемпотентности
+type IdempotencyStorage interface {
+	// Create резервирует ключ за пользователем до record.ExpiresAt.
+	// Истекшие к моменту at ключи пользователя при этом удаляются.
+	// Если у пользователя уже есть действующий такой ключ, возвращает ErrIdempotencyKeyExists.
+	Create(ctx context.Context, record *models.IdempotencyRecord, at time.Time) error
+
+	// Takeover продлевает аренду незавершенного ключа до record.LockedUntil, если
+	// прежняя аренда истекла к моменту at (запрос с ключом упал, не сохранив ответ).
+	// Если ключ завершен, еще арендован или не найден, возвращает ErrIdempotencyInProgress.
+	Takeover(ctx context.Context, record *models.IdempotencyRecord, at time.Time) error
+
+	// Get возвращает действующий в момент at ключ пользователя. Если ключ не найден,
+	// возвращает nil и ошибку.
+	Get(ctx context.Context, userID int, key string, at time.Time) (*models.IdempotencyRecord, error)
+
+	// Complete сохраняет ответ на запрос с ключом (StatusCode и Response).
+	// Возвращает ошибку при неудаче.
+	Complete(ctx context.Context, record *models.IdempotencyRecord) error
+
+	// Delete удаляет ключ пользователя, чтобы запрос можно было повторить.
+	// Возвращает ошибку при неудаче.
+	Delete(ctx context.Context, userID int, key string) error
+}
@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// ImageStorage определяет контракт для работы с описаниями изображений товаров.
+// Сами файлы хранятся в BlobStorage
+type ImageStorage interface {
+	// Create сохраняет изображение неудаленного товара image.MerchId, обновляет ID изображения.
+	// Возвращает ErrMerchNotFound, если товара нет, и ErrImageExists, если ключ файла уже занят.
+	Create(ctx context.Context, image *models.Image) error
+
+	// Get возвращает изображение по ID. Если изображение не найдено,
+	// возвращает ErrImageNotFound.
+	Get(ctx context.Context, id int) (*models.Image, error)
+
+	// List возвращает изображения товаров merchIDs, упорядоченные по ID товара и изображения.
+	// Возвращает ошибку при неудаче.
+	List(ctx context.Context, merchIDs []int) ([]*models.Image, error)
+
+	// Delete удаляет изображение по ID. Файлы из BlobStorage не удаляются.
+	// Возвращает ErrImageNotFound, если изображения нет.
+	Delete(ctx context.Context, id int) error
+}
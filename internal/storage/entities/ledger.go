@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// LedgerStorage определяет контракт журнала монет (двойная запись)
+type LedgerStorage interface {
+	// Post - добавляет сбалансированную запись в журнал и применяет
+	// проводки по счетам пользователей к их кешированному балансу.
+	// Заполняет Id и CreatedAt записи.
+	// Возвращает ошибку при неудаче.
+	Post(ctx context.Context, entry *models.JournalEntry) error
+
+	// Balance - возвращает баланс пользователя по журналу (сумму его проводок).
+	// Возвращает ошибку при неудаче.
+	Balance(ctx context.Context, userID int) (int, error)
+
+	// Reconcile - сверяет журнал: сумму всех проводок, баланс каждой записи
+	// и совпадение кешированных балансов пользователей с журналом.
+	// Возвращает ошибку при неудаче.
+	Reconcile(ctx context.Context) (*models.LedgerReport, error)
+}
@@ -12,6 +12,7 @@ type MerchStorage interface {
 
 	// Create создает новый экземпляр мерча на основе экземпляра Item,
 	// обновляет ID экземпляра.
+	// Возвращает ErrMerchExists, если мерч с таким именем уже есть.
 	// Возвращает ошибку при неудаче.
 	Create(ctx context.Context, merch *models.Item) error
 
@@ -19,15 +20,25 @@ type MerchStorage interface {
 	// возвращает nil и ошибку.
 	Get(ctx context.Context, id int) (*models.Item, error)
 
+	// GetForUpdate возвращает мерч по ID и блокирует его строку
+	// до конца текущей транзакции (см. TxManager).
+	GetForUpdate(ctx context.Context, id int) (*models.Item, error)
+
 	// Get возвращает мерч по name. Если мерч не найден,
 	// возвращает nil и ошибку.
 	GetByName(ctx context.Context, merchName string) (*models.Item, error)
 
+	// GetByNameForUpdate возвращает мерч по name и блокирует его строку
+	// до конца текущей транзакции (см. TxManager).
+	GetByNameForUpdate(ctx context.Context, merchName string) (*models.Item, error)
+
 	// Update обновляет данные в БД на основе полей экземпляра Item.
+	// Возвращает ErrMerchExists, если имя занято другим мерчем.
 	// Возвращает ошибку при неудаче.
 	Update(ctx context.Context, merch *models.Item) error
 
-	// Delete удаляет мерч в БД по ID.
+	// Delete удаляет мерч по ID. Удаление мягкое: удаленный мерч
+	// не возвращается остальными методами, но остается для истории покупок.
 	// Возвращает ошибку при неудаче.
 	Delete(ctx context.Context, id int) error
 
@@ -36,4 +47,15 @@ type MerchStorage interface {
 	// GetList возвращает слайс всех мерчей.
 	// Возвращает ошибку при неудаче.
 	GetList(ctx context.Context) ([]*models.Item, error)
+
+	// List возвращает страницу мерчей, подходящих под фильтр, в порядке filter.Sort
+	// (по умолчанию по ID), с числом проданных штук (Item.Sold).
+	// Фильтр должен быть нормализован (см. MerchFilter.Normalize).
+	// Возвращает ошибку при неудаче.
+	List(ctx context.Context, filter *models.MerchFilter) (*models.Page[*models.Item], error)
+
+	// RefreshSearchText пересчитывает текст для поиска (см. models.MerchSearchText)
+	// у всех мерчей, в том числе удаленных, и возвращает число мерчей, у которых он изменился.
+	// Возвращает ошибку при неудаче.
+	RefreshSearchText(ctx context.Context) (int, error)
 }
@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// NotificationStorage определяет контракт для работы с уведомлениями пользователей
+type NotificationStorage interface {
+	// CreateBackInStock создает уведомление NotificationBackInStock каждому
+	// подписчику мерча merchID (см. WishlistStorage.Add) и возвращает их
+	// с логинами и именем мерча. Вызывается внутри транзакции, вернувшей мерч на склад.
+	CreateBackInStock(ctx context.Context, merchID int) ([]*models.Notification, error)
+
+	// List возвращает страницу уведомлений пользователя от новых к старым.
+	List(ctx context.Context, userID int, q *models.PageQuery) (*models.Page[*models.Notification], error)
+
+	// Undelivered возвращает не более limit уведомлений, еще не доставленных во внешний
+	// канал (см. Notifier), от старых к новым с логинами и именем мерча.
+	Undelivered(ctx context.Context, limit int) ([]*models.Notification, error)
+
+	// MarkDelivered отмечает уведомления ids доставленными во внешний канал в момент at.
+	// Повторная отметка не меняет время.
+	MarkDelivered(ctx context.Context, ids []int, at time.Time) error
+
+	// MarkRead отмечает уведомление прочитанным (повторная отметка не меняет время).
+	// Чужое уведомление не отличается от несуществующего: ErrNotificationNotFound.
+	MarkRead(ctx context.Context, userID, id int) error
+}
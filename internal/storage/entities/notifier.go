@@ -0,0 +1,17 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// Notifier определяет контракт внешнего канала уведомлений (например, вебхука).
+// Внутренний ящик пользователя - NotificationStorage, Notifier только рассылает
+// уже сохраненные уведомления и вызывается после фиксации транзакции.
+// Недоставленные уведомления отправляются повторно, поэтому канал должен
+// отбрасывать повторы по id уведомления.
+type Notifier interface {
+	// Notify отправляет уведомления. Ошибка не отменяет уже сохраненные уведомления.
+	Notify(ctx context.Context, notes []*models.Notification) error
+}
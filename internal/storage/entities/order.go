@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// OrderStorage определяет контракт для работы с заказами
+type OrderStorage interface {
+	// Create сохраняет заказ вместе со строками (в историю покупок),
+	// скидкой и промокодом. Заказ создается в статусе OrderPlaced.
+	// Заполняет Id, Status, CreatedAt и UpdatedAt заказа и Id строк.
+	// Возвращает ошибку при неудаче.
+	Create(ctx context.Context, order *models.Order) error
+
+	// Get возвращает заказ со строками и логином покупателя.
+	// Если заказа нет, возвращает ErrOrderNotFound.
+	Get(ctx context.Context, id int) (*models.Order, error)
+
+	// GetForUpdate - то же, что Get, но блокирует заказ
+	// до конца транзакции. Имеет смысл только внутри TxManager.WithinTx.
+	GetForUpdate(ctx context.Context, id int) (*models.Order, error)
+
+	// List возвращает страницу заказов со строками, упорядоченных
+	// от новых к старым по дате оформления и id (см. models.OrderFilter).
+	List(ctx context.Context, filter *models.OrderFilter) (*models.Page[*models.Order], error)
+
+	// UpdateStatus меняет статус заказа и время его изменения.
+	// Переходы между статусами проверяет сервис.
+	// Если заказа нет, возвращает ErrOrderNotFound.
+	UpdateStatus(ctx context.Context, id int, status models.OrderStatus) error
+
+	// PromoUses возвращает число неотмененных заказов с промокодом promoID:
+	// всего и пользователя userID.
+	PromoUses(ctx context.Context, promoID, userID int) (int, int, error)
+
+	// MerchBought возвращает число штук мерча merchID (всех вариантов)
+	// в неотмененных заказах пользователя userID: всего и в текущем календарном месяце.
+	MerchBought(ctx context.Context, userID, merchID int) (int, int, error)
+}
@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// PromoStorage определяет контракт для работы с промокодами и распродажами
+type PromoStorage interface {
+	// CreatePromo сохраняет промокод, обновляет его ID.
+	// Возвращает ErrPromoExists, если такой промокод уже есть.
+	CreatePromo(ctx context.Context, promo *models.PromoCode) error
+
+	// GetPromoByCode возвращает промокод по коду. Если промокода нет,
+	// возвращает ErrPromoNotFound.
+	GetPromoByCode(ctx context.Context, code string) (*models.PromoCode, error)
+
+	// GetPromoByCodeForUpdate возвращает промокод по коду и блокирует его
+	// до конца текущей транзакции (см. TxManager), чтобы лимиты использования
+	// проверялись по одному заказу за раз.
+	GetPromoByCodeForUpdate(ctx context.Context, code string) (*models.PromoCode, error)
+
+	// ListPromos возвращает все неудаленные промокоды по возрастанию ID, без Uses.
+	ListPromos(ctx context.Context) ([]*models.PromoCode, error)
+
+	// DeletePromo удаляет промокод по ID. Удаление мягкое: на промокод ссылаются заказы.
+	// Возвращает ErrPromoNotFound, если промокода нет.
+	DeletePromo(ctx context.Context, id int) error
+
+	// CreateSale сохраняет распродажу, обновляет её ID.
+	CreateSale(ctx context.Context, sale *models.Sale) error
+
+	// ListSales возвращает все неудаленные распродажи, в том числе прошедшие
+	// и будущие, по возрастанию ID.
+	ListSales(ctx context.Context) ([]*models.Sale, error)
+
+	// ActiveSales возвращает распродажи, идущие в момент at.
+	ActiveSales(ctx context.Context, at time.Time) ([]*models.Sale, error)
+
+	// DeleteSale удаляет распродажу по ID. Удаление мягкое: на распродажу ссылаются покупки.
+	// Возвращает ErrSaleNotFound, если распродажи нет.
+	DeleteSale(ctx context.Context, id int) error
+}
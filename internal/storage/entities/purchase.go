@@ -5,10 +5,13 @@ import (
 	"merch_service/internal/models"
 )
 
+// PurchaseStorage - история покупок. Покупки добавляются
+// в историю вместе с заказом (см. OrderStorage.Create)
 type PurchaseStorage interface {
-	// Create - добавляет покупкку мерча юзером в историю
-	Create(ctx context.Context, currUser *models.User, merch *models.Item, count int) error
-
 	// Get - получает слайс покупок пользователя
 	Get(ctx context.Context, user *models.User) ([]*models.PurchaseEntry, error)
+
+	// List - получает страницу покупок пользователя,
+	// упорядоченных от новых к старым по дате и id
+	List(ctx context.Context, user *models.User, q *models.PageQuery) (*models.Page[*models.PurchaseEntry], error)
 }
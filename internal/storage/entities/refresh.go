@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// RefreshTokenStorage определяет контракт для работы с refresh токенами
+type RefreshTokenStorage interface {
+	// Create сохраняет новый refresh токен.
+	// Возвращает ошибку при неудаче.
+	Create(ctx context.Context, token *models.RefreshToken) error
+
+	// GetForUpdate возвращает refresh токен по ID и блокирует его строку
+	// до конца текущей транзакции (см. TxManager). Если токен не найден,
+	// возвращает nil и ошибку.
+	GetForUpdate(ctx context.Context, id string) (*models.RefreshToken, error)
+
+	// MarkUsed помечает refresh токен использованным.
+	// Возвращает ошибку при неудаче.
+	MarkUsed(ctx context.Context, id string) error
+
+	// RevokeFamily отзывает все refresh токены семейства.
+	// Возвращает ошибку при неудаче.
+	RevokeFamily(ctx context.Context, familyID string) error
+
+	// RevokeAllByUser отзывает все refresh токены пользователя.
+	// Возвращает ошибку при неудаче.
+	RevokeAllByUser(ctx context.Context, userID int) error
+}
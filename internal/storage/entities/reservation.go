@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// ReservationStorage определяет контракт для работы с бронями товаров
+type ReservationStorage interface {
+	// Create сохраняет активную бронь, обновляет ID и дату создания брони.
+	Create(ctx context.Context, r *models.Reservation) error
+
+	// Get возвращает бронь по ID с именем мерча и артикулом варианта.
+	// Если брони нет, возвращает ErrReservationNotFound.
+	Get(ctx context.Context, id int) (*models.Reservation, error)
+
+	// GetForUpdate возвращает бронь по ID и блокирует ее строку
+	// до конца текущей транзакции (см. TxManager).
+	GetForUpdate(ctx context.Context, id int) (*models.Reservation, error)
+
+	// List возвращает страницу броней пользователя от новых к старым.
+	List(ctx context.Context, userID int, q *models.PageQuery) (*models.Page[*models.Reservation], error)
+
+	// Reserved возвращает штуки мерча merchID (варианта variantID, 0 - мерча без вариантов)
+	// в бронях, активных в момент at (см. Reservation.ActiveAt).
+	Reserved(ctx context.Context, merchID, variantID int, at time.Time) (models.Reserved, error)
+
+	// UserReserved возвращает штуки мерча merchID (всех вариантов) в бронях пользователя
+	// userID, активных в момент at, кроме брони excludeID (0 - без исключения).
+	UserReserved(ctx context.Context, userID, merchID int, at time.Time, excludeID int) (int, error)
+
+	// Expired возвращает id не более limit активных броней, истекших к моменту at,
+	// от старых к новым.
+	Expired(ctx context.Context, at time.Time, limit int) ([]int, error)
+
+	// UpdateStatus переводит бронь в status и связывает ее с заказом orderID (0 - без заказа).
+	// Возвращает ErrReservationNotFound, если брони нет.
+	UpdateStatus(ctx context.Context, id int, status models.ReservationStatus, orderID int) error
+}
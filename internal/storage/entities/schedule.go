@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// ScheduleStorage определяет контракт для работы с запланированными переводами
+type ScheduleStorage interface {
+	// Create сохраняет активный перевод с первым срабатыванием в StartAt,
+	// обновляет ID, статус, NextRunAt и дату создания перевода.
+	Create(ctx context.Context, s *models.ScheduledTransfer) error
+
+	// Get возвращает перевод по ID с логином получателя и последним срабатыванием.
+	// Если перевода нет, возвращает ErrScheduleNotFound.
+	Get(ctx context.Context, id int) (*models.ScheduledTransfer, error)
+
+	// GetForUpdate возвращает перевод по ID и блокирует его строку
+	// до конца текущей транзакции (см. TxManager).
+	GetForUpdate(ctx context.Context, id int) (*models.ScheduledTransfer, error)
+
+	// List возвращает страницу переводов отправителя от новых к старым.
+	List(ctx context.Context, senderID int, q *models.PageQuery) (*models.Page[*models.ScheduledTransfer], error)
+
+	// Due возвращает id не более limit активных переводов, срабатывание
+	// которых наступило к моменту at, от ранних к поздним.
+	Due(ctx context.Context, at time.Time, limit int) ([]int, error)
+
+	// UpdateStatus переводит перевод в status со следующим срабатыванием nextRunAt (nil - без него).
+	// Возвращает ErrScheduleNotFound, если перевода нет.
+	UpdateStatus(ctx context.Context, id int, status models.ScheduleStatus, nextRunAt *time.Time) error
+
+	// CreateRun записывает срабатывание, обновляет его ID и дату создания.
+	// Если срабатывание на это же время уже записано, возвращает ErrScheduleRunExists.
+	CreateRun(ctx context.Context, run *models.ScheduleRun) error
+
+	// Runs возвращает страницу срабатываний перевода от поздних к ранним.
+	Runs(ctx context.Context, scheduleID int, q *models.PageQuery) (*models.Page[*models.ScheduleRun], error)
+}
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// SessionStorage определяет контракт для работы с сессиями пользователей
+type SessionStorage interface {
+	// Create сохраняет новую сессию.
+	// Возвращает ошибку при неудаче.
+	Create(ctx context.Context, session *models.Session) error
+
+	// Get возвращает сессию по ID (jti токена). Если сессия не найдена,
+	// возвращает nil и ошибку.
+	Get(ctx context.Context, id string) (*models.Session, error)
+
+	// ListActive возвращает неотозванные и неистекшие сессии пользователя.
+	// Возвращает ошибку при неудаче.
+	ListActive(ctx context.Context, userID int) ([]*models.Session, error)
+
+	// RevokeFamily отзывает все сессии семейства.
+	// Возвращает ошибку при неудаче.
+	RevokeFamily(ctx context.Context, familyID string) error
+
+	// RevokeAllByUser отзывает все сессии пользователя.
+	// Возвращает ошибку при неудаче.
+	RevokeAllByUser(ctx context.Context, userID int) error
+}
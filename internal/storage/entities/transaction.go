@@ -2,6 +2,7 @@ package entities
 
 import (
 	"context"
+	"time"
 
 	"merch_service/internal/models"
 )
@@ -10,7 +11,36 @@ import (
 type TransactionStorage interface {
 	// Базовые CRUD операции
 
-	// Create создает новую транзакцию между пользователями.
+	// Create записывает перевод между пользователями с поводом и сообщением note
+	// и возвращает его id. Балансы не меняет: движение монет проводится через LedgerStorage.
 	// Возвращает ошибку при неудаче.
-	Create(ctx context.Context, send *models.User, recv *models.User, amount int) error
+	Create(ctx context.Context, send *models.User, recv *models.User, amount int, note models.TransferNote) (int, error)
+
+	// Дополнительные методы
+
+	// List возвращает страницу переводов пользователя, подходящих под filter,
+	// упорядоченных от новых к старым по дате и id.
+	// Возвращает ошибку при неудаче.
+	List(ctx context.Context, user *models.User, filter *models.TransferFilter) (*models.Page[*models.TransferEntry], error)
+
+	// Get возвращает перевод id, в котором участвует пользователь, как запись его истории.
+	// Возвращает ErrTransferNotFound, если перевода нет или он чужой
+	Get(ctx context.Context, user *models.User, id int) (*models.TransferEntry, error)
+
+	// React ставит реакцию получателя receiverID на перевод id (ReactionNone снимает реакцию).
+	// Возвращает ErrTransferNotFound, если перевода нет или receiverID не его получатель
+	React(ctx context.Context, id, receiverID int, reaction models.TransferReaction) error
+
+	// Reply сохраняет ответ получателя receiverID на перевод id. Ответить можно один раз,
+	// повторный ответ - ErrTransferReplied. Возвращает ErrTransferNotFound, если перевода нет
+	// или receiverID не его получатель
+	Reply(ctx context.Context, id, receiverID int, message string) error
+
+	// Velocity возвращает сумму переводов senderID за сутки и за 7 дней до at
+	// и число его переводов не больше smallAmount монет receiverID за сутки до at.
+	Velocity(ctx context.Context, senderID, receiverID int, at time.Time, smallAmount int) (models.TransferVelocity, error)
+
+	// HasChain проверяет, есть ли цепочка переводов от fromID к toID не длиннее depth,
+	// все переводы которой сделаны начиная с since.
+	HasChain(ctx context.Context, fromID, toID int, since time.Time, depth int) (bool, error)
 }
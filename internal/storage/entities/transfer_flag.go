@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// TransferFlagStorage определяет контракт для работы с пометками подозрительных переводов
+type TransferFlagStorage interface {
+	// Create сохраняет открытую пометку перевода, обновляет ID, статус и дату создания.
+	Create(ctx context.Context, f *models.TransferFlag) error
+
+	// Get возвращает пометку по ID с логинами сторон перевода и проверяющего.
+	// Если пометки нет, возвращает ErrTransferFlagNotFound.
+	Get(ctx context.Context, id int) (*models.TransferFlag, error)
+
+	// List возвращает страницу пометок от новых к старым.
+	List(ctx context.Context, filter *models.TransferFlagFilter) (*models.Page[*models.TransferFlag], error)
+
+	// Review закрывает открытую пометку статусом status от имени reviewerID.
+	// Повторная проверка - ErrTransferFlagClosed, нет пометки - ErrTransferFlagNotFound.
+	Review(ctx context.Context, id int, status models.TransferFlagStatus, reviewerID int) error
+}
@@ -0,0 +1,13 @@
+package entities
+
+import "context"
+
+// TxManager определяет контракт единицы работы (unit of work).
+// Все вызовы хранилищ внутри fn, получающие переданный в fn контекст,
+// выполняются в одной транзакции БД.
+type TxManager interface {
+	// WithinTx открывает транзакцию и выполняет в ней fn.
+	// Если fn вернула ошибку, транзакция откатывается, иначе фиксируется.
+	// Вложенный вызов WithinTx переиспользует уже открытую транзакцию.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -32,4 +32,24 @@ type UserStorage interface {
 	// Get возвращает пользователя по ID. Если пользователь не найден,
 	// возвращает nil и ошибку.
 	GetByLogin(ctx context.Context, login string) (*models.User, error)
+
+	// GetByLoginForUpdate возвращает пользователя по логину и блокирует
+	// его строку до конца текущей транзакции (см. TxManager).
+	GetByLoginForUpdate(ctx context.Context, login string) (*models.User, error)
+
+	// CountByRoleForUpdate возвращает число пользователей с ролью role и блокирует
+	// их строки до конца текущей транзакции (см. TxManager).
+	CountByRoleForUpdate(ctx context.Context, role models.Role) (int, error)
+
+	// UpdatePassword обновляет только пароль (хеш пароля) пользователя с id.
+	// Возвращает ошибку при неудаче.
+	UpdatePassword(ctx context.Context, id int, password string) error
+
+	// UpdateRole обновляет только роль пользователя с id.
+	// Возвращает ошибку при неудаче.
+	UpdateRole(ctx context.Context, id int, role models.Role) error
+
+	// GetList возвращает слайс всех пользователей.
+	// Возвращает ошибку при неудаче.
+	GetList(ctx context.Context) ([]*models.User, error)
 }
@@ -0,0 +1,39 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// VariantStorage определяет контракт для работы с вариантами товаров
+type VariantStorage interface {
+	// Create создает вариант товара variant.MerchId, обновляет ID варианта.
+	// Возвращает ErrVariantExists, если артикул уже занят.
+	// Возвращает ошибку при неудаче.
+	Create(ctx context.Context, variant *models.Variant) error
+
+	// Get возвращает вариант по ID. Если вариант не найден,
+	// возвращает ErrVariantNotFound.
+	Get(ctx context.Context, id int) (*models.Variant, error)
+
+	// GetForUpdate возвращает вариант по ID и блокирует его строку
+	// до конца текущей транзакции (см. TxManager).
+	GetForUpdate(ctx context.Context, id int) (*models.Variant, error)
+
+	// GetBySku возвращает вариант по артикулу. Если вариант не найден,
+	// возвращает ErrVariantNotFound.
+	GetBySku(ctx context.Context, sku string) (*models.Variant, error)
+
+	// List возвращает варианты товаров merchIDs, упорядоченные по ID товара и варианта.
+	// Возвращает ошибку при неудаче.
+	List(ctx context.Context, merchIDs []int) ([]*models.Variant, error)
+
+	// Update обновляет артикул, атрибуты, цену и остаток варианта.
+	// Возвращает ErrVariantExists, если артикул занят другим вариантом.
+	Update(ctx context.Context, variant *models.Variant) error
+
+	// Delete удаляет вариант по ID. Удаление мягкое, как у товаров (см. MerchStorage.Delete).
+	// Возвращает ErrVariantNotFound, если варианта нет.
+	Delete(ctx context.Context, id int) error
+}
@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+)
+
+// WishlistStorage определяет контракт для работы со списками желаний
+type WishlistStorage interface {
+	// Add добавляет мерч merchID в список желаний пользователя userID
+	// или меняет подписку notify у уже добавленного.
+	// Если мерча нет в каталоге, возвращает ErrMerchNotFound.
+	Add(ctx context.Context, userID, merchID int, notify bool) error
+
+	// Remove убирает мерч из списка желаний.
+	// Если его там нет, возвращает ErrWishlistItemNotFound.
+	Remove(ctx context.Context, userID, merchID int) error
+
+	// List возвращает список желаний пользователя по времени добавления.
+	// Удаленный из каталога мерч не возвращается.
+	List(ctx context.Context, userID int) ([]*models.WishlistItem, error)
+}
@@ -0,0 +1,107 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+)
+
+var _ entities.BlobStorage = (*BlobFS)(nil)
+
+// BlobFS реализует интерфейс BlobStorage в локальной файловой системе:
+// файл с ключом key лежит в root/key
+type BlobFS struct {
+	root string
+}
+
+// NewBlobStorage создает новый экземпляр хранилища файлов в каталоге root.
+// Каталог создается при первой записи
+func NewBlobStorage(root string) *BlobFS {
+	return &BlobFS{root: root}
+}
+
+// path - путь к файлу key. Ключ не может выходить за пределы root
+func (b *BlobFS) path(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(key) || strings.Contains(key, `\`) {
+		return "", models.ErrInvalidBlobKey
+	}
+	return filepath.Join(b.root, filepath.FromSlash(key)), nil
+}
+
+// Put записывает файл во временный файл рядом и переименовывает его,
+// поэтому читатели не видят недописанный файл
+func (b *BlobFS) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open открывает файл key, время записи - время изменения файла
+func (b *BlobFS) Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, time.Time{}, models.ErrBlobNotFound
+		}
+		return nil, time.Time{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, time.Time{}, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, time.Time{}, models.ErrBlobNotFound
+	}
+
+	return file, info.ModTime(), nil
+}
+
+// Delete удаляет файл key
+func (b *BlobFS) Delete(ctx context.Context, key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.CartStorage = (*CartPG)(nil)
+
+// CartPG реализует интерфейс CartStorage в PostgreSQL
+type CartPG struct {
+	db *pgxpool.Pool
+}
+
+// NewCartStorage создает новый экземпляр хранилища корзин.
+func NewCartStorage(db *pgxpool.Pool) *CartPG {
+	return &CartPG{db: db}
+}
+
+// cartQuery - строки корзины с текущими именем и ценой неудаленного товара
+// или варианта (цена варианта, если задана, иначе цена товара)
+const cartQuery = `
+	SELECT m.merch_id, c.variant_id, m.name, COALESCE(v.sku, ''), COALESCE(v.price, m.price), c.count
+	FROM merchshop.cart_items AS c
+	JOIN merchshop.merch AS m ON m.merch_id = c.merch_id
+	LEFT JOIN merchshop.merch_variants AS v ON v.variant_id = c.variant_id AND v.deleted_at IS NULL
+	WHERE c.user_id = $1 AND m.deleted_at IS NULL
+		AND (c.variant_id = 0 OR v.variant_id IS NOT NULL)
+	ORDER BY c.merch_id, c.variant_id
+`
+
+// Get возвращает строки корзины пользователя
+func (c *CartPG) Get(ctx context.Context, userID int) ([]*models.CartLine, error) {
+	return c.get(ctx, cartQuery, userID)
+}
+
+// GetForUpdate возвращает строки корзины пользователя и блокирует их
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (c *CartPG) GetForUpdate(ctx context.Context, userID int) ([]*models.CartLine, error) {
+	return c.get(ctx, cartQuery+" FOR UPDATE OF c", userID)
+}
+
+func (c *CartPG) get(ctx context.Context, query string, userID int) ([]*models.CartLine, error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	rows, err := conn(ctx, c.db).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]*models.CartLine, 0)
+	for rows.Next() {
+		var line models.CartLine
+		if err := rows.Scan(
+			&line.MerchId,
+			&line.VariantId,
+			&line.Name,
+			&line.Variant,
+			&line.Price,
+			&line.Count,
+		); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// Add добавляет товар в корзину, увеличивая количество уже лежащего товара
+func (c *CartPG) Add(ctx context.Context, userID, merchID, variantID, count int) error {
+	return c.upsert(ctx, userID, merchID, variantID, count, "merchshop.cart_items.count + EXCLUDED.count")
+}
+
+// Set задает количество товара в корзине
+func (c *CartPG) Set(ctx context.Context, userID, merchID, variantID, count int) error {
+	return c.upsert(ctx, userID, merchID, variantID, count, "EXCLUDED.count")
+}
+
+// upsert добавляет строку корзины или меняет количество на newCount.
+// Строка вставляется только для неудаленного товара и неудаленного варианта этого товара
+func (c *CartPG) upsert(ctx context.Context, userID, merchID, variantID, count int, newCount string) error {
+	if userID <= 0 {
+		return models.ErrInvalidUserID
+	}
+	if merchID <= 0 {
+		return models.ErrInvalidMerchID
+	}
+	if count <= 0 {
+		return models.ErrInvalidCount
+	}
+
+	query := `
+		INSERT INTO merchshop.cart_items (user_id, merch_id, variant_id, count)
+		SELECT $1, m.merch_id, $3, $4
+		FROM merchshop.merch AS m
+		WHERE m.merch_id = $2 AND m.deleted_at IS NULL
+			AND ($3 = 0 OR EXISTS (
+				SELECT 1 FROM merchshop.merch_variants AS v
+				WHERE v.variant_id = $3 AND v.merch_id = m.merch_id AND v.deleted_at IS NULL
+			))
+		ON CONFLICT (user_id, merch_id, variant_id) DO UPDATE SET count = ` + newCount
+
+	result, err := conn(ctx, c.db).Exec(ctx, query, userID, merchID, variantID, count)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		if variantID != 0 {
+			return models.ErrVariantNotFound
+		}
+		return models.ErrMerchNotFound
+	}
+
+	return nil
+}
+
+// RemoveDeleted убирает из корзины строки удаленных товаров и вариантов
+func (c *CartPG) RemoveDeleted(ctx context.Context, userID int) ([]*models.CartLine, error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	rows, err := conn(ctx, c.db).Query(ctx, `
+		DELETE FROM merchshop.cart_items AS c
+		USING merchshop.merch AS m
+		WHERE c.user_id = $1 AND m.merch_id = c.merch_id
+			AND (m.deleted_at IS NOT NULL OR (c.variant_id <> 0 AND NOT EXISTS (
+				SELECT 1 FROM merchshop.merch_variants AS v
+				WHERE v.variant_id = c.variant_id AND v.deleted_at IS NULL
+			)))
+		RETURNING c.merch_id, c.variant_id, m.name, COALESCE((
+			SELECT v.sku FROM merchshop.merch_variants AS v WHERE v.variant_id = c.variant_id
+		), ''), c.count
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]*models.CartLine, 0)
+	for rows.Next() {
+		var line models.CartLine
+		if err := rows.Scan(
+			&line.MerchId,
+			&line.VariantId,
+			&line.Name,
+			&line.Variant,
+			&line.Count,
+		); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// Remove убирает товар из корзины
+func (c *CartPG) Remove(ctx context.Context, userID, merchID, variantID int) error {
+	result, err := conn(ctx, c.db).Exec(ctx,
+		"DELETE FROM merchshop.cart_items WHERE user_id = $1 AND merch_id = $2 AND variant_id = $3",
+		userID, merchID, variantID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrCartItemNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.CoinRequestStorage = (*CoinRequestPG)(nil)
+
+// CoinRequestPG реализует интерфейс CoinRequestStorage в PostgreSQL
+type CoinRequestPG struct {
+	db *pgxpool.Pool
+}
+
+// NewCoinRequestStorage создает новый экземпляр хранилища запросов монет.
+func NewCoinRequestStorage(db *pgxpool.Pool) *CoinRequestPG {
+	return &CoinRequestPG{db: db}
+}
+
+// Create сохраняет ожидающий запрос монет
+func (c *CoinRequestPG) Create(ctx context.Context, r *models.CoinRequest) error {
+	if r == nil {
+		return models.ErrEmptyCoinRequest
+	}
+	if r.RequesterId <= 0 {
+		return models.ErrInvalidReceiverID
+	}
+	if r.PayerId <= 0 {
+		return models.ErrInvalidSenderID
+	}
+	if r.RequesterId == r.PayerId {
+		return models.ErrSameSenderReceiver
+	}
+	if r.Amount <= 0 {
+		return models.ErrInvalidAmount
+	}
+	if !r.Category.Valid() {
+		return models.ErrInvalidTransferCategory
+	}
+
+	expires := r.ExpiresAt.UTC()
+	err := conn(ctx, c.db).QueryRow(ctx, `
+		INSERT INTO merchshop.coin_requests
+			(requester_id, payer_id, amount, category, message, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+		RETURNING request_id, status, created_at
+	`,
+		r.RequesterId, r.PayerId, r.Amount, string(r.Category), r.Message, expires,
+	).Scan(&r.Id, &r.Status, &r.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	r.ExpiresAt = expires
+	r.TransferId = 0
+	r.ResolvedAt = nil
+	return nil
+}
+
+// coinRequestQuery - запрос монет с логинами обеих сторон
+const coinRequestQuery = `
+	SELECT r.request_id, r.requester_id, req.login, r.payer_id, payer.login, r.amount,
+		COALESCE(r.category, ''), COALESCE(r.message, ''), r.status,
+		COALESCE(r.transaction_id, 0), r.created_at, r.expires_at, r.resolved_at
+	FROM merchshop.coin_requests AS r
+	JOIN merchshop.users AS req ON req.user_id = r.requester_id
+	JOIN merchshop.users AS payer ON payer.user_id = r.payer_id
+`
+
+// scanCoinRequest - читает строку coinRequestQuery
+func scanCoinRequest(row pgx.Row) (*models.CoinRequest, error) {
+	var r models.CoinRequest
+	err := row.Scan(
+		&r.Id,
+		&r.RequesterId,
+		&r.Requester,
+		&r.PayerId,
+		&r.Payer,
+		&r.Amount,
+		&r.Category,
+		&r.Message,
+		&r.Status,
+		&r.TransferId,
+		&r.CreatedAt,
+		&r.ExpiresAt,
+		&r.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Get возвращает запрос монет по id
+func (c *CoinRequestPG) Get(ctx context.Context, id int) (*models.CoinRequest, error) {
+	return c.get(ctx, id, "")
+}
+
+// GetForUpdate возвращает запрос монет по id и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (c *CoinRequestPG) GetForUpdate(ctx context.Context, id int) (*models.CoinRequest, error) {
+	return c.get(ctx, id, " FOR UPDATE OF r")
+}
+
+func (c *CoinRequestPG) get(ctx context.Context, id int, lock string) (*models.CoinRequest, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidCoinRequestID
+	}
+
+	r, err := scanCoinRequest(conn(ctx, c.db).QueryRow(ctx, coinRequestQuery+"WHERE r.request_id = $1"+lock, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrCoinRequestNotFound
+		}
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// List возвращает страницу запросов, отправленных пользователем и (или) полученных им,
+// начиная с самых новых. Ожидающий запрос с expires_at не позже at считается истекшим
+func (c *CoinRequestPG) List(ctx context.Context, userID int, filter *models.CoinRequestFilter, at time.Time) (*models.Page[*models.CoinRequest], error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	if filter == nil {
+		filter = &models.CoinRequestFilter{}
+	}
+
+	direction := filter.Direction
+	if direction == "" {
+		direction = models.TransferAll
+	}
+
+	p := newPageParams(&filter.PageQuery)
+
+	rows, err := conn(ctx, c.db).Query(ctx, coinRequestQuery+`
+		WHERE ((r.requester_id = $1 AND $2 <> 'received') OR (r.payer_id = $1 AND $2 <> 'sent'))
+			AND ($3 = '' OR $3 = CASE
+				WHEN r.status = 'pending' AND r.expires_at <= $4 THEN 'expired'
+				ELSE r.status
+			END)
+			AND ($5::timestamp IS NULL OR r.created_at >= $5)
+			AND ($6::timestamp IS NULL OR r.created_at < $6)
+			AND ($7::timestamp IS NULL OR (r.created_at, r.request_id) < ($7, $8))
+		ORDER BY r.created_at DESC, r.request_id DESC
+		LIMIT $9
+	`,
+		userID,
+		string(direction),
+		string(filter.Status),
+		at.UTC(),
+		p.from,
+		p.to,
+		p.afterDate,
+		p.afterID,
+		p.limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.CoinRequest, error) {
+		r, err := scanCoinRequest(row)
+		if err != nil {
+			return nil, err
+		}
+		r.Status = r.StatusAt(at)
+		return r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(list, p.pageLimit(), (*models.CoinRequest).Cursor), nil
+}
+
+// Counts считает ожидающие и недавно созданные запросы пользователя
+func (c *CoinRequestPG) Counts(ctx context.Context, requesterID, payerID int, at, since time.Time) (models.CoinRequestCounts, error) {
+	var counts models.CoinRequestCounts
+	if requesterID <= 0 {
+		return counts, models.ErrInvalidUserID
+	}
+
+	err := conn(ctx, c.db).QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending' AND expires_at > $3),
+			COUNT(*) FILTER (WHERE status = 'pending' AND expires_at > $3 AND payer_id = $2),
+			COUNT(*) FILTER (WHERE created_at >= $4)
+		FROM merchshop.coin_requests
+		WHERE requester_id = $1
+	`, requesterID, payerID, at.UTC(), since.UTC()).Scan(&counts.Pending, &counts.PendingToPayer, &counts.Created)
+
+	return counts, err
+}
+
+// Resolve закрывает запрос монет статусом status и запоминает время ответа
+func (c *CoinRequestPG) Resolve(ctx context.Context, id int, status models.CoinRequestStatus, transferID int) error {
+	if !status.Valid() || status == models.CoinRequestPending {
+		return models.ErrInvalidCoinRequestStatus
+	}
+
+	result, err := conn(ctx, c.db).Exec(ctx, `
+		UPDATE merchshop.coin_requests
+		SET status = $2, transaction_id = NULLIF($3, 0), resolved_at = CURRENT_TIMESTAMP
+		WHERE request_id = $1
+	`, id, string(status), transferID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrCoinRequestNotFound
+	}
+
+	return nil
+}
@@ -27,7 +27,7 @@ func (c *CoinsPG) Create(ctx context.Context, currUser *models.User, oldBalance
 		VALUES ($1, $2, $3);
 	`
 
-	_, err := c.db.Exec(
+	_, err := conn(ctx, c.db).Exec(
 		ctx,
 		query,
 		currUser.Id,
@@ -38,15 +38,102 @@ func (c *CoinsPG) Create(ctx context.Context, currUser *models.User, oldBalance
 	return err
 }
 
+// CreateForOrder - добавляет изменение баланса юзера в историю со ссылкой на заказ
+func (c *CoinsPG) CreateForOrder(ctx context.Context, currUser *models.User, oldBalance int, reason models.LedgerReason, orderID int) error {
+	query := `
+		INSERT INTO merchshop.coinhistory (user_id, coins_before, coins_after, reason, order_id)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	_, err := conn(ctx, c.db).Exec(
+		ctx,
+		query,
+		currUser.Id,
+		oldBalance,
+		currUser.Coins,
+		string(reason),
+		orderID,
+	)
+
+	return err
+}
+
+// CreateForReservation - добавляет изменение баланса юзера в историю со ссылкой на бронь
+func (c *CoinsPG) CreateForReservation(ctx context.Context, currUser *models.User, oldBalance int, reason models.LedgerReason, reservationID int) error {
+	query := `
+		INSERT INTO merchshop.coinhistory (user_id, coins_before, coins_after, reason, reservation_id)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	_, err := conn(ctx, c.db).Exec(
+		ctx,
+		query,
+		currUser.Id,
+		oldBalance,
+		currUser.Coins,
+		string(reason),
+		reservationID,
+	)
+
+	return err
+}
+
+// CreateForTransfer - добавляет изменение баланса юзера в историю со ссылкой на перевод.
+// Повод и сообщение не дублируются: Get и List берут их из merchshop.transactions
+func (c *CoinsPG) CreateForTransfer(ctx context.Context, currUser *models.User, oldBalance int, transferID int, note models.TransferNote) error {
+	query := `
+		INSERT INTO merchshop.coinhistory (user_id, coins_before, coins_after, reason, transfer_id)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	_, err := conn(ctx, c.db).Exec(
+		ctx,
+		query,
+		currUser.Id,
+		oldBalance,
+		currUser.Coins,
+		string(models.ReasonTransfer),
+		transferID,
+	)
+
+	return err
+}
+
+// CreateForGrant - добавляет начисление из казны в историю со ссылкой на строку пакета.
+// Причина не дублируется: Get и List берут ее из merchshop.coin_grant_items
+func (c *CoinsPG) CreateForGrant(ctx context.Context, currUser *models.User, oldBalance int, grantID int, row *models.GrantRow) error {
+	query := `
+		INSERT INTO merchshop.coinhistory (user_id, coins_before, coins_after, reason, grant_item_id)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	_, err := conn(ctx, c.db).Exec(
+		ctx,
+		query,
+		currUser.Id,
+		oldBalance,
+		currUser.Coins,
+		string(models.ReasonGrant),
+		row.Id,
+	)
+
+	return err
+}
+
 // Get - получает слайс изменений баланса пользователя
 func (c *CoinsPG) Get(ctx context.Context, user *models.User) ([]*models.CoinsEntry, error) {
 	query := `
-		SELECT change_id, change_date, coins_before, coins_after
-		FROM merchshop.coinhistory
-		WHERE user_id = $1;
+		SELECT h.change_id, h.change_date, h.coins_before, h.coins_after, COALESCE(h.reason, ''),
+			COALESCE(h.order_id, 0), COALESCE(h.reservation_id, 0), COALESCE(h.transfer_id, 0),
+			COALESCE(g.grant_id, 0), COALESCE(t.category, ''), COALESCE(t.message, g.reason, '')
+		FROM merchshop.coinhistory AS h
+		LEFT JOIN merchshop.transactions AS t ON t.transaction_id = h.transfer_id
+		LEFT JOIN merchshop.coin_grant_items AS g ON g.item_id = h.grant_item_id
+		WHERE h.user_id = $1
+		ORDER BY h.change_date, h.change_id;
 	`
 
-	rows, err := c.db.Query(
+	rows, err := conn(ctx, c.db).Query(
 		ctx,
 		query,
 		user.Id,
@@ -66,6 +153,13 @@ func (c *CoinsPG) Get(ctx context.Context, user *models.User) ([]*models.CoinsEn
 			&entry.Date,
 			&entry.CoinsBefore,
 			&entry.CoinsAfter,
+			&entry.Reason,
+			&entry.OrderId,
+			&entry.ReservationId,
+			&entry.TransferId,
+			&entry.GrantId,
+			&entry.Category,
+			&entry.Message,
 		); err != nil {
 			return nil, err
 		}
@@ -77,3 +171,68 @@ func (c *CoinsPG) Get(ctx context.Context, user *models.User) ([]*models.CoinsEn
 	}
 	return coinsHist, nil
 }
+
+// List - получает страницу изменений баланса пользователя,
+// начиная с самых новых
+func (c *CoinsPG) List(ctx context.Context, user *models.User, q *models.PageQuery) (*models.Page[*models.CoinsEntry], error) {
+	p := newPageParams(q)
+
+	query := `
+		SELECT h.change_id, h.change_date, h.coins_before, h.coins_after, COALESCE(h.reason, ''),
+			COALESCE(h.order_id, 0), COALESCE(h.reservation_id, 0), COALESCE(h.transfer_id, 0),
+			COALESCE(g.grant_id, 0), COALESCE(t.category, ''), COALESCE(t.message, g.reason, '')
+		FROM merchshop.coinhistory AS h
+		LEFT JOIN merchshop.transactions AS t ON t.transaction_id = h.transfer_id
+		LEFT JOIN merchshop.coin_grant_items AS g ON g.item_id = h.grant_item_id
+		WHERE h.user_id = $1
+			AND ($2::timestamp IS NULL OR h.change_date >= $2)
+			AND ($3::timestamp IS NULL OR h.change_date < $3)
+			AND ($4::timestamp IS NULL OR (h.change_date, h.change_id) < ($4, $5))
+		ORDER BY h.change_date DESC, h.change_id DESC
+		LIMIT $6;
+	`
+
+	rows, err := conn(ctx, c.db).Query(
+		ctx,
+		query,
+		user.Id,
+		p.from,
+		p.to,
+		p.afterDate,
+		p.afterID,
+		p.limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var coinsHist []*models.CoinsEntry
+	for rows.Next() {
+		var entry models.CoinsEntry
+		if err := rows.Scan(
+			&entry.Id,
+			&entry.Date,
+			&entry.CoinsBefore,
+			&entry.CoinsAfter,
+			&entry.Reason,
+			&entry.OrderId,
+			&entry.ReservationId,
+			&entry.TransferId,
+			&entry.GrantId,
+			&entry.Category,
+			&entry.Message,
+		); err != nil {
+			return nil, err
+		}
+		coinsHist = append(coinsHist, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(coinsHist, p.pageLimit(), (*models.CoinsEntry).Cursor), nil
+}
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.GrantStorage = (*GrantPG)(nil)
+
+// GrantPG реализует интерфейс GrantStorage в PostgreSQL
+type GrantPG struct {
+	db *pgxpool.Pool
+}
+
+// NewGrantStorage создает новый экземпляр хранилища пакетов начислений.
+func NewGrantStorage(db *pgxpool.Pool) *GrantPG {
+	return &GrantPG{db: db}
+}
+
+// Create сохраняет пакет начислений и его строки в одной транзакции
+func (g *GrantPG) Create(ctx context.Context, b *models.GrantBatch) error {
+	if b == nil || len(b.Rows) == 0 {
+		return models.ErrEmptyGrantBatch
+	}
+	if b.AdminId <= 0 {
+		return models.ErrInvalidUserID
+	}
+	for _, row := range b.Rows {
+		if row.UserId <= 0 {
+			return models.ErrInvalidUserID
+		}
+		if row.Amount <= 0 {
+			return models.ErrInvalidGrantAmount
+		}
+	}
+
+	tx, err := conn(ctx, g.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO merchshop.coin_grants (admin_id, total)
+		VALUES ($1, $2)
+		RETURNING grant_id, created_at
+	`, b.AdminId, b.Total).Scan(&b.Id, &b.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range b.Rows {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO merchshop.coin_grant_items (grant_id, line, user_id, amount, reason)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING item_id
+		`, b.Id, row.Line, row.UserId, row.Amount, row.Reason).Scan(&row.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
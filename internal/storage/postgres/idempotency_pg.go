@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.IdempotencyStorage = (*IdempotencyPG)(nil)
+
+// IdempotencyPG реализует интерфейс IdempotencyStorage в PostgreSQL
+type IdempotencyPG struct {
+	db *pgxpool.Pool
+}
+
+// NewIdempotencyStorage создает новый экземпляр хранилища ключей идемпотентности.
+func NewIdempotencyStorage(db *pgxpool.Pool) *IdempotencyPG {
+	return &IdempotencyPG{db: db}
+}
+
+// Create резервирует ключ за пользователем. Первичный ключ (user_id, idempotency_key)
+// гарантирует, что из параллельных запросов с одним ключом выполнится только один
+func (i *IdempotencyPG) Create(ctx context.Context, record *models.IdempotencyRecord, at time.Time) error {
+	if record == nil {
+		return models.ErrEmptyIdempotencyRecord
+	}
+
+	if record.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+
+	db := conn(ctx, i.db)
+
+	_, err := db.Exec(ctx,
+		"DELETE FROM merchshop.idempotency_keys WHERE user_id = $1 AND expires_at <= $2",
+		record.UserId, at.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO merchshop.idempotency_keys (user_id, idempotency_key, request_hash, locked_until, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	err = db.QueryRow(
+		ctx,
+		query,
+		record.UserId,
+		record.Key,
+		record.RequestHash,
+		record.LockedUntil.UTC(),
+		record.ExpiresAt.UTC(),
+	).Scan(&record.CreatedAt)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrIdempotencyKeyExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Takeover продлевает истекшую аренду незавершенного ключа. Условие на locked_until
+// в UPDATE не дает двум повторам занять брошенный ключ одновременно
+func (i *IdempotencyPG) Takeover(ctx context.Context, record *models.IdempotencyRecord, at time.Time) error {
+	if record == nil {
+		return models.ErrEmptyIdempotencyRecord
+	}
+
+	result, err := conn(ctx, i.db).Exec(ctx, `
+		UPDATE merchshop.idempotency_keys
+		SET locked_until = $3
+		WHERE user_id = $1 AND idempotency_key = $2
+			AND status_code IS NULL AND locked_until <= $4 AND expires_at > $4
+	`, record.UserId, record.Key, record.LockedUntil.UTC(), at.UTC())
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrIdempotencyInProgress
+	}
+
+	return nil
+}
+
+// Get возвращает действующий в момент at ключ пользователя. Если ключ не найден или истек,
+// возвращает nil и ошибку.
+func (i *IdempotencyPG) Get(ctx context.Context, userID int, key string, at time.Time) (*models.IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, idempotency_key, request_hash, COALESCE(status_code, 0), response, created_at, locked_until, expires_at
+		FROM merchshop.idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND expires_at > $3
+	`
+
+	var record models.IdempotencyRecord
+	err := conn(ctx, i.db).QueryRow(ctx, query, userID, key, at.UTC()).Scan(
+		&record.UserId,
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.Response,
+		&record.CreatedAt,
+		&record.LockedUntil,
+		&record.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrIdempotencyNotFound
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Complete сохраняет ответ на запрос с ключом
+func (i *IdempotencyPG) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	if record == nil {
+		return models.ErrEmptyIdempotencyRecord
+	}
+
+	query := `
+		UPDATE merchshop.idempotency_keys
+		SET status_code = $3, response = $4
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+
+	result, err := conn(ctx, i.db).Exec(
+		ctx,
+		query,
+		record.UserId,
+		record.Key,
+		record.StatusCode,
+		record.Response,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrIdempotencyNotFound
+	}
+
+	return nil
+}
+
+// Delete удаляет ключ пользователя
+func (i *IdempotencyPG) Delete(ctx context.Context, userID int, key string) error {
+	_, err := conn(ctx, i.db).Exec(ctx,
+		"DELETE FROM merchshop.idempotency_keys WHERE user_id = $1 AND idempotency_key = $2",
+		userID, key,
+	)
+	return err
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.ImageStorage = (*ImagePG)(nil)
+
+// ImagePG реализует интерфейс ImageStorage в PostgreSQL
+type ImagePG struct {
+	db *pgxpool.Pool
+}
+
+// NewImageStorage создает новый экземпляр хранилища изображений товаров.
+func NewImageStorage(db *pgxpool.Pool) *ImagePG {
+	return &ImagePG{db: db}
+}
+
+// Create сохраняет изображение неудаленного товара.
+// Если товара нет или он удален, возвращает ErrMerchNotFound
+func (i *ImagePG) Create(ctx context.Context, image *models.Image) error {
+	if image == nil {
+		return models.ErrEmptyImage
+	}
+
+	query := `
+		INSERT INTO merchshop.merch_images (merch_id, blob_key, thumb_key, content_type, size, width, height)
+		SELECT merch_id, $2, $3, $4, $5, $6, $7
+		FROM merchshop.merch
+		WHERE merch_id = $1 AND deleted_at IS NULL
+		RETURNING image_id
+	`
+
+	err := conn(ctx, i.db).QueryRow(ctx, query,
+		image.MerchId,
+		image.Key,
+		image.ThumbKey,
+		image.ContentType,
+		image.Size,
+		image.Width,
+		image.Height,
+	).Scan(&image.Id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ErrMerchNotFound
+		}
+		if isUniqueViolation(err) {
+			return models.ErrImageExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// imageQuery - изображения товаров
+const imageQuery = `
+	SELECT image_id, merch_id, blob_key, thumb_key, content_type, size, width, height
+	FROM merchshop.merch_images
+`
+
+// scanImage - читает строку imageQuery
+func scanImage(row pgx.Row) (*models.Image, error) {
+	var image models.Image
+	err := row.Scan(
+		&image.Id,
+		&image.MerchId,
+		&image.Key,
+		&image.ThumbKey,
+		&image.ContentType,
+		&image.Size,
+		&image.Width,
+		&image.Height,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrImageNotFound
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
+// Get возвращает изображение по ID
+func (i *ImagePG) Get(ctx context.Context, id int) (*models.Image, error) {
+	return scanImage(conn(ctx, i.db).QueryRow(ctx, imageQuery+" WHERE image_id = $1", id))
+}
+
+// List возвращает изображения товаров merchIDs
+func (i *ImagePG) List(ctx context.Context, merchIDs []int) ([]*models.Image, error) {
+	if len(merchIDs) == 0 {
+		return make([]*models.Image, 0), nil
+	}
+
+	rows, err := conn(ctx, i.db).Query(ctx,
+		imageQuery+" WHERE merch_id = ANY($1) ORDER BY merch_id, image_id",
+		merchIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Image, error) {
+		return scanImage(row)
+	})
+}
+
+// Delete удаляет строку изображения, на изображения никто не ссылается
+func (i *ImagePG) Delete(ctx context.Context, id int) error {
+	result, err := conn(ctx, i.db).Exec(ctx,
+		`DELETE FROM merchshop.merch_images WHERE image_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrImageNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.LedgerStorage = (*LedgerPG)(nil)
+
+// LedgerPG реализует интерфейс LedgerStorage в PostgreSQL
+type LedgerPG struct {
+	db *pgxpool.Pool
+}
+
+// NewLedgerStorage создает новый экземпляр журнала монет.
+func NewLedgerStorage(db *pgxpool.Pool) *LedgerPG {
+	return &LedgerPG{db: db}
+}
+
+// Post добавляет запись журнала с проводками и в той же транзакции
+// меняет кешированные балансы пользователей на суммы их проводок.
+// Счет пользователя создается при первой проводке по нему
+func (l *LedgerPG) Post(ctx context.Context, entry *models.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := conn(ctx, l.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO merchshop.journal_entries (reason, reference_id)
+		VALUES ($1, NULLIF($2, 0))
+		RETURNING entry_id, created_at`,
+		string(entry.Reason), entry.ReferenceId,
+	).Scan(&entry.Id, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range entry.Postings {
+		accountID, err := l.account(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO merchshop.postings (entry_id, account_id, amount)
+			VALUES ($1, $2, $3)`,
+			entry.Id, accountID, p.Amount,
+		)
+		if err != nil {
+			return err
+		}
+
+		if p.UserId == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE merchshop.users SET coins = coins + $1 WHERE user_id = $2",
+			p.Amount, p.UserId,
+		)
+		if err != nil {
+			if isCheckViolation(err) {
+				return models.ErrNegativeUserCoins
+			}
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// account возвращает id счета, по которому делается проводка p.
+// Системные счета заводит миграция, но они, как и счета пользователей,
+// создаются при первой проводке, если их нет (коды проверяет JournalEntry.Validate)
+func (l *LedgerPG) account(ctx context.Context, tx pgx.Tx, p models.Posting) (int, error) {
+	var accountID int
+
+	// DO UPDATE вместо DO NOTHING, чтобы RETURNING вернул уже существующий счет
+	if p.UserId == 0 {
+		err := tx.QueryRow(ctx,
+			`INSERT INTO merchshop.accounts (code)
+			VALUES ($1)
+			ON CONFLICT (code) DO UPDATE SET code = EXCLUDED.code
+			RETURNING account_id`,
+			string(p.System),
+		).Scan(&accountID)
+		return accountID, err
+	}
+
+	err := tx.QueryRow(ctx,
+		`INSERT INTO merchshop.accounts (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING account_id`,
+		p.UserId,
+	).Scan(&accountID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return 0, models.ErrUserNotFound
+		}
+		return 0, err
+	}
+
+	return accountID, nil
+}
+
+// Balance возвращает сумму проводок по счету пользователя.
+// У пользователя без счета баланс по журналу нулевой
+func (l *LedgerPG) Balance(ctx context.Context, userID int) (int, error) {
+	if userID <= 0 {
+		return 0, models.ErrInvalidUserID
+	}
+
+	query := `
+		SELECT COALESCE(SUM(p.amount), 0)
+		FROM merchshop.postings AS p
+		JOIN merchshop.accounts AS a ON a.account_id = p.account_id
+		WHERE a.user_id = $1
+	`
+
+	var balance int
+	if err := conn(ctx, l.db).QueryRow(ctx, query, userID).Scan(&balance); err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// Reconcile сверяет журнал с самим собой и с кешем балансов в merchshop.users
+func (l *LedgerPG) Reconcile(ctx context.Context) (*models.LedgerReport, error) {
+	db := conn(ctx, l.db)
+
+	report := &models.LedgerReport{
+		UnbalancedEntries: make([]int, 0),
+		BalanceMismatches: make([]models.BalanceMismatch, 0),
+	}
+
+	err := db.QueryRow(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM merchshop.postings",
+	).Scan(&report.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT entry_id
+		FROM merchshop.postings
+		GROUP BY entry_id
+		HAVING SUM(amount) <> 0
+		ORDER BY entry_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	report.UnbalancedEntries, err = pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT u.user_id, u.login, u.coins, COALESCE(SUM(p.amount), 0)
+		FROM merchshop.users AS u
+		LEFT JOIN merchshop.accounts AS a ON a.user_id = u.user_id
+		LEFT JOIN merchshop.postings AS p ON p.account_id = a.account_id
+		GROUP BY u.user_id
+		HAVING u.coins <> COALESCE(SUM(p.amount), 0)
+		ORDER BY u.user_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.BalanceMismatch
+		if err := rows.Scan(&m.UserId, &m.Login, &m.Cached, &m.Ledger); err != nil {
+			return nil, err
+		}
+		report.BalanceMismatches = append(report.BalanceMismatches, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
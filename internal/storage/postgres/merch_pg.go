@@ -3,6 +3,9 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"merch_service/internal/models"
 	"merch_service/internal/storage/entities"
@@ -45,9 +48,49 @@ func (m *MerchPG) validateMerch(merch *models.Item) error {
 	if merch.Stock < 0 {
 		return models.ErrNegativeStock
 	}
+	if utf8.RuneCountInString(merch.Category) > models.MaxCategoryLength {
+		return models.ErrCategoryTooLong
+	}
+	if !merch.Limits.Valid() {
+		return models.ErrInvalidLimits
+	}
 	return nil
 }
 
+// tags - теги для записи в TEXT[], nil записывается как пустой массив
+func tags(merch *models.Item) []string {
+	if merch.Tags == nil {
+		return []string{}
+	}
+	return merch.Tags
+}
+
+// merchColumns - поля товара в порядке scanMerch
+const merchColumns = "merch_id, name, price, stock, category, tags, limit_per_user, limit_per_month, limit_per_order"
+
+// scanMerch - читает строку с полями merchColumns
+func scanMerch(row pgx.Row) (*models.Item, error) {
+	var item models.Item
+	err := row.Scan(
+		&item.Id,
+		&item.Name,
+		&item.Price,
+		&item.Stock,
+		&item.Category,
+		&item.Tags,
+		&item.Limits.PerUser,
+		&item.Limits.PerMonth,
+		&item.Limits.PerOrder,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrMerchNotFound
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
 // Create создает новый товар в базе данных.
 // Возвращает ошибку при невалидных данных или проблемах с БД.
 func (m *MerchPG) Create(ctx context.Context, merch *models.Item) error {
@@ -56,20 +99,30 @@ func (m *MerchPG) Create(ctx context.Context, merch *models.Item) error {
 	}
 
 	query := `
-		INSERT INTO merchshop.merch (name, price, stock)
-		VALUES ($1, $2, $3)
+		INSERT INTO merchshop.merch
+			(name, price, stock, category, tags, search_text, limit_per_user, limit_per_month, limit_per_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING merch_id
 	`
 
-	err := m.db.QueryRow(
+	err := conn(ctx, m.db).QueryRow(
 		ctx,
 		query,
 		merch.Name,
 		merch.Price,
 		merch.Stock,
+		merch.Category,
+		tags(merch),
+		models.MerchSearchText(merch),
+		merch.Limits.PerUser,
+		merch.Limits.PerMonth,
+		merch.Limits.PerOrder,
 	).Scan(&merch.Id)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrMerchExists
+		}
 		return err
 	}
 
@@ -84,59 +137,60 @@ func (m *MerchPG) Get(ctx context.Context, id int) (*models.Item, error) {
 	}
 
 	query := `
-		SELECT merch_id, name, price, stock
+		SELECT ` + merchColumns + `
 		FROM merchshop.merch
-		WHERE merch_id = $1
+		WHERE merch_id = $1 AND deleted_at IS NULL
 	`
 
-	var item models.Item
-	err := m.db.QueryRow(ctx, query, id).Scan(
-		&item.Id,
-		&item.Name,
-		&item.Price,
-		&item.Stock,
-	)
+	return scanMerch(conn(ctx, m.db).QueryRow(ctx, query, id))
+}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, models.ErrMerchNotFound
-		}
+// GetForUpdate возвращает товар по ID и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (m *MerchPG) GetForUpdate(ctx context.Context, id int) (*models.Item, error) {
+	if err := m.validateID(id); err != nil {
 		return nil, err
 	}
 
-	return &item, nil
+	query := `
+		SELECT ` + merchColumns + `
+		FROM merchshop.merch
+		WHERE merch_id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	return scanMerch(conn(ctx, m.db).QueryRow(ctx, query, id))
 }
 
 // Get возвращает мерч по name. Если мерч не найден,
 // возвращает nil и ошибку.
 func (m *MerchPG) GetByName(ctx context.Context, merchName string) (*models.Item, error) {
 	query := `
-		SELECT merch_id, name, price, stock
+		SELECT ` + merchColumns + `
 		FROM merchshop.merch
-		WHERE name = $1
+		WHERE name = $1 AND deleted_at IS NULL
 	`
 
-	var item models.Item
-	err := m.db.QueryRow(ctx, query, merchName).Scan(
-		&item.Id,
-		&item.Name,
-		&item.Price,
-		&item.Stock,
-	)
+	return scanMerch(conn(ctx, m.db).QueryRow(ctx, query, merchName))
+}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, models.ErrMerchNotFound
-		}
-		return nil, err
-	}
+// GetByNameForUpdate возвращает мерч по name и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (m *MerchPG) GetByNameForUpdate(ctx context.Context, merchName string) (*models.Item, error) {
+	query := `
+		SELECT ` + merchColumns + `
+		FROM merchshop.merch
+		WHERE name = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
 
-	return &item, nil
+	return scanMerch(conn(ctx, m.db).QueryRow(ctx, query, merchName))
 }
 
 // Update обновляет данные товара. Возвращает ошибку если:
 // - товар не найден
 // - данные невалидны
+// - имя занято другим товаром
 // - произошла ошибка БД
 func (m *MerchPG) Update(ctx context.Context, merch *models.Item) error {
 	if err := m.validateID(merch.Id); err != nil {
@@ -149,20 +203,30 @@ func (m *MerchPG) Update(ctx context.Context, merch *models.Item) error {
 
 	query := `
 		UPDATE merchshop.merch
-		SET name = $1, price = $2, stock = $3
-		WHERE merch_id = $4
+		SET name = $1, price = $2, stock = $3, category = $4, tags = $5, search_text = $6,
+			limit_per_user = $7, limit_per_month = $8, limit_per_order = $9
+		WHERE merch_id = $10 AND deleted_at IS NULL
 	`
 
-	result, err := m.db.Exec(
+	result, err := conn(ctx, m.db).Exec(
 		ctx,
 		query,
 		merch.Name,
 		merch.Price,
 		merch.Stock,
+		merch.Category,
+		tags(merch),
+		models.MerchSearchText(merch),
+		merch.Limits.PerUser,
+		merch.Limits.PerMonth,
+		merch.Limits.PerOrder,
 		merch.Id,
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrMerchExists
+		}
 		return err
 	}
 
@@ -173,8 +237,9 @@ func (m *MerchPG) Update(ctx context.Context, merch *models.Item) error {
 	return nil
 }
 
-// Delete удаляет товар по ID. Возвращает ошибку если:
-// - товар не найден
+// Delete помечает товар удаленным (deleted_at), строка остается в БД,
+// так как на нее ссылаются покупки. Возвращает ошибку если:
+// - товар не найден или уже удален
 // - ID невалиден
 // - произошла ошибка БД
 func (m *MerchPG) Delete(ctx context.Context, id int) error {
@@ -183,11 +248,12 @@ func (m *MerchPG) Delete(ctx context.Context, id int) error {
 	}
 
 	query := `
-		DELETE FROM merchshop.merch
-		WHERE merch_id = $1
+		UPDATE merchshop.merch
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE merch_id = $1 AND deleted_at IS NULL
 	`
 
-	result, err := m.db.Exec(ctx, query, id)
+	result, err := conn(ctx, m.db).Exec(ctx, query, id)
 
 	if err != nil {
 		return err
@@ -200,16 +266,171 @@ func (m *MerchPG) Delete(ctx context.Context, id int) error {
 	return nil
 }
 
-// GetList возвращает список всех товаров.
+// GetList возвращает список всех неудаленных товаров.
 // Возвращает ошибку при проблемах с БД.
 func (m *MerchPG) GetList(ctx context.Context) ([]*models.Item, error) {
 	query := `
-		SELECT merch_id, name, price, stock
+		SELECT ` + merchColumns + `
 		FROM merchshop.merch
+		WHERE deleted_at IS NULL
 		ORDER BY merch_id
 	`
 
-	rows, err := m.db.Query(ctx, query)
+	rows, err := conn(ctx, m.db).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.Item
+	for rows.Next() {
+		item, err := scanMerch(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// RefreshSearchText пересчитывает search_text в Go, а не в SQL:
+// lower() в БД зависит от локали и может не приводить кириллицу к нижнему регистру
+func (m *MerchPG) RefreshSearchText(ctx context.Context) (int, error) {
+	rows, err := conn(ctx, m.db).Query(ctx, `
+		SELECT merch_id, name, tags, search_text
+		FROM merchshop.merch
+		ORDER BY merch_id
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	stale := make(map[int]string)
+	for rows.Next() {
+		var (
+			item models.Item
+			text string
+		)
+		if err := rows.Scan(&item.Id, &item.Name, &item.Tags, &text); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if fresh := models.MerchSearchText(&item); fresh != text {
+			stale[item.Id] = fresh
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, text := range stale {
+		if _, err := conn(ctx, m.db).Exec(ctx,
+			"UPDATE merchshop.merch SET search_text = $2 WHERE merch_id = $1", id, text,
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(stale), nil
+}
+
+// merchSortKeys - выражение ключа сортировки каталога для каждого порядка
+var merchSortKeys = map[models.MerchSort]string{
+	models.MerchSortPrice:     "m.price",
+	models.MerchSortPriceDesc: "m.price",
+	models.MerchSortName:      "m.name",
+	models.MerchSortNameDesc:  "m.name",
+	models.MerchSortPopular:   "COALESCE(s.sold, 0)",
+}
+
+// List возвращает страницу неудаленных товаров, подходящих под фильтр,
+// в порядке filter.Sort (при равных ключах - по ID).
+// Число продаж считается по строкам неотмененных заказов.
+// Фильтр по датам к каталогу не применяется.
+func (m *MerchPG) List(ctx context.Context, filter *models.MerchFilter) (*models.Page[*models.Item], error) {
+	if filter == nil {
+		filter = &models.MerchFilter{}
+	}
+	p := newPageParams(&filter.PageQuery)
+
+	search := ""
+	if filter.Search != "" {
+		search = "%" + escapeLike(filter.Search) + "%"
+	}
+
+	args := []any{search, filter.Category, filter.Tag, filter.MinPrice, filter.MaxPrice, filter.InStock}
+	query := `
+		SELECT m.merch_id, m.name, m.price, m.stock, m.category, m.tags,
+			m.limit_per_user, m.limit_per_month, m.limit_per_order, COALESCE(s.sold, 0)
+		FROM merchshop.merch AS m
+		LEFT JOIN (
+			SELECT p.merch_id, SUM(p.count) AS sold
+			FROM merchshop.purchases AS p
+			JOIN merchshop.orders AS o ON o.order_id = p.order_id
+			WHERE o.status <> 'cancelled'
+			GROUP BY p.merch_id
+		) AS s ON s.merch_id = m.merch_id
+		WHERE m.deleted_at IS NULL
+			AND ($1 = '' OR m.search_text LIKE $1)
+			AND ($2 = '' OR m.category = $2)
+			AND ($3 = '' OR $3 = ANY(m.tags))
+			AND ($4::int IS NULL OR m.price >= $4)
+			AND ($5::int IS NULL OR m.price <= $5)
+			AND (NOT $6 OR CASE
+				WHEN EXISTS (
+					SELECT 1 FROM merchshop.merch_variants AS v
+					WHERE v.merch_id = m.merch_id AND v.deleted_at IS NULL
+				) THEN EXISTS (
+					SELECT 1 FROM merchshop.merch_variants AS v
+					WHERE v.merch_id = m.merch_id AND v.deleted_at IS NULL AND v.stock > 0
+				)
+				ELSE m.stock > 0
+			END)
+	`
+
+	key, sorted := merchSortKeys[filter.Sort]
+	if filter.After != nil {
+		if !sorted {
+			args = append(args, p.afterID)
+			query += fmt.Sprintf(" AND m.merch_id > $%d", len(args))
+		} else {
+			var after any = filter.After.Key
+			if filter.Sort != models.MerchSortName && filter.Sort != models.MerchSortNameDesc {
+				after = filter.AfterKey()
+			}
+
+			cmp := ">"
+			if filter.Sort.Desc() {
+				cmp = "<"
+			}
+
+			args = append(args, after, p.afterID)
+			query += fmt.Sprintf(" AND (%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND m.merch_id > $%[4]d))",
+				key, cmp, len(args)-1, len(args))
+		}
+	}
+
+	if sorted {
+		direction := ""
+		if filter.Sort.Desc() {
+			direction = " DESC"
+		}
+		query += " ORDER BY " + key + direction + ", m.merch_id"
+	} else {
+		query += " ORDER BY m.merch_id"
+	}
+
+	args = append(args, p.limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	rows, err := conn(ctx, m.db).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -223,6 +444,12 @@ func (m *MerchPG) GetList(ctx context.Context) ([]*models.Item, error) {
 			&item.Name,
 			&item.Price,
 			&item.Stock,
+			&item.Category,
+			&item.Tags,
+			&item.Limits.PerUser,
+			&item.Limits.PerMonth,
+			&item.Limits.PerOrder,
+			&item.Sold,
 		); err != nil {
 			return nil, err
 		}
@@ -233,5 +460,10 @@ func (m *MerchPG) GetList(ctx context.Context) ([]*models.Item, error) {
 		return nil, err
 	}
 
-	return items, nil
+	return models.NewPage(items, p.pageLimit(), filter.Sort.Cursor), nil
+}
+
+// escapeLike - экранирует спецсимволы LIKE, чтобы строка искалась как есть
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.NotificationStorage = (*NotificationPG)(nil)
+
+// NotificationPG реализует интерфейс NotificationStorage в PostgreSQL
+type NotificationPG struct {
+	db *pgxpool.Pool
+}
+
+// NewNotificationStorage создает новый экземпляр хранилища уведомлений.
+func NewNotificationStorage(db *pgxpool.Pool) *NotificationPG {
+	return &NotificationPG{db: db}
+}
+
+// scanNotification - читает строку с полями уведомления, логином и именем мерча
+func scanNotification(row pgx.Row) (*models.Notification, error) {
+	var n models.Notification
+	err := row.Scan(
+		&n.Id,
+		&n.UserId,
+		&n.Login,
+		&n.Kind,
+		&n.MerchId,
+		&n.MerchName,
+		&n.CreatedAt,
+		&n.ReadAt,
+	)
+	return &n, err
+}
+
+// CreateBackInStock создает уведомления подписчикам мерча одним запросом
+func (n *NotificationPG) CreateBackInStock(ctx context.Context, merchID int) ([]*models.Notification, error) {
+	rows, err := conn(ctx, n.db).Query(ctx, `
+		WITH created AS (
+			INSERT INTO merchshop.notifications (user_id, kind, merch_id)
+			SELECT wi.user_id, $2, wi.merch_id
+			FROM merchshop.wishlist_items AS wi
+			WHERE wi.merch_id = $1 AND wi.notify
+			RETURNING notification_id, user_id, kind, merch_id, created_at, read_at
+		)
+		SELECT c.notification_id, c.user_id, u.login, c.kind, c.merch_id, m.name, c.created_at, c.read_at
+		FROM created AS c
+		JOIN merchshop.users AS u ON u.user_id = c.user_id
+		JOIN merchshop.merch AS m ON m.merch_id = c.merch_id
+		ORDER BY c.notification_id
+	`, merchID, string(models.NotificationBackInStock))
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Notification, error) {
+		return scanNotification(row)
+	})
+}
+
+// List возвращает страницу уведомлений пользователя
+func (n *NotificationPG) List(ctx context.Context, userID int, q *models.PageQuery) (*models.Page[*models.Notification], error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	p := newPageParams(q)
+
+	rows, err := conn(ctx, n.db).Query(ctx, `
+		SELECT nt.notification_id, nt.user_id, u.login, nt.kind, nt.merch_id, m.name, nt.created_at, nt.read_at
+		FROM merchshop.notifications AS nt
+		JOIN merchshop.users AS u ON u.user_id = nt.user_id
+		JOIN merchshop.merch AS m ON m.merch_id = nt.merch_id
+		WHERE nt.user_id = $1
+			AND ($2::timestamp IS NULL OR nt.created_at >= $2)
+			AND ($3::timestamp IS NULL OR nt.created_at < $3)
+			AND ($4::timestamp IS NULL OR (nt.created_at, nt.notification_id) < ($4, $5))
+		ORDER BY nt.created_at DESC, nt.notification_id DESC
+		LIMIT $6
+	`, userID, p.from, p.to, p.afterDate, p.afterID, p.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Notification, error) {
+		return scanNotification(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(notes, p.pageLimit(), (*models.Notification).Cursor), nil
+}
+
+// Undelivered возвращает недоставленные уведомления от старых к новым
+func (n *NotificationPG) Undelivered(ctx context.Context, limit int) ([]*models.Notification, error) {
+	rows, err := conn(ctx, n.db).Query(ctx, `
+		SELECT nt.notification_id, nt.user_id, u.login, nt.kind, nt.merch_id, m.name, nt.created_at, nt.read_at
+		FROM merchshop.notifications AS nt
+		JOIN merchshop.users AS u ON u.user_id = nt.user_id
+		JOIN merchshop.merch AS m ON m.merch_id = nt.merch_id
+		WHERE nt.delivered_at IS NULL
+		ORDER BY nt.notification_id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Notification, error) {
+		return scanNotification(row)
+	})
+}
+
+// MarkDelivered отмечает уведомления доставленными
+func (n *NotificationPG) MarkDelivered(ctx context.Context, ids []int, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := conn(ctx, n.db).Exec(ctx, `
+		UPDATE merchshop.notifications
+		SET delivered_at = $2
+		WHERE notification_id = ANY($1) AND delivered_at IS NULL
+	`, ids, at.UTC())
+	return err
+}
+
+// MarkRead отмечает уведомление пользователя прочитанным
+func (n *NotificationPG) MarkRead(ctx context.Context, userID, id int) error {
+	result, err := conn(ctx, n.db).Exec(ctx, `
+		UPDATE merchshop.notifications
+		SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE notification_id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrNotificationNotFound
+	}
+
+	return nil
+}
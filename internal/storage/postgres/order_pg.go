@@ -0,0 +1,272 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.OrderStorage = (*OrderPG)(nil)
+
+// OrderPG реализует интерфейс OrderStorage в PostgreSQL
+type OrderPG struct {
+	db *pgxpool.Pool
+}
+
+// NewOrderStorage создает новый экземпляр хранилища заказов.
+func NewOrderStorage(db *pgxpool.Pool) *OrderPG {
+	return &OrderPG{db: db}
+}
+
+// Create сохраняет заказ и его строки в merchshop.purchases
+func (o *OrderPG) Create(ctx context.Context, order *models.Order) error {
+	if order == nil || len(order.Lines) == 0 {
+		return models.ErrEmptyOrder
+	}
+
+	if order.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+
+	tx, err := conn(ctx, o.db).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO merchshop.orders (user_id, total, discount, promo_id)
+		VALUES ($1, $2, $3, NULLIF($4, 0))
+		RETURNING order_id, status, created_at, updated_at`,
+		order.UserId, order.Total, order.Discount, order.PromoId,
+	).Scan(&order.Id, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range order.Lines {
+		err = tx.QueryRow(ctx,
+			`INSERT INTO merchshop.purchases (user_id, merch_id, variant_id, count, order_id, price, list_price, sale_id, purchase_date)
+			VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, NULLIF($8, 0), $9)
+			RETURNING purchase_id`,
+			order.UserId, line.MerchId, line.VariantId, line.Count, order.Id, line.Price, line.ListPrice, line.SaleId, order.CreatedAt,
+		).Scan(&line.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// orderQuery - заказ с логином покупателя и промокодом, без строк (см. lines)
+const orderQuery = `
+	SELECT o.order_id, o.user_id, u.login, o.status, o.total, o.discount,
+		COALESCE(o.promo_id, 0), COALESCE(pc.code, ''), o.created_at, o.updated_at
+	FROM merchshop.orders AS o
+	JOIN merchshop.users AS u ON u.user_id = o.user_id
+	LEFT JOIN merchshop.promo_codes AS pc ON pc.promo_id = o.promo_id
+`
+
+// scanOrder - читает строку orderQuery
+func scanOrder(row pgx.Row) (*models.Order, error) {
+	var order models.Order
+	err := row.Scan(
+		&order.Id,
+		&order.UserId,
+		&order.Login,
+		&order.Status,
+		&order.Total,
+		&order.Discount,
+		&order.PromoId,
+		&order.PromoCode,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Get возвращает заказ по id со строками
+func (o *OrderPG) Get(ctx context.Context, id int) (*models.Order, error) {
+	return o.get(ctx, id, "")
+}
+
+// GetForUpdate возвращает заказ по id со строками и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (o *OrderPG) GetForUpdate(ctx context.Context, id int) (*models.Order, error) {
+	return o.get(ctx, id, " FOR UPDATE OF o")
+}
+
+func (o *OrderPG) get(ctx context.Context, id int, lock string) (*models.Order, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidOrderID
+	}
+
+	order, err := scanOrder(conn(ctx, o.db).QueryRow(ctx, orderQuery+"WHERE o.order_id = $1"+lock, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	if err := o.lines(ctx, []*models.Order{order}); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// List возвращает страницу заказов, начиная с самых новых
+func (o *OrderPG) List(ctx context.Context, filter *models.OrderFilter) (*models.Page[*models.Order], error) {
+	if filter == nil {
+		filter = &models.OrderFilter{}
+	}
+
+	p := newPageParams(&filter.PageQuery)
+
+	query := orderQuery + `
+		WHERE ($1 = 0 OR o.user_id = $1)
+			AND ($2 = '' OR o.status = $2)
+			AND ($3::timestamp IS NULL OR o.created_at >= $3)
+			AND ($4::timestamp IS NULL OR o.created_at < $4)
+			AND ($5::timestamp IS NULL OR (o.created_at, o.order_id) < ($5, $6))
+		ORDER BY o.created_at DESC, o.order_id DESC
+		LIMIT $7
+	`
+
+	rows, err := conn(ctx, o.db).Query(ctx, query,
+		filter.UserId,
+		string(filter.Status),
+		p.from,
+		p.to,
+		p.afterDate,
+		p.afterID,
+		p.limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Order, error) {
+		return scanOrder(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.lines(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(orders, p.pageLimit(), (*models.Order).Cursor), nil
+}
+
+// lines - заполняет строки заказов одним запросом
+func (o *OrderPG) lines(ctx context.Context, orders []*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Order, len(orders))
+	ids := make([]int, 0, len(orders))
+	for _, order := range orders {
+		order.Lines = make([]*models.OrderLine, 0)
+		byID[order.Id] = order
+		ids = append(ids, order.Id)
+	}
+
+	rows, err := conn(ctx, o.db).Query(ctx, `
+		SELECT p.order_id, p.purchase_id, p.merch_id, COALESCE(p.variant_id, 0), m.name, COALESCE(v.sku, ''),
+			p.price, COALESCE(p.list_price, p.price), COALESCE(p.sale_id, 0), p.count
+		FROM merchshop.purchases AS p
+		JOIN merchshop.merch AS m ON m.merch_id = p.merch_id
+		LEFT JOIN merchshop.merch_variants AS v ON v.variant_id = p.variant_id
+		WHERE p.order_id = ANY($1)
+		ORDER BY p.order_id, p.purchase_id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID int
+			line    models.OrderLine
+		)
+		if err := rows.Scan(&orderID, &line.Id, &line.MerchId, &line.VariantId, &line.Name, &line.Variant,
+			&line.Price, &line.ListPrice, &line.SaleId, &line.Count); err != nil {
+			return err
+		}
+		byID[orderID].Lines = append(byID[orderID].Lines, &line)
+	}
+
+	return rows.Err()
+}
+
+// UpdateStatus меняет статус заказа
+func (o *OrderPG) UpdateStatus(ctx context.Context, id int, status models.OrderStatus) error {
+	if id <= 0 {
+		return models.ErrInvalidOrderID
+	}
+
+	tag, err := conn(ctx, o.db).Exec(ctx,
+		`UPDATE merchshop.orders
+		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE order_id = $1`,
+		id, string(status),
+	)
+	if err != nil {
+		if isCheckViolation(err) {
+			return models.ErrInvalidOrderStatus
+		}
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return models.ErrOrderNotFound
+	}
+
+	return nil
+}
+
+// PromoUses считает неотмененные заказы с промокодом
+func (o *OrderPG) PromoUses(ctx context.Context, promoID, userID int) (int, int, error) {
+	var total, byUser int
+	err := conn(ctx, o.db).QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+		FROM merchshop.orders
+		WHERE promo_id = $1 AND status <> 'cancelled'
+	`, promoID, userID).Scan(&total, &byUser)
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, byUser, nil
+}
+
+// MerchBought считает штуки мерча в неотмененных заказах пользователя.
+// Начало месяца берется по часам БД, как и время оформления заказа
+func (o *OrderPG) MerchBought(ctx context.Context, userID, merchID int) (int, int, error) {
+	var total, month int
+	err := conn(ctx, o.db).QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(p.count), 0),
+			COALESCE(SUM(p.count) FILTER (WHERE o.created_at >= date_trunc('month', CURRENT_TIMESTAMP)), 0)
+		FROM merchshop.purchases AS p
+		JOIN merchshop.orders AS o ON o.order_id = p.order_id
+		WHERE o.user_id = $1 AND p.merch_id = $2 AND o.status <> 'cancelled'
+	`, userID, merchID).Scan(&total, &month)
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, month, nil
+}
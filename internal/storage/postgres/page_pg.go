@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"time"
+
+	"merch_service/internal/models"
+)
+
+// pageParams - параметры запроса страницы для SQL.
+// Нулевые даты и отсутствующий курсор передаются как NULL,
+// чтобы одно и то же условие работало и с фильтром, и без него
+type pageParams struct {
+	from      *time.Time
+	to        *time.Time
+	afterDate *time.Time
+	afterID   int
+	limit     int // На одну запись больше запрошенного, см. models.NewPage
+}
+
+// newPageParams - переводит PageQuery в параметры запроса.
+// nil запрос равносилен первой странице размера по умолчанию
+func newPageParams(q *models.PageQuery) pageParams {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = models.DefaultPageLimit
+	}
+
+	p := pageParams{limit: limit + 1}
+
+	if !q.From.IsZero() {
+		p.from = &q.From
+	}
+	if !q.To.IsZero() {
+		p.to = &q.To
+	}
+	if q.After != nil {
+		p.afterDate = &q.After.Date
+		p.afterID = q.After.Id
+	}
+
+	return p
+}
+
+// pageLimit - размер страницы без лишней записи
+func (p pageParams) pageLimit() int {
+	return p.limit - 1
+}
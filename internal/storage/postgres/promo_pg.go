@@ -0,0 +1,259 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.PromoStorage = (*PromoPG)(nil)
+
+// PromoPG реализует интерфейс PromoStorage в PostgreSQL
+type PromoPG struct {
+	db *pgxpool.Pool
+}
+
+// NewPromoStorage создает новый экземпляр хранилища промокодов и распродаж.
+func NewPromoStorage(db *pgxpool.Pool) *PromoPG {
+	return &PromoPG{db: db}
+}
+
+// validateWindow проверяет, что окончание акции позже начала
+func validateWindow(startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return models.ErrInvalidPromoWindow
+	}
+	return nil
+}
+
+// utcPtr - переводит необязательную границу акции в UTC: колонки TIMESTAMP
+// хранят время без часового пояса
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// validatePromo проверяет валидность данных промокода.
+func (p *PromoPG) validatePromo(promo *models.PromoCode) error {
+	if promo == nil {
+		return models.ErrEmptyPromo
+	}
+	if strings.TrimSpace(promo.Code) == "" {
+		return models.ErrEmptyPromoCode
+	}
+	if !promo.Discount.Valid() {
+		return models.ErrInvalidDiscount
+	}
+	if promo.MaxUses < 0 || promo.MaxUsesPerUser < 0 {
+		return models.ErrInvalidPromoLimit
+	}
+	return validateWindow(promo.StartsAt, promo.EndsAt)
+}
+
+// validateSale проверяет валидность данных распродажи.
+func (p *PromoPG) validateSale(sale *models.Sale) error {
+	if sale == nil {
+		return models.ErrEmptySale
+	}
+	if strings.TrimSpace(sale.Name) == "" {
+		return models.ErrEmptySaleName
+	}
+	if !sale.Discount.Valid() {
+		return models.ErrInvalidDiscount
+	}
+	return validateWindow(&sale.StartsAt, &sale.EndsAt)
+}
+
+// CreatePromo сохраняет промокод
+func (p *PromoPG) CreatePromo(ctx context.Context, promo *models.PromoCode) error {
+	if err := p.validatePromo(promo); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO merchshop.promo_codes
+			(code, kind, value, merch_id, starts_at, ends_at, max_uses, max_uses_per_user)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8)
+		RETURNING promo_id
+	`
+
+	err := conn(ctx, p.db).QueryRow(ctx, query,
+		promo.Code,
+		string(promo.Kind),
+		promo.Value,
+		promo.MerchId,
+		utcPtr(promo.StartsAt),
+		utcPtr(promo.EndsAt),
+		promo.MaxUses,
+		promo.MaxUsesPerUser,
+	).Scan(&promo.Id)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrPromoExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// promoQuery - неудаленные промокоды
+const promoQuery = `
+	SELECT promo_id, code, kind, value, COALESCE(merch_id, 0), starts_at, ends_at, max_uses, max_uses_per_user
+	FROM merchshop.promo_codes
+	WHERE deleted_at IS NULL
+`
+
+// scanPromo - читает строку promoQuery
+func scanPromo(row pgx.Row) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	err := row.Scan(
+		&promo.Id,
+		&promo.Code,
+		&promo.Kind,
+		&promo.Value,
+		&promo.MerchId,
+		&promo.StartsAt,
+		&promo.EndsAt,
+		&promo.MaxUses,
+		&promo.MaxUsesPerUser,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrPromoNotFound
+		}
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// GetPromoByCode возвращает промокод по коду
+func (p *PromoPG) GetPromoByCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	return scanPromo(conn(ctx, p.db).QueryRow(ctx, promoQuery+" AND code = $1", code))
+}
+
+// GetPromoByCodeForUpdate возвращает промокод по коду и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (p *PromoPG) GetPromoByCodeForUpdate(ctx context.Context, code string) (*models.PromoCode, error) {
+	return scanPromo(conn(ctx, p.db).QueryRow(ctx, promoQuery+" AND code = $1 FOR UPDATE", code))
+}
+
+// ListPromos возвращает неудаленные промокоды
+func (p *PromoPG) ListPromos(ctx context.Context) ([]*models.PromoCode, error) {
+	rows, err := conn(ctx, p.db).Query(ctx, promoQuery+" ORDER BY promo_id")
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.PromoCode, error) {
+		return scanPromo(row)
+	})
+}
+
+// DeletePromo помечает промокод удаленным
+func (p *PromoPG) DeletePromo(ctx context.Context, id int) error {
+	result, err := conn(ctx, p.db).Exec(ctx, `
+		UPDATE merchshop.promo_codes
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE promo_id = $1 AND deleted_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrPromoNotFound
+	}
+
+	return nil
+}
+
+// CreateSale сохраняет распродажу
+func (p *PromoPG) CreateSale(ctx context.Context, sale *models.Sale) error {
+	if err := p.validateSale(sale); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO merchshop.sales (name, kind, value, merch_id, starts_at, ends_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6)
+		RETURNING sale_id
+	`
+
+	return conn(ctx, p.db).QueryRow(ctx, query,
+		sale.Name,
+		string(sale.Kind),
+		sale.Value,
+		sale.MerchId,
+		sale.StartsAt.UTC(),
+		sale.EndsAt.UTC(),
+	).Scan(&sale.Id)
+}
+
+// saleQuery - неудаленные распродажи
+const saleQuery = `
+	SELECT sale_id, name, kind, value, COALESCE(merch_id, 0), starts_at, ends_at
+	FROM merchshop.sales
+	WHERE deleted_at IS NULL
+`
+
+// listSales - читает распродажи запросом saleQuery + where
+func (p *PromoPG) listSales(ctx context.Context, where string, args ...any) ([]*models.Sale, error) {
+	rows, err := conn(ctx, p.db).Query(ctx, saleQuery+where+" ORDER BY sale_id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Sale, error) {
+		var sale models.Sale
+		err := row.Scan(
+			&sale.Id,
+			&sale.Name,
+			&sale.Kind,
+			&sale.Value,
+			&sale.MerchId,
+			&sale.StartsAt,
+			&sale.EndsAt,
+		)
+		return &sale, err
+	})
+}
+
+// ListSales возвращает неудаленные распродажи
+func (p *PromoPG) ListSales(ctx context.Context) ([]*models.Sale, error) {
+	return p.listSales(ctx, "")
+}
+
+// ActiveSales возвращает распродажи, идущие в момент at
+func (p *PromoPG) ActiveSales(ctx context.Context, at time.Time) ([]*models.Sale, error) {
+	return p.listSales(ctx, " AND starts_at <= $1 AND ends_at > $1", at.UTC())
+}
+
+// DeleteSale помечает распродажу удаленной
+func (p *PromoPG) DeleteSale(ctx context.Context, id int) error {
+	result, err := conn(ctx, p.db).Exec(ctx, `
+		UPDATE merchshop.sales
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE sale_id = $1 AND deleted_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrSaleNotFound
+	}
+
+	return nil
+}
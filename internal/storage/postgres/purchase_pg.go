@@ -20,41 +20,106 @@ func NewPurchaseStorage(db *pgxpool.Pool) *PurchasePG {
 	return &PurchasePG{db: db}
 }
 
-// Create - добавляет покупкку мерча юзером в историю
-func (p *PurchasePG) Create(ctx context.Context, currUser *models.User, merch *models.Item, count int) error {
+// Get - получает слайс покупок пользователя со статусами их заказов
+func (p *PurchasePG) Get(ctx context.Context, user *models.User) ([]*models.PurchaseEntry, error) {
 	query := `
-		INSERT INTO merchshop.purchases (user_id, merch_id, count)
-		VALUES ($1, $2, $3);
+		SELECT
+			p.purchase_id,
+			p.order_id,
+			m.name,
+			COALESCE(v.sku, ''),
+			p.count,
+			p.price,
+			COALESCE(p.list_price, p.price),
+			COALESCE(pc.code, ''),
+			o.status,
+			p.purchase_date
+		FROM merchshop.purchases AS p
+		JOIN merchshop.merch AS m  ON p.merch_id = m.merch_id
+		JOIN merchshop.orders AS o ON p.order_id = o.order_id
+		LEFT JOIN merchshop.merch_variants AS v ON p.variant_id = v.variant_id
+		LEFT JOIN merchshop.promo_codes AS pc ON o.promo_id = pc.promo_id
+		WHERE p.user_id = $1
+		ORDER BY p.purchase_date, p.purchase_id;
 	`
 
-	_, err := p.db.Exec(
+	rows, err := conn(ctx, p.db).Query(
 		ctx,
 		query,
-		currUser.Id,
-		merch.Id,
-		count,
+		user.Id,
 	)
 
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var purchHist []*models.PurchaseEntry
+	for rows.Next() {
+		var entry models.PurchaseEntry
+		if err := rows.Scan(
+			&entry.Id,
+			&entry.OrderId,
+			&entry.ItemName,
+			&entry.Variant,
+			&entry.Count,
+			&entry.Price,
+			&entry.ListPrice,
+			&entry.PromoCode,
+			&entry.Status,
+			&entry.Date,
+		); err != nil {
+			return nil, err
+		}
+		purchHist = append(purchHist, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return purchHist, nil
 }
 
-// Get - получает слайс покупок пользователя
-func (p *PurchasePG) Get(ctx context.Context, user *models.User) ([]*models.PurchaseEntry, error) {
+// List - получает страницу покупок пользователя, начиная с самых новых
+func (p *PurchasePG) List(ctx context.Context, user *models.User, q *models.PageQuery) (*models.Page[*models.PurchaseEntry], error) {
+	pp := newPageParams(q)
+
 	query := `
 		SELECT
 			p.purchase_id,
+			p.order_id,
 			m.name,
+			COALESCE(v.sku, ''),
 			p.count,
+			p.price,
+			COALESCE(p.list_price, p.price),
+			COALESCE(pc.code, ''),
+			o.status,
 			p.purchase_date
 		FROM merchshop.purchases AS p
 		JOIN merchshop.merch AS m  ON p.merch_id = m.merch_id
-		WHERE p.user_id = $1; 
+		JOIN merchshop.orders AS o ON p.order_id = o.order_id
+		LEFT JOIN merchshop.merch_variants AS v ON p.variant_id = v.variant_id
+		LEFT JOIN merchshop.promo_codes AS pc ON o.promo_id = pc.promo_id
+		WHERE p.user_id = $1
+			AND ($2::timestamp IS NULL OR p.purchase_date >= $2)
+			AND ($3::timestamp IS NULL OR p.purchase_date < $3)
+			AND ($4::timestamp IS NULL OR (p.purchase_date, p.purchase_id) < ($4, $5))
+		ORDER BY p.purchase_date DESC, p.purchase_id DESC
+		LIMIT $6;
 	`
 
-	rows, err := p.db.Query(
+	rows, err := conn(ctx, p.db).Query(
 		ctx,
 		query,
 		user.Id,
+		pp.from,
+		pp.to,
+		pp.afterDate,
+		pp.afterID,
+		pp.limit,
 	)
 
 	if err != nil {
@@ -68,8 +133,14 @@ func (p *PurchasePG) Get(ctx context.Context, user *models.User) ([]*models.Purc
 		var entry models.PurchaseEntry
 		if err := rows.Scan(
 			&entry.Id,
+			&entry.OrderId,
 			&entry.ItemName,
+			&entry.Variant,
 			&entry.Count,
+			&entry.Price,
+			&entry.ListPrice,
+			&entry.PromoCode,
+			&entry.Status,
 			&entry.Date,
 		); err != nil {
 			return nil, err
@@ -81,5 +152,5 @@ func (p *PurchasePG) Get(ctx context.Context, user *models.User) ([]*models.Purc
 		return nil, err
 	}
 
-	return purchHist, nil
+	return models.NewPage(purchHist, pp.pageLimit(), (*models.PurchaseEntry).Cursor), nil
 }
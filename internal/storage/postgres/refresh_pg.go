@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.RefreshTokenStorage = (*RefreshTokenPG)(nil)
+
+// RefreshTokenPG реализует интерфейс RefreshTokenStorage в PostgreSQL
+type RefreshTokenPG struct {
+	db *pgxpool.Pool
+}
+
+// NewRefreshTokenStorage создает новый экземпляр хранилища refresh токенов.
+func NewRefreshTokenStorage(db *pgxpool.Pool) *RefreshTokenPG {
+	return &RefreshTokenPG{db: db}
+}
+
+// Create сохраняет новый refresh токен.
+// Возвращает ошибку при невалидных данных или проблемах с БД.
+func (r *RefreshTokenPG) Create(ctx context.Context, token *models.RefreshToken) error {
+	if token == nil {
+		return models.ErrEmptyRefreshToken
+	}
+
+	if token.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+
+	query := `
+		INSERT INTO merchshop.refresh_tokens (token_id, family_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := conn(ctx, r.db).Exec(
+		ctx,
+		query,
+		token.Id,
+		token.FamilyId,
+		token.UserId,
+		token.ExpiresAt,
+	)
+
+	return err
+}
+
+// GetForUpdate возвращает refresh токен по ID и блокирует его строку
+// до конца транзакции. Имеет смысл только внутри TxManager.WithinTx.
+func (r *RefreshTokenPG) GetForUpdate(ctx context.Context, id string) (*models.RefreshToken, error) {
+	query := `
+		SELECT t.token_id, t.family_id, t.user_id, u.login, t.used, t.revoked, t.expires_at
+		FROM merchshop.refresh_tokens AS t
+		JOIN merchshop.users AS u ON t.user_id = u.user_id
+		WHERE t.token_id = $1
+		FOR UPDATE OF t
+	`
+
+	var token models.RefreshToken
+	err := conn(ctx, r.db).QueryRow(ctx, query, id).Scan(
+		&token.Id,
+		&token.FamilyId,
+		&token.UserId,
+		&token.Login,
+		&token.Used,
+		&token.Revoked,
+		&token.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkUsed помечает refresh токен использованным.
+func (r *RefreshTokenPG) MarkUsed(ctx context.Context, id string) error {
+	query := `
+		UPDATE merchshop.refresh_tokens
+		SET used = TRUE
+		WHERE token_id = $1
+	`
+
+	result, err := conn(ctx, r.db).Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrRefreshTokenNotFound
+	}
+
+	return nil
+}
+
+// RevokeFamily отзывает все refresh токены семейства.
+func (r *RefreshTokenPG) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+		UPDATE merchshop.refresh_tokens
+		SET revoked = TRUE
+		WHERE family_id = $1
+	`
+
+	_, err := conn(ctx, r.db).Exec(ctx, query, familyID)
+
+	return err
+}
+
+// RevokeAllByUser отзывает все refresh токены пользователя.
+func (r *RefreshTokenPG) RevokeAllByUser(ctx context.Context, userID int) error {
+	query := `
+		UPDATE merchshop.refresh_tokens
+		SET revoked = TRUE
+		WHERE user_id = $1
+	`
+
+	_, err := conn(ctx, r.db).Exec(ctx, query, userID)
+
+	return err
+}
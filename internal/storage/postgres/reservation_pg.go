@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.ReservationStorage = (*ReservationPG)(nil)
+
+// ReservationPG реализует интерфейс ReservationStorage в PostgreSQL
+type ReservationPG struct {
+	db *pgxpool.Pool
+}
+
+// NewReservationStorage создает новый экземпляр хранилища броней.
+func NewReservationStorage(db *pgxpool.Pool) *ReservationPG {
+	return &ReservationPG{db: db}
+}
+
+// Create сохраняет активную бронь
+func (r *ReservationPG) Create(ctx context.Context, res *models.Reservation) error {
+	if res == nil {
+		return models.ErrEmptyReservation
+	}
+	if res.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+	if res.MerchId <= 0 {
+		return models.ErrInvalidMerchID
+	}
+	if res.Count <= 0 {
+		return models.ErrInvalidCount
+	}
+	if res.Price < 0 || res.ListPrice < 0 {
+		return models.ErrNegativePrice
+	}
+
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO merchshop.reservations
+			(user_id, merch_id, variant_id, kind, count, price, list_price, sale_id, expires_at)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, NULLIF($8, 0), $9)
+		RETURNING reservation_id, status, created_at
+	`,
+		res.UserId, res.MerchId, res.VariantId, string(res.Kind), res.Count,
+		res.Price, res.ListPrice, res.SaleId, res.ExpiresAt.UTC(),
+	).Scan(&res.Id, &res.Status, &res.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	res.Total = res.Price * res.Count
+	return nil
+}
+
+// reservationQuery - бронь с именем мерча и артикулом варианта
+const reservationQuery = `
+	SELECT r.reservation_id, r.user_id, r.kind, r.status, r.merch_id, m.name,
+		COALESCE(r.variant_id, 0), COALESCE(v.sku, ''), r.count, r.price, r.list_price,
+		COALESCE(r.sale_id, 0), COALESCE(r.order_id, 0), r.created_at, r.expires_at
+	FROM merchshop.reservations AS r
+	JOIN merchshop.merch AS m ON m.merch_id = r.merch_id
+	LEFT JOIN merchshop.merch_variants AS v ON v.variant_id = r.variant_id
+`
+
+// scanReservation - читает строку reservationQuery
+func scanReservation(row pgx.Row) (*models.Reservation, error) {
+	var res models.Reservation
+	err := row.Scan(
+		&res.Id,
+		&res.UserId,
+		&res.Kind,
+		&res.Status,
+		&res.MerchId,
+		&res.Name,
+		&res.VariantId,
+		&res.Variant,
+		&res.Count,
+		&res.Price,
+		&res.ListPrice,
+		&res.SaleId,
+		&res.OrderId,
+		&res.CreatedAt,
+		&res.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Total = res.Price * res.Count
+	return &res, nil
+}
+
+// Get возвращает бронь по id
+func (r *ReservationPG) Get(ctx context.Context, id int) (*models.Reservation, error) {
+	return r.get(ctx, id, "")
+}
+
+// GetForUpdate возвращает бронь по id и блокирует ее строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (r *ReservationPG) GetForUpdate(ctx context.Context, id int) (*models.Reservation, error) {
+	return r.get(ctx, id, " FOR UPDATE OF r")
+}
+
+func (r *ReservationPG) get(ctx context.Context, id int, lock string) (*models.Reservation, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidReservationID
+	}
+
+	res, err := scanReservation(conn(ctx, r.db).QueryRow(ctx, reservationQuery+"WHERE r.reservation_id = $1"+lock, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrReservationNotFound
+		}
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// List возвращает страницу броней пользователя, начиная с самых новых
+func (r *ReservationPG) List(ctx context.Context, userID int, q *models.PageQuery) (*models.Page[*models.Reservation], error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	p := newPageParams(q)
+
+	rows, err := conn(ctx, r.db).Query(ctx, reservationQuery+`
+		WHERE r.user_id = $1
+			AND ($2::timestamp IS NULL OR r.created_at >= $2)
+			AND ($3::timestamp IS NULL OR r.created_at < $3)
+			AND ($4::timestamp IS NULL OR (r.created_at, r.reservation_id) < ($4, $5))
+		ORDER BY r.created_at DESC, r.reservation_id DESC
+		LIMIT $6
+	`, userID, p.from, p.to, p.afterDate, p.afterID, p.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Reservation, error) {
+		return scanReservation(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(list, p.pageLimit(), (*models.Reservation).Cursor), nil
+}
+
+// Reserved считает штуки в активных неистекших бронях по видам брони
+func (r *ReservationPG) Reserved(ctx context.Context, merchID, variantID int, at time.Time) (models.Reserved, error) {
+	var reserved models.Reserved
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(count) FILTER (WHERE kind = $4), 0),
+			COALESCE(SUM(count) FILTER (WHERE kind = $5), 0)
+		FROM merchshop.reservations
+		WHERE merch_id = $1 AND COALESCE(variant_id, 0) = $2
+			AND status = 'active' AND expires_at > $3
+	`, merchID, variantID, at.UTC(), string(models.ReservationHold), string(models.ReservationPreorder),
+	).Scan(&reserved.Holds, &reserved.Preorders)
+
+	return reserved, err
+}
+
+// UserReserved считает штуки мерча в активных неистекших бронях пользователя
+func (r *ReservationPG) UserReserved(ctx context.Context, userID, merchID int, at time.Time, excludeID int) (int, error) {
+	var reserved int
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		SELECT COALESCE(SUM(count), 0)
+		FROM merchshop.reservations
+		WHERE user_id = $1 AND merch_id = $2
+			AND status = 'active' AND expires_at > $3 AND reservation_id <> $4
+	`, userID, merchID, at.UTC(), excludeID).Scan(&reserved)
+
+	return reserved, err
+}
+
+// Expired возвращает id истекших активных броней
+func (r *ReservationPG) Expired(ctx context.Context, at time.Time, limit int) ([]int, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT reservation_id
+		FROM merchshop.reservations
+		WHERE status = 'active' AND expires_at <= $1
+		ORDER BY expires_at, reservation_id
+		LIMIT $2
+	`, at.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+// UpdateStatus меняет статус брони и время изменения
+func (r *ReservationPG) UpdateStatus(ctx context.Context, id int, status models.ReservationStatus, orderID int) error {
+	switch status {
+	case models.ReservationActive, models.ReservationConfirmed, models.ReservationCancelled, models.ReservationExpired:
+	default:
+		return models.ErrInvalidReservationStatus
+	}
+
+	result, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE merchshop.reservations
+		SET status = $2, order_id = NULLIF($3, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE reservation_id = $1
+	`, id, string(status), orderID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrReservationNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.ScheduleStorage = (*SchedulePG)(nil)
+
+// SchedulePG реализует интерфейс ScheduleStorage в PostgreSQL
+type SchedulePG struct {
+	db *pgxpool.Pool
+}
+
+// NewScheduleStorage создает новый экземпляр хранилища запланированных переводов.
+func NewScheduleStorage(db *pgxpool.Pool) *SchedulePG {
+	return &SchedulePG{db: db}
+}
+
+// Create сохраняет активный перевод, первое срабатывание - в StartAt
+func (s *SchedulePG) Create(ctx context.Context, st *models.ScheduledTransfer) error {
+	if st == nil {
+		return models.ErrEmptySchedule
+	}
+	if st.SenderId <= 0 {
+		return models.ErrInvalidSenderID
+	}
+	if st.ReceiverId <= 0 {
+		return models.ErrInvalidReceiverID
+	}
+	if st.SenderId == st.ReceiverId {
+		return models.ErrSameSenderReceiver
+	}
+	if st.Amount <= 0 {
+		return models.ErrInvalidAmount
+	}
+	if !st.Rule.Valid() {
+		return models.ErrInvalidScheduleRule
+	}
+	if !st.Category.Valid() {
+		return models.ErrInvalidTransferCategory
+	}
+
+	next := st.StartAt.UTC()
+	err := conn(ctx, s.db).QueryRow(ctx, `
+		INSERT INTO merchshop.scheduled_transfers
+			(sender_id, receiver_id, amount, category, message, rule, start_at, next_run_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $7)
+		RETURNING schedule_id, status, created_at
+	`,
+		st.SenderId, st.ReceiverId, st.Amount, string(st.Category), st.Message,
+		string(st.Rule), next,
+	).Scan(&st.Id, &st.Status, &st.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	st.StartAt = next
+	st.NextRunAt = &next
+	st.LastRun = nil
+	return nil
+}
+
+// scheduleQuery - перевод с логином получателя и последним срабатыванием
+const scheduleQuery = `
+	SELECT s.schedule_id, s.sender_id, s.receiver_id, u.login, s.amount,
+		COALESCE(s.category, ''), COALESCE(s.message, ''), s.rule, s.status,
+		s.start_at, s.next_run_at, s.created_at,
+		r.run_id, r.scheduled_for, r.status, COALESCE(r.reason, ''),
+		COALESCE(r.transaction_id, 0), r.created_at
+	FROM merchshop.scheduled_transfers AS s
+	JOIN merchshop.users AS u ON u.user_id = s.receiver_id
+	LEFT JOIN LATERAL (
+		SELECT * FROM merchshop.scheduled_transfer_runs
+		WHERE schedule_id = s.schedule_id
+		ORDER BY scheduled_for DESC, run_id DESC
+		LIMIT 1
+	) AS r ON true
+`
+
+// scanSchedule - читает строку scheduleQuery
+func scanSchedule(row pgx.Row) (*models.ScheduledTransfer, error) {
+	var (
+		st      models.ScheduledTransfer
+		runID   *int
+		runFor  *time.Time
+		runStat *models.ScheduleRunStatus
+		run     models.ScheduleRun
+		runAt   *time.Time
+	)
+
+	err := row.Scan(
+		&st.Id,
+		&st.SenderId,
+		&st.ReceiverId,
+		&st.Receiver,
+		&st.Amount,
+		&st.Category,
+		&st.Message,
+		&st.Rule,
+		&st.Status,
+		&st.StartAt,
+		&st.NextRunAt,
+		&st.CreatedAt,
+		&runID,
+		&runFor,
+		&runStat,
+		&run.Reason,
+		&run.TransferId,
+		&runAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if runID != nil {
+		run.Id = *runID
+		run.ScheduleId = st.Id
+		run.ScheduledFor = *runFor
+		run.Status = *runStat
+		run.CreatedAt = *runAt
+		st.LastRun = &run
+	}
+
+	return &st, nil
+}
+
+// Get возвращает перевод по id
+func (s *SchedulePG) Get(ctx context.Context, id int) (*models.ScheduledTransfer, error) {
+	return s.get(ctx, id, "")
+}
+
+// GetForUpdate возвращает перевод по id и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (s *SchedulePG) GetForUpdate(ctx context.Context, id int) (*models.ScheduledTransfer, error) {
+	return s.get(ctx, id, " FOR UPDATE OF s")
+}
+
+func (s *SchedulePG) get(ctx context.Context, id int, lock string) (*models.ScheduledTransfer, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidScheduleID
+	}
+
+	st, err := scanSchedule(conn(ctx, s.db).QueryRow(ctx, scheduleQuery+"WHERE s.schedule_id = $1"+lock, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrScheduleNotFound
+		}
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// List возвращает страницу переводов отправителя, начиная с самых новых
+func (s *SchedulePG) List(ctx context.Context, senderID int, q *models.PageQuery) (*models.Page[*models.ScheduledTransfer], error) {
+	if senderID <= 0 {
+		return nil, models.ErrInvalidSenderID
+	}
+
+	p := newPageParams(q)
+
+	rows, err := conn(ctx, s.db).Query(ctx, scheduleQuery+`
+		WHERE s.sender_id = $1
+			AND ($2::timestamp IS NULL OR s.created_at >= $2)
+			AND ($3::timestamp IS NULL OR s.created_at < $3)
+			AND ($4::timestamp IS NULL OR (s.created_at, s.schedule_id) < ($4, $5))
+		ORDER BY s.created_at DESC, s.schedule_id DESC
+		LIMIT $6
+	`, senderID, p.from, p.to, p.afterDate, p.afterID, p.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.ScheduledTransfer, error) {
+		return scanSchedule(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(list, p.pageLimit(), (*models.ScheduledTransfer).Cursor), nil
+}
+
+// Due возвращает id активных переводов, срабатывание которых наступило
+func (s *SchedulePG) Due(ctx context.Context, at time.Time, limit int) ([]int, error) {
+	rows, err := conn(ctx, s.db).Query(ctx, `
+		SELECT schedule_id
+		FROM merchshop.scheduled_transfers
+		WHERE status = 'active' AND next_run_at <= $1
+		ORDER BY next_run_at, schedule_id
+		LIMIT $2
+	`, at.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+// UpdateStatus меняет статус перевода, время следующего срабатывания и время изменения
+func (s *SchedulePG) UpdateStatus(ctx context.Context, id int, status models.ScheduleStatus, nextRunAt *time.Time) error {
+	switch status {
+	case models.ScheduleActive, models.ScheduleCompleted, models.ScheduleCancelled:
+	default:
+		return models.ErrInvalidScheduleStatus
+	}
+
+	var next *time.Time
+	if nextRunAt != nil {
+		utc := nextRunAt.UTC()
+		next = &utc
+	}
+
+	result, err := conn(ctx, s.db).Exec(ctx, `
+		UPDATE merchshop.scheduled_transfers
+		SET status = $2, next_run_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE schedule_id = $1
+	`, id, string(status), next)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+// CreateRun записывает срабатывание перевода
+func (s *SchedulePG) CreateRun(ctx context.Context, run *models.ScheduleRun) error {
+	if run == nil {
+		return models.ErrEmptySchedule
+	}
+	if run.ScheduleId <= 0 {
+		return models.ErrInvalidScheduleID
+	}
+
+	err := conn(ctx, s.db).QueryRow(ctx, `
+		INSERT INTO merchshop.scheduled_transfer_runs
+			(schedule_id, scheduled_for, status, reason, transaction_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, 0))
+		RETURNING run_id, created_at
+	`,
+		run.ScheduleId, run.ScheduledFor.UTC(), string(run.Status), string(run.Reason), run.TransferId,
+	).Scan(&run.Id, &run.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrScheduleRunExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Runs возвращает страницу срабатываний перевода, начиная с самых поздних
+func (s *SchedulePG) Runs(ctx context.Context, scheduleID int, q *models.PageQuery) (*models.Page[*models.ScheduleRun], error) {
+	if scheduleID <= 0 {
+		return nil, models.ErrInvalidScheduleID
+	}
+
+	p := newPageParams(q)
+
+	rows, err := conn(ctx, s.db).Query(ctx, `
+		SELECT run_id, schedule_id, scheduled_for, status, COALESCE(reason, ''),
+			COALESCE(transaction_id, 0), created_at
+		FROM merchshop.scheduled_transfer_runs
+		WHERE schedule_id = $1
+			AND ($2::timestamp IS NULL OR scheduled_for >= $2)
+			AND ($3::timestamp IS NULL OR scheduled_for < $3)
+			AND ($4::timestamp IS NULL OR (scheduled_for, run_id) < ($4, $5))
+		ORDER BY scheduled_for DESC, run_id DESC
+		LIMIT $6
+	`, scheduleID, p.from, p.to, p.afterDate, p.afterID, p.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.ScheduleRun, error) {
+		var run models.ScheduleRun
+		err := row.Scan(
+			&run.Id,
+			&run.ScheduleId,
+			&run.ScheduledFor,
+			&run.Status,
+			&run.Reason,
+			&run.TransferId,
+			&run.CreatedAt,
+		)
+		return &run, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(runs, p.pageLimit(), (*models.ScheduleRun).Cursor), nil
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.SessionStorage = (*SessionPG)(nil)
+
+// SessionPG реализует интерфейс SessionStorage в PostgreSQL
+type SessionPG struct {
+	db *pgxpool.Pool
+}
+
+// NewSessionStorage создает новый экземпляр хранилища сессий.
+func NewSessionStorage(db *pgxpool.Pool) *SessionPG {
+	return &SessionPG{db: db}
+}
+
+// Create сохраняет новую сессию.
+// Возвращает ошибку при невалидных данных или проблемах с БД.
+func (s *SessionPG) Create(ctx context.Context, session *models.Session) error {
+	if session == nil {
+		return models.ErrEmptySession
+	}
+
+	if session.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+
+	query := `
+		INSERT INTO merchshop.sessions (session_id, family_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+
+	return conn(ctx, s.db).QueryRow(
+		ctx,
+		query,
+		session.Id,
+		session.FamilyId,
+		session.UserId,
+		session.ExpiresAt,
+	).Scan(&session.CreatedAt)
+}
+
+// Get возвращает сессию по ID. Если сессия не найдена,
+// возвращает nil и ошибку.
+func (s *SessionPG) Get(ctx context.Context, id string) (*models.Session, error) {
+	query := `
+		SELECT s.session_id, s.family_id, s.user_id, u.login, s.revoked, s.created_at, s.expires_at
+		FROM merchshop.sessions AS s
+		JOIN merchshop.users AS u ON s.user_id = u.user_id
+		WHERE s.session_id = $1
+	`
+
+	var session models.Session
+	err := conn(ctx, s.db).QueryRow(ctx, query, id).Scan(
+		&session.Id,
+		&session.FamilyId,
+		&session.UserId,
+		&session.Login,
+		&session.Revoked,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ListActive возвращает неотозванные и неистекшие сессии пользователя,
+// начиная с самых новых.
+func (s *SessionPG) ListActive(ctx context.Context, userID int) ([]*models.Session, error) {
+	query := `
+		SELECT s.session_id, s.family_id, s.user_id, u.login, s.revoked, s.created_at, s.expires_at
+		FROM merchshop.sessions AS s
+		JOIN merchshop.users AS u ON s.user_id = u.user_id
+		WHERE s.user_id = $1 AND NOT s.revoked AND s.expires_at > $2
+		ORDER BY s.created_at DESC
+	`
+
+	// expires_at хранится без часового пояса в UTC (см. AuthService)
+	rows, err := conn(ctx, s.db).Query(ctx, query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.Id,
+			&session.FamilyId,
+			&session.UserId,
+			&session.Login,
+			&session.Revoked,
+			&session.CreatedAt,
+			&session.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeFamily отзывает все сессии семейства.
+func (s *SessionPG) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+		UPDATE merchshop.sessions
+		SET revoked = TRUE
+		WHERE family_id = $1
+	`
+
+	_, err := conn(ctx, s.db).Exec(ctx, query, familyID)
+
+	return err
+}
+
+// RevokeAllByUser отзывает все сессии пользователя.
+func (s *SessionPG) RevokeAllByUser(ctx context.Context, userID int) error {
+	query := `
+		UPDATE merchshop.sessions
+		SET revoked = TRUE
+		WHERE user_id = $1
+	`
+
+	_, err := conn(ctx, s.db).Exec(ctx, query, userID)
+
+	return err
+}
@@ -2,10 +2,13 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"merch_service/internal/models"
 	"merch_service/internal/storage/entities"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -41,114 +44,265 @@ func (t *TransactionPG) validateTransaction(send *models.User, recv *models.User
 	return nil
 }
 
-// Create создает новую транзакцию между пользователями
-func (t *TransactionPG) Create(ctx context.Context, send *models.User, recv *models.User, amount int) error {
+// Create записывает перевод между пользователями с поводом и сообщением и возвращает его id.
+// Монеты переводятся записью журнала (см. LedgerPG.Post)
+func (t *TransactionPG) Create(ctx context.Context, send *models.User, recv *models.User, amount int, note models.TransferNote) (int, error) {
 	if err := t.validateTransaction(send, recv, amount); err != nil {
-		return err
+		return 0, err
 	}
-
-	tx, err := t.db.Begin(ctx)
-	if err != nil {
-		return err
+	if !note.Category.Valid() {
+		return 0, models.ErrInvalidTransferCategory
 	}
-	defer tx.Rollback(ctx)
+
+	db := conn(ctx, t.db)
 
 	// Проверяем существование пользователей
 	var exists bool
-	err = tx.QueryRow(ctx,
+	err := db.QueryRow(ctx,
 		"SELECT EXISTS(SELECT 1 FROM merchshop.users WHERE user_id = $1)",
 		send.Id,
 	).Scan(&exists)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !exists {
-		return models.ErrSenderNotFound
+		return 0, models.ErrSenderNotFound
 	}
 
-	err = tx.QueryRow(ctx,
+	err = db.QueryRow(ctx,
 		"SELECT EXISTS(SELECT 1 FROM merchshop.users WHERE user_id = $1)",
 		recv.Id,
 	).Scan(&exists)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !exists {
-		return models.ErrReceiverNotFound
+		return 0, models.ErrReceiverNotFound
 	}
 
-	// Проверяем баланс отправителя
-	var senderCoins int
-	err = tx.QueryRow(ctx,
-		"SELECT coins FROM merchshop.users WHERE user_id = $1 FOR UPDATE",
-		send.Id,
-	).Scan(&senderCoins)
+	var id int
+	err = db.QueryRow(ctx,
+		`INSERT INTO merchshop.transactions
+		(sender_id, receiver_id, amount, category, message)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+		RETURNING transaction_id`,
+		send.Id, recv.Id, amount, string(note.Category), note.Message,
+	).Scan(&id)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if senderCoins < amount {
-		return models.ErrInsufficientCoins
-	}
+	return id, nil
+}
+
+// transferQuery - переводы пользователя $1 с логином второй стороны,
+// поводом, сообщением, реакцией и ответом
+const transferQuery = `
+	SELECT
+		t.transaction_id,
+		CASE WHEN t.sender_id = $1 THEN 'sent' ELSE 'received' END,
+		u.login,
+		t.amount,
+		t.transaction_date,
+		COALESCE(t.category, ''),
+		COALESCE(t.message, ''),
+		COALESCE(t.reaction, ''),
+		COALESCE(t.reply, ''),
+		t.replied_at
+	FROM merchshop.transactions AS t
+	JOIN merchshop.users AS u
+		ON u.user_id = CASE WHEN t.sender_id = $1 THEN t.receiver_id ELSE t.sender_id END
+`
 
-	// Выполняем перевод
-	_, err = tx.Exec(ctx,
-		"UPDATE merchshop.users SET coins = coins - $1 WHERE user_id = $2",
-		amount, send.Id,
+// scanTransfer - читает строку transferQuery
+func scanTransfer(row pgx.Row) (*models.TransferEntry, error) {
+	var entry models.TransferEntry
+	err := row.Scan(
+		&entry.Id,
+		&entry.Direction,
+		&entry.Counterpart,
+		&entry.Amount,
+		&entry.Date,
+		&entry.Category,
+		&entry.Message,
+		&entry.Reaction,
+		&entry.Reply,
+		&entry.RepliedAt,
 	)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// List возвращает страницу переводов пользователя вместе с логином
+// второй стороны, начиная с самых новых.
+// Пустое направление в filter равносильно TransferAll
+func (t *TransactionPG) List(ctx context.Context, user *models.User, filter *models.TransferFilter) (*models.Page[*models.TransferEntry], error) {
+	if user == nil {
+		return nil, models.ErrEmptyUser
 	}
 
-	_, err = tx.Exec(ctx,
-		"UPDATE merchshop.users SET coins = coins + $1 WHERE user_id = $2",
-		amount, recv.Id,
+	if filter == nil {
+		filter = &models.TransferFilter{}
+	}
+
+	direction := filter.Direction
+	if direction == "" {
+		direction = models.TransferAll
+	}
+
+	p := newPageParams(&filter.PageQuery)
+
+	rows, err := conn(ctx, t.db).Query(ctx, transferQuery+`
+		WHERE ((t.sender_id = $1 AND $2 <> 'received') OR (t.receiver_id = $1 AND $2 <> 'sent'))
+			AND ($3::timestamp IS NULL OR t.transaction_date >= $3)
+			AND ($4::timestamp IS NULL OR t.transaction_date < $4)
+			AND ($5::timestamp IS NULL OR (t.transaction_date, t.transaction_id) < ($5, $6))
+		ORDER BY t.transaction_date DESC, t.transaction_id DESC
+		LIMIT $7
+	`,
+		user.Id,
+		string(direction),
+		p.from,
+		p.to,
+		p.afterDate,
+		p.afterID,
+		p.limit,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Записываем транзакцию (без возвращения ID)
-	_, err = tx.Exec(ctx,
-		`INSERT INTO merchshop.transactions 
-		(sender_id, receiver_id, amount)
-		VALUES ($1, $2, $3)`,
-		send.Id, recv.Id, amount,
-	)
+	transfers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.TransferEntry, error) {
+		return scanTransfer(row)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Записываем в историю изменений баланса отправителя
-	_, err = tx.Exec(ctx,
-		`INSERT INTO merchshop.coinhistory 
-		(user_id, coins_before, coins_after)
-		VALUES ($1, $2, $3)`,
-		send.Id, senderCoins, senderCoins-amount,
-	)
+	return models.NewPage(transfers, p.pageLimit(), (*models.TransferEntry).Cursor), nil
+}
+
+// Get возвращает перевод id, в котором участвует пользователь
+func (t *TransactionPG) Get(ctx context.Context, user *models.User, id int) (*models.TransferEntry, error) {
+	if user == nil {
+		return nil, models.ErrEmptyUser
+	}
+
+	entry, err := scanTransfer(conn(ctx, t.db).QueryRow(ctx, transferQuery+`
+		WHERE t.transaction_id = $2 AND (t.sender_id = $1 OR t.receiver_id = $1)
+	`, user.Id, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrTransferNotFound
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// React ставит или снимает реакцию получателя на перевод
+func (t *TransactionPG) React(ctx context.Context, id, receiverID int, reaction models.TransferReaction) error {
+	if !reaction.Valid() {
+		return models.ErrInvalidTransferReaction
+	}
+
+	result, err := conn(ctx, t.db).Exec(ctx, `
+		UPDATE merchshop.transactions
+		SET reaction = NULLIF($3, '')
+		WHERE transaction_id = $1 AND receiver_id = $2
+	`, id, receiverID, string(reaction))
 	if err != nil {
 		return err
 	}
 
-	// Записываем в историю изменений баланса получателя
-	var receiverCoins int
-	err = tx.QueryRow(ctx,
-		"SELECT coins FROM merchshop.users WHERE user_id = $1",
-		recv.Id,
-	).Scan(&receiverCoins)
+	if result.RowsAffected() == 0 {
+		return models.ErrTransferNotFound
+	}
+
+	return nil
+}
+
+// Reply сохраняет ответ получателя, если на перевод еще не ответили.
+// Условие reply IS NULL в UPDATE не дает двум параллельным ответам перезаписать друг друга
+func (t *TransactionPG) Reply(ctx context.Context, id, receiverID int, message string) error {
+	if message == "" {
+		return models.ErrEmptyTransferReply
+	}
+
+	db := conn(ctx, t.db)
+
+	result, err := db.Exec(ctx, `
+		UPDATE merchshop.transactions
+		SET reply = $3, replied_at = CURRENT_TIMESTAMP
+		WHERE transaction_id = $1 AND receiver_id = $2 AND reply IS NULL
+	`, id, receiverID, message)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(ctx,
-		`INSERT INTO merchshop.coinhistory 
-		(user_id, coins_before, coins_after)
-		VALUES ($1, $2, $3)`,
-		recv.Id, receiverCoins, receiverCoins+amount,
-	)
+	if result.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM merchshop.transactions WHERE transaction_id = $1 AND receiver_id = $2)",
+		id, receiverID,
+	).Scan(&exists)
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return models.ErrTransferNotFound
+	}
+
+	return models.ErrTransferReplied
+}
+
+// Velocity считает исходящие переводы отправителя за скользящие сутки и неделю до at
+func (t *TransactionPG) Velocity(ctx context.Context, senderID, receiverID int, at time.Time, smallAmount int) (models.TransferVelocity, error) {
+	var v models.TransferVelocity
+	if senderID <= 0 {
+		return v, models.ErrInvalidSenderID
+	}
+
+	day, week := at.Add(-24*time.Hour).UTC(), at.Add(-7*24*time.Hour).UTC()
+	err := conn(ctx, t.db).QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE transaction_date > $3), 0),
+			COALESCE(SUM(amount), 0),
+			COUNT(*) FILTER (WHERE transaction_date > $3 AND receiver_id = $2 AND amount <= $5)
+		FROM merchshop.transactions
+		WHERE sender_id = $1 AND transaction_date > $4
+	`, senderID, receiverID, day, week, smallAmount).Scan(&v.Daily, &v.Weekly, &v.SmallToReceiver)
+
+	return v, err
+}
+
+// HasChain ищет цепочку переводов от fromID к toID рекурсивным запросом:
+// на каждом шаге берутся получатели переводов, сделанных начиная с since
+func (t *TransactionPG) HasChain(ctx context.Context, fromID, toID int, since time.Time, depth int) (bool, error) {
+	if depth <= 0 {
+		return false, nil
+	}
+
+	var found bool
+	err := conn(ctx, t.db).QueryRow(ctx, `
+		WITH RECURSIVE chain (user_id, depth) AS (
+				SELECT receiver_id, 1
+				FROM merchshop.transactions
+				WHERE sender_id = $1 AND transaction_date >= $3
+			UNION
+				SELECT t.receiver_id, c.depth + 1
+				FROM chain AS c
+				JOIN merchshop.transactions AS t ON t.sender_id = c.user_id
+				WHERE c.depth < $4 AND c.user_id <> $2 AND t.transaction_date >= $3
+		)
+		SELECT EXISTS(SELECT 1 FROM chain WHERE user_id = $2)
+	`, fromID, toID, since.UTC(), depth).Scan(&found)
 
-	return tx.Commit(ctx)
+	return found, err
 }
@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.TransferFlagStorage = (*TransferFlagPG)(nil)
+
+// TransferFlagPG реализует интерфейс TransferFlagStorage в PostgreSQL
+type TransferFlagPG struct {
+	db *pgxpool.Pool
+}
+
+// NewTransferFlagStorage создает новый экземпляр хранилища пометок переводов.
+func NewTransferFlagStorage(db *pgxpool.Pool) *TransferFlagPG {
+	return &TransferFlagPG{db: db}
+}
+
+// Create сохраняет открытую пометку перевода. Стороны и сумма берутся из самого перевода
+func (f *TransferFlagPG) Create(ctx context.Context, flag *models.TransferFlag) error {
+	if flag == nil || flag.TransferId <= 0 || len(flag.Reasons) == 0 {
+		return models.ErrEmptyTransferFlag
+	}
+
+	reasons := make([]string, 0, len(flag.Reasons))
+	for _, r := range flag.Reasons {
+		reasons = append(reasons, string(r))
+	}
+
+	err := conn(ctx, f.db).QueryRow(ctx, `
+		INSERT INTO merchshop.transfer_flags (transaction_id, reasons)
+		VALUES ($1, $2)
+		RETURNING flag_id, status, created_at
+	`, flag.TransferId, reasons).Scan(&flag.Id, &flag.Status, &flag.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	flag.ReviewerId, flag.Reviewer, flag.ReviewedAt = 0, "", nil
+	return nil
+}
+
+// transferFlagQuery - пометка со сторонами и суммой перевода и логином проверяющего
+const transferFlagQuery = `
+	SELECT f.flag_id, f.transaction_id, t.sender_id, s.login, t.receiver_id, r.login, t.amount,
+		f.reasons, f.status, COALESCE(f.reviewer_id, 0), COALESCE(rev.login, ''),
+		f.created_at, f.reviewed_at
+	FROM merchshop.transfer_flags AS f
+	JOIN merchshop.transactions AS t ON t.transaction_id = f.transaction_id
+	JOIN merchshop.users AS s ON s.user_id = t.sender_id
+	JOIN merchshop.users AS r ON r.user_id = t.receiver_id
+	LEFT JOIN merchshop.users AS rev ON rev.user_id = f.reviewer_id
+`
+
+// scanTransferFlag - читает строку transferFlagQuery
+func scanTransferFlag(row pgx.Row) (*models.TransferFlag, error) {
+	var (
+		flag    models.TransferFlag
+		reasons []string
+	)
+	err := row.Scan(
+		&flag.Id,
+		&flag.TransferId,
+		&flag.SenderId,
+		&flag.Sender,
+		&flag.ReceiverId,
+		&flag.Receiver,
+		&flag.Amount,
+		&reasons,
+		&flag.Status,
+		&flag.ReviewerId,
+		&flag.Reviewer,
+		&flag.CreatedAt,
+		&flag.ReviewedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	flag.Reasons = make([]models.TransferFlagReason, 0, len(reasons))
+	for _, r := range reasons {
+		flag.Reasons = append(flag.Reasons, models.TransferFlagReason(r))
+	}
+	return &flag, nil
+}
+
+// Get возвращает пометку перевода по id
+func (f *TransferFlagPG) Get(ctx context.Context, id int) (*models.TransferFlag, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidTransferFlagID
+	}
+
+	flag, err := scanTransferFlag(conn(ctx, f.db).QueryRow(ctx, transferFlagQuery+"WHERE f.flag_id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrTransferFlagNotFound
+		}
+		return nil, err
+	}
+
+	return flag, nil
+}
+
+// List возвращает страницу пометок, начиная с самых новых
+func (f *TransferFlagPG) List(ctx context.Context, filter *models.TransferFlagFilter) (*models.Page[*models.TransferFlag], error) {
+	if filter == nil {
+		filter = &models.TransferFlagFilter{}
+	}
+
+	p := newPageParams(&filter.PageQuery)
+
+	rows, err := conn(ctx, f.db).Query(ctx, transferFlagQuery+`
+		WHERE ($1 = '' OR f.status = $1)
+			AND ($2::timestamp IS NULL OR f.created_at >= $2)
+			AND ($3::timestamp IS NULL OR f.created_at < $3)
+			AND ($4::timestamp IS NULL OR (f.created_at, f.flag_id) < ($4, $5))
+		ORDER BY f.created_at DESC, f.flag_id DESC
+		LIMIT $6
+	`,
+		string(filter.Status),
+		p.from,
+		p.to,
+		p.afterDate,
+		p.afterID,
+		p.limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.TransferFlag, error) {
+		return scanTransferFlag(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(list, p.pageLimit(), (*models.TransferFlag).Cursor), nil
+}
+
+// Review закрывает открытую пометку. Условие status = 'open' в UPDATE не дает
+// двум администраторам перезаписать решения друг друга
+func (f *TransferFlagPG) Review(ctx context.Context, id int, status models.TransferFlagStatus, reviewerID int) error {
+	if !status.Valid() || status == models.FlagOpen {
+		return models.ErrInvalidTransferFlagStatus
+	}
+	if id <= 0 {
+		return models.ErrInvalidTransferFlagID
+	}
+
+	db := conn(ctx, f.db)
+
+	result, err := db.Exec(ctx, `
+		UPDATE merchshop.transfer_flags
+		SET status = $2, reviewer_id = $3, reviewed_at = CURRENT_TIMESTAMP
+		WHERE flag_id = $1 AND status = 'open'
+	`, id, string(status), reviewerID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM merchshop.transfer_flags WHERE flag_id = $1)",
+		id,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrTransferFlagNotFound
+	}
+
+	return models.ErrTransferFlagClosed
+}
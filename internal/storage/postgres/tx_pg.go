@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.TxManager = (*TxManagerPG)(nil)
+
+// txKey - ключ, под которым открытая транзакция хранится в контексте
+type txKey struct{}
+
+// querier - общее подмножество методов *pgxpool.Pool и pgx.Tx,
+// которое используют хранилища
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// conn возвращает транзакцию из контекста, если она была открыта
+// через TxManagerPG, иначе пул соединений
+func conn(ctx context.Context, db *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// isUniqueViolation проверяет, что запрос нарушил ограничение уникальности
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// isCheckViolation проверяет, что запрос нарушил CHECK ограничение
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
+
+// TxManagerPG реализует интерфейс TxManager в PostgreSQL
+type TxManagerPG struct {
+	db *pgxpool.Pool
+}
+
+// NewTxManager создает новый менеджер транзакций.
+func NewTxManager(db *pgxpool.Pool) *TxManagerPG {
+	return &TxManagerPG{db: db}
+}
+
+// WithinTx выполняет fn в транзакции. Транзакция передается хранилищам
+// через контекст, поэтому fn должна пользоваться полученным ctx.
+func (t *TxManagerPG) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -53,6 +53,9 @@ func (u *UserPG) validateUser(user *models.User) error {
 	if user.Coins < 0 {
 		return models.ErrNegativeUserCoins
 	}
+	if user.Role != "" && !user.Role.Valid() {
+		return models.ErrInvalidUserRole
+	}
 	return nil
 }
 
@@ -63,18 +66,24 @@ func (u *UserPG) Create(ctx context.Context, user *models.User) error {
 		return err
 	}
 
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
 	query := `
-		INSERT INTO merchshop.users (login, password)
-		VALUES ($1, $2)
-		RETURNING user_id
+		INSERT INTO merchshop.users (login, password, coins, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING user_id, created_at
 	`
 
-	err := u.db.QueryRow(
+	err := conn(ctx, u.db).QueryRow(
 		ctx,
 		query,
 		user.Login,
 		user.Password,
-	).Scan(&user.Id)
+		user.Coins,
+		user.Role,
+	).Scan(&user.Id, &user.CreatedAt)
 
 	if err != nil {
 		return err
@@ -91,17 +100,19 @@ func (u *UserPG) Get(ctx context.Context, id int) (*models.User, error) {
 	}
 
 	query := `
-		SELECT user_id, login, password, coins
+		SELECT user_id, login, password, coins, role, created_at
 		FROM merchshop.users
 		WHERE user_id = $1
 	`
 
 	var user models.User
-	err := u.db.QueryRow(ctx, query, id).Scan(
+	err := conn(ctx, u.db).QueryRow(ctx, query, id).Scan(
 		&user.Id,
 		&user.Login,
 		&user.Password,
 		&user.Coins,
+		&user.Role,
+		&user.CreatedAt,
 	)
 
 	if err != nil {
@@ -133,7 +144,7 @@ func (u *UserPG) Update(ctx context.Context, user *models.User) error {
 		WHERE user_id = $4
 	`
 
-	result, err := u.db.Exec(
+	result, err := conn(ctx, u.db).Exec(
 		ctx,
 		query,
 		user.Login,
@@ -167,7 +178,7 @@ func (u *UserPG) Delete(ctx context.Context, id int) error {
 		WHERE user_id = $1
 	`
 
-	result, err := u.db.Exec(ctx, query, id)
+	result, err := conn(ctx, u.db).Exec(ctx, query, id)
 
 	if err != nil {
 		return err
@@ -188,17 +199,53 @@ func (u *UserPG) GetByLogin(ctx context.Context, login string) (*models.User, er
 	}
 
 	query := `
-		SELECT user_id, login, password, coins
+		SELECT user_id, login, password, coins, role, created_at
+		FROM merchshop.users
+		WHERE login = $1
+	`
+
+	var user models.User
+	err := conn(ctx, u.db).QueryRow(ctx, query, login).Scan(
+		&user.Id,
+		&user.Login,
+		&user.Password,
+		&user.Coins,
+		&user.Role,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetByLoginForUpdate возвращает пользователя по логину и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (u *UserPG) GetByLoginForUpdate(ctx context.Context, login string) (*models.User, error) {
+	if err := u.validateLogin(login); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT user_id, login, password, coins, role, created_at
 		FROM merchshop.users
 		WHERE login = $1
+		FOR UPDATE
 	`
 
 	var user models.User
-	err := u.db.QueryRow(ctx, query, login).Scan(
+	err := conn(ctx, u.db).QueryRow(ctx, query, login).Scan(
 		&user.Id,
 		&user.Login,
 		&user.Password,
 		&user.Coins,
+		&user.Role,
+		&user.CreatedAt,
 	)
 
 	if err != nil {
@@ -211,6 +258,126 @@ func (u *UserPG) GetByLogin(ctx context.Context, login string) (*models.User, er
 	return &user, nil
 }
 
+// UpdatePassword обновляет пароль пользователя. В отличие от Update
+// не трогает баланс, поэтому безопасен при параллельных покупках.
+func (u *UserPG) UpdatePassword(ctx context.Context, id int, password string) error {
+	if err := u.validateID(id); err != nil {
+		return err
+	}
+
+	if password == "" {
+		return models.ErrEmptyUserPassword
+	}
+
+	query := `
+		UPDATE merchshop.users
+		SET password = $1
+		WHERE user_id = $2
+	`
+
+	result, err := conn(ctx, u.db).Exec(ctx, query, password, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateRole обновляет роль пользователя.
+// Возвращает ошибку если пользователь не найден или роль не существует.
+func (u *UserPG) UpdateRole(ctx context.Context, id int, role models.Role) error {
+	if err := u.validateID(id); err != nil {
+		return err
+	}
+
+	if !role.Valid() {
+		return models.ErrInvalidUserRole
+	}
+
+	query := `
+		UPDATE merchshop.users
+		SET role = $1
+		WHERE user_id = $2
+	`
+
+	result, err := conn(ctx, u.db).Exec(ctx, query, role, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// CountByRoleForUpdate возвращает число пользователей с ролью role и блокирует их строки
+// до конца транзакции (SELECT ... FOR UPDATE). Строки блокируются по порядку user_id,
+// поэтому параллельные транзакции ждут друг друга, а не взаимно блокируются.
+// Имеет смысл только внутри TxManager.WithinTx.
+func (u *UserPG) CountByRoleForUpdate(ctx context.Context, role models.Role) (int, error) {
+	rows, err := conn(ctx, u.db).Query(ctx, `
+		SELECT user_id
+		FROM merchshop.users
+		WHERE role = $1
+		ORDER BY user_id
+		FOR UPDATE
+	`, role)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// GetList возвращает список всех пользователей.
+// Возвращает ошибку при проблемах с БД.
+func (u *UserPG) GetList(ctx context.Context) ([]*models.User, error) {
+	query := `
+		SELECT user_id, login, password, coins, role, created_at
+		FROM merchshop.users
+		ORDER BY user_id
+	`
+
+	rows, err := conn(ctx, u.db).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.Id,
+			&user.Login,
+			&user.Password,
+			&user.Coins,
+			&user.Role,
+			&user.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (u *UserPG) GetCoinsHistory(ctx context.Context, userLogin string) ([]models.CoinsEntry, error) {
 	query := `
         SELECT change_id, change_date, coins_before, coins_after
@@ -219,7 +386,7 @@ func (u *UserPG) GetCoinsHistory(ctx context.Context, userLogin string) ([]model
         ORDER BY change_date DESC
     `
 
-	rows, err := u.db.Query(ctx, query, userLogin)
+	rows, err := conn(ctx, u.db).Query(ctx, query, userLogin)
 	if err != nil {
 		return nil, err
 	}
@@ -256,7 +423,7 @@ func (u *UserPG) GetPurchaseHistory(ctx context.Context, userLogin string) ([]mo
         ORDER BY p.purchase_date DESC
     `
 
-	rows, err := u.db.Query(ctx, query, userLogin)
+	rows, err := conn(ctx, u.db).Query(ctx, query, userLogin)
 	if err != nil {
 		return nil, err
 	}
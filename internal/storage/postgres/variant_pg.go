@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.VariantStorage = (*VariantPG)(nil)
+
+// VariantPG реализует интерфейс VariantStorage в PostgreSQL
+type VariantPG struct {
+	db *pgxpool.Pool
+}
+
+// NewVariantStorage создает новый экземпляр хранилища вариантов товаров.
+func NewVariantStorage(db *pgxpool.Pool) *VariantPG {
+	return &VariantPG{db: db}
+}
+
+// validateVariant проверяет валидность данных варианта.
+func (v *VariantPG) validateVariant(variant *models.Variant) error {
+	if variant == nil {
+		return models.ErrEmptyVariant
+	}
+	if variant.Sku == "" {
+		return models.ErrEmptyVariantSku
+	}
+	if variant.Price != nil && *variant.Price < 0 {
+		return models.ErrNegativePrice
+	}
+	if variant.Stock < 0 {
+		return models.ErrNegativeStock
+	}
+	return nil
+}
+
+// attributes - атрибуты для записи в JSONB, nil записывается как пустой объект
+func attributes(variant *models.Variant) map[string]string {
+	if variant.Attributes == nil {
+		return map[string]string{}
+	}
+	return variant.Attributes
+}
+
+// Create создает вариант неудаленного товара.
+// Если товара нет или он удален, возвращает ErrMerchNotFound
+func (v *VariantPG) Create(ctx context.Context, variant *models.Variant) error {
+	if err := v.validateVariant(variant); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO merchshop.merch_variants (merch_id, sku, attributes, price, stock)
+		SELECT merch_id, $2, $3, $4, $5
+		FROM merchshop.merch
+		WHERE merch_id = $1 AND deleted_at IS NULL
+		RETURNING variant_id
+	`
+
+	err := conn(ctx, v.db).QueryRow(ctx, query,
+		variant.MerchId,
+		variant.Sku,
+		attributes(variant),
+		variant.Price,
+		variant.Stock,
+	).Scan(&variant.Id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ErrMerchNotFound
+		}
+		if isUniqueViolation(err) {
+			return models.ErrVariantExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// variantQuery - неудаленные варианты неудаленных товаров
+const variantQuery = `
+	SELECT v.variant_id, v.merch_id, v.sku, v.attributes, v.price, v.stock
+	FROM merchshop.merch_variants AS v
+	JOIN merchshop.merch AS m ON m.merch_id = v.merch_id
+	WHERE v.deleted_at IS NULL AND m.deleted_at IS NULL
+`
+
+// scanVariant - читает строку variantQuery
+func scanVariant(row pgx.Row) (*models.Variant, error) {
+	var variant models.Variant
+	err := row.Scan(
+		&variant.Id,
+		&variant.MerchId,
+		&variant.Sku,
+		&variant.Attributes,
+		&variant.Price,
+		&variant.Stock,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrVariantNotFound
+		}
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// Get возвращает вариант по ID
+func (v *VariantPG) Get(ctx context.Context, id int) (*models.Variant, error) {
+	return scanVariant(conn(ctx, v.db).QueryRow(ctx, variantQuery+" AND v.variant_id = $1", id))
+}
+
+// GetForUpdate возвращает вариант по ID и блокирует его строку
+// до конца транзакции (SELECT ... FOR UPDATE). Имеет смысл только внутри TxManager.WithinTx.
+func (v *VariantPG) GetForUpdate(ctx context.Context, id int) (*models.Variant, error) {
+	return scanVariant(conn(ctx, v.db).QueryRow(ctx, variantQuery+" AND v.variant_id = $1 FOR UPDATE OF v", id))
+}
+
+// GetBySku возвращает вариант по артикулу
+func (v *VariantPG) GetBySku(ctx context.Context, sku string) (*models.Variant, error) {
+	return scanVariant(conn(ctx, v.db).QueryRow(ctx, variantQuery+" AND v.sku = $1", sku))
+}
+
+// List возвращает варианты товаров merchIDs
+func (v *VariantPG) List(ctx context.Context, merchIDs []int) ([]*models.Variant, error) {
+	if len(merchIDs) == 0 {
+		return make([]*models.Variant, 0), nil
+	}
+
+	rows, err := conn(ctx, v.db).Query(ctx,
+		variantQuery+" AND v.merch_id = ANY($1) ORDER BY v.merch_id, v.variant_id",
+		merchIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Variant, error) {
+		return scanVariant(row)
+	})
+}
+
+// Update обновляет неудаленный вариант
+func (v *VariantPG) Update(ctx context.Context, variant *models.Variant) error {
+	if err := v.validateVariant(variant); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE merchshop.merch_variants
+		SET sku = $1, attributes = $2, price = $3, stock = $4
+		WHERE variant_id = $5 AND deleted_at IS NULL
+	`
+
+	result, err := conn(ctx, v.db).Exec(ctx, query,
+		variant.Sku,
+		attributes(variant),
+		variant.Price,
+		variant.Stock,
+		variant.Id,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrVariantExists
+		}
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrVariantNotFound
+	}
+
+	return nil
+}
+
+// Delete помечает вариант удаленным, на него могут ссылаться покупки
+func (v *VariantPG) Delete(ctx context.Context, id int) error {
+	query := `
+		UPDATE merchshop.merch_variants
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE variant_id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := conn(ctx, v.db).Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrVariantNotFound
+	}
+
+	return nil
+}
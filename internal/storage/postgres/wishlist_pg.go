@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/entities"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ entities.WishlistStorage = (*WishlistPG)(nil)
+
+// WishlistPG реализует интерфейс WishlistStorage в PostgreSQL
+type WishlistPG struct {
+	db *pgxpool.Pool
+}
+
+// NewWishlistStorage создает новый экземпляр хранилища списков желаний.
+func NewWishlistStorage(db *pgxpool.Pool) *WishlistPG {
+	return &WishlistPG{db: db}
+}
+
+// Add добавляет неудаленный мерч в список желаний или меняет подписку
+func (w *WishlistPG) Add(ctx context.Context, userID, merchID int, notify bool) error {
+	if userID <= 0 {
+		return models.ErrInvalidUserID
+	}
+	if merchID <= 0 {
+		return models.ErrInvalidMerchID
+	}
+
+	query := `
+		INSERT INTO merchshop.wishlist_items (user_id, merch_id, notify)
+		SELECT $1, m.merch_id, $3
+		FROM merchshop.merch AS m
+		WHERE m.merch_id = $2 AND m.deleted_at IS NULL
+		ON CONFLICT (user_id, merch_id) DO UPDATE SET notify = EXCLUDED.notify
+	`
+
+	result, err := conn(ctx, w.db).Exec(ctx, query, userID, merchID, notify)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrMerchNotFound
+	}
+
+	return nil
+}
+
+// Remove убирает мерч из списка желаний
+func (w *WishlistPG) Remove(ctx context.Context, userID, merchID int) error {
+	result, err := conn(ctx, w.db).Exec(ctx,
+		"DELETE FROM merchshop.wishlist_items WHERE user_id = $1 AND merch_id = $2",
+		userID, merchID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrWishlistItemNotFound
+	}
+
+	return nil
+}
+
+// List возвращает список желаний с текущими именем, ценой и остатком неудаленного мерча
+func (w *WishlistPG) List(ctx context.Context, userID int) ([]*models.WishlistItem, error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	rows, err := conn(ctx, w.db).Query(ctx, `
+		SELECT m.merch_id, m.name, m.price, m.stock, wi.notify, wi.created_at
+		FROM merchshop.wishlist_items AS wi
+		JOIN merchshop.merch AS m ON m.merch_id = wi.merch_id
+		WHERE wi.user_id = $1 AND m.deleted_at IS NULL
+		ORDER BY wi.created_at, m.merch_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.WishlistItem, error) {
+		var item models.WishlistItem
+		err := row.Scan(
+			&item.MerchId,
+			&item.Name,
+			&item.Price,
+			&item.Stock,
+			&item.Notify,
+			&item.AddedAt,
+		)
+		return &item, err
+	})
+}
@@ -0,0 +1,4 @@
+-- Пароли теперь хранятся в виде хеша argon2id вместе с параметрами
+-- ($argon2id$v=19$m=...,t=...,p=...$<соль>$<хеш>), 100 символов может не хватить
+ALTER TABLE merchshop.users
+    ALTER COLUMN password TYPE VARCHAR(255);
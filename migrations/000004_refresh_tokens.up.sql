@@ -0,0 +1,18 @@
+-- Таблица refresh токенов
+-- Каждый refresh токен одноразовый: при обмене на новую пару токенов
+-- он помечается использованным, а новый токен получает тот же family_id.
+-- Повторное использование токена означает его утечку - тогда
+-- отзывается все семейство (см. AuthService.Refresh)
+CREATE TABLE IF NOT EXISTS merchshop.refresh_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    family_id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES merchshop.users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON merchshop.refresh_tokens (family_id);
@@ -0,0 +1,17 @@
+-- Таблица сессий
+-- Каждому выданному токену авторизации соответствует сессия с session_id = jti токена.
+-- family_id связывает сессию с семейством refresh токенов, выданных после одного входа
+CREATE TABLE IF NOT EXISTS merchshop.sessions (
+    session_id VARCHAR(64) PRIMARY KEY,
+    family_id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES merchshop.users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_family_idx ON merchshop.sessions (family_id);
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON merchshop.sessions (user_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON merchshop.refresh_tokens (user_id);
@@ -0,0 +1,3 @@
+-- Роль пользователя (см. models.Role). Права ролей описаны в коде
+ALTER TABLE merchshop.users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'user';
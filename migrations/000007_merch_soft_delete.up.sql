@@ -0,0 +1,10 @@
+-- Мягкое удаление мерча: на товар ссылаются merchshop.purchases,
+-- поэтому удаленный товар только скрывается (deleted_at IS NOT NULL)
+ALTER TABLE merchshop.merch
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Имя уникально среди неудаленных товаров,
+-- имя удаленного товара можно использовать повторно
+CREATE UNIQUE INDEX IF NOT EXISTS merch_name_uniq
+    ON merchshop.merch (name)
+    WHERE deleted_at IS NULL;
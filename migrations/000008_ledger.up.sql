@@ -0,0 +1,98 @@
+-- Журнал монет (двойная запись).
+-- Каждое движение монет - запись журнала с причиной и id связанного объекта
+-- (покупки, перевода), проводки записи в сумме дают ноль.
+-- merchshop.users.coins остается кешем баланса: он меняется только
+-- вместе с проводками и сверяется с журналом (см. LedgerStorage.Reconcile)
+
+-- Счета: у каждого пользователя свой счет, у системных счетов - код
+CREATE TABLE IF NOT EXISTS merchshop.accounts (
+    account_id SERIAL PRIMARY KEY,
+    user_id INTEGER UNIQUE,
+    code VARCHAR(64) UNIQUE,
+
+    FOREIGN KEY (user_id) REFERENCES merchshop.users(user_id),
+    CHECK ((user_id IS NULL) <> (code IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS merchshop.journal_entries (
+    entry_id SERIAL PRIMARY KEY,
+    reason VARCHAR(32) NOT NULL,
+    reference_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS merchshop.postings (
+    posting_id SERIAL PRIMARY KEY,
+    entry_id INTEGER NOT NULL,
+    account_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount <> 0),
+
+    FOREIGN KEY (entry_id) REFERENCES merchshop.journal_entries(entry_id),
+    FOREIGN KEY (account_id) REFERENCES merchshop.accounts(account_id)
+);
+
+CREATE INDEX IF NOT EXISTS postings_entry_idx ON merchshop.postings (entry_id);
+CREATE INDEX IF NOT EXISTS postings_account_idx ON merchshop.postings (account_id);
+
+-- Журнал только дополняется: исправления вносятся новыми записями
+CREATE OR REPLACE FUNCTION merchshop.ledger_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'журнал монет только дополняется';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_entries_append_only ON merchshop.journal_entries;
+CREATE TRIGGER journal_entries_append_only
+    BEFORE UPDATE OR DELETE ON merchshop.journal_entries
+    FOR EACH ROW EXECUTE FUNCTION merchshop.ledger_append_only();
+
+DROP TRIGGER IF EXISTS postings_append_only ON merchshop.postings;
+CREATE TRIGGER postings_append_only
+    BEFORE UPDATE OR DELETE ON merchshop.postings
+    FOR EACH ROW EXECUTE FUNCTION merchshop.ledger_append_only();
+
+-- Системные счета (см. models.SystemAccount)
+INSERT INTO merchshop.accounts (code)
+VALUES ('shop_revenue'), ('grants')
+ON CONFLICT (code) DO NOTHING;
+
+-- Перенос существующих балансов: счета для всех пользователей и одна
+-- запись 'opening', переводящая текущие балансы со счета 'grants'
+INSERT INTO merchshop.accounts (user_id)
+SELECT user_id FROM merchshop.users
+ON CONFLICT (user_id) DO NOTHING;
+
+DO $$
+DECLARE
+    opening_id INTEGER;
+    opening_total INTEGER;
+BEGIN
+    IF EXISTS (SELECT 1 FROM merchshop.journal_entries WHERE reason = 'opening') THEN
+        RETURN;
+    END IF;
+
+    SELECT COALESCE(SUM(coins), 0) INTO opening_total FROM merchshop.users;
+    IF opening_total = 0 THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO merchshop.journal_entries (reason)
+    VALUES ('opening')
+    RETURNING entry_id INTO opening_id;
+
+    INSERT INTO merchshop.postings (entry_id, account_id, amount)
+    SELECT opening_id, a.account_id, u.coins
+    FROM merchshop.users AS u
+    JOIN merchshop.accounts AS a ON a.user_id = u.user_id
+    WHERE u.coins <> 0;
+
+    INSERT INTO merchshop.postings (entry_id, account_id, amount)
+    SELECT opening_id, account_id, -opening_total
+    FROM merchshop.accounts
+    WHERE code = 'grants';
+END;
+$$;
+
+-- Стартовые монеты теперь начисляются записью 'signup'
+ALTER TABLE merchshop.users
+    ALTER COLUMN coins SET DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Ключи идемпотентности для POST /merch/buy и POST /coins/transfer.
+-- Повтор запроса с тем же ключом получает сохраненный ответ, а не выполняется заново.
+-- status_code и response пустые, пока первый запрос с ключом еще выполняется
+CREATE TABLE IF NOT EXISTS merchshop.idempotency_keys (
+    user_id INTEGER NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    response JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, idempotency_key),
+    FOREIGN KEY (user_id) REFERENCES merchshop.users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON merchshop.idempotency_keys (expires_at);
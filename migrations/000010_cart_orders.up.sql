@@ -0,0 +1,55 @@
+-- Заказы: одна покупка (POST /merch/buy) или оформление корзины (POST /cart/checkout)
+-- списывает монеты одной записью журнала, строки заказа лежат в merchshop.purchases
+CREATE TABLE IF NOT EXISTS merchshop.orders (
+    order_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    total INTEGER NOT NULL CHECK (total >= 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES merchshop.users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_idx ON merchshop.orders (user_id);
+
+-- Цена строки фиксируется в момент покупки
+ALTER TABLE merchshop.purchases
+    ADD COLUMN IF NOT EXISTS order_id INTEGER REFERENCES merchshop.orders(order_id),
+    ADD COLUMN IF NOT EXISTS price INTEGER CHECK (price >= 0);
+
+-- Каждая прежняя покупка становится заказом с тем же id, поэтому записи журнала
+-- 'purchase' продолжают ссылаться на правильный объект.
+-- Цена покупки не хранилась, берется текущая цена мерча
+INSERT INTO merchshop.orders (order_id, user_id, total, created_at)
+SELECT p.purchase_id, p.user_id, p.count * m.price, p.purchase_date
+FROM merchshop.purchases AS p
+JOIN merchshop.merch AS m ON m.merch_id = p.merch_id
+WHERE p.order_id IS NULL;
+
+UPDATE merchshop.purchases AS p
+SET order_id = p.purchase_id, price = m.price
+FROM merchshop.merch AS m
+WHERE m.merch_id = p.merch_id AND p.order_id IS NULL;
+
+SELECT setval(
+    pg_get_serial_sequence('merchshop.orders', 'order_id'),
+    COALESCE((SELECT MAX(order_id) FROM merchshop.orders), 1),
+    EXISTS (SELECT 1 FROM merchshop.orders)
+);
+
+ALTER TABLE merchshop.purchases
+    ALTER COLUMN order_id SET NOT NULL,
+    ALTER COLUMN price SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS purchases_order_idx ON merchshop.purchases (order_id);
+
+-- Корзина: по одной строке на товар
+CREATE TABLE IF NOT EXISTS merchshop.cart_items (
+    user_id INTEGER NOT NULL,
+    merch_id INTEGER NOT NULL,
+    count INTEGER NOT NULL CHECK (count > 0),
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, merch_id),
+    FOREIGN KEY (user_id) REFERENCES merchshop.users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (merch_id) REFERENCES merchshop.merch(merch_id)
+);
@@ -0,0 +1,20 @@
+-- Статус выдачи заказа (см. models.OrderStatus):
+-- placed -> confirmed -> ready_for_pickup -> delivered, либо cancelled.
+-- Заказы, созданные до появления статусов, считаются выданными:
+-- столбец добавляется со значением 'delivered', затем меняется значение по умолчанию
+ALTER TABLE merchshop.orders
+    ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'delivered'
+        CHECK (status IN ('placed', 'confirmed', 'ready_for_pickup', 'delivered', 'cancelled')),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+ALTER TABLE merchshop.orders
+    ALTER COLUMN status SET DEFAULT 'placed';
+
+UPDATE merchshop.orders
+SET updated_at = created_at
+WHERE updated_at IS NULL;
+
+ALTER TABLE merchshop.orders
+    ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS orders_status_idx ON merchshop.orders (status, created_at, order_id);
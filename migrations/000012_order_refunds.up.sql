@@ -0,0 +1,8 @@
+-- Ссылка записи истории кошелька на заказ: списание за покупку ('purchase')
+-- или возврат монет за отмененный заказ ('refund').
+-- У остальных записей (переводы) reason и order_id пустые
+ALTER TABLE merchshop.coinhistory
+    ADD COLUMN IF NOT EXISTS reason VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS order_id INTEGER REFERENCES merchshop.orders(order_id);
+
+CREATE INDEX IF NOT EXISTS coinhistory_order_idx ON merchshop.coinhistory (order_id);
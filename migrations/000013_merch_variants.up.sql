@@ -0,0 +1,35 @@
+-- Варианты товара (размер, цвет и т.д.): у каждого варианта свой артикул (sku),
+-- свой остаток и, при необходимости, своя цена (NULL - цена товара).
+-- Товар с вариантами продается только по варианту, merchshop.merch.stock
+-- для него не используется. Товары без вариантов продаются как раньше
+CREATE TABLE IF NOT EXISTS merchshop.merch_variants (
+    variant_id SERIAL PRIMARY KEY,
+    merch_id INTEGER NOT NULL,
+    sku VARCHAR(64) NOT NULL,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    price INTEGER CHECK (price >= 0),
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    deleted_at TIMESTAMP,
+
+    FOREIGN KEY (merch_id) REFERENCES merchshop.merch(merch_id)
+);
+
+-- Артикул уникален среди неудаленных вариантов (как имя товара)
+CREATE UNIQUE INDEX IF NOT EXISTS merch_variants_sku_uniq
+    ON merchshop.merch_variants (sku)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS merch_variants_merch_idx ON merchshop.merch_variants (merch_id);
+
+ALTER TABLE merchshop.purchases
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES merchshop.merch_variants(variant_id);
+
+-- Строка корзины - товар или его вариант, 0 - товар без вариантов
+ALTER TABLE merchshop.cart_items
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE merchshop.cart_items
+    DROP CONSTRAINT IF EXISTS cart_items_pkey;
+
+ALTER TABLE merchshop.cart_items
+    ADD PRIMARY KEY (user_id, merch_id, variant_id);
@@ -0,0 +1,22 @@
+-- Категория и свободные теги товара для фильтров каталога (GET /merch).
+-- Пустая категория - товар без категории, категория и теги хранятся в нижнем регистре
+ALTER TABLE merchshop.merch
+    ADD COLUMN IF NOT EXISTS category VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS search_text TEXT NOT NULL DEFAULT '';
+
+-- search_text - имя и теги в нижнем регистре с ё, замененной на е.
+-- Приложение заполняет его само (models.MerchSearchText), чтобы поиск по кириллице
+-- не зависел от локали БД. Для уже существующих товаров заполняется здесь
+UPDATE merchshop.merch
+SET search_text = translate(lower(name), 'Ёё', 'ее')
+WHERE search_text = '';
+
+CREATE INDEX IF NOT EXISTS merch_category_idx
+    ON merchshop.merch (category)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS merch_tags_idx ON merchshop.merch USING GIN (tags);
+
+-- Популярность товара считается по строкам заказов
+CREATE INDEX IF NOT EXISTS purchases_merch_idx ON merchshop.purchases (merch_id);
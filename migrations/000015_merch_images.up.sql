@@ -0,0 +1,19 @@
+-- Изображения товаров. Файлы лежат в хранилище файлов (entities.BlobStorage),
+-- здесь только их ключи: оригинал (blob_key) и миниатюра (thumb_key).
+-- Ключ строится по содержимому файла, поэтому повторная загрузка того же файла
+-- для товара упирается в уникальность blob_key
+CREATE TABLE IF NOT EXISTS merchshop.merch_images (
+    image_id SERIAL PRIMARY KEY,
+    merch_id INTEGER NOT NULL,
+    blob_key VARCHAR(255) NOT NULL UNIQUE,
+    thumb_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(32) NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    width INTEGER NOT NULL CHECK (width > 0),
+    height INTEGER NOT NULL CHECK (height > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (merch_id) REFERENCES merchshop.merch(merch_id)
+);
+
+CREATE INDEX IF NOT EXISTS merch_images_merch_idx ON merchshop.merch_images (merch_id);
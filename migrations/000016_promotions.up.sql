@@ -0,0 +1,60 @@
+-- Промокоды: скидка по коду на весь каталог (merch_id IS NULL) или на один товар.
+-- Срок действия [starts_at, ends_at), NULL - без ограничения.
+-- Лимиты использования (0 - без лимита) считаются по неотмененным заказам с промокодом,
+-- поэтому отмена заказа возвращает использование
+CREATE TABLE IF NOT EXISTS merchshop.promo_codes (
+    promo_id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value INTEGER NOT NULL CHECK (value > 0),
+    merch_id INTEGER REFERENCES merchshop.merch(merch_id),
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    max_uses INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+    max_uses_per_user INTEGER NOT NULL DEFAULT 0 CHECK (max_uses_per_user >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+
+    CHECK (kind <> 'percent' OR value <= 100),
+    CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at)
+);
+
+-- Код уникален среди неудаленных промокодов и хранится в верхнем регистре
+CREATE UNIQUE INDEX IF NOT EXISTS promo_codes_code_uniq
+    ON merchshop.promo_codes (code)
+    WHERE deleted_at IS NULL;
+
+-- Распродажи: скидка без промокода на время [starts_at, ends_at)
+CREATE TABLE IF NOT EXISTS merchshop.sales (
+    sale_id SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value INTEGER NOT NULL CHECK (value > 0),
+    merch_id INTEGER REFERENCES merchshop.merch(merch_id),
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+
+    CHECK (kind <> 'percent' OR value <= 100),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS sales_window_idx
+    ON merchshop.sales (starts_at, ends_at)
+    WHERE deleted_at IS NULL;
+
+-- Скидка заказа и его промокод. total уже учитывает скидку
+ALTER TABLE merchshop.orders
+    ADD COLUMN IF NOT EXISTS discount INTEGER NOT NULL DEFAULT 0 CHECK (discount >= 0),
+    ADD COLUMN IF NOT EXISTS promo_id INTEGER REFERENCES merchshop.promo_codes(promo_id);
+
+CREATE INDEX IF NOT EXISTS orders_promo_idx
+    ON merchshop.orders (promo_id, user_id)
+    WHERE promo_id IS NOT NULL;
+
+-- price - уплаченная цена за штуку, list_price - цена без скидок (NULL у старых покупок - без скидки),
+-- sale_id - распродажа, снизившая цену
+ALTER TABLE merchshop.purchases
+    ADD COLUMN IF NOT EXISTS list_price INTEGER CHECK (list_price >= 0),
+    ADD COLUMN IF NOT EXISTS sale_id INTEGER REFERENCES merchshop.sales(sale_id);
@@ -0,0 +1,7 @@
+-- Лимиты покупки товара одним пользователем (0 - без лимита):
+-- всего, за календарный месяц и в одном заказе. Считаются по неотмененным заказам
+ALTER TABLE merchshop.merch
+    ADD COLUMN IF NOT EXISTS limit_per_user INTEGER NOT NULL DEFAULT 0 CHECK (limit_per_user >= 0),
+    ADD COLUMN IF NOT EXISTS limit_per_month INTEGER NOT NULL DEFAULT 0 CHECK (limit_per_month >= 0),
+    ADD COLUMN IF NOT EXISTS limit_per_order INTEGER NOT NULL DEFAULT 0 CHECK (limit_per_order >= 0);
+
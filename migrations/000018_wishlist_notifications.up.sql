@@ -0,0 +1,27 @@
+-- Список желаний пользователя. notify - подписка на уведомление,
+-- когда мерч снова появится в наличии
+CREATE TABLE IF NOT EXISTS merchshop.wishlist_items (
+    user_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    merch_id INTEGER NOT NULL REFERENCES merchshop.merch(merch_id),
+    notify BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, merch_id)
+);
+
+CREATE INDEX IF NOT EXISTS wishlist_items_notify_idx
+    ON merchshop.wishlist_items (merch_id)
+    WHERE notify;
+
+-- Внутренний ящик уведомлений. read_at - время прочтения, NULL у непрочитанных
+CREATE TABLE IF NOT EXISTS merchshop.notifications (
+    notification_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    kind VARCHAR(32) NOT NULL,
+    merch_id INTEGER NOT NULL REFERENCES merchshop.merch(merch_id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx
+    ON merchshop.notifications (user_id, created_at, notification_id);
@@ -0,0 +1,39 @@
+-- Брони товаров: удержание товара в наличии (hold) и предзаказ (preorder).
+-- Активная бронь уменьшает доступный остаток, но не склад; total списан с пользователя
+-- и держится на системном счете 'reservations' до подтверждения или возврата
+CREATE TABLE IF NOT EXISTS merchshop.reservations (
+    reservation_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    merch_id INTEGER NOT NULL REFERENCES merchshop.merch(merch_id),
+    variant_id INTEGER REFERENCES merchshop.merch_variants(variant_id),
+    kind VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    count INTEGER NOT NULL CHECK (count > 0),
+    price INTEGER NOT NULL CHECK (price >= 0),
+    list_price INTEGER NOT NULL CHECK (list_price >= 0),
+    sale_id INTEGER REFERENCES merchshop.sales(sale_id),
+    order_id INTEGER REFERENCES merchshop.orders(order_id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS reservations_merch_idx
+    ON merchshop.reservations (merch_id, variant_id)
+    WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS reservations_expires_idx
+    ON merchshop.reservations (expires_at)
+    WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS reservations_user_idx
+    ON merchshop.reservations (user_id, created_at, reservation_id);
+
+-- Запись истории кошелька об оплате брони ('reserve') или возврате за нее ('release')
+ALTER TABLE merchshop.coinhistory
+    ADD COLUMN IF NOT EXISTS reservation_id INTEGER REFERENCES merchshop.reservations(reservation_id);
+
+-- Системный счет удержанных бронями монет (см. models.AccountReservations)
+INSERT INTO merchshop.accounts (code)
+VALUES ('reservations')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,12 @@
+-- Повод и сообщение перевода (задает отправитель), реакция и ответ (задает получатель).
+-- Пустой повод и пустая реакция хранятся как NULL
+ALTER TABLE merchshop.transactions
+    ADD COLUMN IF NOT EXISTS category VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS message VARCHAR(1024),
+    ADD COLUMN IF NOT EXISTS reaction VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS reply VARCHAR(1024),
+    ADD COLUMN IF NOT EXISTS replied_at TIMESTAMP;
+
+-- Запись истории кошелька о переводе ('transfer'), повод и сообщение берутся из перевода
+ALTER TABLE merchshop.coinhistory
+    ADD COLUMN IF NOT EXISTS transfer_id INTEGER REFERENCES merchshop.transactions(transaction_id);
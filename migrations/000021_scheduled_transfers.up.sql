@@ -0,0 +1,39 @@
+-- Запланированные переводы: разовые (once) и повторяющиеся (weekly, monthly).
+-- У активного перевода next_run_at - время следующего срабатывания
+CREATE TABLE IF NOT EXISTS merchshop.scheduled_transfers (
+    schedule_id SERIAL PRIMARY KEY,
+    sender_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    receiver_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    category VARCHAR(32),
+    message VARCHAR(1024),
+    rule VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    start_at TIMESTAMP NOT NULL,
+    next_run_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (sender_id <> receiver_id)
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_due_idx
+    ON merchshop.scheduled_transfers (next_run_at)
+    WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_sender_idx
+    ON merchshop.scheduled_transfers (sender_id, created_at, schedule_id);
+
+-- Срабатывания запланированных переводов. Уникальность (schedule_id, scheduled_for)
+-- гарантирует, что одно срабатывание не выполнится дважды, в том числе после перезапуска
+CREATE TABLE IF NOT EXISTS merchshop.scheduled_transfer_runs (
+    run_id SERIAL PRIMARY KEY,
+    schedule_id INTEGER NOT NULL REFERENCES merchshop.scheduled_transfers(schedule_id),
+    scheduled_for TIMESTAMP NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    reason VARCHAR(32),
+    transaction_id INTEGER REFERENCES merchshop.transactions(transaction_id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (schedule_id, scheduled_for)
+);
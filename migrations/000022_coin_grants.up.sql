@@ -0,0 +1,29 @@
+-- Пакеты начислений администратора: монеты переводятся со счета казны 'treasury'
+-- одной записью журнала 'grant' на пакет
+CREATE TABLE IF NOT EXISTS merchshop.coin_grants (
+    grant_id SERIAL PRIMARY KEY,
+    admin_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    total INTEGER NOT NULL CHECK (total > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS merchshop.coin_grant_items (
+    item_id SERIAL PRIMARY KEY,
+    grant_id INTEGER NOT NULL REFERENCES merchshop.coin_grants(grant_id),
+    line INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    reason VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS coin_grant_items_grant_idx
+    ON merchshop.coin_grant_items (grant_id);
+
+-- Запись истории кошелька о начислении ('grant'), причина берется из строки пакета
+ALTER TABLE merchshop.coinhistory
+    ADD COLUMN IF NOT EXISTS grant_item_id INTEGER REFERENCES merchshop.coin_grant_items(item_id);
+
+-- Казна (см. models.AccountTreasury)
+INSERT INTO merchshop.accounts (code)
+VALUES ('treasury')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,24 @@
+-- Запросы монет: requester просит amount монет у payer. Принятый запрос
+-- выполняется переводом transaction_id. Истекшие запросы остаются в статусе
+-- 'pending' и считаются истекшими по expires_at
+CREATE TABLE IF NOT EXISTS merchshop.coin_requests (
+    request_id SERIAL PRIMARY KEY,
+    requester_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    payer_id INTEGER NOT NULL REFERENCES merchshop.users(user_id),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    category VARCHAR(32),
+    message VARCHAR(1024),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    transaction_id INTEGER REFERENCES merchshop.transactions(transaction_id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+
+    CHECK (requester_id <> payer_id)
+);
+
+CREATE INDEX IF NOT EXISTS coin_requests_requester_idx
+    ON merchshop.coin_requests (requester_id, created_at, request_id);
+
+CREATE INDEX IF NOT EXISTS coin_requests_payer_idx
+    ON merchshop.coin_requests (payer_id, created_at, request_id);
@@ -0,0 +1,29 @@
+-- Дата регистрации нужна для ограничения переводов с новых аккаунтов.
+-- Уже существующие пользователи новыми не считаются
+ALTER TABLE merchshop.users
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP;
+
+UPDATE merchshop.users SET created_at = 'epoch' WHERE created_at IS NULL;
+
+ALTER TABLE merchshop.users
+    ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN created_at SET NOT NULL;
+
+-- Лимиты считаются по исходящим переводам отправителя за последние дни
+CREATE INDEX IF NOT EXISTS transactions_sender_date_idx
+    ON merchshop.transactions (sender_id, transaction_date);
+
+-- Пометки подозрительных переводов для проверки администратором.
+-- reasons - circular и (или) small_transfers (см. models.TransferFlagReason)
+CREATE TABLE IF NOT EXISTS merchshop.transfer_flags (
+    flag_id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL UNIQUE REFERENCES merchshop.transactions(transaction_id),
+    reasons TEXT[] NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    reviewer_id INTEGER REFERENCES merchshop.users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS transfer_flags_created_idx
+    ON merchshop.transfer_flags (created_at, flag_id);
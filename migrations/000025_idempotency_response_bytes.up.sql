@@ -0,0 +1,4 @@
+-- Ответ на запрос с ключом повторяется байт в байт, а JSONB переупорядочивает
+-- ключи и убирает пробелы, поэтому тело ответа хранится как есть
+ALTER TABLE merchshop.idempotency_keys
+    ALTER COLUMN response TYPE BYTEA USING convert_to(response::text, 'UTF8');
@@ -0,0 +1,9 @@
+-- Аренда незавершенного ключа: если запрос упал, не сохранив ответ, повтор
+-- того же запроса занимает ключ после locked_until, а не ждет expires_at
+ALTER TABLE merchshop.idempotency_keys
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+UPDATE merchshop.idempotency_keys SET locked_until = expires_at WHERE locked_until IS NULL;
+
+ALTER TABLE merchshop.idempotency_keys
+    ALTER COLUMN locked_until SET NOT NULL;
@@ -0,0 +1,11 @@
+-- Доставка уведомлений во внешний канал (вебхук): delivered_at - время подтвержденной
+-- доставки, NULL у еще не доставленных. Недоставленные отправляются повторно,
+-- пока вебхук не ответит 2xx. Уже созданные уведомления повторно не рассылаются
+ALTER TABLE merchshop.notifications
+    ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+
+UPDATE merchshop.notifications SET delivered_at = created_at WHERE delivered_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS notifications_undelivered_idx
+    ON merchshop.notifications (notification_id)
+    WHERE delivered_at IS NULL;
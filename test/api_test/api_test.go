@@ -1,15 +1,23 @@
 package apitest
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"image"
+	"image/png"
 	"log"
+	"merch_service/configs"
 	"merch_service/internal/handlers"
 	"merch_service/internal/models"
 	"merch_service/internal/server"
 	"merch_service/internal/service"
 	"merch_service/test/mock"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,24 +26,72 @@ import (
 func ServerStart(t *testing.T) server.Server {
 	t.Helper()
 
+	return ServerStartWithLimits(t, models.TransferLimits{})
+}
+
+// ServerStartWithLimits - ServerStart с лимитами переводов limits
+// (в остальных тестах переводы не ограничены)
+func ServerStartWithLimits(t *testing.T, limits models.TransferLimits) server.Server {
+	t.Helper()
+
+	// Пароль администратора в конфиге не хранится (см. configs.AdminConfig)
+	t.Setenv(configs.AdminPasswordEnv, "adminabobapass")
+
 	userStorage := mock.NewMockUserStorage()
 	purchaseStorage := mock.NewMockPurchaseStorage()
 	coinsStorage := mock.NewMockCoinsStorage()
 	merchStorage := mock.NewMockMerchStorage()
 	transactionStorage := mock.NewMockTransactionStorage()
+	refreshStorage := mock.NewMockRefreshTokenStorage()
+	sessionStorage := mock.NewMockSessionStorage()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	idempotencyStorage := mock.NewMockIdempotencyStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	imageStorage := mock.NewMockImageStorage(merchStorage)
+	promoStorage := mock.NewMockPromoStorage()
+	reservationStorage := mock.NewMockReservationStorage()
+	scheduleStorage := mock.NewMockScheduleStorage()
+	coinRequestStorage := mock.NewMockCoinRequestStorage()
+	transferFlagStorage := mock.NewMockTransferFlagStorage(userStorage)
+	grantStorage := mock.NewMockGrantStorage()
+	cartStorage := mock.NewMockCartStorage(merchStorage, variantStorage)
+	wishlistStorage := mock.NewMockWishlistStorage(merchStorage)
+	notificationStorage := mock.NewMockNotificationStorage(wishlistStorage, userStorage, merchStorage)
+	txManager := mock.NewMockTxManager()
+
+	restockNotifier := service.NewRestockNotifier(notificationStorage, variantStorage, nil)
 
-	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage)
-	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage)
-	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage)
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, transactionStorage, txManager, ledgerStorage, sessionStorage, refreshStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, cartStorage, variantStorage, imageStorage, promoStorage, reservationStorage, restockNotifier)
+	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, txManager, ledgerStorage, scheduleStorage, coinRequestStorage, transferFlagStorage)
+	transactionService.Limits = limits
+	authService := service.NewAuthService(refreshStorage, sessionStorage, userStorage, txManager)
+	ledgerService := service.NewLedgerService(ledgerStorage, userStorage, coinsStorage, txManager, grantStorage)
+	idempotencyService := service.NewIdempotencyService(idempotencyStorage, userStorage)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, restockNotifier)
+	mediaService := service.NewMediaService(imageStorage, merchStorage, mock.NewMockBlobStorage())
+	promoService := service.NewPromoService(promoStorage, merchStorage, orderStorage)
+	notificationService := service.NewNotificationService(wishlistStorage, notificationStorage, userStorage, variantStorage)
 
 	// Инициализация хендлеров
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, authService)
 	merchHandler := handlers.NewMerchHandler(merchService)
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	authHandler := handlers.NewAuthHandler(authService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	idempotencyHandler := handlers.NewIdempotencyHandler(idempotencyService)
+	orderHandler := handlers.NewOrderHandler(orderService)
+	mediaHandler := handlers.NewMediaHandler(mediaService)
+	promoHandler := handlers.NewPromoHandler(promoService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
 
 	// Эти серивисы передаются в Server
 	// Захардкоженые пути, простите =(
-	serv := server.NewMerchServer(userHandler, transactionHandler, merchHandler, "../../configs/server_config.yml")
+	serv := server.NewMerchServer(userHandler, transactionHandler, merchHandler, authHandler, ledgerHandler, idempotencyHandler, orderHandler, mediaHandler, promoHandler, notificationHandler, "../../configs/server_config.yml")
+
+	admin := serv.Config().Admin
+	require.NoError(t, userService.BootstrapAdmin(context.Background(), admin.Login, admin.Password))
 
 	go serv.Start()
 
@@ -158,6 +214,1760 @@ func TestLoginAPI(t *testing.T) {
 	server.Stop()
 }
 
+func TestRefreshAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	loginReq := &models.LoginRequest{
+		Login:    "aboba",
+		Password: "123123",
+	}
+
+	_, err := cli.Register(context.Background(), loginReq)
+	require.NoError(t, err)
+
+	response, err := cli.GetTokens(context.Background(), loginReq)
+	require.NoError(t, err)
+
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	// Первый обмен успешен и возвращает новый refresh токен
+	refreshResp, err := cli.Refresh(context.Background(), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, refreshResp.ErrorCode)
+	assert.Equal(t, handlers.RefreshOK, refreshResp.Message)
+
+	newTokens, ok := refreshResp.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+	assert.NotEmpty(t, newTokens.Token)
+	assert.NotEqual(t, tokens.Refresh, newTokens.Refresh)
+
+	// Повторный обмен того же токена - признак кражи
+	reusedResp, err := cli.Refresh(context.Background(), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, reusedResp.ErrorCode)
+	assert.Equal(t, handlers.RefreshReusedError, reusedResp.Message)
+
+	// Все семейство отозвано
+	revokedResp, err := cli.Refresh(context.Background(), newTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, revokedResp.ErrorCode)
+	assert.Equal(t, handlers.RefreshInvalidError, revokedResp.Message)
+
+	server.Stop()
+}
+
+func TestLogoutAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	loginReq := &models.LoginRequest{
+		Login:    "aboba",
+		Password: "123123",
+	}
+
+	_, err := cli.Register(context.Background(), loginReq)
+	require.NoError(t, err)
+
+	response, err := cli.GetTokens(context.Background(), loginReq)
+	require.NoError(t, err)
+
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	purchReq := &models.PurchaseRequest{
+		Item:  "Футболка", // Взято из mock.NewMockMerchStorage
+		Count: 1,
+	}
+
+	purchResp, err := cli.Buy(context.Background(), purchReq, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, purchResp.ErrorCode)
+
+	logoutResp, err := cli.Logout(context.Background(), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, logoutResp.ErrorCode)
+	assert.Equal(t, handlers.LogoutOK, logoutResp.Message)
+
+	// Срок токена не истек, но сессия завершена
+	purchResp, err = cli.Buy(context.Background(), purchReq, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, purchResp.ErrorCode)
+	assert.Equal(t, handlers.SessionRevokedError, purchResp.Message)
+
+	// Refresh токен того же входа тоже отозван
+	refreshResp, err := cli.Refresh(context.Background(), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, refreshResp.ErrorCode)
+
+	server.Stop()
+}
+
+func TestAdminAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	login := func(t *testing.T, req *models.LoginRequest) *UserTokens {
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+
+		tokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		return tokens
+	}
+
+	userReq := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), userReq)
+	require.NoError(t, err)
+	userTokens := login(t, userReq)
+
+	// Администратор создается из configs/server_config.yml (см. ServerStart)
+	adminTokens := login(t, &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+
+	response, err := cli.Users(context.Background(), userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+	assert.Equal(t, handlers.ForbiddenError, response.Message)
+
+	response, err = cli.Users(context.Background(), adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+	users, ok := response.Data.(*[]models.UserInfo)
+	require.True(t, ok)
+	assert.Len(t, *users, 2)
+
+	response, err = cli.SetRole(context.Background(), "aboba", "superuser", adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response, err = cli.SetRole(context.Background(), "aboba", models.RoleAdmin, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	// Смена роли завершает сессии пользователя: старые токены со старой ролью не действуют
+	response, err = cli.Users(context.Background(), userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.ErrorCode)
+	assert.Equal(t, handlers.SessionRevokedError, response.Message)
+
+	response, err = cli.Refresh(context.Background(), userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.ErrorCode)
+
+	userTokens = login(t, userReq)
+	response, err = cli.Users(context.Background(), userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	// Разжалованный администратор теряет права сразу, а не после истечения токена
+	response, err = cli.SetRole(context.Background(), "aboba", models.RoleUser, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Users(context.Background(), userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.ErrorCode)
+
+	userTokens = login(t, userReq)
+	response, err = cli.Users(context.Background(), userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	// Последний администратор не может снять роль с себя
+	response, err = cli.SetRole(context.Background(), "admin", models.RoleUser, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.LastAdminError, response.Message)
+
+	server.Stop()
+}
+
+func TestAdminMerchAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	login := func(t *testing.T, req *models.LoginRequest) *UserTokens {
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+
+		tokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		return tokens
+	}
+
+	userReq := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), userReq)
+	require.NoError(t, err)
+	userTokens := login(t, userReq)
+	adminTokens := login(t, &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+
+	newItem := &models.MerchRequest{Name: "Худи", Price: 300, Stock: 2}
+
+	response, err := cli.AdminMerch(context.Background(), http.MethodPost, "", newItem, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodPost, "", newItem, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+	item, ok := response.Data.(*models.Item)
+	require.True(t, ok)
+	path := fmt.Sprintf("/%d", item.Id)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodPost, "", newItem, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.MerchExistsError, response.Message)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodPut, path,
+		&models.MerchRequest{Name: "Худи", Price: -1, Stock: 2}, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidMerchError, response.Message)
+
+	stock := 5
+	response, err = cli.AdminMerch(context.Background(), http.MethodPatch, path,
+		&models.MerchPatchRequest{Stock: &stock}, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	item, ok = response.Data.(*models.Item)
+	require.True(t, ok)
+	assert.Equal(t, 300, item.Price)
+	assert.Equal(t, 5, item.Stock)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Худи", Count: 1}, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodDelete, path, nil, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodDelete, path, nil, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Худи", Count: 1}, userTokens)
+	require.NoError(t, err)
+	assert.NotEqual(t, http.StatusOK, response.ErrorCode)
+
+	server.Stop()
+}
+
+func TestMerchCatalogAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	response, err := cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodPost, "",
+		&models.MerchRequest{Name: "Ёлочная игрушка", Price: 40, Stock: 3, Category: "Декор", Tags: []string{"Новый год"}}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+
+	names := func(t *testing.T, query string) []string {
+		response, err := cli.Merch(context.Background(), query, tokens)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.ErrorCode)
+		page, ok := response.Data.(*models.Page[models.Item])
+		require.True(t, ok)
+
+		result := make([]string, 0, len(page.Items))
+		for _, item := range page.Items {
+			result = append(result, item.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Ёлочная игрушка"}, names(t, "q="+url.QueryEscape("ЕЛОЧН")))
+	assert.Equal(t, []string{"Ёлочная игрушка"}, names(t, "category="+url.QueryEscape("декор")))
+	assert.Equal(t, []string{"Ёлочная игрушка"}, names(t, "tag="+url.QueryEscape("новый год")))
+	assert.Equal(t, []string{"Кружка", "Ёлочная игрушка"}, names(t, "max_price=50&sort=price"))
+	assert.Equal(t, []string{"Футболка", "Ёлочная игрушка"}, names(t, "min_price=40&max_price=100&in_stock=true&sort=-price"))
+
+	for _, query := range []string{"sort=cheapest", "min_price=abc", "min_price=10&max_price=5", "in_stock=maybe"} {
+		response, err = cli.Merch(context.Background(), query, tokens)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, response.ErrorCode, query)
+		assert.Equal(t, handlers.InvalidMerchFilterError, response.Message, query)
+	}
+
+	server.Stop()
+}
+
+func TestMerchVariantsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	login := func(t *testing.T, req *models.LoginRequest) *UserTokens {
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+
+		tokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		return tokens
+	}
+
+	userReq := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), userReq)
+	require.NoError(t, err)
+	userTokens := login(t, userReq)
+	adminTokens := login(t, &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+
+	price := 150
+	variantReq := &models.VariantRequest{Sku: "TEE-XL", Attributes: map[string]string{"size": "XL"}, Price: &price, Stock: 2}
+
+	response, err := cli.AdminVariant(context.Background(), http.MethodPost, 1, "", variantReq, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	response, err = cli.AdminVariant(context.Background(), http.MethodPost, 1, "", variantReq, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+	variant, ok := response.Data.(*models.Variant)
+	require.True(t, ok)
+	assert.Equal(t, "XL", variant.Attributes["size"])
+
+	response, err = cli.AdminVariant(context.Background(), http.MethodPost, 2, "", variantReq, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.VariantExistsError, response.Message)
+
+	response, err = cli.AdminVariant(context.Background(), http.MethodPut, 1, fmt.Sprintf("/%d", variant.Id),
+		&models.VariantRequest{Sku: "", Stock: 2}, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidVariantError, response.Message)
+
+	response, err = cli.AdminVariant(context.Background(), http.MethodPut, 2, fmt.Sprintf("/%d", variant.Id), variantReq, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Count: 1}, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.VariantRequiredError, response.Message)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Кружка", Variant: "TEE-XL", Count: 1}, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Variant: "TEE-XL", Count: 1}, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	// Мерч без вариантов покупается как раньше
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Кружка", Count: 1}, userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Cart(context.Background(), "POST", "/items", &models.PurchaseRequest{Item: "Футболка", Variant: "TEE-XL", Count: 1}, userTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Cart(context.Background(), "PUT", "/items/1?variant=TEE-XL", &models.CartItemRequest{Count: 2}, userTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	cart, ok := response.Data.(*models.Cart)
+	require.True(t, ok)
+	require.Len(t, cart.Lines, 1)
+	assert.Equal(t, "TEE-XL", cart.Lines[0].Variant)
+	assert.Equal(t, 300, cart.Total)
+
+	// На складе варианта осталась одна штука
+	response, err = cli.Checkout(context.Background(), "", userTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.NotEnoughMerchError, response.Message)
+
+	response, err = cli.AdminVariant(context.Background(), http.MethodDelete, 1, fmt.Sprintf("/%d", variant.Id), nil, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.AdminVariant(context.Background(), http.MethodDelete, 1, fmt.Sprintf("/%d", variant.Id), nil, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	server.Stop()
+}
+
+func TestMerchImagesAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	response, err := cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	img := image.NewRGBA(image.Rect(0, 0, 512, 1024))
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	response, err = cli.UploadImage(context.Background(), 1, buf.Bytes(), tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+	uploaded, ok := response.Data.(*models.Image)
+	require.True(t, ok)
+
+	response, err = cli.UploadImage(context.Background(), 1, []byte("<html></html>"), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnsupportedMediaType, response.ErrorCode)
+
+	response, err = cli.UploadImage(context.Background(), 1, make([]byte, models.MaxImageSize+1), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.ErrorCode)
+
+	response, err = cli.UploadImage(context.Background(), 100, buf.Bytes(), tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.Merch(context.Background(), "limit=1", tokens)
+	require.NoError(t, err)
+	page, ok := response.Data.(*models.Page[models.Item])
+	require.True(t, ok)
+	require.Len(t, page.Items[0].Images, 1)
+	assert.Equal(t, uploaded.URL, page.Items[0].Images[0].URL)
+
+	resp, body, err := cli.Media(context.Background(), uploaded.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, buf.Bytes(), body)
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	assert.Contains(t, resp.Header.Get("Cache-Control"), "max-age=")
+	require.NotEmpty(t, resp.Header.Get("ETag"))
+
+	resp, _, err = cli.Media(context.Background(), uploaded.URL, http.Header{"If-None-Match": {resp.Header.Get("ETag")}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body, err = cli.Media(context.Background(), uploaded.ThumbnailURL, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	thumb, err := png.DecodeConfig(bytes.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, models.ThumbnailSize/2, thumb.Width)
+	assert.Equal(t, models.ThumbnailSize, thumb.Height)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodDelete, fmt.Sprintf("/1/images/%d", uploaded.Id), nil, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	resp, _, err = cli.Media(context.Background(), uploaded.URL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _, err = cli.Media(context.Background(), "/media/../configs/server_config.yml", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	server.Stop()
+}
+
+func TestLedgerAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	response, err := cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Count: 1}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 100}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Reconcile(context.Background(), tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	// Администратор создается из configs/server_config.yml (см. ServerStart)
+	response, err = cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	adminTokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.Reconcile(context.Background(), adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+	report, ok := response.Data.(*models.LedgerReport)
+	require.True(t, ok)
+	assert.True(t, report.OK())
+
+	server.Stop()
+}
+
+func TestGrantsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	csvBody := []byte("login,amount,reason\naboba,100,Премия за квартал\nbiba,50,Доклад\n")
+
+	response, err := cli.Grant(context.Background(), "text/csv", csvBody, false, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	// Администратор создается из configs/server_config.yml (см. ServerStart)
+	response, err = cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	adminTokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.Grant(context.Background(), "text/csv", csvBody, true, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.GrantDryRunOK, response.Message)
+	report, ok := response.Data.(*models.GrantReport)
+	require.True(t, ok)
+	assert.False(t, report.Applied)
+	assert.Equal(t, 150, report.Total)
+
+	response, err = cli.Grant(context.Background(), "text/csv", []byte("aboba,100,Премия\nnobody,10,Нет такого\nbiba,-5,Минус\n"), false, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidGrantBatchError, response.Message)
+	report, ok = response.Data.(*models.GrantReport)
+	require.True(t, ok)
+	assert.Equal(t, 1, report.Valid)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Equal(t, 2, report.Rows[1].Line)
+	assert.NotEmpty(t, report.Rows[1].Error)
+
+	response, err = cli.Grant(context.Background(), "text/csv", []byte("aboba,\"100,Премия\n"), false, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.GrantCSVError, response.Message)
+
+	response, err = cli.Grant(context.Background(), "application/json", []byte(`{"rows":[]}`), false, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.EmptyGrantBatchError, response.Message)
+
+	response, err = cli.Grant(context.Background(), "text/csv", csvBody, false, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.GrantOK, response.Message)
+	report, ok = response.Data.(*models.GrantReport)
+	require.True(t, ok)
+	assert.True(t, report.Applied)
+	assert.NotZero(t, report.Id)
+
+	response, err = cli.Grant(context.Background(), "application/json", []byte(`{"rows":[{"login":"biba","amount":25,"reason":"Хакатон"}]}`), false, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.CoinsHistory(context.Background(), "", tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	history, ok := response.Data.(*models.Page[models.CoinsEntry])
+	require.True(t, ok)
+	require.Len(t, history.Items, 2)
+	assert.Equal(t, models.ReasonGrant, history.Items[0].Reason)
+	assert.Equal(t, "Хакатон", history.Items[0].Message)
+	assert.Equal(t, models.SignupBonus+75, history.Items[0].CoinsAfter)
+	assert.Equal(t, report.Id, history.Items[1].GrantId)
+
+	response, err = cli.Reconcile(context.Background(), adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	server.Stop()
+}
+
+func TestIdempotencyAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	buy := func(t *testing.T, key string, count int) *ResponseBody {
+		cli.IdempotencyKey = key
+		defer func() { cli.IdempotencyKey = "" }()
+
+		response, err := cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Count: count}, tokens["aboba"])
+		require.NoError(t, err)
+		return response
+	}
+
+	// Повтор с тем же ключом получает исходный ответ, монеты списываются один раз
+	for range 3 {
+		response := buy(t, "buy-1", 1)
+		require.Equal(t, http.StatusOK, response.ErrorCode)
+		assert.Equal(t, 900, response.Data.(*PurchaseEntry).Balance)
+	}
+
+	response := buy(t, "buy-1", 2)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.ErrorCode)
+	assert.Equal(t, handlers.IdempotencyKeyReusedError, response.Message)
+
+	response = buy(t, "ключ", 1)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response = buy(t, "", 1)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, 800, response.Data.(*PurchaseEntry).Balance)
+
+	// Ошибочный ответ тоже сохраняется и повторяется
+	for range 2 {
+		response = buy(t, "buy-too-many", 100)
+		assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+		assert.Equal(t, handlers.NotEnoughMerchError, response.Message)
+	}
+
+	cli.IdempotencyKey = "transfer-1"
+	for range 2 {
+		response, err := cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 100}, tokens["aboba"])
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.ErrorCode)
+	}
+	cli.IdempotencyKey = ""
+
+	response, err := cli.TransferHistory(context.Background(), "", tokens["biba"])
+	require.NoError(t, err)
+	transfers, ok := response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	assert.Len(t, transfers.Items, 1)
+
+	// Тело запроса с ключом читается целиком, поэтому его размер ограничен
+	cli.IdempotencyKey = "buy-huge-body"
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: strings.Repeat("ф", models.MaxIdempotentBodySize), Count: 1}, tokens["aboba"])
+	cli.IdempotencyKey = ""
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.ErrorCode)
+	assert.Equal(t, handlers.RequestTooLargeError, response.Message)
+
+	server.Stop()
+}
+
+func TestCartAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	req := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), req)
+	require.NoError(t, err)
+
+	response, err := cli.GetTokens(context.Background(), req)
+	require.NoError(t, err)
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.Checkout(context.Background(), "", tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.EmptyCartError, response.Message)
+
+	// Повторное добавление увеличивает количество в строке
+	for _, item := range []string{"Футболка", "Кружка", "Футболка"} {
+		response, err = cli.Cart(context.Background(), "POST", "/items", &models.PurchaseRequest{Item: item, Count: 1}, tokens)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.ErrorCode)
+	}
+
+	response, err = cli.Cart(context.Background(), "POST", "/items", &models.PurchaseRequest{Item: "Худи", Count: 1}, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.Cart(context.Background(), "PUT", "/items/2", &models.CartItemRequest{Count: 3}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Cart(context.Background(), "GET", "", nil, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	cart, ok := response.Data.(*models.Cart)
+	require.True(t, ok)
+	require.Len(t, cart.Lines, 2)
+	assert.Equal(t, 2, cart.Lines[0].Count)
+	assert.Equal(t, 200, cart.Lines[0].Subtotal)
+	assert.Equal(t, 290, cart.Total)
+
+	response, err = cli.Cart(context.Background(), "DELETE", "/items/3", nil, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+	assert.Equal(t, handlers.CartItemNotFoundError, response.Message)
+
+	response, err = cli.Checkout(context.Background(), "", tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	result, ok := response.Data.(*models.CheckoutResult)
+	require.True(t, ok)
+	assert.Equal(t, 710, result.Balance)
+	assert.Equal(t, 290, result.Order.Total)
+	assert.Len(t, result.Order.Lines, 2)
+
+	response, err = cli.Cart(context.Background(), "GET", "", nil, tokens)
+	require.NoError(t, err)
+	cart, ok = response.Data.(*models.Cart)
+	require.True(t, ok)
+	assert.Empty(t, cart.Lines)
+
+	server.Stop()
+}
+
+func TestOrdersAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	req := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), req)
+	require.NoError(t, err)
+
+	response, err := cli.GetTokens(context.Background(), req)
+	require.NoError(t, err)
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	adminTokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Count: 1}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Orders(context.Background(), "/orders", tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	orders, ok := response.Data.(*models.Page[models.Order])
+	require.True(t, ok)
+	require.Len(t, orders.Items, 1)
+	orderID := orders.Items[0].Id
+	assert.Equal(t, models.OrderPlaced, orders.Items[0].Status)
+
+	response, err = cli.SetOrderStatus(context.Background(), orderID, models.OrderConfirmed, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	response, err = cli.Orders(context.Background(), "/admin/orders?status=placed", adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	orders, ok = response.Data.(*models.Page[models.Order])
+	require.True(t, ok)
+	require.Len(t, orders.Items, 1)
+	assert.Equal(t, "aboba", orders.Items[0].Login)
+
+	response, err = cli.Orders(context.Background(), "/admin/orders?status=lost", adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response, err = cli.SetOrderStatus(context.Background(), orderID, models.OrderReadyForPickup, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.SetOrderStatus(context.Background(), orderID, models.OrderConfirmed, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.OrderTransitionError, response.Message)
+
+	response, err = cli.Order(context.Background(), orderID, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	order, ok := response.Data.(*models.Order)
+	require.True(t, ok)
+	assert.Equal(t, models.OrderReadyForPickup, order.Status)
+
+	response, err = cli.Order(context.Background(), orderID+1, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.CancelOrder(context.Background(), fmt.Sprintf("/orders/%d/cancel", orderID), tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	order, ok = response.Data.(*models.Order)
+	require.True(t, ok)
+	assert.Equal(t, models.OrderCancelled, order.Status)
+
+	response, err = cli.CancelOrder(context.Background(), fmt.Sprintf("/admin/orders/%d/cancel", orderID), adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+
+	// Возвращенных монет хватает на ту же покупку по полной цене
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Count: 1}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, 900, response.Data.(*PurchaseEntry).Balance)
+
+	server.Stop()
+}
+
+func TestPromotionsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	req := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), req)
+	require.NoError(t, err)
+
+	response, err := cli.GetTokens(context.Background(), req)
+	require.NoError(t, err)
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	adminTokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	promoReq := &models.PromoCodeRequest{Code: "spring10", Kind: models.DiscountPercent, Value: 10, MaxUsesPerUser: 1}
+	response, err = cli.AdminPromo(context.Background(), http.MethodPost, "/promos", promoReq, nil, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	promo := &models.PromoCode{}
+	response, err = cli.AdminPromo(context.Background(), http.MethodPost, "/promos", promoReq, promo, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+	assert.Equal(t, "SPRING10", promo.Code)
+
+	for _, tt := range []struct {
+		req     *models.PromoCodeRequest
+		code    int
+		message string
+	}{
+		{promoReq, http.StatusConflict, handlers.PromoExistsError},
+		{&models.PromoCodeRequest{Code: "HALF", Kind: models.DiscountPercent, Value: 101}, http.StatusBadRequest, handlers.InvalidPromoError},
+		{&models.PromoCodeRequest{Code: "NOPE", Kind: models.DiscountFixed, Value: 5, MerchId: 100}, http.StatusNotFound, handlers.MerchNotFoundError},
+	} {
+		response, err = cli.AdminPromo(context.Background(), http.MethodPost, "/promos", tt.req, nil, adminTokens)
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, response.ErrorCode, tt.req.Code)
+		assert.Equal(t, tt.message, response.Message, tt.req.Code)
+	}
+
+	response, err = cli.AdminPromo(context.Background(), http.MethodPost, "/promos",
+		&models.PromoCodeRequest{Code: "MUG5", Kind: models.DiscountFixed, Value: 5, MerchId: 2}, nil, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+
+	now := time.Now()
+	sale := &models.Sale{}
+	response, err = cli.AdminPromo(context.Background(), http.MethodPost, "/sales",
+		&models.SaleRequest{Name: "Кружки", Kind: models.DiscountFixed, Value: 10, MerchId: 2, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}, sale, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+
+	response, err = cli.Merch(context.Background(), "q="+url.QueryEscape("кружка"), tokens)
+	require.NoError(t, err)
+	page, ok := response.Data.(*models.Page[models.Item])
+	require.True(t, ok)
+	require.Len(t, page.Items, 1)
+	require.NotNil(t, page.Items[0].Sale)
+	assert.Equal(t, 20, page.Items[0].SalePrice)
+
+	// Распродажа и промокод: 30 -> 20 -> 18
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Кружка", Promo: "spring10", Count: 1}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	balance, ok := response.Data.(*PurchaseEntry)
+	require.True(t, ok)
+	assert.Equal(t, 982, balance.Balance)
+
+	for _, tt := range []struct {
+		promo   string
+		code    int
+		message string
+	}{
+		{"SPRING10", http.StatusConflict, handlers.PromoUserLimitError},
+		{"UNKNOWN", http.StatusNotFound, handlers.PromoNotFoundError},
+	} {
+		response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Кружка", Promo: tt.promo, Count: 1}, tokens)
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, response.ErrorCode, tt.promo)
+		assert.Equal(t, tt.message, response.Message, tt.promo)
+	}
+
+	response, err = cli.Cart(context.Background(), "POST", "/items", &models.PurchaseRequest{Item: "Футболка", Count: 1}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Checkout(context.Background(), "MUG5", tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.PromoNotApplicableError, response.Message)
+
+	response, err = cli.Checkout(context.Background(), "", tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	result, ok := response.Data.(*models.CheckoutResult)
+	require.True(t, ok)
+	assert.Equal(t, 882, result.Balance)
+
+	response, err = cli.Orders(context.Background(), "/orders", tokens)
+	require.NoError(t, err)
+	orders, ok := response.Data.(*models.Page[models.Order])
+	require.True(t, ok)
+	require.Len(t, orders.Items, 2)
+	assert.Equal(t, "SPRING10", orders.Items[1].PromoCode)
+	assert.Equal(t, 12, orders.Items[1].Discount)
+	assert.Equal(t, sale.Id, orders.Items[1].Lines[0].SaleId)
+
+	promos := &[]models.PromoCode{}
+	response, err = cli.AdminPromo(context.Background(), http.MethodGet, "/promos", nil, promos, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	require.Len(t, *promos, 2)
+	assert.Equal(t, 1, (*promos)[0].Uses)
+
+	for _, tt := range []struct {
+		path string
+		code int
+	}{
+		{fmt.Sprintf("/promos/%d", promo.Id), http.StatusOK},
+		{fmt.Sprintf("/promos/%d", promo.Id), http.StatusNotFound},
+		{"/promos/abc", http.StatusBadRequest},
+		{fmt.Sprintf("/sales/%d", sale.Id), http.StatusOK},
+		{fmt.Sprintf("/sales/%d", sale.Id), http.StatusNotFound},
+	} {
+		response, err = cli.AdminPromo(context.Background(), http.MethodDelete, tt.path, nil, nil, adminTokens)
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, response.ErrorCode, tt.path)
+	}
+
+	sales := &[]models.Sale{}
+	response, err = cli.AdminPromo(context.Background(), http.MethodGet, "/sales", nil, sales, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Empty(t, *sales)
+
+	server.Stop()
+}
+
+func TestPurchaseLimitsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	req := &models.LoginRequest{Login: "aboba", Password: "123123"}
+	_, err := cli.Register(context.Background(), req)
+	require.NoError(t, err)
+
+	response, err := cli.GetTokens(context.Background(), req)
+	require.NoError(t, err)
+	tokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.GetTokens(context.Background(), &models.LoginRequest{Login: "admin", Password: "adminabobapass"})
+	require.NoError(t, err)
+	adminTokens, ok := response.Data.(*UserTokens)
+	require.True(t, ok, "должны получить токены")
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodPost, "",
+		&models.MerchRequest{Name: "КиберТелефон", Price: 100, Stock: 15, Limits: models.PurchaseLimits{PerOrder: -1}}, adminTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidMerchError, response.Message)
+
+	response, err = cli.AdminMerch(context.Background(), http.MethodPost, "",
+		&models.MerchRequest{Name: "КиберТелефон", Price: 100, Stock: 15, Limits: models.PurchaseLimits{PerUser: 1}}, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, response.ErrorCode)
+	item, ok := response.Data.(*models.Item)
+	require.True(t, ok)
+	assert.Equal(t, 1, item.Limits.PerUser)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "КиберТелефон", Count: 1}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "КиберТелефон", Count: 1}, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.PurchaseLimitError, response.Message)
+
+	response, err = cli.Cart(context.Background(), "POST", "/items", &models.PurchaseRequest{Item: "КиберТелефон", Count: 1}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Checkout(context.Background(), "", tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.PurchaseLimitError, response.Message)
+
+	// Снятый лимит больше не мешает покупке
+	response, err = cli.AdminMerch(context.Background(), http.MethodPatch, fmt.Sprintf("/%d", item.Id),
+		&models.MerchPatchRequest{Limits: &models.PurchaseLimits{}}, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Checkout(context.Background(), "", tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	server.Stop()
+}
+
+func TestWishlistNotificationsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokensOf := func(login, password string) *UserTokens {
+		t.Helper()
+		response, err := cli.GetTokens(context.Background(), &models.LoginRequest{Login: login, Password: password})
+		require.NoError(t, err)
+		tokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		return tokens
+	}
+
+	for _, login := range []string{"aboba", "biba"} {
+		_, err := cli.Register(context.Background(), &models.LoginRequest{Login: login, Password: "123123"})
+		require.NoError(t, err)
+	}
+	tokens := tokensOf("aboba", "123123")
+	otherTokens := tokensOf("biba", "123123")
+	adminTokens := tokensOf("admin", "adminabobapass")
+
+	response, err := cli.Wishlist(context.Background(), http.MethodPut, "/999", &models.WishlistRequest{Notify: true}, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+	assert.Equal(t, handlers.MerchNotFoundError, response.Message)
+
+	response, err = cli.Wishlist(context.Background(), http.MethodPut, "/abc", nil, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	// Кружка (id 2) раскупается, покупатель подписывается на поступление
+	response, err = cli.Wishlist(context.Background(), http.MethodPut, "/2", &models.WishlistRequest{Notify: true}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.WishlistAddOK, response.Message)
+	items, ok := response.Data.([]models.WishlistItem)
+	require.True(t, ok)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Кружка", items[0].Name)
+	assert.True(t, items[0].Notify)
+
+	// Без тела товар добавляется без подписки
+	response, err = cli.Wishlist(context.Background(), http.MethodPut, "/1", nil, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	items, ok = response.Data.([]models.WishlistItem)
+	require.True(t, ok)
+	require.Len(t, items, 2)
+	assert.False(t, items[1].Notify)
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Кружка", Count: 5}, otherTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	stock := 3
+	response, err = cli.AdminMerch(context.Background(), http.MethodPatch, "/2", &models.MerchPatchRequest{Stock: &stock}, adminTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Notifications(context.Background(), "", tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	page, ok := response.Data.(*models.Page[models.Notification])
+	require.True(t, ok)
+	require.Len(t, page.Items, 1)
+	note := page.Items[0]
+	assert.Equal(t, models.NotificationBackInStock, note.Kind)
+	assert.Equal(t, 2, note.MerchId)
+	assert.Equal(t, "Кружка", note.MerchName)
+	assert.Nil(t, note.ReadAt)
+
+	response, err = cli.Notifications(context.Background(), "", otherTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	page, ok = response.Data.(*models.Page[models.Notification])
+	require.True(t, ok)
+	assert.Empty(t, page.Items)
+
+	response, err = cli.Notifications(context.Background(), "limit=1000", tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	// Чужое уведомление не отличается от несуществующего
+	response, err = cli.ReadNotification(context.Background(), note.Id, otherTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+	assert.Equal(t, handlers.NotificationNotFoundError, response.Message)
+
+	response, err = cli.ReadNotification(context.Background(), note.Id, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Notifications(context.Background(), "", tokens)
+	require.NoError(t, err)
+	page, ok = response.Data.(*models.Page[models.Notification])
+	require.True(t, ok)
+	require.Len(t, page.Items, 1)
+	assert.NotNil(t, page.Items[0].ReadAt)
+
+	response, err = cli.Wishlist(context.Background(), http.MethodDelete, "/2", nil, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	items, ok = response.Data.([]models.WishlistItem)
+	require.True(t, ok)
+	require.Len(t, items, 1)
+	assert.Equal(t, 1, items[0].MerchId)
+
+	response, err = cli.Wishlist(context.Background(), http.MethodDelete, "/2", nil, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+	assert.Equal(t, handlers.WishlistItemNotFoundError, response.Message)
+
+	server.Stop()
+}
+
+// TestReservationsAPI - бронь уменьшает доступный остаток, подтверждение оформляет заказ,
+// отмена возвращает монеты, чужая бронь не видна
+func TestReservationsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokensOf := func(login, password string) *UserTokens {
+		t.Helper()
+		response, err := cli.GetTokens(context.Background(), &models.LoginRequest{Login: login, Password: password})
+		require.NoError(t, err)
+		tokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		return tokens
+	}
+
+	for _, login := range []string{"aboba", "biba"} {
+		_, err := cli.Register(context.Background(), &models.LoginRequest{Login: login, Password: "123123"})
+		require.NoError(t, err)
+	}
+	tokens := tokensOf("aboba", "123123")
+	otherTokens := tokensOf("biba", "123123")
+
+	response, err := cli.Reserve(context.Background(), &models.ReservationRequest{Item: "Кружка", Count: 0}, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response, err = cli.Reserve(context.Background(), &models.ReservationRequest{Item: "Нет такого", Count: 1}, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	// Все кружки удержаны: купить нельзя никому
+	response, err = cli.Reserve(context.Background(), &models.ReservationRequest{Item: "Кружка", Count: 5}, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.ReserveOK, response.Message)
+	hold, ok := response.Data.(*models.Reservation)
+	require.True(t, ok)
+	assert.Equal(t, models.ReservationHold, hold.Kind)
+	assert.Equal(t, 150, hold.Total)
+	assert.True(t, hold.ExpiresAt.After(time.Now()))
+
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Кружка", Count: 1}, otherTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.NotEnoughMerchError, response.Message)
+
+	response, err = cli.Reserve(context.Background(), &models.ReservationRequest{Item: "Кружка", Count: 1}, otherTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	// Предзаказ не требует остатка
+	response, err = cli.Reserve(context.Background(), &models.ReservationRequest{Item: "Кружка", Count: 1, Preorder: true}, otherTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	preorder, ok := response.Data.(*models.Reservation)
+	require.True(t, ok)
+	assert.Equal(t, models.ReservationPreorder, preorder.Kind)
+
+	// Чужая бронь не отличается от несуществующей
+	response, err = cli.ConfirmReservation(context.Background(), hold.Id, otherTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+	assert.Equal(t, handlers.ReservationNotFoundError, response.Message)
+
+	response, err = cli.ConfirmReservation(context.Background(), preorder.Id, otherTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.NotEnoughMerchError, response.Message)
+
+	response, err = cli.ConfirmReservation(context.Background(), hold.Id, tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.ReserveConfirmOK, response.Message)
+	order, ok := response.Data.(*models.Order)
+	require.True(t, ok)
+	assert.Equal(t, 150, order.Total)
+	require.Len(t, order.Lines, 1)
+	assert.Equal(t, "Кружка", order.Lines[0].Name)
+
+	response, err = cli.ConfirmReservation(context.Background(), hold.Id, tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.ReservationNotActiveError, response.Message)
+
+	response, err = cli.CancelReservation(context.Background(), preorder.Id, otherTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	cancelled, ok := response.Data.(*models.Reservation)
+	require.True(t, ok)
+	assert.Equal(t, models.ReservationCancelled, cancelled.Status)
+
+	response, err = cli.CancelReservation(context.Background(), 0, otherTokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	// Монеты за предзаказ вернулись: после покупки футболки остается 1000 - 100
+	response, err = cli.Buy(context.Background(), &models.PurchaseRequest{Item: "Футболка", Count: 1}, otherTokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, 900, response.Data.(*PurchaseEntry).Balance)
+
+	response, err = cli.Reservations(context.Background(), "", tokens)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	page, ok := response.Data.(*models.Page[models.Reservation])
+	require.True(t, ok)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.ReservationConfirmed, page.Items[0].Status)
+	assert.Equal(t, order.Id, page.Items[0].OrderId)
+
+	response, err = cli.Reservations(context.Background(), "limit=1000", tokens)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	server.Stop()
+}
+
+func TestTransferHistoryAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	response, err := cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 100}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "aboba", Amount: 40}, tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.TransferHistory(context.Background(), "", tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	transfers, ok := response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 2)
+	assert.Equal(t, "biba", transfers.Items[0].Counterpart)
+	assert.Equal(t, models.TransferReceived, transfers.Items[0].Direction)
+	assert.Equal(t, 40, transfers.Items[0].Amount)
+
+	response, err = cli.TransferHistory(context.Background(), "direction=sent", tokens["aboba"])
+	require.NoError(t, err)
+	transfers, ok = response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 1)
+	assert.Equal(t, 100, transfers.Items[0].Amount)
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	response, err = cli.TransferHistory(context.Background(), "from="+tomorrow, tokens["aboba"])
+	require.NoError(t, err)
+	transfers, ok = response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	assert.Empty(t, transfers.Items)
+
+	// Постраничный обход
+	response, err = cli.TransferHistory(context.Background(), "limit=1", tokens["aboba"])
+	require.NoError(t, err)
+	transfers, ok = response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 1)
+	assert.Equal(t, 40, transfers.Items[0].Amount)
+	require.NotEmpty(t, transfers.NextCursor)
+
+	response, err = cli.TransferHistory(context.Background(), "limit=1&cursor="+transfers.NextCursor, tokens["aboba"])
+	require.NoError(t, err)
+	transfers, ok = response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 1)
+	assert.Equal(t, 100, transfers.Items[0].Amount)
+	assert.Empty(t, transfers.NextCursor)
+
+	response, err = cli.TransferHistory(context.Background(), "limit=1000", tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response, err = cli.TransferHistory(context.Background(), "cursor=abc", tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response, err = cli.TransferHistory(context.Background(), "direction=up", tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidFilterError, response.Message)
+
+	response, err = cli.TransferHistory(context.Background(), "from=yesterday", tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	server.Stop()
+}
+
+// TestTransferNotesAPI - повод и сообщение перевода видны в историях,
+// получатель ставит реакцию и отвечает один раз
+func TestTransferNotesAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	note := models.TransferNote{Category: "bribe"}
+	response, err := cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 50, TransferNote: note}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.TransferCategoryError, response.Message)
+
+	note = models.TransferNote{Message: strings.Repeat("ы", models.MaxTransferMessageLen+1)}
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 50, TransferNote: note}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.TransferMessageError, response.Message)
+
+	note = models.TransferNote{Category: models.TransferQuestReward, Message: "За <b>найденный</b> баг\x00"}
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 50, TransferNote: note}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.TransferHistory(context.Background(), "", tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	transfers, ok := response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 1)
+	transfer := transfers.Items[0]
+	assert.Equal(t, models.TransferQuestReward, transfer.Category)
+	assert.Equal(t, "За <b>найденный</b> баг", transfer.Message)
+
+	response, err = cli.CoinsHistory(context.Background(), "", tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	coins, ok := response.Data.(*models.Page[models.CoinsEntry])
+	require.True(t, ok)
+	require.NotEmpty(t, coins.Items)
+	assert.Equal(t, transfer.Id, coins.Items[0].TransferId)
+	assert.Equal(t, models.TransferQuestReward, coins.Items[0].Category)
+	assert.Equal(t, transfer.Message, coins.Items[0].Message)
+
+	// Реагирует и отвечает только получатель
+	response, err = cli.ReactToTransfer(context.Background(), transfer.Id, models.ReactionParty, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+	assert.Equal(t, handlers.TransferNotFoundError, response.Message)
+
+	response, err = cli.ReactToTransfer(context.Background(), transfer.Id, "fire", tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.TransferReactionError, response.Message)
+
+	response, err = cli.ReactToTransfer(context.Background(), transfer.Id, models.ReactionParty, tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	entry, ok := response.Data.(*models.TransferEntry)
+	require.True(t, ok)
+	assert.Equal(t, models.ReactionParty, entry.Reaction)
+
+	response, err = cli.ReplyToTransfer(context.Background(), transfer.Id, "   ", tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+
+	response, err = cli.ReplyToTransfer(context.Background(), transfer.Id, "Спасибо!", tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	entry, ok = response.Data.(*models.TransferEntry)
+	require.True(t, ok)
+	assert.Equal(t, "Спасибо!", entry.Reply)
+
+	response, err = cli.ReplyToTransfer(context.Background(), transfer.Id, "И еще раз", tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.TransferRepliedError, response.Message)
+
+	response, err = cli.TransferHistory(context.Background(), "direction=sent", tokens["aboba"])
+	require.NoError(t, err)
+	transfers, ok = response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 1)
+	assert.Equal(t, models.ReactionParty, transfers.Items[0].Reaction)
+	assert.Equal(t, "Спасибо!", transfers.Items[0].Reply)
+	assert.NotNil(t, transfers.Items[0].RepliedAt)
+
+	server.Stop()
+}
+
+func TestSchedulesAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	invalid := []*models.ScheduleRequest{
+		{Reciever: "biba", Amount: 100, Rule: "daily", StartAt: start},
+		{Reciever: "biba", Amount: 100, Rule: models.ScheduleWeekly, StartAt: time.Now().Add(-time.Hour)},
+		{Reciever: "biba", Amount: 0, Rule: models.ScheduleWeekly, StartAt: start},
+		{Reciever: "aboba", Amount: 100, Rule: models.ScheduleWeekly, StartAt: start},
+	}
+	for _, req := range invalid {
+		response, err := cli.Schedule(context.Background(), req, tokens["aboba"])
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+		assert.Equal(t, handlers.InvalidScheduleError, response.Message)
+	}
+
+	response, err := cli.Schedule(context.Background(), &models.ScheduleRequest{Reciever: "nobody", Amount: 100, Rule: models.ScheduleWeekly, StartAt: start}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.Schedule(context.Background(), &models.ScheduleRequest{
+		Reciever:     "biba",
+		Amount:       100,
+		Rule:         models.ScheduleMonthly,
+		StartAt:      start,
+		TransferNote: models.TransferNote{Category: models.TransferQuestReward, Message: "Ежемесячная премия"},
+	}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.ScheduleOK, response.Message)
+	st, ok := response.Data.(*models.ScheduledTransfer)
+	require.True(t, ok)
+	assert.Equal(t, "biba", st.Receiver)
+	assert.Equal(t, models.ScheduleActive, st.Status)
+	require.NotNil(t, st.NextRunAt)
+	assert.True(t, st.NextRunAt.Equal(start))
+
+	response, err = cli.Schedules(context.Background(), "", tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	list, ok := response.Data.(*models.Page[models.ScheduledTransfer])
+	require.True(t, ok)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "Ежемесячная премия", list.Items[0].Message)
+
+	// Чужие переводы не видны
+	response, err = cli.Schedules(context.Background(), "", tokens["biba"])
+	require.NoError(t, err)
+	list, ok = response.Data.(*models.Page[models.ScheduledTransfer])
+	require.True(t, ok)
+	assert.Empty(t, list.Items)
+
+	response, err = cli.ScheduleRuns(context.Background(), st.Id, tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.ScheduleRuns(context.Background(), st.Id, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	runs, ok := response.Data.(*models.Page[models.ScheduleRun])
+	require.True(t, ok)
+	assert.Empty(t, runs.Items)
+
+	response, err = cli.CancelSchedule(context.Background(), st.Id, tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.CancelSchedule(context.Background(), st.Id, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.ScheduleCancelOK, response.Message)
+	st, ok = response.Data.(*models.ScheduledTransfer)
+	require.True(t, ok)
+	assert.Equal(t, models.ScheduleCancelled, st.Status)
+	assert.Nil(t, st.NextRunAt)
+
+	response, err = cli.CancelSchedule(context.Background(), st.Id, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.ScheduleNotActiveError, response.Message)
+
+	server.Stop()
+}
+
+func TestCoinRequestsAPI(t *testing.T) {
+	server := ServerStart(t)
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba", "boba"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		_, err := cli.Register(context.Background(), req)
+		require.NoError(t, err)
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	for _, req := range []*models.AskCoinsRequest{{Payer: "biba", Amount: 0}, {Payer: "aboba", Amount: 10}} {
+		response, err := cli.AskCoins(context.Background(), req, tokens["aboba"])
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+		assert.Equal(t, handlers.InvalidCoinRequestError, response.Message)
+	}
+
+	response, err := cli.AskCoins(context.Background(), &models.AskCoinsRequest{Payer: "nobody", Amount: 10}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.AskCoins(context.Background(), &models.AskCoinsRequest{
+		Payer:        "biba",
+		Amount:       200,
+		TransferNote: models.TransferNote{Category: models.TransferBet, Message: "Спор про дедлайн"},
+	}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.CoinAskOK, response.Message)
+	cr, ok := response.Data.(*models.CoinRequest)
+	require.True(t, ok)
+	assert.Equal(t, "biba", cr.Payer)
+	assert.Equal(t, models.CoinRequestPending, cr.Status)
+
+	response, err = cli.AskCoins(context.Background(), &models.AskCoinsRequest{Payer: "biba", Amount: 10}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.CoinRequestExistsError, response.Message)
+
+	response, err = cli.CoinRequests(context.Background(), "direction=received&status=pending", tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	list, ok := response.Data.(*models.Page[models.CoinRequest])
+	require.True(t, ok)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "aboba", list.Items[0].Requester)
+	assert.Equal(t, "Спор про дедлайн", list.Items[0].Message)
+
+	response, err = cli.CoinRequests(context.Background(), "status=paid", tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidCoinRequestFilterError, response.Message)
+
+	response, err = cli.CoinRequest(context.Background(), "GET", cr.Id, "", tokens["boba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.CoinRequest(context.Background(), "POST", cr.Id, "/accept", tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	response, err = cli.CoinRequest(context.Background(), "POST", cr.Id, "/accept", tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.CoinAcceptOK, response.Message)
+	cr, ok = response.Data.(*models.CoinRequest)
+	require.True(t, ok)
+	assert.Equal(t, models.CoinRequestAccepted, cr.Status)
+	assert.NotZero(t, cr.TransferId)
+
+	response, err = cli.CoinRequest(context.Background(), "POST", cr.Id, "/decline", tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.CoinRequestNotPendingError, response.Message)
+
+	response, err = cli.TransferHistory(context.Background(), "direction=received", tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	transfers, ok := response.Data.(*models.Page[models.TransferEntry])
+	require.True(t, ok)
+	require.Len(t, transfers.Items, 1)
+	assert.Equal(t, "biba", transfers.Items[0].Counterpart)
+	assert.Equal(t, 200, transfers.Items[0].Amount)
+	assert.Equal(t, models.TransferBet, transfers.Items[0].Category)
+
+	response, err = cli.AskCoins(context.Background(), &models.AskCoinsRequest{Payer: "aboba", Amount: 5000}, tokens["boba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	big, ok := response.Data.(*models.CoinRequest)
+	require.True(t, ok)
+
+	response, err = cli.CoinRequest(context.Background(), "POST", big.Id, "/accept", tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.NotEnoughCoinsError, response.Message)
+
+	response, err = cli.CoinRequest(context.Background(), "POST", big.Id, "/decline", tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.CoinDeclineOK, response.Message)
+
+	response, err = cli.CoinRequest(context.Background(), "GET", big.Id, "", tokens["boba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	big, ok = response.Data.(*models.CoinRequest)
+	require.True(t, ok)
+	assert.Equal(t, models.CoinRequestDeclined, big.Status)
+
+	server.Stop()
+}
+
+func TestTransferLimitsAPI(t *testing.T) {
+	server := ServerStartWithLimits(t, models.TransferLimits{
+		MaxAmount:      300,
+		Daily:          400,
+		CircularWindow: 86400,
+	})
+	cli := NewClient()
+
+	tokens := make(map[string]*UserTokens)
+	for _, login := range []string{"aboba", "biba", "admin"} {
+		req := &models.LoginRequest{Login: login, Password: "123123"}
+		if login == "admin" {
+			// Администратор создается из configs/server_config.yml (см. ServerStart)
+			req.Password = "adminabobapass"
+		} else {
+			_, err := cli.Register(context.Background(), req)
+			require.NoError(t, err)
+		}
+
+		response, err := cli.GetTokens(context.Background(), req)
+		require.NoError(t, err)
+		userTokens, ok := response.Data.(*UserTokens)
+		require.True(t, ok, "должны получить токены")
+		tokens[login] = userTokens
+	}
+
+	response, err := cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 301}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.TransferTooLargeError, response.Message)
+
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 200}, tokens["aboba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "biba", Amount: 250}, tokens["aboba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, response.ErrorCode)
+	assert.Equal(t, handlers.TransferLimitError, response.Message)
+
+	// Монеты возвращаются отправителю - перевод выполняется, но помечается
+	response, err = cli.Transfer(context.Background(), &models.TransactionRequest{Reciever: "aboba", Amount: 100}, tokens["biba"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+
+	response, err = cli.TransferFlags(context.Background(), "", tokens["biba"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.ErrorCode)
+
+	response, err = cli.TransferFlags(context.Background(), "status=closed", tokens["admin"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidTransferFlagFilterError, response.Message)
+
+	response, err = cli.TransferFlags(context.Background(), "status=open", tokens["admin"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.TransferFlagsOK, response.Message)
+	flags, ok := response.Data.(*models.Page[models.TransferFlag])
+	require.True(t, ok)
+	require.Len(t, flags.Items, 1)
+	flag := flags.Items[0]
+	assert.Equal(t, "biba", flag.Sender)
+	assert.Equal(t, "aboba", flag.Receiver)
+	assert.Equal(t, 100, flag.Amount)
+	assert.Equal(t, []models.TransferFlagReason{models.FlagCircular}, flag.Reasons)
+
+	response, err = cli.ReviewTransferFlag(context.Background(), flag.Id, models.FlagOpen, tokens["admin"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.ErrorCode)
+	assert.Equal(t, handlers.InvalidTransferFlagError, response.Message)
+
+	response, err = cli.ReviewTransferFlag(context.Background(), flag.Id, models.FlagConfirmed, tokens["admin"])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.ErrorCode)
+	assert.Equal(t, handlers.FlagReviewOK, response.Message)
+	reviewed, ok := response.Data.(*models.TransferFlag)
+	require.True(t, ok)
+	assert.Equal(t, models.FlagConfirmed, reviewed.Status)
+	assert.Equal(t, "admin", reviewed.Reviewer)
+
+	response, err = cli.ReviewTransferFlag(context.Background(), flag.Id, models.FlagDismissed, tokens["admin"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.ErrorCode)
+	assert.Equal(t, handlers.TransferFlagClosedError, response.Message)
+
+	response, err = cli.ReviewTransferFlag(context.Background(), 100, models.FlagDismissed, tokens["admin"])
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.ErrorCode)
+
+	server.Stop()
+}
+
 func TestBuyAPI(t *testing.T) {
 	server := ServerStart(t)
 	cli := NewClient()
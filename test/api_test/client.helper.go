@@ -13,6 +13,9 @@ type Client struct {
 	Token        string
 	RefreshToken string
 	HTTPClient   *http.Client
+
+	// IdempotencyKey - если не пустой, передается в заголовке Idempotency-Key
+	IdempotencyKey string
 }
 
 type Credentials struct {
@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"merch_service/internal/models"
+	"mime/multipart"
 	"net/http"
 )
 
@@ -69,6 +71,768 @@ func (c *Client) GetTokens(ctx context.Context, user *models.LoginRequest) (*Res
 	return response, nil
 }
 
+// Refresh обменивает refresh токен на новую пару токенов
+func (c *Client) Refresh(ctx context.Context, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(models.RefreshRequest{Refresh: tokens.Refresh})
+	if err != nil {
+		return nil, err
+	}
+
+	// см. похожий блок в Register
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s/auth/refresh", c.BaseURL),
+		bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	newTokens := &UserTokens{}
+	response, err := c.SendRequest(req, newTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Logout завершает сессию токена tokens.Token
+func (c *Client) Logout(ctx context.Context, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s/auth/logout", c.BaseURL),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, nil)
+}
+
+// Users запрашивает список пользователей (только для администратора)
+func (c *Client) Users(ctx context.Context, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/admin/users", c.BaseURL),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	// При ошибке в data приходит пустой объект, а не список,
+	// поэтому список разбираем только из успешного ответа
+	raw := &json.RawMessage{}
+	response, err := c.SendRequest(req, raw)
+	if err != nil || response.ErrorCode != http.StatusOK {
+		return response, err
+	}
+
+	users := &[]models.UserInfo{}
+	if err := json.Unmarshal(*raw, users); err != nil {
+		return nil, err
+	}
+	response.Data = users
+
+	return response, nil
+}
+
+// SetRole назначает роль пользователю login (только для администратора)
+func (c *Client) SetRole(ctx context.Context, login string, role models.Role, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(models.RoleRequest{Role: role})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT",
+		fmt.Sprintf("%s/admin/users/%s/role", c.BaseURL, login),
+		bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, nil)
+}
+
+// AdminMerch отправляет запрос method на /admin/merch + path
+// (только для администратора). body может быть nil
+func (c *Client) AdminMerch(ctx context.Context, method, path string, body any, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s/admin/merch%s", c.BaseURL, path),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	item := &models.Item{}
+	return c.SendRequest(req, item)
+}
+
+// AdminVariant отправляет запрос method на /admin/merch/{merchID}/variants + path
+// (только для администратора). body может быть nil
+func (c *Client) AdminVariant(ctx context.Context, method string, merchID int, path string, body any, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s/admin/merch/%d/variants%s", c.BaseURL, merchID, path),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	variant := &models.Variant{}
+	return c.SendRequest(req, variant)
+}
+
+// UploadImage загружает изображение data товара merchID формой multipart
+// с файлом в поле image (только для администратора)
+func (c *Client) UploadImage(ctx context.Context, merchID int, data []byte, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	form := multipart.NewWriter(&reqBody)
+
+	part, err := form.CreateFormFile("image", "image")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s/admin/merch/%d/images", c.BaseURL, merchID),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	image := &models.Image{}
+	return c.SendRequest(req, image)
+}
+
+// Media запрашивает файл по URL изображения без авторизации, header - дополнительные
+// заголовки запроса (например, If-None-Match). Возвращает ответ и его тело
+func (c *Client) Media(ctx context.Context, url string, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req = req.WithContext(ctx)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// Reconcile запрашивает сверку журнала монет (только для администратора)
+func (c *Client) Reconcile(ctx context.Context, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/admin/ledger/reconcile", c.BaseURL),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.LedgerReport{})
+}
+
+// Grant отправляет пакет начислений body с типом contentType ("text/csv" или
+// "application/json") на /admin/coins/grants, dryRun - только проверить пакет
+func (c *Client) Grant(ctx context.Context, contentType string, body []byte, dryRun bool, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s/admin/coins/grants?dry_run=%t", c.BaseURL, dryRun),
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+	req.Header.Set("Content-Type", contentType)
+
+	return c.SendRequest(req, &models.GrantReport{})
+}
+
+// Cart отправляет запрос method на /cart + path, например
+// ("GET", "") или ("PUT", "/items/1"). body может быть nil
+func (c *Client) Cart(ctx context.Context, method, path string, body any, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s/cart%s", c.BaseURL, path),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Cart{})
+}
+
+// Checkout оформляет корзину одним заказом. Пустой promo - запрос без тела
+func (c *Client) Checkout(ctx context.Context, promo string, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	if promo != "" {
+		if err := json.NewEncoder(&reqBody).Encode(&models.CheckoutRequest{Promo: promo}); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s/cart/checkout", c.BaseURL),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.CheckoutResult{})
+}
+
+// AdminPromo отправляет запрос method на /admin + path, например
+// ("POST", "/promos") или ("DELETE", "/sales/1") (только для администратора).
+// body может быть nil. Успешный ответ декодируется в v
+func (c *Client) AdminPromo(ctx context.Context, method, path string, body, v any, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s/admin%s", c.BaseURL, path),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	// При ошибке в data приходит пустой объект, а не список (см. Users)
+	raw := &json.RawMessage{}
+	response, err := c.SendRequest(req, raw)
+	if err != nil || response.ErrorCode >= http.StatusBadRequest || v == nil {
+		return response, err
+	}
+
+	if err := json.Unmarshal(*raw, v); err != nil {
+		return nil, err
+	}
+	response.Data = v
+
+	return response, nil
+}
+
+// Wishlist отправляет запрос method на /wishlist + path, например
+// ("GET", "") или ("PUT", "/1"). body может быть nil
+func (c *Client) Wishlist(ctx context.Context, method, path string, body any, tokens *UserTokens) (*ResponseBody, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s/wishlist%s", c.BaseURL, path),
+		&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	// При ошибке в data приходит пустой объект, а не список (см. Users)
+	raw := &json.RawMessage{}
+	response, err := c.SendRequest(req, raw)
+	if err != nil || response.ErrorCode != http.StatusOK {
+		return response, err
+	}
+
+	items := []models.WishlistItem{}
+	if err := json.Unmarshal(*raw, &items); err != nil {
+		return nil, err
+	}
+	response.Data = items
+
+	return response, nil
+}
+
+// Notifications запрашивает страницу уведомлений с query, например "limit=10"
+func (c *Client) Notifications(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/notifications?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.Notification]{})
+}
+
+// ReadNotification отмечает уведомление id прочитанным
+func (c *Client) ReadNotification(ctx context.Context, id int, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notifications/%d/read", c.BaseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, nil)
+}
+
+// Reserve бронирует товар или оформляет предзаказ
+func (c *Client) Reserve(ctx context.Context, resReq *models.ReservationRequest, tokens *UserTokens) (*ResponseBody, error) {
+	resReqBytes, err := json.Marshal(resReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL+"/reservations", bytes.NewBuffer(resReqBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Reservation{})
+}
+
+// Reservations запрашивает страницу броней с параметрами query, например "limit=10"
+func (c *Client) Reservations(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/reservations?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.Reservation]{})
+}
+
+// ConfirmReservation превращает бронь id в заказ
+func (c *Client) ConfirmReservation(ctx context.Context, id int, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/reservations/%d/confirm", c.BaseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Order{})
+}
+
+// CancelReservation отменяет бронь id
+func (c *Client) CancelReservation(ctx context.Context, id int, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/reservations/%d/cancel", c.BaseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Reservation{})
+}
+
+// Orders запрашивает страницу заказов по path, например
+// "/orders?limit=10" или "/admin/orders?status=placed" (только для администратора)
+func (c *Client) Orders(ctx context.Context, path string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	// При ошибке в data приходит пустой объект, а не список (см. Users)
+	raw := &json.RawMessage{}
+	response, err := c.SendRequest(req, raw)
+	if err != nil || response.ErrorCode != http.StatusOK {
+		return response, err
+	}
+
+	orders := &models.Page[models.Order]{}
+	if err := json.Unmarshal(*raw, orders); err != nil {
+		return nil, err
+	}
+	response.Data = orders
+
+	return response, nil
+}
+
+// Merch запрашивает страницу каталога с query, например "q=футболка&sort=price"
+func (c *Client) Merch(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/merch?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	// При ошибке в data приходит пустой объект, а не список (см. Users)
+	raw := &json.RawMessage{}
+	response, err := c.SendRequest(req, raw)
+	if err != nil || response.ErrorCode != http.StatusOK {
+		return response, err
+	}
+
+	items := &models.Page[models.Item]{}
+	if err := json.Unmarshal(*raw, items); err != nil {
+		return nil, err
+	}
+	response.Data = items
+
+	return response, nil
+}
+
+// Order запрашивает заказ пользователя по id
+func (c *Client) Order(ctx context.Context, id int, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/orders/%d", c.BaseURL, id),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Order{})
+}
+
+// SetOrderStatus переводит заказ в статус status (только для администратора)
+func (c *Client) SetOrderStatus(ctx context.Context, id int, status models.OrderStatus, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(&models.OrderStatusRequest{Status: status})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT",
+		fmt.Sprintf("%s/admin/orders/%d/status", c.BaseURL, id),
+		bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Order{})
+}
+
+// CancelOrder отменяет заказ: path "/orders/1/cancel" для покупателя
+// или "/admin/orders/1/cancel" для администратора
+func (c *Client) CancelOrder(ctx context.Context, path string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST", c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Order{})
+}
+
+// Transfer переводит монеты пользователю reciever
+func (c *Client) Transfer(ctx context.Context, transReq *models.TransactionRequest, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(transReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s/coins/transfer", c.BaseURL),
+		bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &TransferEntry{})
+}
+
+// ReactToTransfer ставит реакцию reaction на полученный перевод id
+func (c *Client) ReactToTransfer(ctx context.Context, id int, reaction models.TransferReaction, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(&models.TransferReactionRequest{Reaction: reaction})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/coins/transfer/%d/reaction", c.BaseURL, id), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.TransferEntry{})
+}
+
+// ReplyToTransfer отвечает message на полученный перевод id
+func (c *Client) ReplyToTransfer(ctx context.Context, id int, message string, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(&models.TransferReplyRequest{Message: message})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/coins/transfer/%d/reply", c.BaseURL, id), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.TransferEntry{})
+}
+
+// Schedule планирует перевод монет
+func (c *Client) Schedule(ctx context.Context, schedReq *models.ScheduleRequest, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(schedReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL+"/coins/schedules", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.ScheduledTransfer{})
+}
+
+// Schedules запрашивает страницу запланированных переводов с параметрами query, например "limit=10"
+func (c *Client) Schedules(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/coins/schedules?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.ScheduledTransfer]{})
+}
+
+// ScheduleRuns запрашивает страницу срабатываний запланированного перевода id
+func (c *Client) ScheduleRuns(ctx context.Context, id int, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/coins/schedules/%d/runs", c.BaseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.ScheduleRun]{})
+}
+
+// CancelSchedule отменяет запланированный перевод id
+func (c *Client) CancelSchedule(ctx context.Context, id int, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/coins/schedules/%d/cancel", c.BaseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.ScheduledTransfer{})
+}
+
+// AskCoins отправляет запрос монет у другого пользователя
+func (c *Client) AskCoins(ctx context.Context, askReq *models.AskCoinsRequest, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(askReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL+"/coins/requests", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.CoinRequest{})
+}
+
+// CoinRequests запрашивает страницу запросов монет с параметрами query, например "direction=received&status=pending"
+func (c *Client) CoinRequests(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/coins/requests?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.CoinRequest]{})
+}
+
+// CoinRequest отправляет запрос method на /coins/requests/:id + path, например
+// ("GET", "") или ("POST", "/accept")
+func (c *Client) CoinRequest(ctx context.Context, method string, id int, path string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/coins/requests/%d%s", c.BaseURL, id, path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.CoinRequest{})
+}
+
+// TransferFlags запрашивает страницу пометок подозрительных переводов с параметрами query, например "status=open"
+func (c *Client) TransferFlags(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/admin/transfers/flags?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.TransferFlag]{})
+}
+
+// ReviewTransferFlag закрывает пометку перевода id решением status
+func (c *Client) ReviewTransferFlag(ctx context.Context, id int, status models.TransferFlagStatus, tokens *UserTokens) (*ResponseBody, error) {
+	body, err := json.Marshal(&models.ReviewFlagRequest{Status: status})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/admin/transfers/flags/%d/review", c.BaseURL, id), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.TransferFlag{})
+}
+
+// CoinsHistory запрашивает страницу истории кошелька с параметрами query, например "limit=10"
+func (c *Client) CoinsHistory(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/history/coins?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	return c.SendRequest(req, &models.Page[models.CoinsEntry]{})
+}
+
+// TransferHistory запрашивает страницу истории переводов, query - строка
+// параметров без "?", например "direction=sent&from=2025-01-01&limit=10"
+func (c *Client) TransferHistory(ctx context.Context, query string, tokens *UserTokens) (*ResponseBody, error) {
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/history/transfer?%s", c.BaseURL, query),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tokens.Token)
+
+	// При ошибке в data приходит пустой объект, а не список (см. Users)
+	raw := &json.RawMessage{}
+	response, err := c.SendRequest(req, raw)
+	if err != nil || response.ErrorCode != http.StatusOK {
+		return response, err
+	}
+
+	transfers := &models.Page[models.TransferEntry]{}
+	if err := json.Unmarshal(*raw, transfers); err != nil {
+		return nil, err
+	}
+	response.Data = transfers
+
+	return response, nil
+}
+
 // TODO authorization header
 func (c *Client) Buy(ctx context.Context, purchReq *models.PurchaseRequest, tokens *UserTokens) (*ResponseBody, error) {
 	purchReqBytes, err := json.Marshal(purchReq)
@@ -103,7 +867,13 @@ func (c *Client) Buy(ctx context.Context, purchReq *models.PurchaseRequest, toke
 //
 //	*UserTokens, *MerchList, *HistoryLog ...
 func (c *Client) SendRequest(req *http.Request, v interface{}) (*ResponseBody, error) {
-	req.Header.Set("Content-Type", "application/json")
+	// Тип тела, заданный вызывающим (например, multipart), не перезаписывается
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.IdempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", c.IdempotencyKey)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		// При ошибке resp можно игнорировать (не закрывать)
@@ -1,21 +1,111 @@
 package mock
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"io"
+	"maps"
 	"merch_service/internal/models"
 	"merch_service/internal/storage/entities"
+	"path"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 var (
-	_ entities.MerchStorage       = (*MockMerchStorage)(nil)
-	_ entities.UserStorage        = (*MockUserStorage)(nil)
-	_ entities.TransactionStorage = (*MockTransactionStorage)(nil)
-	_ entities.CoinsStorage       = (*MockCoinsStorage)(nil)
-	_ entities.PurchaseStorage    = (*MockPurchaseStorage)(nil)
+	_ entities.MerchStorage        = (*MockMerchStorage)(nil)
+	_ entities.UserStorage         = (*MockUserStorage)(nil)
+	_ entities.TransactionStorage  = (*MockTransactionStorage)(nil)
+	_ entities.CoinsStorage        = (*MockCoinsStorage)(nil)
+	_ entities.PurchaseStorage     = (*MockPurchaseStorage)(nil)
+	_ entities.TxManager           = (*MockTxManager)(nil)
+	_ entities.LedgerStorage       = (*MockLedgerStorage)(nil)
+	_ entities.IdempotencyStorage  = (*MockIdempotencyStorage)(nil)
+	_ entities.OrderStorage        = (*MockOrderStorage)(nil)
+	_ entities.CartStorage         = (*MockCartStorage)(nil)
+	_ entities.VariantStorage      = (*MockVariantStorage)(nil)
+	_ entities.ImageStorage        = (*MockImageStorage)(nil)
+	_ entities.BlobStorage         = (*MockBlobStorage)(nil)
+	_ entities.PromoStorage        = (*MockPromoStorage)(nil)
+	_ entities.WishlistStorage     = (*MockWishlistStorage)(nil)
+	_ entities.NotificationStorage = (*MockNotificationStorage)(nil)
+	_ entities.ReservationStorage  = (*MockReservationStorage)(nil)
+	_ entities.ScheduleStorage     = (*MockScheduleStorage)(nil)
+	_ entities.GrantStorage        = (*MockGrantStorage)(nil)
+	_ entities.CoinRequestStorage  = (*MockCoinRequestStorage)(nil)
+	_ entities.TransferFlagStorage = (*MockTransferFlagStorage)(nil)
+
+	_ entities.RefreshTokenStorage = (*MockRefreshTokenStorage)(nil)
+	_ entities.SessionStorage      = (*MockSessionStorage)(nil)
 )
 
+// MockTxManager реализация
+// Настоящих транзакций у моков нет, поэтому вызовы WithinTx просто
+// сериализуются мьютексом - это имитирует блокировку строк в PostgreSQL
+type MockTxManager struct {
+	mu sync.Mutex
+}
+
+// mockTxKey - признак того, что контекст уже внутри WithinTx
+type mockTxKey struct{}
+
+func NewMockTxManager() *MockTxManager {
+	return &MockTxManager{}
+}
+
+func (m *MockTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(mockTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(context.WithValue(ctx, mockTxKey{}, true))
+}
+
+// mockPage - страница из записей, упорядоченных от новых к старым,
+// с теми же фильтрами и курсором, что и в хранилищах PostgreSQL.
+// Даты сравниваются с точностью до микросекунд, как в БД и в курсоре
+func mockPage[T any](entries []T, q *models.PageQuery, cursor func(T) *models.Cursor) *models.Page[T] {
+	if q == nil {
+		q = &models.PageQuery{}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = models.DefaultPageLimit
+	}
+
+	list := make([]T, 0)
+	for _, entry := range entries {
+		c := cursor(entry)
+		date := c.Date.Truncate(time.Microsecond)
+
+		if !q.From.IsZero() && date.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !date.Before(q.To) {
+			continue
+		}
+		if q.After != nil && !(date.Before(q.After.Date) || date.Equal(q.After.Date) && c.Id < q.After.Id) {
+			continue
+		}
+
+		list = append(list, entry)
+		if len(list) > limit {
+			break
+		}
+	}
+
+	return models.NewPage(list, limit, cursor)
+}
+
 // MockUserStorage реализация
 type MockUserStorage struct {
 	mu     sync.RWMutex
@@ -38,13 +128,16 @@ func (s *MockUserStorage) Create(ctx context.Context, user *models.User) error {
 		return models.ErrUserExists
 	}
 
-	// Пришлось добавить, для api тестов, чтобы не пересоздавать новые моки
-	if user.Coins == 0 {
-		user.Coins = 1000
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
 	}
 	user.Id = len(s.users) + 1
-	s.users[user.Id] = user
-	s.byName[user.Login] = user
+	stored := *user
+	s.users[user.Id] = &stored
+	s.byName[user.Login] = &stored
 	return nil
 }
 
@@ -56,7 +149,8 @@ func (s *MockUserStorage) Get(ctx context.Context, id int) (*models.User, error)
 	if !exists {
 		return nil, models.ErrUserNotFound
 	}
-	return user, nil
+	copied := *user
+	return &copied, nil
 }
 
 func (s *MockUserStorage) GetByLogin(ctx context.Context, login string) (*models.User, error) {
@@ -67,37 +161,110 @@ func (s *MockUserStorage) GetByLogin(ctx context.Context, login string) (*models
 	if !exists {
 		return nil, models.ErrUserNotFound
 	}
-	return user, nil
+	copied := *user
+	return &copied, nil
+}
+
+// GetByLoginForUpdate - блокировки строк имитирует MockTxManager.
+// Gosched расширяет окно гонки между чтением и записью, как это делает
+// задержка до настоящей БД - без транзакций параллельные покупки ломаются
+func (s *MockUserStorage) GetByLoginForUpdate(ctx context.Context, login string) (*models.User, error) {
+	user, err := s.GetByLogin(ctx, login)
+	runtime.Gosched()
+	return user, err
+}
+
+func (s *MockUserStorage) CountByRoleForUpdate(ctx context.Context, role models.Role) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, user := range s.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
 }
 
 func (s *MockUserStorage) Update(ctx context.Context, user *models.User) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.users[user.Id]; !exists {
+	old, exists := s.users[user.Id]
+	if !exists {
+		return models.ErrUserNotFound
+	}
+
+	stored := *user
+	delete(s.byName, old.Login)
+	s.users[user.Id] = &stored
+	s.byName[user.Login] = &stored
+	return nil
+}
+
+func (s *MockUserStorage) UpdatePassword(ctx context.Context, id int, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return models.ErrUserNotFound
+	}
+
+	user.Password = password
+	return nil
+}
+
+func (s *MockUserStorage) UpdateRole(ctx context.Context, id int, role models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
 		return models.ErrUserNotFound
 	}
 
-	s.users[user.Id] = user
+	user.Role = role
 	return nil
 }
 
+func (s *MockUserStorage) GetList(ctx context.Context) ([]*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.User, 0, len(s.users))
+	for _, user := range s.users {
+		copied := *user
+		list = append(list, &copied)
+	}
+	return list, nil
+}
+
 func (s *MockUserStorage) Delete(ctx context.Context, id int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.users[id]; !exists {
+	user, exists := s.users[id]
+	if !exists {
 		return models.ErrUserNotFound
 	}
 
 	delete(s.users, id)
+	delete(s.byName, user.Login)
 	return nil
 }
 
 // MockMerchStorage реализация
+// Удаленные товары просто убираются из items: как и в MerchPG,
+// они больше не возвращаются, а их id не используются повторно
+// Популярность в каталоге берется из sold (см. SetSold), а не из заказов,
+// in_stock не учитывает варианты
 type MockMerchStorage struct {
-	mu    sync.RWMutex
-	items map[int]*models.Item
+	mu     sync.RWMutex
+	items  map[int]*models.Item
+	sold   map[int]int
+	lastId int
 }
 
 func NewMockMerchStorage() *MockMerchStorage {
@@ -107,15 +274,67 @@ func NewMockMerchStorage() *MockMerchStorage {
 			2: {Id: 2, Name: "Кружка", Price: 30, Stock: 5},
 			3: {Id: 3, Name: "ОченьДорогаяВещь", Price: 100500, Stock: 5},
 		},
+		sold:   make(map[int]int),
+		lastId: 3,
+	}
+}
+
+// SetSold - задает число проданных штук товара id для порядка popularity
+func (s *MockMerchStorage) SetSold(id, sold int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sold[id] = sold
+}
+
+// validateMerch - те же проверки, что и в MerchPG
+func (s *MockMerchStorage) validateMerch(merch *models.Item) error {
+	if merch == nil {
+		return models.ErrEmptyMerch
+	}
+	if merch.Name == "" {
+		return models.ErrEmptyMerchName
+	}
+	if merch.Price < 0 {
+		return models.ErrNegativePrice
+	}
+	if merch.Stock < 0 {
+		return models.ErrNegativeStock
+	}
+	if utf8.RuneCountInString(merch.Category) > models.MaxCategoryLength {
+		return models.ErrCategoryTooLong
+	}
+	if !merch.Limits.Valid() {
+		return models.ErrInvalidLimits
 	}
+	return nil
+}
+
+// nameTaken - проверяет уникальность имени среди товаров, кроме id
+func (s *MockMerchStorage) nameTaken(name string, id int) bool {
+	for _, item := range s.items {
+		if item.Name == name && item.Id != id {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *MockMerchStorage) Create(ctx context.Context, merch *models.Item) error {
+	if err := s.validateMerch(merch); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	merch.Id = len(s.items) + 1
-	s.items[merch.Id] = merch
+	if s.nameTaken(merch.Name, 0) {
+		return models.ErrMerchExists
+	}
+
+	s.lastId++
+	merch.Id = s.lastId
+	stored := *merch
+	s.items[merch.Id] = &stored
 	return nil
 }
 
@@ -127,7 +346,15 @@ func (s *MockMerchStorage) Get(ctx context.Context, id int) (*models.Item, error
 	if !exists {
 		return nil, models.ErrMerchNotFound
 	}
-	return item, nil
+	copied := *item
+	return &copied, nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (s *MockMerchStorage) GetForUpdate(ctx context.Context, id int) (*models.Item, error) {
+	item, err := s.Get(ctx, id)
+	runtime.Gosched()
+	return item, err
 }
 
 func (s *MockMerchStorage) GetByName(ctx context.Context, name string) (*models.Item, error) {
@@ -136,24 +363,121 @@ func (s *MockMerchStorage) GetByName(ctx context.Context, name string) (*models.
 
 	for _, item := range s.items {
 		if item.Name == name {
-			return item, nil
+			copied := *item
+			return &copied, nil
 		}
 	}
 	return nil, models.ErrMerchNotFound
 }
 
+// GetByNameForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (s *MockMerchStorage) GetByNameForUpdate(ctx context.Context, name string) (*models.Item, error) {
+	item, err := s.GetByName(ctx, name)
+	runtime.Gosched()
+	return item, err
+}
+
 func (s *MockMerchStorage) GetList(ctx context.Context) ([]*models.Item, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	list := make([]*models.Item, 0, len(s.items))
 	for _, item := range s.items {
-		list = append(list, item)
+		copied := *item
+		list = append(list, &copied)
 	}
 	return list, nil
 }
 
+// RefreshSearchText - в моках текст для поиска не хранится, а считается при поиске
+func (s *MockMerchStorage) RefreshSearchText(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (s *MockMerchStorage) List(ctx context.Context, filter *models.MerchFilter) (*models.Page[*models.Item], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if filter == nil {
+		filter = &models.MerchFilter{}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = models.DefaultPageLimit
+	}
+
+	list := make([]*models.Item, 0)
+	for _, item := range s.items {
+		if s.matches(item, filter) {
+			copied := *item
+			copied.Sold = s.sold[item.Id]
+			list = append(list, &copied)
+		}
+	}
+
+	compare := func(a, b *models.Item) int {
+		return cmp.Or(merchSortCompare(filter.Sort, a, b), cmp.Compare(a.Id, b.Id))
+	}
+	slices.SortFunc(list, compare)
+
+	if filter.After != nil {
+		// Товар, на котором остановилась прошлая страница, восстанавливается из курсора
+		key, _ := strconv.Atoi(filter.After.Key)
+		after := &models.Item{Id: filter.After.Id, Name: filter.After.Key, Price: key, Sold: key}
+		list = slices.DeleteFunc(list, func(item *models.Item) bool {
+			return compare(item, after) <= 0
+		})
+	}
+
+	if len(list) > limit+1 {
+		list = list[:limit+1]
+	}
+
+	return models.NewPage(list, limit, filter.Sort.Cursor), nil
+}
+
+// matches - те же условия фильтра, что и в MerchPG.List
+func (s *MockMerchStorage) matches(item *models.Item, filter *models.MerchFilter) bool {
+	switch {
+	case filter.Search != "" && !strings.Contains(models.MerchSearchText(item), filter.Search):
+		return false
+	case filter.Category != "" && item.Category != filter.Category:
+		return false
+	case filter.Tag != "" && !slices.Contains(item.Tags, filter.Tag):
+		return false
+	case filter.MinPrice != nil && item.Price < *filter.MinPrice:
+		return false
+	case filter.MaxPrice != nil && item.Price > *filter.MaxPrice:
+		return false
+	case filter.InStock && item.Stock <= 0:
+		return false
+	}
+	return true
+}
+
+// merchSortCompare - сравнение товаров по ключу порядка sort (без учета id)
+func merchSortCompare(sort models.MerchSort, a, b *models.Item) int {
+	switch sort {
+	case models.MerchSortPrice:
+		return cmp.Compare(a.Price, b.Price)
+	case models.MerchSortPriceDesc:
+		return cmp.Compare(b.Price, a.Price)
+	case models.MerchSortName:
+		return cmp.Compare(a.Name, b.Name)
+	case models.MerchSortNameDesc:
+		return cmp.Compare(b.Name, a.Name)
+	case models.MerchSortPopular:
+		return cmp.Compare(b.Sold, a.Sold)
+	}
+	return 0
+}
+
 func (s *MockMerchStorage) Update(ctx context.Context, merch *models.Item) error {
+	if err := s.validateMerch(merch); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -161,7 +485,12 @@ func (s *MockMerchStorage) Update(ctx context.Context, merch *models.Item) error
 		return models.ErrMerchNotFound
 	}
 
-	s.items[merch.Id] = merch
+	if s.nameTaken(merch.Name, merch.Id) {
+		return models.ErrMerchExists
+	}
+
+	stored := *merch
+	s.items[merch.Id] = &stored
 	return nil
 }
 
@@ -181,31 +510,199 @@ func (s *MockMerchStorage) Delete(ctx context.Context, id int) error {
 type MockTransactionStorage struct {
 	mu           sync.RWMutex
 	transactions []models.TransactionEntry
+	logins       map[int]string
 }
 
 func NewMockTransactionStorage() *MockTransactionStorage {
 	return &MockTransactionStorage{
 		transactions: make([]models.TransactionEntry, 0),
+		logins:       make(map[int]string),
 	}
 }
 
-func (s *MockTransactionStorage) Create(ctx context.Context, sender, recv *models.User, amount int) error {
+func (s *MockTransactionStorage) Create(ctx context.Context, sender, recv *models.User, amount int, note models.TransferNote) (int, error) {
+	if !note.Category.Valid() {
+		return 0, models.ErrInvalidTransferCategory
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	id := len(s.transactions) + 1
 	s.transactions = append(s.transactions, models.TransactionEntry{
-		Id:         len(s.transactions) + 1,
-		SenderID:   sender.Id,
-		ReceiverID: recv.Id,
-		Amount:     amount,
+		Id:           id,
+		SenderID:     sender.Id,
+		ReceiverID:   recv.Id,
+		Amount:       amount,
+		Date:         time.Now(),
+		TransferNote: note,
 	})
+	s.logins[sender.Id] = sender.Login
+	s.logins[recv.Id] = recv.Login
+	return id, nil
+}
+
+func (s *MockTransactionStorage) List(ctx context.Context, user *models.User, filter *models.TransferFilter) (*models.Page[*models.TransferEntry], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if filter == nil {
+		filter = &models.TransferFilter{}
+	}
+
+	entries := make([]*models.TransferEntry, 0)
+	// С конца, чтобы новые переводы шли первыми, как в TransactionPG
+	for i := len(s.transactions) - 1; i >= 0; i-- {
+		t := s.transactions[i]
+
+		entry := s.entry(t, user.Id)
+		switch {
+		case entry.Direction == models.TransferSent && filter.Direction != models.TransferReceived:
+		case entry.Direction == models.TransferReceived && filter.Direction != models.TransferSent:
+		default:
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return mockPage(entries, &filter.PageQuery, (*models.TransferEntry).Cursor), nil
+}
+
+// entry - перевод t в истории пользователя userID. Direction пустое, если пользователь не участвует
+func (s *MockTransactionStorage) entry(t models.TransactionEntry, userID int) *models.TransferEntry {
+	entry := &models.TransferEntry{
+		Id:           t.Id,
+		Amount:       t.Amount,
+		Date:         t.Date,
+		TransferNote: t.TransferNote,
+		Reaction:     t.Reaction,
+		Reply:        t.Reply,
+		RepliedAt:    t.RepliedAt,
+	}
+
+	switch userID {
+	case t.SenderID:
+		entry.Direction = models.TransferSent
+		entry.Counterpart = s.logins[t.ReceiverID]
+	case t.ReceiverID:
+		entry.Direction = models.TransferReceived
+		entry.Counterpart = s.logins[t.SenderID]
+	}
+	return entry
+}
+
+func (s *MockTransactionStorage) Get(ctx context.Context, user *models.User, id int) (*models.TransferEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id <= 0 || id > len(s.transactions) {
+		return nil, models.ErrTransferNotFound
+	}
+
+	entry := s.entry(s.transactions[id-1], user.Id)
+	if entry.Direction == "" {
+		return nil, models.ErrTransferNotFound
+	}
+	return entry, nil
+}
+
+func (s *MockTransactionStorage) React(ctx context.Context, id, receiverID int, reaction models.TransferReaction) error {
+	if !reaction.Valid() {
+		return models.ErrInvalidTransferReaction
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id <= 0 || id > len(s.transactions) || s.transactions[id-1].ReceiverID != receiverID {
+		return models.ErrTransferNotFound
+	}
+
+	s.transactions[id-1].Reaction = reaction
+	return nil
+}
+
+func (s *MockTransactionStorage) Reply(ctx context.Context, id, receiverID int, message string) error {
+	if message == "" {
+		return models.ErrEmptyTransferReply
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id <= 0 || id > len(s.transactions) || s.transactions[id-1].ReceiverID != receiverID {
+		return models.ErrTransferNotFound
+	}
+
+	t := &s.transactions[id-1]
+	if t.RepliedAt != nil {
+		return models.ErrTransferReplied
+	}
+
+	now := time.Now()
+	t.Reply = message
+	t.RepliedAt = &now
 	return nil
 }
 
+func (s *MockTransactionStorage) Velocity(ctx context.Context, senderID, receiverID int, at time.Time, smallAmount int) (models.TransferVelocity, error) {
+	var v models.TransferVelocity
+	if senderID <= 0 {
+		return v, models.ErrInvalidSenderID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	day, week := at.Add(-24*time.Hour), at.Add(-7*24*time.Hour)
+	for _, t := range s.transactions {
+		if t.SenderID != senderID || !t.Date.After(week) {
+			continue
+		}
+		v.Weekly += t.Amount
+		if !t.Date.After(day) {
+			continue
+		}
+		v.Daily += t.Amount
+		if t.ReceiverID == receiverID && t.Amount <= smallAmount {
+			v.SmallToReceiver++
+		}
+	}
+	return v, nil
+}
+
+// HasChain - обход в ширину по переводам, сделанным начиная с since
+func (s *MockTransactionStorage) HasChain(ctx context.Context, fromID, toID int, since time.Time, depth int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	level := []int{fromID}
+	seen := map[int]bool{fromID: true}
+	for range depth {
+		var next []int
+		for _, t := range s.transactions {
+			if t.Date.Before(since) || !slices.Contains(level, t.SenderID) {
+				continue
+			}
+			if t.ReceiverID == toID {
+				return true, nil
+			}
+			if !seen[t.ReceiverID] {
+				seen[t.ReceiverID] = true
+				next = append(next, t.ReceiverID)
+			}
+		}
+		level = next
+	}
+	return false, nil
+}
+
 // MockPurchaseStorage реализация
 type MockPurchaseStorage struct {
-	mu    sync.RWMutex
-	purch map[string][]*models.PurchaseEntry
+	mu     sync.RWMutex
+	purch  map[string][]*models.PurchaseEntry
+	lastId int
 }
 
 func NewMockPurchaseStorage() *MockPurchaseStorage {
@@ -214,13 +711,6 @@ func NewMockPurchaseStorage() *MockPurchaseStorage {
 	}
 }
 
-func (p *MockPurchaseStorage) Create(ctx context.Context, currUser *models.User, merch *models.Item, count int) error {
-	p.mu.Lock()
-	p.purch[currUser.Login] = append(p.purch[currUser.Login], &models.PurchaseEntry{ItemName: merch.Name, Count: count, Date: time.Now()})
-	p.mu.Unlock()
-	return nil
-}
-
 func (p *MockPurchaseStorage) Get(ctx context.Context, user *models.User) ([]*models.PurchaseEntry, error) {
 	p.mu.Lock()
 	purchHist, exists := p.purch[user.Login]
@@ -231,31 +721,2122 @@ func (p *MockPurchaseStorage) Get(ctx context.Context, user *models.User) ([]*mo
 	return purchHist, nil
 }
 
-// MockCoinsStorage реализация
-type MockCoinsStorage struct {
-	mu    sync.RWMutex
-	coins map[string][]*models.CoinsEntry
+func (p *MockPurchaseStorage) List(ctx context.Context, user *models.User, q *models.PageQuery) (*models.Page[*models.PurchaseEntry], error) {
+	p.mu.Lock()
+	purchHist := p.purch[user.Login]
+	entries := make([]*models.PurchaseEntry, 0, len(purchHist))
+	for i := len(purchHist) - 1; i >= 0; i-- {
+		entries = append(entries, purchHist[i])
+	}
+	p.mu.Unlock()
+
+	return mockPage(entries, q, (*models.PurchaseEntry).Cursor), nil
 }
 
-func NewMockCoinsStorage() *MockCoinsStorage {
-	return &MockCoinsStorage{
-		coins: make(map[string][]*models.CoinsEntry),
+// MockOrderStorage реализация
+// Строки заказов пишутся в историю MockPurchaseStorage, как в merchshop.purchases,
+// и статус заказа обновляется в этих записях
+type MockOrderStorage struct {
+	mu        sync.Mutex
+	users     *MockUserStorage
+	purchases *MockPurchaseStorage
+	orders    map[int]*models.Order
+	entries   map[int][]*models.PurchaseEntry
+	lastId    int
+}
+
+func NewMockOrderStorage(users *MockUserStorage, purchases *MockPurchaseStorage) *MockOrderStorage {
+	return &MockOrderStorage{
+		users:     users,
+		purchases: purchases,
+		orders:    make(map[int]*models.Order),
+		entries:   make(map[int][]*models.PurchaseEntry),
 	}
 }
 
-func (c *MockCoinsStorage) Create(ctx context.Context, currUser *models.User, oldBalance int) error {
-	c.mu.Lock()
-	c.coins[currUser.Login] = append(c.coins[currUser.Login], &models.CoinsEntry{CoinsBefore: oldBalance, CoinsAfter: currUser.Coins, Id: currUser.Id, Date: time.Now()})
-	c.mu.Unlock()
+func (o *MockOrderStorage) Create(ctx context.Context, order *models.Order) error {
+	if order == nil || len(order.Lines) == 0 {
+		return models.ErrEmptyOrder
+	}
+
+	user, err := o.users.Get(ctx, order.UserId)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.lastId++
+	order.Id = o.lastId
+	order.Status = models.OrderPlaced
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+
+	o.purchases.mu.Lock()
+	defer o.purchases.mu.Unlock()
+	for _, line := range order.Lines {
+		o.purchases.lastId++
+		line.Id = o.purchases.lastId
+		entry := &models.PurchaseEntry{
+			Id:        line.Id,
+			OrderId:   order.Id,
+			ItemName:  line.Name,
+			Variant:   line.Variant,
+			Count:     line.Count,
+			Price:     line.Price,
+			ListPrice: line.ListPrice,
+			PromoCode: order.PromoCode,
+			Status:    order.Status,
+			Date:      order.CreatedAt,
+		}
+		o.purchases.purch[user.Login] = append(o.purchases.purch[user.Login], entry)
+		o.entries[order.Id] = append(o.entries[order.Id], entry)
+	}
+
+	stored := *order
+	stored.Login = user.Login
+	stored.Lines = make([]*models.OrderLine, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		copied := *line
+		stored.Lines = append(stored.Lines, &copied)
+	}
+	o.orders[order.Id] = &stored
 	return nil
 }
 
-func (c *MockCoinsStorage) Get(ctx context.Context, user *models.User) ([]*models.CoinsEntry, error) {
-	c.mu.Lock()
-	coinsHist, exists := c.coins[user.Login]
-	c.mu.Unlock()
+// copyOrder - копия заказа со строками, вызывается под o.mu
+func (o *MockOrderStorage) copyOrder(order *models.Order) *models.Order {
+	copied := *order
+	copied.Lines = make([]*models.OrderLine, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		l := *line
+		copied.Lines = append(copied.Lines, &l)
+	}
+	return &copied
+}
+
+func (o *MockOrderStorage) Get(ctx context.Context, id int) (*models.Order, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidOrderID
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	order, exists := o.orders[id]
 	if !exists {
-		return nil, models.ErrUserNotFound
+		return nil, models.ErrOrderNotFound
 	}
-	return coinsHist, nil
+	return o.copyOrder(order), nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (o *MockOrderStorage) GetForUpdate(ctx context.Context, id int) (*models.Order, error) {
+	order, err := o.Get(ctx, id)
+	runtime.Gosched()
+	return order, err
+}
+
+func (o *MockOrderStorage) List(ctx context.Context, filter *models.OrderFilter) (*models.Page[*models.Order], error) {
+	if filter == nil {
+		filter = &models.OrderFilter{}
+	}
+
+	o.mu.Lock()
+	ids := slices.Sorted(maps.Keys(o.orders))
+	orders := make([]*models.Order, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		order := o.orders[ids[i]]
+		if filter.UserId != 0 && order.UserId != filter.UserId {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		orders = append(orders, o.copyOrder(order))
+	}
+	o.mu.Unlock()
+
+	return mockPage(orders, &filter.PageQuery, (*models.Order).Cursor), nil
+}
+
+func (o *MockOrderStorage) UpdateStatus(ctx context.Context, id int, status models.OrderStatus) error {
+	if id <= 0 {
+		return models.ErrInvalidOrderID
+	}
+	if !status.Valid() {
+		return models.ErrInvalidOrderStatus
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	order, exists := o.orders[id]
+	if !exists {
+		return models.ErrOrderNotFound
+	}
+	order.Status = status
+	order.UpdatedAt = time.Now()
+
+	o.purchases.mu.Lock()
+	defer o.purchases.mu.Unlock()
+	for _, entry := range o.entries[id] {
+		entry.Status = status
+	}
+	return nil
+}
+
+func (o *MockOrderStorage) PromoUses(ctx context.Context, promoID, userID int) (int, int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var total, byUser int
+	for _, order := range o.orders {
+		if order.PromoId != promoID || order.Status == models.OrderCancelled {
+			continue
+		}
+		total++
+		if order.UserId == userID {
+			byUser++
+		}
+	}
+	return total, byUser, nil
+}
+
+func (o *MockOrderStorage) MerchBought(ctx context.Context, userID, merchID int) (int, int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	var total, month int
+	for _, order := range o.orders {
+		if order.UserId != userID || order.Status == models.OrderCancelled {
+			continue
+		}
+		for _, line := range order.Lines {
+			if line.MerchId != merchID {
+				continue
+			}
+			total += line.Count
+			if !order.CreatedAt.Before(monthStart) {
+				month += line.Count
+			}
+		}
+	}
+	return total, month, nil
+}
+
+// Count - число сохраненных заказов (для проверок в тестах)
+func (o *MockOrderStorage) Count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.orders)
+}
+
+// MockVariantStorage реализация
+// Варианты удаленного из MockMerchStorage товара не возвращаются, как в VariantPG
+type MockVariantStorage struct {
+	mu       sync.RWMutex
+	merch    *MockMerchStorage
+	variants map[int]*models.Variant
+	lastId   int
+}
+
+func NewMockVariantStorage(merch *MockMerchStorage) *MockVariantStorage {
+	return &MockVariantStorage{
+		merch:    merch,
+		variants: make(map[int]*models.Variant),
+	}
+}
+
+// validateVariant - те же проверки, что и в VariantPG
+func (s *MockVariantStorage) validateVariant(variant *models.Variant) error {
+	if variant == nil {
+		return models.ErrEmptyVariant
+	}
+	if variant.Sku == "" {
+		return models.ErrEmptyVariantSku
+	}
+	if variant.Price != nil && *variant.Price < 0 {
+		return models.ErrNegativePrice
+	}
+	if variant.Stock < 0 {
+		return models.ErrNegativeStock
+	}
+	return nil
+}
+
+// skuTaken - проверяет уникальность артикула среди вариантов, кроме id
+func (s *MockVariantStorage) skuTaken(sku string, id int) bool {
+	for _, variant := range s.variants {
+		if variant.Sku == sku && variant.Id != id {
+			return true
+		}
+	}
+	return false
+}
+
+// copyVariant - копия варианта, чтобы тесты не меняли хранилище в обход Update
+func copyVariant(variant *models.Variant) *models.Variant {
+	copied := *variant
+	copied.Attributes = maps.Clone(variant.Attributes)
+	if variant.Price != nil {
+		price := *variant.Price
+		copied.Price = &price
+	}
+	return &copied
+}
+
+func (s *MockVariantStorage) Create(ctx context.Context, variant *models.Variant) error {
+	if err := s.validateVariant(variant); err != nil {
+		return err
+	}
+
+	if _, err := s.merch.Get(ctx, variant.MerchId); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.skuTaken(variant.Sku, 0) {
+		return models.ErrVariantExists
+	}
+
+	s.lastId++
+	variant.Id = s.lastId
+	s.variants[variant.Id] = copyVariant(variant)
+	return nil
+}
+
+// visible - вариант, если он есть и его товар не удален
+func (s *MockVariantStorage) visible(ctx context.Context, variant *models.Variant) bool {
+	_, err := s.merch.Get(ctx, variant.MerchId)
+	return err == nil
+}
+
+func (s *MockVariantStorage) Get(ctx context.Context, id int) (*models.Variant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variant, exists := s.variants[id]
+	if !exists || !s.visible(ctx, variant) {
+		return nil, models.ErrVariantNotFound
+	}
+	return copyVariant(variant), nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (s *MockVariantStorage) GetForUpdate(ctx context.Context, id int) (*models.Variant, error) {
+	variant, err := s.Get(ctx, id)
+	runtime.Gosched()
+	return variant, err
+}
+
+func (s *MockVariantStorage) GetBySku(ctx context.Context, sku string) (*models.Variant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, variant := range s.variants {
+		if variant.Sku == sku && s.visible(ctx, variant) {
+			return copyVariant(variant), nil
+		}
+	}
+	return nil, models.ErrVariantNotFound
+}
+
+func (s *MockVariantStorage) List(ctx context.Context, merchIDs []int) ([]*models.Variant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.Variant, 0)
+	for _, id := range slices.Sorted(maps.Keys(s.variants)) {
+		variant := s.variants[id]
+		if slices.Contains(merchIDs, variant.MerchId) && s.visible(ctx, variant) {
+			list = append(list, copyVariant(variant))
+		}
+	}
+
+	slices.SortStableFunc(list, func(a, b *models.Variant) int {
+		return a.MerchId - b.MerchId
+	})
+	return list, nil
+}
+
+func (s *MockVariantStorage) Update(ctx context.Context, variant *models.Variant) error {
+	if err := s.validateVariant(variant); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.variants[variant.Id]
+	if !exists {
+		return models.ErrVariantNotFound
+	}
+
+	if s.skuTaken(variant.Sku, variant.Id) {
+		return models.ErrVariantExists
+	}
+
+	updated := copyVariant(variant)
+	updated.MerchId = stored.MerchId
+	s.variants[variant.Id] = updated
+	return nil
+}
+
+func (s *MockVariantStorage) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.variants[id]; !exists {
+		return models.ErrVariantNotFound
+	}
+
+	delete(s.variants, id)
+	return nil
+}
+
+// MockCartStorage реализация
+// Имя и цена строк берутся из MockMerchStorage и MockVariantStorage в момент чтения
+type MockCartStorage struct {
+	mu       sync.Mutex
+	merch    *MockMerchStorage
+	variants *MockVariantStorage
+	items    map[int]map[cartKey]int // user_id -> (merch_id, variant_id) -> count
+}
+
+// cartKey - строка корзины: товар и вариант (0 - без варианта)
+type cartKey struct {
+	merchID   int
+	variantID int
+}
+
+func NewMockCartStorage(merch *MockMerchStorage, variants *MockVariantStorage) *MockCartStorage {
+	return &MockCartStorage{
+		merch:    merch,
+		variants: variants,
+		items:    make(map[int]map[cartKey]int),
+	}
+}
+
+func (c *MockCartStorage) Get(ctx context.Context, userID int) ([]*models.CartLine, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := slices.SortedFunc(maps.Keys(c.items[userID]), func(a, b cartKey) int {
+		if a.merchID != b.merchID {
+			return a.merchID - b.merchID
+		}
+		return a.variantID - b.variantID
+	})
+	lines := make([]*models.CartLine, 0, len(keys))
+	for _, key := range keys {
+		item, err := c.merch.Get(ctx, key.merchID)
+		if err != nil {
+			continue
+		}
+		line := &models.CartLine{
+			MerchId: item.Id,
+			Name:    item.Name,
+			Price:   item.Price,
+			Count:   c.items[userID][key],
+		}
+		if key.variantID != 0 {
+			variant, err := c.variants.Get(ctx, key.variantID)
+			if err != nil {
+				continue
+			}
+			line.VariantId = variant.Id
+			line.Variant = variant.Sku
+			line.Price = variant.PriceFor(item)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (c *MockCartStorage) GetForUpdate(ctx context.Context, userID int) ([]*models.CartLine, error) {
+	lines, err := c.Get(ctx, userID)
+	runtime.Gosched()
+	return lines, err
+}
+
+func (c *MockCartStorage) Add(ctx context.Context, userID, merchID, variantID, count int) error {
+	return c.upsert(ctx, userID, cartKey{merchID, variantID}, func(old int) int { return old + count })
+}
+
+func (c *MockCartStorage) Set(ctx context.Context, userID, merchID, variantID, count int) error {
+	return c.upsert(ctx, userID, cartKey{merchID, variantID}, func(int) int { return count })
+}
+
+func (c *MockCartStorage) upsert(ctx context.Context, userID int, key cartKey, count func(old int) int) error {
+	if _, err := c.merch.Get(ctx, key.merchID); err != nil {
+		return err
+	}
+
+	if key.variantID != 0 {
+		variant, err := c.variants.Get(ctx, key.variantID)
+		if err != nil {
+			return err
+		}
+		if variant.MerchId != key.merchID {
+			return models.ErrVariantNotFound
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items[userID] == nil {
+		c.items[userID] = make(map[cartKey]int)
+	}
+	c.items[userID][key] = count(c.items[userID][key])
+	return nil
+}
+
+// RemoveDeleted - удаленные товары и варианты в моках не хранятся, поэтому
+// у убранных строк нет имени
+func (c *MockCartStorage) RemoveDeleted(ctx context.Context, userID int) ([]*models.CartLine, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines := make([]*models.CartLine, 0)
+	for key, count := range c.items[userID] {
+		_, merchErr := c.merch.Get(ctx, key.merchID)
+		_, variantErr := c.variants.Get(ctx, key.variantID)
+		if merchErr == nil && (key.variantID == 0 || variantErr == nil) {
+			continue
+		}
+		lines = append(lines, &models.CartLine{MerchId: key.merchID, VariantId: key.variantID, Count: count})
+		delete(c.items[userID], key)
+	}
+	return lines, nil
+}
+
+func (c *MockCartStorage) Remove(ctx context.Context, userID, merchID, variantID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cartKey{merchID, variantID}
+	if _, exists := c.items[userID][key]; !exists {
+		return models.ErrCartItemNotFound
+	}
+	delete(c.items[userID], key)
+	return nil
+}
+
+// MockCoinsStorage реализация
+type MockCoinsStorage struct {
+	mu     sync.RWMutex
+	coins  map[string][]*models.CoinsEntry
+	lastId int
+}
+
+func NewMockCoinsStorage() *MockCoinsStorage {
+	return &MockCoinsStorage{
+		coins: make(map[string][]*models.CoinsEntry),
+	}
+}
+
+func (c *MockCoinsStorage) Create(ctx context.Context, currUser *models.User, oldBalance int) error {
+	c.mu.Lock()
+	c.lastId++
+	c.coins[currUser.Login] = append(c.coins[currUser.Login], &models.CoinsEntry{CoinsBefore: oldBalance, CoinsAfter: currUser.Coins, Id: c.lastId, Date: time.Now()})
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MockCoinsStorage) CreateForOrder(ctx context.Context, currUser *models.User, oldBalance int, reason models.LedgerReason, orderID int) error {
+	c.mu.Lock()
+	c.lastId++
+	c.coins[currUser.Login] = append(c.coins[currUser.Login], &models.CoinsEntry{
+		Id:          c.lastId,
+		Date:        time.Now(),
+		CoinsBefore: oldBalance,
+		CoinsAfter:  currUser.Coins,
+		Reason:      reason,
+		OrderId:     orderID,
+	})
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MockCoinsStorage) CreateForReservation(ctx context.Context, currUser *models.User, oldBalance int, reason models.LedgerReason, reservationID int) error {
+	c.mu.Lock()
+	c.lastId++
+	c.coins[currUser.Login] = append(c.coins[currUser.Login], &models.CoinsEntry{
+		Id:            c.lastId,
+		Date:          time.Now(),
+		CoinsBefore:   oldBalance,
+		CoinsAfter:    currUser.Coins,
+		Reason:        reason,
+		ReservationId: reservationID,
+	})
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MockCoinsStorage) CreateForTransfer(ctx context.Context, currUser *models.User, oldBalance int, transferID int, note models.TransferNote) error {
+	c.mu.Lock()
+	c.lastId++
+	c.coins[currUser.Login] = append(c.coins[currUser.Login], &models.CoinsEntry{
+		Id:           c.lastId,
+		Date:         time.Now(),
+		CoinsBefore:  oldBalance,
+		CoinsAfter:   currUser.Coins,
+		Reason:       models.ReasonTransfer,
+		TransferId:   transferID,
+		TransferNote: note,
+	})
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MockCoinsStorage) CreateForGrant(ctx context.Context, currUser *models.User, oldBalance int, grantID int, row *models.GrantRow) error {
+	c.mu.Lock()
+	c.lastId++
+	c.coins[currUser.Login] = append(c.coins[currUser.Login], &models.CoinsEntry{
+		Id:           c.lastId,
+		Date:         time.Now(),
+		CoinsBefore:  oldBalance,
+		CoinsAfter:   currUser.Coins,
+		Reason:       models.ReasonGrant,
+		GrantId:      grantID,
+		TransferNote: models.TransferNote{Message: row.Reason},
+	})
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MockCoinsStorage) Get(ctx context.Context, user *models.User) ([]*models.CoinsEntry, error) {
+	c.mu.Lock()
+	coinsHist, exists := c.coins[user.Login]
+	c.mu.Unlock()
+	if !exists {
+		return nil, models.ErrUserNotFound
+	}
+	return coinsHist, nil
+}
+
+func (c *MockCoinsStorage) List(ctx context.Context, user *models.User, q *models.PageQuery) (*models.Page[*models.CoinsEntry], error) {
+	c.mu.Lock()
+	coinsHist := c.coins[user.Login]
+	entries := make([]*models.CoinsEntry, 0, len(coinsHist))
+	for i := len(coinsHist) - 1; i >= 0; i-- {
+		entries = append(entries, coinsHist[i])
+	}
+	c.mu.Unlock()
+
+	return mockPage(entries, q, (*models.CoinsEntry).Cursor), nil
+}
+
+// MockLedgerStorage реализация
+// Проводки по счетам пользователей применяются к балансам в MockUserStorage,
+// как LedgerPG меняет merchshop.users.coins
+type MockLedgerStorage struct {
+	mu      sync.RWMutex
+	users   *MockUserStorage
+	entries []*models.JournalEntry
+}
+
+func NewMockLedgerStorage(users *MockUserStorage) *MockLedgerStorage {
+	return &MockLedgerStorage{
+		users:   users,
+		entries: make([]*models.JournalEntry, 0),
+	}
+}
+
+func (l *MockLedgerStorage) Post(ctx context.Context, entry *models.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users.mu.Lock()
+	defer l.users.mu.Unlock()
+
+	// Сначала проверяем все проводки, чтобы запись применилась целиком или никак
+	balances := make(map[int]int)
+	for _, p := range entry.Postings {
+		if p.UserId == 0 {
+			continue
+		}
+		user, exists := l.users.users[p.UserId]
+		if !exists {
+			return models.ErrUserNotFound
+		}
+		if _, seen := balances[p.UserId]; !seen {
+			balances[p.UserId] = user.Coins
+		}
+		balances[p.UserId] += p.Amount
+		if balances[p.UserId] < 0 {
+			return models.ErrNegativeUserCoins
+		}
+	}
+
+	for id, coins := range balances {
+		l.users.users[id].Coins = coins
+	}
+
+	entry.Id = len(l.entries) + 1
+	entry.CreatedAt = time.Now()
+	stored := *entry
+	stored.Postings = append([]models.Posting(nil), entry.Postings...)
+	l.entries = append(l.entries, &stored)
+	return nil
+}
+
+func (l *MockLedgerStorage) Balance(ctx context.Context, userID int) (int, error) {
+	if userID <= 0 {
+		return 0, models.ErrInvalidUserID
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	balance := 0
+	for _, e := range l.entries {
+		for _, p := range e.Postings {
+			if p.UserId == userID {
+				balance += p.Amount
+			}
+		}
+	}
+	return balance, nil
+}
+
+func (l *MockLedgerStorage) Reconcile(ctx context.Context) (*models.LedgerReport, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	l.users.mu.RLock()
+	defer l.users.mu.RUnlock()
+
+	report := &models.LedgerReport{
+		UnbalancedEntries: make([]int, 0),
+		BalanceMismatches: make([]models.BalanceMismatch, 0),
+	}
+
+	ledger := make(map[int]int)
+	for _, e := range l.entries {
+		sum := 0
+		for _, p := range e.Postings {
+			sum += p.Amount
+			if p.UserId != 0 {
+				ledger[p.UserId] += p.Amount
+			}
+		}
+		report.Total += sum
+		if sum != 0 {
+			report.UnbalancedEntries = append(report.UnbalancedEntries, e.Id)
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(l.users.users)) {
+		user := l.users.users[id]
+		if user.Coins == ledger[id] {
+			continue
+		}
+		report.BalanceMismatches = append(report.BalanceMismatches, models.BalanceMismatch{
+			UserId: id,
+			Login:  user.Login,
+			Cached: user.Coins,
+			Ledger: ledger[id],
+		})
+	}
+
+	return report, nil
+}
+
+// MockIdempotencyStorage реализация
+type MockIdempotencyStorage struct {
+	mu   sync.Mutex
+	keys map[int]map[string]*models.IdempotencyRecord
+}
+
+func NewMockIdempotencyStorage() *MockIdempotencyStorage {
+	return &MockIdempotencyStorage{
+		keys: make(map[int]map[string]*models.IdempotencyRecord),
+	}
+}
+
+func (s *MockIdempotencyStorage) Create(ctx context.Context, record *models.IdempotencyRecord, at time.Time) error {
+	if record == nil {
+		return models.ErrEmptyIdempotencyRecord
+	}
+	if record.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userKeys, exists := s.keys[record.UserId]
+	if !exists {
+		userKeys = make(map[string]*models.IdempotencyRecord)
+		s.keys[record.UserId] = userKeys
+	}
+	for key, stored := range userKeys {
+		if !stored.ExpiresAt.After(at) {
+			delete(userKeys, key)
+		}
+	}
+
+	if _, exists := userKeys[record.Key]; exists {
+		return models.ErrIdempotencyKeyExists
+	}
+
+	record.CreatedAt = at
+	stored := *record
+	userKeys[record.Key] = &stored
+	return nil
+}
+
+func (s *MockIdempotencyStorage) Takeover(ctx context.Context, record *models.IdempotencyRecord, at time.Time) error {
+	if record == nil {
+		return models.ErrEmptyIdempotencyRecord
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.keys[record.UserId][record.Key]
+	if !exists || stored.Completed() || stored.LockedUntil.After(at) || !stored.ExpiresAt.After(at) {
+		return models.ErrIdempotencyInProgress
+	}
+	stored.LockedUntil = record.LockedUntil
+	return nil
+}
+
+func (s *MockIdempotencyStorage) Get(ctx context.Context, userID int, key string, at time.Time) (*models.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.keys[userID][key]
+	if !exists || !stored.ExpiresAt.After(at) {
+		return nil, models.ErrIdempotencyNotFound
+	}
+	copied := *stored
+	return &copied, nil
+}
+
+func (s *MockIdempotencyStorage) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	if record == nil {
+		return models.ErrEmptyIdempotencyRecord
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.keys[record.UserId][record.Key]
+	if !exists {
+		return models.ErrIdempotencyNotFound
+	}
+	stored.StatusCode = record.StatusCode
+	stored.Response = record.Response
+	return nil
+}
+
+func (s *MockIdempotencyStorage) Delete(ctx context.Context, userID int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys[userID], key)
+	return nil
+}
+
+// MockRefreshTokenStorage реализация
+type MockRefreshTokenStorage struct {
+	mu     sync.RWMutex
+	tokens map[string]*models.RefreshToken
+}
+
+func NewMockRefreshTokenStorage() *MockRefreshTokenStorage {
+	return &MockRefreshTokenStorage{
+		tokens: make(map[string]*models.RefreshToken),
+	}
+}
+
+func (r *MockRefreshTokenStorage) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *token
+	r.tokens[token.Id] = &stored
+	return nil
+}
+
+func (r *MockRefreshTokenStorage) GetForUpdate(ctx context.Context, id string) (*models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return nil, models.ErrRefreshTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *MockRefreshTokenStorage) MarkUsed(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return models.ErrRefreshTokenNotFound
+	}
+	token.Used = true
+	return nil
+}
+
+func (r *MockRefreshTokenStorage) RevokeFamily(ctx context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.FamilyId == familyID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *MockRefreshTokenStorage) RevokeAllByUser(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.UserId == userID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+// MockSessionStorage - in-memory реализация SessionStorage
+type MockSessionStorage struct {
+	mu       sync.RWMutex
+	sessions map[string]*models.Session
+}
+
+func NewMockSessionStorage() *MockSessionStorage {
+	return &MockSessionStorage{
+		sessions: make(map[string]*models.Session),
+	}
+}
+
+func (s *MockSessionStorage) Create(ctx context.Context, session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.CreatedAt = time.Now()
+	stored := *session
+	s.sessions[session.Id] = &stored
+	return nil
+}
+
+func (s *MockSessionStorage) Get(ctx context.Context, id string) (*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, models.ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (s *MockSessionStorage) ListActive(ctx context.Context, userID int) ([]*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*models.Session
+	for _, session := range s.sessions {
+		if session.UserId == userID && !session.Revoked && session.ExpiresAt.After(time.Now()) {
+			copied := *session
+			list = append(list, &copied)
+		}
+	}
+	return list, nil
+}
+
+func (s *MockSessionStorage) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.FamilyId == familyID {
+			session.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (s *MockSessionStorage) RevokeAllByUser(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.UserId == userID {
+			session.Revoked = true
+		}
+	}
+	return nil
+}
+
+// MockImageStorage реализация
+type MockImageStorage struct {
+	mu     sync.RWMutex
+	merch  *MockMerchStorage
+	images map[int]*models.Image
+	lastId int
+}
+
+func NewMockImageStorage(merch *MockMerchStorage) *MockImageStorage {
+	return &MockImageStorage{
+		merch:  merch,
+		images: make(map[int]*models.Image),
+	}
+}
+
+func (s *MockImageStorage) Create(ctx context.Context, image *models.Image) error {
+	if image == nil {
+		return models.ErrEmptyImage
+	}
+
+	if _, err := s.merch.Get(ctx, image.MerchId); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.images {
+		if stored.Key == image.Key {
+			return models.ErrImageExists
+		}
+	}
+
+	s.lastId++
+	image.Id = s.lastId
+	copied := *image
+	s.images[image.Id] = &copied
+	return nil
+}
+
+func (s *MockImageStorage) Get(ctx context.Context, id int) (*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	image, exists := s.images[id]
+	if !exists {
+		return nil, models.ErrImageNotFound
+	}
+	copied := *image
+	return &copied, nil
+}
+
+func (s *MockImageStorage) List(ctx context.Context, merchIDs []int) ([]*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.Image, 0)
+	for _, id := range slices.Sorted(maps.Keys(s.images)) {
+		if image := s.images[id]; slices.Contains(merchIDs, image.MerchId) {
+			copied := *image
+			list = append(list, &copied)
+		}
+	}
+
+	slices.SortStableFunc(list, func(a, b *models.Image) int {
+		return a.MerchId - b.MerchId
+	})
+	return list, nil
+}
+
+func (s *MockImageStorage) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.images[id]; !exists {
+		return models.ErrImageNotFound
+	}
+
+	delete(s.images, id)
+	return nil
+}
+
+// MockBlobStorage реализация
+// Файлы хранятся в памяти, время записи - время вызова Put
+type MockBlobStorage struct {
+	mu    sync.RWMutex
+	blobs map[string]mockBlob
+}
+
+type mockBlob struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMockBlobStorage() *MockBlobStorage {
+	return &MockBlobStorage{blobs: make(map[string]mockBlob)}
+}
+
+// validKey - те же требования к ключу, что и в local.BlobFS
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../")
+}
+
+func (s *MockBlobStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	if !validKey(key) {
+		return models.ErrInvalidBlobKey
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[key] = mockBlob{data: data, modTime: time.Now().Truncate(time.Second)}
+	return nil
+}
+
+func (s *MockBlobStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	if !validKey(key) {
+		return nil, time.Time{}, models.ErrInvalidBlobKey
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blob, exists := s.blobs[key]
+	if !exists {
+		return nil, time.Time{}, models.ErrBlobNotFound
+	}
+	return nopSeekCloser{bytes.NewReader(blob.data)}, blob.modTime, nil
+}
+
+func (s *MockBlobStorage) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return models.ErrInvalidBlobKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, key)
+	return nil
+}
+
+// Keys - ключи всех файлов хранилища по возрастанию, только для тестов
+func (s *MockBlobStorage) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(s.blobs))
+}
+
+// nopSeekCloser - bytes.Reader с пустым Close
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// MockPromoStorage реализация
+// Удаление мягкое, как в PromoPG: удаленный код можно создать заново
+type MockPromoStorage struct {
+	mu          sync.RWMutex
+	promos      map[int]*models.PromoCode
+	sales       map[int]*models.Sale
+	deleted     map[int]bool
+	deletedSale map[int]bool
+	lastPromoId int
+	lastSaleId  int
+}
+
+func NewMockPromoStorage() *MockPromoStorage {
+	return &MockPromoStorage{
+		promos:      make(map[int]*models.PromoCode),
+		sales:       make(map[int]*models.Sale),
+		deleted:     make(map[int]bool),
+		deletedSale: make(map[int]bool),
+	}
+}
+
+func validateMockWindow(startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return models.ErrInvalidPromoWindow
+	}
+	return nil
+}
+
+func (s *MockPromoStorage) CreatePromo(ctx context.Context, promo *models.PromoCode) error {
+	switch {
+	case promo == nil:
+		return models.ErrEmptyPromo
+	case strings.TrimSpace(promo.Code) == "":
+		return models.ErrEmptyPromoCode
+	case !promo.Discount.Valid():
+		return models.ErrInvalidDiscount
+	case promo.MaxUses < 0 || promo.MaxUsesPerUser < 0:
+		return models.ErrInvalidPromoLimit
+	}
+	if err := validateMockWindow(promo.StartsAt, promo.EndsAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stored := range s.promos {
+		if !s.deleted[id] && stored.Code == promo.Code {
+			return models.ErrPromoExists
+		}
+	}
+
+	s.lastPromoId++
+	promo.Id = s.lastPromoId
+	copied := *promo
+	s.promos[promo.Id] = &copied
+	return nil
+}
+
+func (s *MockPromoStorage) GetPromoByCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, promo := range s.promos {
+		if !s.deleted[id] && promo.Code == code {
+			copied := *promo
+			return &copied, nil
+		}
+	}
+	return nil, models.ErrPromoNotFound
+}
+
+// GetPromoByCodeForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (s *MockPromoStorage) GetPromoByCodeForUpdate(ctx context.Context, code string) (*models.PromoCode, error) {
+	promo, err := s.GetPromoByCode(ctx, code)
+	runtime.Gosched()
+	return promo, err
+}
+
+func (s *MockPromoStorage) ListPromos(ctx context.Context) ([]*models.PromoCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.PromoCode, 0, len(s.promos))
+	for _, id := range slices.Sorted(maps.Keys(s.promos)) {
+		if !s.deleted[id] {
+			copied := *s.promos[id]
+			list = append(list, &copied)
+		}
+	}
+	return list, nil
+}
+
+func (s *MockPromoStorage) DeletePromo(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.promos[id]; !exists || s.deleted[id] {
+		return models.ErrPromoNotFound
+	}
+	s.deleted[id] = true
+	return nil
+}
+
+func (s *MockPromoStorage) CreateSale(ctx context.Context, sale *models.Sale) error {
+	switch {
+	case sale == nil:
+		return models.ErrEmptySale
+	case strings.TrimSpace(sale.Name) == "":
+		return models.ErrEmptySaleName
+	case !sale.Discount.Valid():
+		return models.ErrInvalidDiscount
+	}
+	if err := validateMockWindow(&sale.StartsAt, &sale.EndsAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSaleId++
+	sale.Id = s.lastSaleId
+	copied := *sale
+	s.sales[sale.Id] = &copied
+	return nil
+}
+
+// listSales - неудаленные распродажи, для которых keep возвращает true
+func (s *MockPromoStorage) listSales(keep func(*models.Sale) bool) []*models.Sale {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.Sale, 0, len(s.sales))
+	for _, id := range slices.Sorted(maps.Keys(s.sales)) {
+		if sale := s.sales[id]; !s.deletedSale[id] && keep(sale) {
+			copied := *sale
+			list = append(list, &copied)
+		}
+	}
+	return list
+}
+
+func (s *MockPromoStorage) ListSales(ctx context.Context) ([]*models.Sale, error) {
+	return s.listSales(func(*models.Sale) bool { return true }), nil
+}
+
+func (s *MockPromoStorage) ActiveSales(ctx context.Context, at time.Time) ([]*models.Sale, error) {
+	return s.listSales(func(sale *models.Sale) bool { return sale.ActiveAt(at) }), nil
+}
+
+func (s *MockPromoStorage) DeleteSale(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sales[id]; !exists || s.deletedSale[id] {
+		return models.ErrSaleNotFound
+	}
+	s.deletedSale[id] = true
+	return nil
+}
+
+// MockWishlistStorage реализация. Записи пользователя хранятся в порядке добавления
+type MockWishlistStorage struct {
+	mu      sync.RWMutex
+	merch   *MockMerchStorage
+	entries map[int][]*models.WishlistItem
+}
+
+func NewMockWishlistStorage(merch *MockMerchStorage) *MockWishlistStorage {
+	return &MockWishlistStorage{
+		merch:   merch,
+		entries: make(map[int][]*models.WishlistItem),
+	}
+}
+
+func (s *MockWishlistStorage) Add(ctx context.Context, userID, merchID int, notify bool) error {
+	if _, err := s.merch.Get(ctx, merchID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries[userID] {
+		if entry.MerchId == merchID {
+			entry.Notify = notify
+			return nil
+		}
+	}
+
+	s.entries[userID] = append(s.entries[userID], &models.WishlistItem{
+		MerchId: merchID,
+		Notify:  notify,
+		AddedAt: time.Now(),
+	})
+	return nil
+}
+
+func (s *MockWishlistStorage) Remove(ctx context.Context, userID, merchID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.entries[userID]
+	i := slices.IndexFunc(entries, func(entry *models.WishlistItem) bool { return entry.MerchId == merchID })
+	if i < 0 {
+		return models.ErrWishlistItemNotFound
+	}
+	s.entries[userID] = slices.Delete(entries, i, i+1)
+	return nil
+}
+
+// List - как и в WishlistPG, удаленный из каталога мерч не возвращается
+func (s *MockWishlistStorage) List(ctx context.Context, userID int) ([]*models.WishlistItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.WishlistItem, 0, len(s.entries[userID]))
+	for _, entry := range s.entries[userID] {
+		merch, err := s.merch.Get(ctx, entry.MerchId)
+		if err != nil {
+			continue
+		}
+
+		item := *entry
+		item.Name = merch.Name
+		item.Price = merch.Price
+		item.Stock = merch.Stock
+		list = append(list, &item)
+	}
+	return list, nil
+}
+
+// subscribers - пользователи, подписанные на мерч merchID, по id
+func (s *MockWishlistStorage) subscribers(merchID int) []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]int, 0)
+	for _, userID := range slices.Sorted(maps.Keys(s.entries)) {
+		for _, entry := range s.entries[userID] {
+			if entry.MerchId == merchID && entry.Notify {
+				users = append(users, userID)
+			}
+		}
+	}
+	return users
+}
+
+// MockNotificationStorage реализация
+type MockNotificationStorage struct {
+	mu       sync.Mutex
+	wishlist *MockWishlistStorage
+	users    *MockUserStorage
+	merch    *MockMerchStorage
+	notes    []*models.Notification
+	sent     map[int]bool
+}
+
+func NewMockNotificationStorage(wishlist *MockWishlistStorage, users *MockUserStorage, merch *MockMerchStorage) *MockNotificationStorage {
+	return &MockNotificationStorage{
+		wishlist: wishlist,
+		users:    users,
+		merch:    merch,
+		sent:     make(map[int]bool),
+	}
+}
+
+func (s *MockNotificationStorage) CreateBackInStock(ctx context.Context, merchID int) ([]*models.Notification, error) {
+	merch, err := s.merch.Get(ctx, merchID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := make([]*models.Notification, 0)
+	for _, userID := range s.wishlist.subscribers(merchID) {
+		user, err := s.users.Get(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		note := &models.Notification{
+			Id:        len(s.notes) + 1,
+			UserId:    userID,
+			Login:     user.Login,
+			Kind:      models.NotificationBackInStock,
+			MerchId:   merchID,
+			MerchName: merch.Name,
+			CreatedAt: time.Now(),
+		}
+		s.notes = append(s.notes, note)
+
+		copied := *note
+		created = append(created, &copied)
+	}
+	return created, nil
+}
+
+func (s *MockNotificationStorage) List(ctx context.Context, userID int, q *models.PageQuery) (*models.Page[*models.Notification], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := make([]*models.Notification, 0)
+	for _, note := range slices.Backward(s.notes) {
+		if note.UserId == userID {
+			copied := *note
+			notes = append(notes, &copied)
+		}
+	}
+
+	return mockPage(notes, q, (*models.Notification).Cursor), nil
+}
+
+func (s *MockNotificationStorage) Undelivered(ctx context.Context, limit int) ([]*models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := make([]*models.Notification, 0)
+	for _, note := range s.notes {
+		if len(notes) == limit {
+			break
+		}
+		if !s.sent[note.Id] {
+			copied := *note
+			notes = append(notes, &copied)
+		}
+	}
+	return notes, nil
+}
+
+func (s *MockNotificationStorage) MarkDelivered(ctx context.Context, ids []int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.sent[id] = true
+	}
+	return nil
+}
+
+func (s *MockNotificationStorage) MarkRead(ctx context.Context, userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id <= 0 || id > len(s.notes) || s.notes[id-1].UserId != userID {
+		return models.ErrNotificationNotFound
+	}
+
+	note := s.notes[id-1]
+	if note.ReadAt == nil {
+		now := time.Now()
+		note.ReadAt = &now
+	}
+	return nil
+}
+
+// MockReservationStorage реализация. Брони хранятся в порядке создания, id - индекс + 1.
+// Имя мерча и артикул варианта берутся из брони при создании, а не из каталога
+type MockReservationStorage struct {
+	mu   sync.RWMutex
+	list []*models.Reservation
+}
+
+func NewMockReservationStorage() *MockReservationStorage {
+	return &MockReservationStorage{}
+}
+
+func (s *MockReservationStorage) Create(ctx context.Context, res *models.Reservation) error {
+	if res == nil {
+		return models.ErrEmptyReservation
+	}
+	if res.UserId <= 0 {
+		return models.ErrInvalidUserID
+	}
+	if res.MerchId <= 0 {
+		return models.ErrInvalidMerchID
+	}
+	if res.Count <= 0 {
+		return models.ErrInvalidCount
+	}
+	if res.Price < 0 || res.ListPrice < 0 {
+		return models.ErrNegativePrice
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Id = len(s.list) + 1
+	res.Status = models.ReservationActive
+	res.CreatedAt = time.Now()
+	res.Total = res.Price * res.Count
+
+	copied := *res
+	s.list = append(s.list, &copied)
+	return nil
+}
+
+func (s *MockReservationStorage) Get(ctx context.Context, id int) (*models.Reservation, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidReservationID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id > len(s.list) {
+		return nil, models.ErrReservationNotFound
+	}
+	copied := *s.list[id-1]
+	return &copied, nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (s *MockReservationStorage) GetForUpdate(ctx context.Context, id int) (*models.Reservation, error) {
+	res, err := s.Get(ctx, id)
+	runtime.Gosched()
+	return res, err
+}
+
+func (s *MockReservationStorage) List(ctx context.Context, userID int, q *models.PageQuery) (*models.Page[*models.Reservation], error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.Reservation, 0)
+	for _, res := range slices.Backward(s.list) {
+		if res.UserId == userID {
+			copied := *res
+			list = append(list, &copied)
+		}
+	}
+
+	return mockPage(list, q, (*models.Reservation).Cursor), nil
+}
+
+func (s *MockReservationStorage) Reserved(ctx context.Context, merchID, variantID int, at time.Time) (models.Reserved, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reserved models.Reserved
+	for _, res := range s.list {
+		if res.MerchId != merchID || res.VariantId != variantID || !res.ActiveAt(at) {
+			continue
+		}
+
+		switch res.Kind {
+		case models.ReservationHold:
+			reserved.Holds += res.Count
+		case models.ReservationPreorder:
+			reserved.Preorders += res.Count
+		}
+	}
+	return reserved, nil
+}
+
+func (s *MockReservationStorage) UserReserved(ctx context.Context, userID, merchID int, at time.Time, excludeID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reserved := 0
+	for _, res := range s.list {
+		if res.UserId == userID && res.MerchId == merchID && res.Id != excludeID && res.ActiveAt(at) {
+			reserved += res.Count
+		}
+	}
+	return reserved, nil
+}
+
+func (s *MockReservationStorage) Expired(ctx context.Context, at time.Time, limit int) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int, 0)
+	for _, res := range s.list {
+		if len(ids) >= limit {
+			break
+		}
+		if res.Status == models.ReservationActive && !at.Before(res.ExpiresAt) {
+			ids = append(ids, res.Id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MockReservationStorage) UpdateStatus(ctx context.Context, id int, status models.ReservationStatus, orderID int) error {
+	switch status {
+	case models.ReservationActive, models.ReservationConfirmed, models.ReservationCancelled, models.ReservationExpired:
+	default:
+		return models.ErrInvalidReservationStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id <= 0 || id > len(s.list) {
+		return models.ErrReservationNotFound
+	}
+
+	res := s.list[id-1]
+	res.Status = status
+	res.OrderId = orderID
+	return nil
+}
+
+// MockScheduleStorage реализация. Переводы и срабатывания хранятся в порядке создания,
+// id - индекс + 1. Логин получателя берется из перевода при создании
+type MockScheduleStorage struct {
+	mu        sync.RWMutex
+	schedules []*models.ScheduledTransfer
+	runs      []*models.ScheduleRun
+}
+
+func NewMockScheduleStorage() *MockScheduleStorage {
+	return &MockScheduleStorage{}
+}
+
+func (s *MockScheduleStorage) Create(ctx context.Context, st *models.ScheduledTransfer) error {
+	if st == nil {
+		return models.ErrEmptySchedule
+	}
+	if st.SenderId <= 0 {
+		return models.ErrInvalidSenderID
+	}
+	if st.ReceiverId <= 0 {
+		return models.ErrInvalidReceiverID
+	}
+	if st.SenderId == st.ReceiverId {
+		return models.ErrSameSenderReceiver
+	}
+	if st.Amount <= 0 {
+		return models.ErrInvalidAmount
+	}
+	if !st.Rule.Valid() {
+		return models.ErrInvalidScheduleRule
+	}
+	if !st.Category.Valid() {
+		return models.ErrInvalidTransferCategory
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := st.StartAt.UTC()
+	st.Id = len(s.schedules) + 1
+	st.Status = models.ScheduleActive
+	st.StartAt = next
+	st.NextRunAt = &next
+	st.LastRun = nil
+	st.CreatedAt = time.Now()
+
+	copied := *st
+	s.schedules = append(s.schedules, &copied)
+	return nil
+}
+
+// get - копия перевода с последним срабатыванием, вызывается под мьютексом
+func (s *MockScheduleStorage) get(id int) *models.ScheduledTransfer {
+	copied := *s.schedules[id-1]
+	for _, run := range slices.Backward(s.runs) {
+		if run.ScheduleId == id {
+			last := *run
+			copied.LastRun = &last
+			break
+		}
+	}
+	return &copied
+}
+
+func (s *MockScheduleStorage) Get(ctx context.Context, id int) (*models.ScheduledTransfer, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidScheduleID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id > len(s.schedules) {
+		return nil, models.ErrScheduleNotFound
+	}
+	return s.get(id), nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (s *MockScheduleStorage) GetForUpdate(ctx context.Context, id int) (*models.ScheduledTransfer, error) {
+	st, err := s.Get(ctx, id)
+	runtime.Gosched()
+	return st, err
+}
+
+func (s *MockScheduleStorage) List(ctx context.Context, senderID int, q *models.PageQuery) (*models.Page[*models.ScheduledTransfer], error) {
+	if senderID <= 0 {
+		return nil, models.ErrInvalidSenderID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.ScheduledTransfer, 0)
+	for _, st := range slices.Backward(s.schedules) {
+		if st.SenderId == senderID {
+			list = append(list, s.get(st.Id))
+		}
+	}
+
+	return mockPage(list, q, (*models.ScheduledTransfer).Cursor), nil
+}
+
+func (s *MockScheduleStorage) Due(ctx context.Context, at time.Time, limit int) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	due := make([]*models.ScheduledTransfer, 0)
+	for _, st := range s.schedules {
+		if st.Status == models.ScheduleActive && st.NextRunAt != nil && !st.NextRunAt.After(at) {
+			due = append(due, st)
+		}
+	}
+
+	slices.SortStableFunc(due, func(a, b *models.ScheduledTransfer) int {
+		return a.NextRunAt.Compare(*b.NextRunAt)
+	})
+
+	ids := make([]int, 0, min(len(due), limit))
+	for _, st := range due[:min(len(due), limit)] {
+		ids = append(ids, st.Id)
+	}
+	return ids, nil
+}
+
+func (s *MockScheduleStorage) UpdateStatus(ctx context.Context, id int, status models.ScheduleStatus, nextRunAt *time.Time) error {
+	switch status {
+	case models.ScheduleActive, models.ScheduleCompleted, models.ScheduleCancelled:
+	default:
+		return models.ErrInvalidScheduleStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id <= 0 || id > len(s.schedules) {
+		return models.ErrScheduleNotFound
+	}
+
+	st := s.schedules[id-1]
+	st.Status = status
+	st.NextRunAt = nil
+	if nextRunAt != nil {
+		next := nextRunAt.UTC()
+		st.NextRunAt = &next
+	}
+	return nil
+}
+
+func (s *MockScheduleStorage) CreateRun(ctx context.Context, run *models.ScheduleRun) error {
+	if run == nil {
+		return models.ErrEmptySchedule
+	}
+	if run.ScheduleId <= 0 {
+		return models.ErrInvalidScheduleID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.runs {
+		if existing.ScheduleId == run.ScheduleId && existing.ScheduledFor.Equal(run.ScheduledFor) {
+			return models.ErrScheduleRunExists
+		}
+	}
+
+	run.Id = len(s.runs) + 1
+	run.CreatedAt = time.Now()
+
+	copied := *run
+	s.runs = append(s.runs, &copied)
+	return nil
+}
+
+func (s *MockScheduleStorage) Runs(ctx context.Context, scheduleID int, q *models.PageQuery) (*models.Page[*models.ScheduleRun], error) {
+	if scheduleID <= 0 {
+		return nil, models.ErrInvalidScheduleID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*models.ScheduleRun, 0)
+	for _, run := range slices.Backward(s.runs) {
+		if run.ScheduleId == scheduleID {
+			copied := *run
+			list = append(list, &copied)
+		}
+	}
+
+	return mockPage(list, q, (*models.ScheduleRun).Cursor), nil
+}
+
+// MockGrantStorage реализация. Пакеты хранятся в порядке создания, id - индекс + 1,
+// id строк сквозные по всем пакетам
+type MockGrantStorage struct {
+	mu      sync.RWMutex
+	batches []*models.GrantBatch
+	lastRow int
+}
+
+func NewMockGrantStorage() *MockGrantStorage {
+	return &MockGrantStorage{}
+}
+
+func (g *MockGrantStorage) Create(ctx context.Context, b *models.GrantBatch) error {
+	if b == nil || len(b.Rows) == 0 {
+		return models.ErrEmptyGrantBatch
+	}
+	if b.AdminId <= 0 {
+		return models.ErrInvalidUserID
+	}
+	for _, row := range b.Rows {
+		if row.UserId <= 0 {
+			return models.ErrInvalidUserID
+		}
+		if row.Amount <= 0 {
+			return models.ErrInvalidGrantAmount
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b.Id = len(g.batches) + 1
+	b.CreatedAt = time.Now()
+	for _, row := range b.Rows {
+		g.lastRow++
+		row.Id = g.lastRow
+	}
+	g.batches = append(g.batches, b)
+	return nil
+}
+
+// MockCoinRequestStorage реализация. Запросы хранятся в порядке создания, id - индекс + 1.
+// Логины сторон берутся из запроса при создании
+type MockCoinRequestStorage struct {
+	mu       sync.RWMutex
+	requests []*models.CoinRequest
+}
+
+func NewMockCoinRequestStorage() *MockCoinRequestStorage {
+	return &MockCoinRequestStorage{}
+}
+
+func (c *MockCoinRequestStorage) Create(ctx context.Context, r *models.CoinRequest) error {
+	if r == nil {
+		return models.ErrEmptyCoinRequest
+	}
+	if r.RequesterId <= 0 {
+		return models.ErrInvalidReceiverID
+	}
+	if r.PayerId <= 0 {
+		return models.ErrInvalidSenderID
+	}
+	if r.RequesterId == r.PayerId {
+		return models.ErrSameSenderReceiver
+	}
+	if r.Amount <= 0 {
+		return models.ErrInvalidAmount
+	}
+	if !r.Category.Valid() {
+		return models.ErrInvalidTransferCategory
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r.Id = len(c.requests) + 1
+	r.Status = models.CoinRequestPending
+	r.CreatedAt = time.Now()
+	r.TransferId = 0
+	r.ResolvedAt = nil
+
+	stored := *r
+	c.requests = append(c.requests, &stored)
+	return nil
+}
+
+func (c *MockCoinRequestStorage) Get(ctx context.Context, id int) (*models.CoinRequest, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidCoinRequestID
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if id > len(c.requests) {
+		return nil, models.ErrCoinRequestNotFound
+	}
+	r := *c.requests[id-1]
+	return &r, nil
+}
+
+// GetForUpdate - блокировки строк имитирует MockTxManager (см. MockUserStorage.GetByLoginForUpdate)
+func (c *MockCoinRequestStorage) GetForUpdate(ctx context.Context, id int) (*models.CoinRequest, error) {
+	r, err := c.Get(ctx, id)
+	runtime.Gosched()
+	return r, err
+}
+
+func (c *MockCoinRequestStorage) List(ctx context.Context, userID int, filter *models.CoinRequestFilter, at time.Time) (*models.Page[*models.CoinRequest], error) {
+	if userID <= 0 {
+		return nil, models.ErrInvalidUserID
+	}
+
+	if filter == nil {
+		filter = &models.CoinRequestFilter{}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	list := make([]*models.CoinRequest, 0)
+	for _, stored := range slices.Backward(c.requests) {
+		sent := stored.RequesterId == userID && filter.Direction != models.TransferReceived
+		received := stored.PayerId == userID && filter.Direction != models.TransferSent
+		if !sent && !received {
+			continue
+		}
+
+		r := *stored
+		r.Status = r.StatusAt(at)
+		if filter.Status != "" && r.Status != filter.Status {
+			continue
+		}
+		list = append(list, &r)
+	}
+
+	return mockPage(list, &filter.PageQuery, (*models.CoinRequest).Cursor), nil
+}
+
+func (c *MockCoinRequestStorage) Counts(ctx context.Context, requesterID, payerID int, at, since time.Time) (models.CoinRequestCounts, error) {
+	var counts models.CoinRequestCounts
+	if requesterID <= 0 {
+		return counts, models.ErrInvalidUserID
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, r := range c.requests {
+		if r.RequesterId != requesterID {
+			continue
+		}
+		if r.StatusAt(at) == models.CoinRequestPending {
+			counts.Pending++
+			if r.PayerId == payerID {
+				counts.PendingToPayer++
+			}
+		}
+		if !r.CreatedAt.Before(since) {
+			counts.Created++
+		}
+	}
+	return counts, nil
+}
+
+func (c *MockCoinRequestStorage) Resolve(ctx context.Context, id int, status models.CoinRequestStatus, transferID int) error {
+	if !status.Valid() || status == models.CoinRequestPending {
+		return models.ErrInvalidCoinRequestStatus
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id <= 0 || id > len(c.requests) {
+		return models.ErrCoinRequestNotFound
+	}
+
+	now := time.Now()
+	r := c.requests[id-1]
+	r.Status = status
+	r.TransferId = transferID
+	r.ResolvedAt = &now
+	return nil
+}
+
+// MockTransferFlagStorage реализация. Пометки хранятся в порядке создания, id - индекс + 1.
+// Стороны и сумма перевода берутся из пометки при создании, логин проверяющего - из users
+type MockTransferFlagStorage struct {
+	mu    sync.RWMutex
+	flags []*models.TransferFlag
+	users *MockUserStorage
+}
+
+func NewMockTransferFlagStorage(users *MockUserStorage) *MockTransferFlagStorage {
+	return &MockTransferFlagStorage{users: users}
+}
+
+func (f *MockTransferFlagStorage) Create(ctx context.Context, flag *models.TransferFlag) error {
+	if flag == nil || flag.TransferId <= 0 || len(flag.Reasons) == 0 {
+		return models.ErrEmptyTransferFlag
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	flag.Id = len(f.flags) + 1
+	flag.Status = models.FlagOpen
+	flag.CreatedAt = time.Now()
+	flag.ReviewerId, flag.Reviewer, flag.ReviewedAt = 0, "", nil
+
+	stored := *flag
+	stored.Reasons = slices.Clone(flag.Reasons)
+	f.flags = append(f.flags, &stored)
+	return nil
+}
+
+func (f *MockTransferFlagStorage) Get(ctx context.Context, id int) (*models.TransferFlag, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidTransferFlagID
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if id > len(f.flags) {
+		return nil, models.ErrTransferFlagNotFound
+	}
+	flag := *f.flags[id-1]
+	return &flag, nil
+}
+
+func (f *MockTransferFlagStorage) List(ctx context.Context, filter *models.TransferFlagFilter) (*models.Page[*models.TransferFlag], error) {
+	if filter == nil {
+		filter = &models.TransferFlagFilter{}
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	list := make([]*models.TransferFlag, 0)
+	for _, stored := range slices.Backward(f.flags) {
+		if filter.Status != "" && stored.Status != filter.Status {
+			continue
+		}
+		flag := *stored
+		list = append(list, &flag)
+	}
+
+	return mockPage(list, &filter.PageQuery, (*models.TransferFlag).Cursor), nil
+}
+
+func (f *MockTransferFlagStorage) Review(ctx context.Context, id int, status models.TransferFlagStatus, reviewerID int) error {
+	if !status.Valid() || status == models.FlagOpen {
+		return models.ErrInvalidTransferFlagStatus
+	}
+	if id <= 0 {
+		return models.ErrInvalidTransferFlagID
+	}
+
+	reviewer, err := f.users.Get(ctx, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id > len(f.flags) {
+		return models.ErrTransferFlagNotFound
+	}
+
+	flag := f.flags[id-1]
+	if flag.Status != models.FlagOpen {
+		return models.ErrTransferFlagClosed
+	}
+
+	now := time.Now()
+	flag.Status = status
+	flag.ReviewerId = reviewerID
+	flag.Reviewer = reviewer.Login
+	flag.ReviewedAt = &now
+	return nil
 }
@@ -1,8 +1,20 @@
 package service_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"merch_service/internal/models"
 	"merch_service/internal/service"
@@ -45,7 +57,7 @@ func TestUserServiceRegister(t *testing.T) {
 			userStorage := mock.NewMockUserStorage()
 			purchaseStorage := mock.NewMockPurchaseStorage()
 			coinsStorage := mock.NewMockCoinsStorage()
-			userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage)
+			userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
 
 			// Создаем существующего пользователя для второго теста
 			if tt.wantErr == models.ErrUserExists {
@@ -103,7 +115,7 @@ func TestUserServiceLogin(t *testing.T) {
 			userStorage := mock.NewMockUserStorage()
 			purchaseStorage := mock.NewMockPurchaseStorage()
 			coinsStorage := mock.NewMockCoinsStorage()
-			userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage)
+			userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
 
 			// Create test user
 			userStorage.Create(ctx, &models.User{
@@ -117,6 +129,261 @@ func TestUserServiceLogin(t *testing.T) {
 	}
 }
 
+// TestUserServicePasswordHashing - проверяет хранение паролей в UserService:
+// - при регистрации сохраняется хеш, а не пароль
+// - пароль в открытом виде (до миграции) принимается и перехешируется при входе
+// - при смене параметров хеширования пароль перехешируется при входе
+// - MigratePasswords хеширует все пароли в открытом виде
+func TestUserServicePasswordHashing(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+
+	t.Run("хеш при регистрации", func(t *testing.T) {
+		err := userService.Register(ctx, &models.LoginRequest{Login: "hashed", Password: "secret"})
+		require.NoError(t, err)
+
+		user, err := userStorage.GetByLogin(ctx, "hashed")
+		require.NoError(t, err)
+		assert.NotEqual(t, "secret", user.Password)
+		assert.True(t, strings.HasPrefix(user.Password, "$argon2id$v=19$m=65536,t=1,p=4$"))
+
+		assert.NoError(t, userService.Login(ctx, &models.LoginRequest{Login: "hashed", Password: "secret"}))
+		assert.ErrorIs(t, userService.Login(ctx, &models.LoginRequest{Login: "hashed", Password: "wrong"}), models.ErrWrongPassword)
+	})
+
+	t.Run("перехеширование пароля в открытом виде", func(t *testing.T) {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: "legacy", Password: "plain"}))
+
+		require.NoError(t, userService.Login(ctx, &models.LoginRequest{Login: "legacy", Password: "plain"}))
+
+		user, err := userStorage.GetByLogin(ctx, "legacy")
+		require.NoError(t, err)
+		assert.True(t, userService.Hasher.IsHashed(user.Password))
+		assert.NoError(t, userService.Login(ctx, &models.LoginRequest{Login: "legacy", Password: "plain"}))
+	})
+
+	t.Run("перехеширование при смене параметров", func(t *testing.T) {
+		user, err := userStorage.GetByLogin(ctx, "hashed")
+		require.NoError(t, err)
+		oldHash := user.Password
+
+		params := service.DefaultArgon2Params
+		params.Time = 2
+		userService.Hasher = service.NewArgon2Hasher(params)
+		defer func() { userService.Hasher = service.NewArgon2Hasher(service.DefaultArgon2Params) }()
+
+		require.NoError(t, userService.Login(ctx, &models.LoginRequest{Login: "hashed", Password: "secret"}))
+
+		user, err = userStorage.GetByLogin(ctx, "hashed")
+		require.NoError(t, err)
+		assert.NotEqual(t, oldHash, user.Password)
+		assert.Contains(t, user.Password, "t=2")
+	})
+
+	t.Run("миграция паролей", func(t *testing.T) {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: "legacy2", Password: "plain2"}))
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: "legacy3", Password: "plain3"}))
+
+		migrated, err := userService.MigratePasswords(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, migrated)
+
+		migrated, err = userService.MigratePasswords(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, migrated)
+
+		assert.NoError(t, userService.Login(ctx, &models.LoginRequest{Login: "legacy2", Password: "plain2"}))
+	})
+}
+
+// TestAuthServiceRefresh - проверяет ротацию refresh токенов в AuthService:
+// - обмен токена выдает новый токен того же семейства
+// - повторное использование токена отзывает все семейство
+// - истекший токен отклоняется
+func TestAuthServiceRefresh(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	refreshStorage := mock.NewMockRefreshTokenStorage()
+	sessionStorage := mock.NewMockSessionStorage()
+	authService := service.NewAuthService(refreshStorage, sessionStorage, userStorage, mock.NewMockTxManager())
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "testuser", Password: "password"}))
+
+	t.Run("ротация и повторное использование", func(t *testing.T) {
+		first, err := authService.IssueRefresh(ctx, "testuser", time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "testuser", first.Login)
+
+		second, err := authService.Refresh(ctx, first.Id, time.Hour)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.Id, second.Id)
+		assert.Equal(t, first.FamilyId, second.FamilyId)
+
+		// Украденный первый токен предъявлен повторно
+		_, err = authService.Refresh(ctx, first.Id, time.Hour)
+		assert.ErrorIs(t, err, models.ErrRefreshTokenReused)
+
+		// Теперь отозван и честно полученный второй токен
+		_, err = authService.Refresh(ctx, second.Id, time.Hour)
+		assert.ErrorIs(t, err, models.ErrRefreshTokenRevoked)
+
+		// Другие семейства не затронуты
+		other, err := authService.IssueRefresh(ctx, "testuser", time.Hour)
+		require.NoError(t, err)
+		_, err = authService.Refresh(ctx, other.Id, time.Hour)
+		assert.NoError(t, err)
+	})
+
+	t.Run("истекший токен", func(t *testing.T) {
+		expired, err := authService.IssueRefresh(ctx, "testuser", -time.Minute)
+		require.NoError(t, err)
+
+		_, err = authService.Refresh(ctx, expired.Id, time.Hour)
+		assert.ErrorIs(t, err, models.ErrRefreshTokenExpired)
+	})
+
+	t.Run("неизвестный токен", func(t *testing.T) {
+		_, err := authService.Refresh(ctx, "unknown", time.Hour)
+		assert.ErrorIs(t, err, models.ErrRefreshTokenNotFound)
+	})
+}
+
+// TestAuthServiceSessions - проверяет реестр сессий в AuthService:
+// - новая сессия активна
+// - Logout завершает сессию и refresh токены того же входа, не трогая другие входы
+// - LogoutAll завершает все сессии пользователя
+// - администратор не может завершить сессию, указав чужой логин
+func TestAuthServiceSessions(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	refreshStorage := mock.NewMockRefreshTokenStorage()
+	sessionStorage := mock.NewMockSessionStorage()
+	authService := service.NewAuthService(refreshStorage, sessionStorage, userStorage, mock.NewMockTxManager())
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "testuser", Password: "password"}))
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "other", Password: "password"}))
+
+	login := func(t *testing.T, user string) (*models.RefreshToken, *models.Session) {
+		rt, err := authService.IssueRefresh(ctx, user, time.Hour)
+		require.NoError(t, err)
+		session, err := authService.NewSession(ctx, rt, time.Minute)
+		require.NoError(t, err)
+		return rt, session
+	}
+
+	phoneRT, phone := login(t, "testuser")
+	_, laptop := login(t, "testuser")
+	_, otherSession := login(t, "other")
+
+	assert.NoError(t, authService.CheckSession(ctx, phone.Id))
+	assert.ErrorIs(t, authService.CheckSession(ctx, "unknown"), models.ErrSessionNotFound)
+
+	sessions, err := authService.Sessions(ctx, "testuser")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	t.Run("logout", func(t *testing.T) {
+		require.NoError(t, authService.Logout(ctx, phone.Id))
+
+		assert.ErrorIs(t, authService.CheckSession(ctx, phone.Id), models.ErrSessionRevoked)
+		assert.NoError(t, authService.CheckSession(ctx, laptop.Id))
+
+		_, err := authService.Refresh(ctx, phoneRT.Id, time.Hour)
+		assert.ErrorIs(t, err, models.ErrRefreshTokenRevoked)
+	})
+
+	t.Run("чужая сессия", func(t *testing.T) {
+		err := authService.RevokeSession(ctx, "testuser", otherSession.Id)
+		assert.ErrorIs(t, err, models.ErrSessionNotFound)
+		assert.NoError(t, authService.CheckSession(ctx, otherSession.Id))
+	})
+
+	t.Run("logout-all", func(t *testing.T) {
+		_, tablet := login(t, "testuser")
+		require.NoError(t, authService.LogoutAll(ctx, "testuser"))
+
+		assert.ErrorIs(t, authService.CheckSession(ctx, laptop.Id), models.ErrSessionRevoked)
+		assert.ErrorIs(t, authService.CheckSession(ctx, tablet.Id), models.ErrSessionRevoked)
+		assert.NoError(t, authService.CheckSession(ctx, otherSession.Id))
+
+		sessions, err := authService.Sessions(ctx, "testuser")
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+}
+
+// TestUserServiceRoles - проверяет роли пользователей:
+// - BootstrapAdmin создает администратора и повторно ничего не ломает
+// - BootstrapAdmin не повышает существующего пользователя
+// - BootstrapAdmin не восстанавливает разжалованного администратора
+// - SetRole отклоняет несуществующую роль
+// - SetRole завершает все сессии пользователя
+// - SetRole не снимает роль с последнего администратора
+// - права ролей
+func TestUserServiceRoles(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	sessionStorage := mock.NewMockSessionStorage()
+	userService := service.NewUserService(userStorage, mock.NewMockPurchaseStorage(), mock.NewMockCoinsStorage(), mock.NewMockTransactionStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), sessionStorage, mock.NewMockRefreshTokenStorage())
+
+	require.NoError(t, userService.BootstrapAdmin(ctx, "admin", "adminpass"))
+	require.NoError(t, userService.BootstrapAdmin(ctx, "admin", "adminpass"))
+
+	admin, err := userStorage.GetByLogin(ctx, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, admin.Role)
+	assert.NoError(t, userService.Login(ctx, &models.LoginRequest{Login: "admin", Password: "adminpass"}))
+
+	require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: "boss", Password: "password"}))
+	boss, err := userStorage.GetByLogin(ctx, "boss")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, boss.Role)
+
+	assert.ErrorIs(t, userService.SetRole(ctx, "admin", models.RoleUser), models.ErrLastAdmin)
+
+	// Администраторов не осталось (роль снята в базе вручную)
+	require.NoError(t, userStorage.UpdateRole(ctx, admin.Id, models.RoleUser))
+	require.NoError(t, userService.BootstrapAdmin(ctx, "boss", "bosspass"))
+	require.NoError(t, userService.BootstrapAdmin(ctx, "admin", "adminpass"))
+
+	boss, err = userStorage.GetByLogin(ctx, "boss")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, boss.Role)
+	assert.ErrorIs(t, userService.Login(ctx, &models.LoginRequest{Login: "boss", Password: "bosspass"}), models.ErrWrongPassword)
+
+	admin, err = userStorage.GetByLogin(ctx, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, admin.Role)
+
+	assert.ErrorIs(t, userService.SetRole(ctx, "boss", "superuser"), models.ErrInvalidUserRole)
+	assert.ErrorIs(t, userService.SetRole(ctx, "nobody", models.RoleAdmin), models.ErrUserNotFound)
+
+	require.NoError(t, sessionStorage.Create(ctx, &models.Session{Id: "boss-session", FamilyId: "boss-family", UserId: boss.Id, ExpiresAt: time.Now().UTC().Add(time.Hour)}))
+	require.NoError(t, userService.SetRole(ctx, "boss", models.RoleAdmin))
+	sessions, err := sessionStorage.ListActive(ctx, boss.Id)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	require.NoError(t, userService.BootstrapAdmin(ctx, "root", "rootpass"))
+	_, err = userStorage.GetByLogin(ctx, "root")
+	assert.ErrorIs(t, err, models.ErrUserNotFound)
+
+	require.NoError(t, userService.SetRole(ctx, "admin", models.RoleAdmin))
+	require.NoError(t, userService.SetRole(ctx, "boss", models.RoleUser))
+	assert.ErrorIs(t, userService.SetRole(ctx, "admin", models.RoleUser), models.ErrLastAdmin)
+	require.NoError(t, userService.SetRole(ctx, "admin", models.RoleAdmin))
+
+	users, err := userService.Users(ctx)
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	assert.True(t, models.RoleAdmin.Can(models.PermMerchWrite))
+	assert.False(t, models.RoleUser.Can(models.PermMerchWrite))
+	assert.False(t, models.Role("").Can(models.PermUsersRead))
+}
+
 // TestMerchServiceHistory проверяет добавление в PurchaseHistory и в CoinsHistory после покупки
 func TestMerchServiceHistory(t *testing.T) {
 	ctx := context.Background()
@@ -125,8 +392,11 @@ func TestMerchServiceHistory(t *testing.T) {
 	merchStorage := mock.NewMockMerchStorage()
 	purchaseStorage := mock.NewMockPurchaseStorage()
 	coinsStorage := mock.NewMockCoinsStorage()
-	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage)
-	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
 
 	err := userStorage.Create(ctx, &models.User{
 		Login:    "testuser",
@@ -135,20 +405,20 @@ func TestMerchServiceHistory(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	_, err = merchService.Buy(ctx, "testuser", "Футболка", 2)
+	_, err = merchService.Buy(ctx, "testuser", "Футболка", "", "", 2)
 	assert.NoError(t, err)
 
-	coinsHistory, err := userService.CoinsHistory(ctx, "testuser")
-	assert.NoError(t, err)
-	assert.Len(t, coinsHistory, 1)
-	assert.Equal(t, 1000, coinsHistory[0].CoinsBefore)
-	assert.Equal(t, 800, coinsHistory[0].CoinsAfter)
+	coinsHistory, err := userService.CoinsHistory(ctx, "testuser", nil)
+	require.NoError(t, err)
+	require.Len(t, coinsHistory.Items, 1)
+	assert.Equal(t, 1000, coinsHistory.Items[0].CoinsBefore)
+	assert.Equal(t, 800, coinsHistory.Items[0].CoinsAfter)
 
-	purchaseHistory, err := userService.PurchaseHistory(ctx, "testuser")
-	assert.NoError(t, err)
-	assert.Len(t, purchaseHistory, 1)
-	assert.Equal(t, "Футболка", purchaseHistory[0].ItemName)
-	assert.Equal(t, 2, purchaseHistory[0].Count)
+	purchaseHistory, err := userService.PurchaseHistory(ctx, "testuser", nil)
+	require.NoError(t, err)
+	require.Len(t, purchaseHistory.Items, 1)
+	assert.Equal(t, "Футболка", purchaseHistory.Items[0].ItemName)
+	assert.Equal(t, 2, purchaseHistory.Items[0].Count)
 }
 
 // TestMerchServiceMerchList проверяет корректность возврата списка мерча
@@ -159,21 +429,166 @@ func TestMerchServiceMerchList(t *testing.T) {
 
 	purchaseStorage := mock.NewMockPurchaseStorage()
 	coinsStorage := mock.NewMockCoinsStorage()
-	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage)
+	txManager := mock.NewMockTxManager()
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, mock.NewMockLedgerStorage(userStorage), mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
 
-	items, err := merchService.MerchList(ctx)
-	// Не очень хороший тест, поскольку обход
-	// мапы делается в случайном порядке
-	// Поэтому от запуска к запуску результат меняется
+	page, err := merchService.MerchList(ctx, nil)
 	assert.NoError(t, err)
-	assert.Len(t, items, 3)
-	itemNames := make([]string, 0, len(items))
-	for _, item := range items {
+	assert.Len(t, page.Items, 3)
+	assert.Empty(t, page.NextCursor)
+	itemNames := make([]string, 0, len(page.Items))
+	for _, item := range page.Items {
 		itemNames = append(itemNames, item.Name)
 	}
 	assert.ElementsMatch(t, []string{"Кружка", "Футболка", "ОченьДорогаяВещь"}, itemNames)
 }
 
+// TestMerchServiceCatalogFilter - проверяет фильтры и порядки каталога:
+// - категория и теги приводятся к нижнему регистру при сохранении
+// - поиск по имени и тегам без учета регистра и разницы е/ё
+// - категория, тег, диапазон цены, наличие и порядки с обходом страниц
+// - невалидные порядок, диапазон цены и курсор другого порядка
+func TestMerchServiceCatalogFilter(t *testing.T) {
+	ctx := context.Background()
+	merchStorage := mock.NewMockMerchStorage()
+	userStorage := mock.NewMockUserStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, mock.NewMockCoinsStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+
+	hoodie, err := merchService.CreateMerch(ctx, &models.MerchRequest{
+		Name: "Худи Ёлка", Price: 300, Stock: 0, Category: " Одежда ", Tags: []string{"Тепло", "тепло", " "},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "одежда", hoodie.Category)
+	assert.Equal(t, []string{"тепло"}, hoodie.Tags)
+
+	category := "одежда"
+	_, err = merchService.PatchMerch(ctx, 1, &models.MerchPatchRequest{Category: &category})
+	require.NoError(t, err)
+
+	names := func(filter *models.MerchFilter) []string {
+		page, err := merchService.MerchList(ctx, filter)
+		require.NoError(t, err)
+
+		result := make([]string, 0, len(page.Items))
+		for _, item := range page.Items {
+			result = append(result, item.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Футболка"}, names(&models.MerchFilter{Search: "ФУТБ"}))
+	assert.Equal(t, []string{"Худи Ёлка"}, names(&models.MerchFilter{Search: "елка"}))
+	assert.Equal(t, []string{"Худи Ёлка"}, names(&models.MerchFilter{Search: "ТЁПЛ"}))
+	assert.Equal(t, []string{"Футболка", "Худи Ёлка"}, names(&models.MerchFilter{Category: "ОДЕЖДА"}))
+	assert.Equal(t, []string{"Футболка"}, names(&models.MerchFilter{Category: "одежда", InStock: true}))
+	assert.Equal(t, []string{"Худи Ёлка"}, names(&models.MerchFilter{Tag: "Тепло"}))
+
+	minPrice, maxPrice := 50, 300
+	assert.Equal(t, []string{"Футболка", "Худи Ёлка"}, names(&models.MerchFilter{MinPrice: &minPrice, MaxPrice: &maxPrice}))
+	assert.Equal(t, []string{"Кружка", "ОченьДорогаяВещь", "Футболка", "Худи Ёлка"}, names(&models.MerchFilter{Sort: models.MerchSortName}))
+
+	merchStorage.SetSold(2, 7)
+	merchStorage.SetSold(hoodie.Id, 3)
+	assert.Equal(t, []string{"Кружка", "Худи Ёлка", "Футболка", "ОченьДорогаяВещь"}, names(&models.MerchFilter{Sort: models.MerchSortPopular}))
+
+	// Обход страниц в порядке от дорогих к дешевым
+	var (
+		seen   []string
+		filter = &models.MerchFilter{Sort: models.MerchSortPriceDesc, PageQuery: models.PageQuery{Limit: 3}}
+	)
+	for {
+		page, err := merchService.MerchList(ctx, filter)
+		require.NoError(t, err)
+
+		for _, item := range page.Items {
+			seen = append(seen, item.Name)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		filter.After, err = models.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"ОченьДорогаяВещь", "Худи Ёлка", "Футболка", "Кружка"}, seen)
+
+	_, err = merchService.MerchList(ctx, &models.MerchFilter{Sort: "cheapest"})
+	assert.ErrorIs(t, err, models.ErrInvalidMerchSort)
+
+	_, err = merchService.MerchList(ctx, &models.MerchFilter{MinPrice: &maxPrice, MaxPrice: &minPrice})
+	assert.ErrorIs(t, err, models.ErrInvalidPriceRange)
+
+	page, err := merchService.MerchList(ctx, &models.MerchFilter{Sort: models.MerchSortName, PageQuery: models.PageQuery{Limit: 1}})
+	require.NoError(t, err)
+	after, err := models.DecodeCursor(page.NextCursor)
+	require.NoError(t, err)
+	_, err = merchService.MerchList(ctx, &models.MerchFilter{Sort: models.MerchSortPrice, PageQuery: models.PageQuery{After: after}})
+	assert.ErrorIs(t, err, models.ErrInvalidCursor)
+}
+
+// TestMerchServiceAdmin - проверяет управление каталогом в MerchService:
+// - создание, в том числе с занятым именем и невалидными данными
+// - полная и частичная замена
+// - удаление скрывает мерч из каталога и из покупки
+func TestMerchServiceAdmin(t *testing.T) {
+	ctx := context.Background()
+	merchStorage := mock.NewMockMerchStorage()
+	userStorage := mock.NewMockUserStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, mock.NewMockCoinsStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+
+	item, err := merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Худи", Price: 300, Stock: 3})
+	require.NoError(t, err)
+	assert.NotZero(t, item.Id)
+
+	_, err = merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Кружка", Price: 10, Stock: 1})
+	assert.ErrorIs(t, err, models.ErrMerchExists)
+
+	_, err = merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Шапка", Price: -1, Stock: 1})
+	assert.ErrorIs(t, err, models.ErrNegativePrice)
+
+	replaced, err := merchService.ReplaceMerch(ctx, item.Id, &models.MerchRequest{Name: "Худи v2", Price: 350, Stock: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "Худи v2", replaced.Name)
+
+	_, err = merchService.ReplaceMerch(ctx, item.Id, &models.MerchRequest{Name: "Кружка", Price: 350, Stock: 4})
+	assert.ErrorIs(t, err, models.ErrMerchExists)
+
+	_, err = merchService.ReplaceMerch(ctx, 9999, &models.MerchRequest{Name: "Нет", Price: 1, Stock: 1})
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	price := 400
+	patched, err := merchService.PatchMerch(ctx, item.Id, &models.MerchPatchRequest{Price: &price})
+	require.NoError(t, err)
+	assert.Equal(t, "Худи v2", patched.Name)
+	assert.Equal(t, 400, patched.Price)
+	assert.Equal(t, 4, patched.Stock)
+
+	stock := -1
+	_, err = merchService.PatchMerch(ctx, item.Id, &models.MerchPatchRequest{Stock: &stock})
+	assert.ErrorIs(t, err, models.ErrNegativeStock)
+
+	require.NoError(t, merchService.DeleteMerch(ctx, item.Id))
+	assert.ErrorIs(t, merchService.DeleteMerch(ctx, item.Id), models.ErrMerchNotFound)
+
+	page, err := merchService.MerchList(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 3)
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "buyer", Password: "password"}))
+	_, err = merchService.Buy(ctx, "buyer", "Худи v2", "", "", 1)
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	// Имя удаленного мерча можно использовать снова
+	_, err = merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Худи v2", Price: 1, Stock: 1})
+	assert.NoError(t, err)
+}
+
 // -------------------------- Проверка транзакций -----------------------------
 
 // TestMerchServiceBuy - проверяет следующие сценарии работы метода Buy в MerchService:
@@ -245,12 +660,14 @@ func TestMerchServiceBuy(t *testing.T) {
 			merchStorage := mock.NewMockMerchStorage()
 			purchaseStorage := mock.NewMockPurchaseStorage()
 			coinsStorage := mock.NewMockCoinsStorage()
-			merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage)
+			txManager := mock.NewMockTxManager()
+			variantStorage := mock.NewMockVariantStorage(merchStorage)
+			merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, mock.NewMockLedgerStorage(userStorage), mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
 
 			tt.setupUser(userStorage)
 			tt.setupMerch(merchStorage)
 
-			coins, err := merchService.Buy(ctx, tt.userLogin, tt.merchName, tt.count)
+			coins, err := merchService.Buy(ctx, tt.userLogin, tt.merchName, "", "", tt.count)
 			assert.ErrorIs(t, err, tt.wantErr)
 			if tt.wantErr == nil {
 				assert.Equal(t, tt.wantCoins, coins)
@@ -264,140 +681,2522 @@ func TestMerchServiceBuy(t *testing.T) {
 	}
 }
 
-// TestTransactionServiceSend - проверяет метод Send в TransactionService на следующщие сценарии:
-// - успешный перевод
-// - отправителя нет в базе данных
-// - недостаточно средств у отправителя
-// - перевод самому себе
-// - перевод отрицательной суммы
-func TestTransactionServiceSend(t *testing.T) {
+// TestMerchServiceBuyParallelAccounting - параллельные покупки одного товара
+// на моках. MockTxManager выполняет транзакции по очереди, поэтому тест проверяет
+// только учет в сервисе, а не блокировки строк (см. storagetest TestMerchPGBuyConcurrent):
+// - товар не продается сверх остатка на складе
+// - монеты списаны ровно за совершенные покупки
+// - история кошелька и покупок согласована с балансами
+func TestMerchServiceBuyParallelAccounting(t *testing.T) {
 	ctx := context.Background()
 
-	testCases := []struct {
-		name              string
-		senderLogin       string
-		receiverLogin     string
-		amount            int
-		prepare           func(*mock.MockUserStorage)
-		wantErr           error
-		wantSenderCoins   int // -1 означает не проверять
-		wantReceiverCoins int // -1 означает не проверять
-	}{
-		{
-			name:          "Успешный перевод",
-			senderLogin:   "sender",
-			receiverLogin: "receiver",
-			amount:        300,
-			prepare: func(us *mock.MockUserStorage) {
-				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
-				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
-			},
-			wantErr:           nil,
-			wantSenderCoins:   700,
-			wantReceiverCoins: 800,
-		},
-		{
-			name:          "Отправитель не найден",
-			senderLogin:   "unknown",
-			receiverLogin: "receiver",
-			amount:        100,
-			prepare: func(us *mock.MockUserStorage) {
-				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
-			},
-			wantErr:           models.ErrUserNotFound,
-			wantSenderCoins:   -1,
-			wantReceiverCoins: 500,
-		},
-		{
-			name:          "Недостаточно средств",
-			senderLogin:   "sender",
-			receiverLogin: "receiver",
-			amount:        1500,
-			prepare: func(us *mock.MockUserStorage) {
-				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
-				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
-			},
-			wantErr:           models.ErrNotEnoughCoins,
-			wantSenderCoins:   1000,
-			wantReceiverCoins: 500,
-		},
-		{
-			name:          "Перевод самому себе",
-			senderLogin:   "sender",
-			receiverLogin: "sender",
-			amount:        100,
-			prepare: func(us *mock.MockUserStorage) {
-				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
-			},
-			wantErr:           models.ErrSameSenderReceiver,
-			wantSenderCoins:   1000,
-			wantReceiverCoins: 1000,
-		},
-		{
-			name:          "Отрицательная сумма",
-			senderLogin:   "sender",
-			receiverLogin: "receiver",
-			amount:        -100,
-			prepare: func(us *mock.MockUserStorage) {
-				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
-				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
-			},
-			wantErr:           models.ErrInvalidAmount,
-			wantSenderCoins:   1000,
-			wantReceiverCoins: 500,
-		},
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	txManager := mock.NewMockTxManager()
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, mock.NewMockLedgerStorage(userStorage), mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+
+	const (
+		usersCount    = 10
+		buysPerUser   = 5
+		startingCoins = 100
+		merchName     = "Кружка" // цена 30, на складе 5 (см. mock.NewMockMerchStorage)
+	)
+
+	merch, err := merchStorage.GetByName(ctx, merchName)
+	require.NoError(t, err)
+	startStock := merch.Stock
+
+	for i := range usersCount {
+		err := userStorage.Create(ctx, &models.User{
+			Login:    fmt.Sprintf("user%d", i),
+			Password: "password",
+			Coins:    startingCoins,
+		})
+		require.NoError(t, err)
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			userStorage := mock.NewMockUserStorage()
-			transactionStorage := mock.NewMockTransactionStorage()
-			coinsStorage := mock.NewMockCoinsStorage()
-			service := service.NewTransactionService(transactionStorage, userStorage, coinsStorage)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded = make(map[string]int)
+	)
 
-			if tc.prepare != nil {
-				tc.prepare(userStorage)
-			}
+	for i := range usersCount {
+		login := fmt.Sprintf("user%d", i)
+		for range buysPerUser {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
 
-			err := service.Send(ctx, tc.senderLogin, tc.receiverLogin, tc.amount)
+				_, err := merchService.Buy(ctx, login, merchName, "", "", 1)
+				if err != nil {
+					assert.True(t,
+						errors.Is(err, models.ErrNotEnoughMerch) || errors.Is(err, models.ErrNotEnoughCoins),
+						"неожиданная ошибка: %v", err)
+					return
+				}
 
-			if tc.wantErr != nil {
-				require.ErrorIs(t, err, tc.wantErr)
-			} else {
-				require.NoError(t, err)
-			}
+				mu.Lock()
+				succeeded[login]++
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
 
-			if tc.wantSenderCoins > 0 {
-				sender, err := userStorage.GetByLogin(ctx, tc.senderLogin)
-				require.NoError(t, err)
-				assert.Equal(t, tc.wantSenderCoins, sender.Coins)
-			}
+	totalBought := 0
+	for _, n := range succeeded {
+		totalBought += n
+	}
 
-			if tc.wantReceiverCoins > 0 {
-				receiver, err := userStorage.GetByLogin(ctx, tc.receiverLogin)
-				require.NoError(t, err)
-				assert.Equal(t, tc.wantReceiverCoins, receiver.Coins)
-			}
+	merch, err = merchStorage.GetByName(ctx, merchName)
+	require.NoError(t, err)
+	assert.Equal(t, startStock, totalBought, "должен быть продан весь склад и не больше")
+	assert.Equal(t, startStock-totalBought, merch.Stock)
+	assert.GreaterOrEqual(t, merch.Stock, 0)
 
-			if tc.wantErr == nil {
-				sender, err := userStorage.GetByLogin(ctx, tc.senderLogin)
-				require.NoError(t, err)
+	for i := range usersCount {
+		login := fmt.Sprintf("user%d", i)
+		user, err := userStorage.GetByLogin(ctx, login)
+		require.NoError(t, err)
 
-				senderHistory, err := coinsStorage.Get(ctx, sender)
-				require.NoError(t, err)
-				require.Len(t, senderHistory, 1)
-				assert.Equal(t, sender.Coins+tc.amount, senderHistory[0].CoinsBefore)
-				assert.Equal(t, sender.Coins, senderHistory[0].CoinsAfter)
+		assert.GreaterOrEqual(t, user.Coins, 0)
+		assert.Equal(t, startingCoins-succeeded[login]*merch.Price, user.Coins)
 
-				receiver, err := userStorage.GetByLogin(ctx, tc.receiverLogin)
-				require.NoError(t, err)
+		if succeeded[login] == 0 {
+			continue
+		}
 
-				receiverHistory, err := coinsStorage.Get(ctx, receiver)
-				require.NoError(t, err)
-				require.Len(t, receiverHistory, 1)
-				assert.Equal(t, receiver.Coins-tc.amount, receiverHistory[0].CoinsBefore)
-				assert.Equal(t, receiver.Coins, receiverHistory[0].CoinsAfter)
-			}
-		})
+		coinsHistory, err := coinsStorage.Get(ctx, user)
+		require.NoError(t, err)
+		require.Len(t, coinsHistory, succeeded[login])
+		assert.Equal(t, user.Coins, coinsHistory[len(coinsHistory)-1].CoinsAfter)
+
+		purchaseHistory, err := purchaseStorage.Get(ctx, user)
+		require.NoError(t, err)
+		assert.Len(t, purchaseHistory, succeeded[login])
 	}
 }
+
+// TestMerchServicePurchaseLimits - лимиты покупки товара одним пользователем:
+// - на заказ, за месяц и всего, у каждого пользователя свои покупки
+// - отмененный заказ не считается, варианты одного товара считаются вместе
+// - при превышении в корзине не покупается ничего
+// - параллельные покупки одного пользователя не обходят лимит
+// - активные брони считаются покупками: бронями нельзя занять больше лимита
+func TestMerchServicePurchaseLimits(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, nil)
+
+	for _, login := range []string{"buyer", "other", "racer", "holder"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	_, err := merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Наклейка", Price: 1, Stock: 10, Limits: models.PurchaseLimits{PerUser: -1}})
+	assert.ErrorIs(t, err, models.ErrInvalidLimits)
+
+	phone, err := merchService.CreateMerch(ctx, &models.MerchRequest{
+		Name: "КиберТелефон", Price: 10, Stock: 15,
+		Limits: models.PurchaseLimits{PerOrder: 2, PerMonth: 3},
+	})
+	require.NoError(t, err)
+
+	_, err = merchService.Buy(ctx, "buyer", "КиберТелефон", "", "", 3)
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+
+	_, err = merchService.Buy(ctx, "buyer", "КиберТелефон", "", "", 2)
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "buyer", "КиберТелефон", "", "", 2)
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+	_, err = merchService.Buy(ctx, "buyer", "КиберТелефон", "", "", 1)
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "buyer", "КиберТелефон", "", "", 1)
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+
+	_, err = merchService.Buy(ctx, "other", "КиберТелефон", "", "", 2)
+	require.NoError(t, err)
+
+	// Отмена заказа возвращает лимит
+	orders, err := orderService.Orders(ctx, "buyer", nil)
+	require.NoError(t, err)
+	require.Len(t, orders.Items, 2)
+	_, err = orderService.CancelOrder(ctx, "buyer", orders.Items[1].Id)
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "buyer", "КиберТелефон", "", "", 2)
+	require.NoError(t, err)
+
+	// Лимит на пользователя: штуки вариантов складываются, корзина не покупается частично
+	perUser := 2
+	_, err = merchService.PatchMerch(ctx, 1, &models.MerchPatchRequest{Limits: &models.PurchaseLimits{PerUser: perUser}})
+	require.NoError(t, err)
+	for _, sku := range []string{"TEE-S", "TEE-M"} {
+		_, err = merchService.CreateVariant(ctx, 1, &models.VariantRequest{Sku: sku, Stock: 5})
+		require.NoError(t, err)
+	}
+
+	_, err = merchService.Buy(ctx, "other", "Футболка", "TEE-S", "", 1)
+	require.NoError(t, err)
+	_, err = merchService.AddToCart(ctx, "other", "Футболка", "TEE-M", 1)
+	require.NoError(t, err)
+	_, err = merchService.AddToCart(ctx, "other", "Футболка", "TEE-S", 1)
+	require.NoError(t, err)
+	_, err = merchService.AddToCart(ctx, "other", "Кружка", "", 1)
+	require.NoError(t, err)
+
+	_, err = merchService.Checkout(ctx, "other", "")
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+
+	cart, err := merchService.Cart(ctx, "other")
+	require.NoError(t, err)
+	assert.Len(t, cart.Lines, 3)
+	user, err := userStorage.GetByLogin(ctx, "other")
+	require.NoError(t, err)
+	assert.Equal(t, 1000-2*10-100, user.Coins)
+
+	_, err = merchService.SetCartItem(ctx, "other", 1, "TEE-S", 0)
+	require.NoError(t, err)
+	_, err = merchService.Checkout(ctx, "other", "")
+	require.NoError(t, err)
+
+	// Параллельные покупки одного пользователя
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := merchService.Buy(ctx, "racer", "КиберТелефон", "", "", 1)
+			if err != nil {
+				assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+				return
+			}
+
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, succeeded)
+	item, err := merchStorage.Get(ctx, phone.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 15-2-1-2+2-2-3, item.Stock)
+
+	// Брони занимают лимит, подтверждаемая бронь не считается дважды
+	_, err = merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Значок", Price: 10, Stock: 10, Limits: models.PurchaseLimits{PerUser: 3}})
+	require.NoError(t, err)
+
+	badges, err := merchService.Reserve(ctx, "holder", &models.ReservationRequest{Item: "Значок", Count: 2}, time.Hour)
+	require.NoError(t, err)
+	_, err = merchService.Reserve(ctx, "holder", &models.ReservationRequest{Item: "Значок", Count: 2}, time.Hour)
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+	_, err = merchService.Buy(ctx, "holder", "Значок", "", "", 2)
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+
+	badge, err := merchService.Reserve(ctx, "holder", &models.ReservationRequest{Item: "Значок", Count: 1}, time.Hour)
+	require.NoError(t, err)
+	_, err = merchService.ConfirmReservation(ctx, "holder", badges.Id)
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "holder", "Значок", "", "", 1)
+	assert.ErrorIs(t, err, models.ErrPurchaseLimitExceeded)
+
+	_, err = merchService.CancelReservation(ctx, "holder", badge.Id)
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "holder", "Значок", "", "", 1)
+	require.NoError(t, err)
+}
+
+// recordingNotifier - внешний канал уведомлений для тестов: запоминает
+// разосланные уведомления или возвращает err
+type recordingNotifier struct {
+	mu    sync.Mutex
+	notes []*models.Notification
+	err   error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, notes []*models.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.notes = append(r.notes, notes...)
+	return nil
+}
+
+func (r *recordingNotifier) delivered() []*models.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.notes)
+}
+
+// TestNotificationServiceBackInStock - списки желаний и уведомления о поступлении:
+// - подписчик получает уведомление во внутренний ящик и внешний канал,
+// когда остаток переходит от нуля к положительному (правка мерча, варианта, отмена заказа)
+// - пополнение без перехода через ноль и параллельные пополнения не дают лишних уведомлений
+// - без подписки мерч в списке желаний уведомлений не дает
+// - ошибка внешнего канала не отменяет пополнение, недоставленное уведомление
+// отправляется повторно
+// - уведомления читает только владелец
+func TestNotificationServiceBackInStock(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	wishlistStorage := mock.NewMockWishlistStorage(merchStorage)
+	notificationStorage := mock.NewMockNotificationStorage(wishlistStorage, userStorage, merchStorage)
+
+	notifier := &recordingNotifier{}
+	restock := service.NewRestockNotifier(notificationStorage, variantStorage, notifier)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), restock)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, restock)
+	notificationService := service.NewNotificationService(wishlistStorage, notificationStorage, userStorage, variantStorage)
+
+	for _, login := range []string{"fan", "watcher", "buyer"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	notifications := func(login string) []*models.Notification {
+		t.Helper()
+		page, err := notificationService.Notifications(ctx, login, nil)
+		require.NoError(t, err)
+		return page.Items
+	}
+
+	hat, err := merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Кепка", Price: 10, Stock: 1})
+	require.NoError(t, err)
+
+	_, err = notificationService.AddToWishlist(ctx, "fan", 999, true)
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	wishlist, err := notificationService.AddToWishlist(ctx, "fan", hat.Id, true)
+	require.NoError(t, err)
+	require.Len(t, wishlist, 1)
+	assert.Equal(t, "Кепка", wishlist[0].Name)
+	assert.Equal(t, 1, wishlist[0].Stock)
+	assert.True(t, wishlist[0].Notify)
+
+	_, err = notificationService.AddToWishlist(ctx, "watcher", hat.Id, false)
+	require.NoError(t, err)
+
+	// Покупка последней штуки никого не уведомляет
+	_, err = merchService.Buy(ctx, "buyer", "Кепка", "", "", 1)
+	require.NoError(t, err)
+	assert.Empty(t, notifications("fan"))
+
+	// Пополнение с нуля - одно уведомление, повторное пополнение - ни одного
+	stock := 3
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	stock = 5
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+
+	notes := notifications("fan")
+	require.Len(t, notes, 1)
+	assert.Equal(t, models.NotificationBackInStock, notes[0].Kind)
+	assert.Equal(t, hat.Id, notes[0].MerchId)
+	assert.Equal(t, "Кепка", notes[0].MerchName)
+	assert.Nil(t, notes[0].ReadAt)
+	assert.Empty(t, notifications("watcher"))
+
+	delivered := notifier.delivered()
+	require.Len(t, delivered, 1)
+	assert.Equal(t, "fan", delivered[0].Login)
+
+	// Перезапись мерча и отмена заказа тоже возвращают его в наличие
+	stock = 0
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	_, err = merchService.ReplaceMerch(ctx, hat.Id, &models.MerchRequest{Name: "Кепка", Price: 10, Stock: 2})
+	require.NoError(t, err)
+	assert.Len(t, notifications("fan"), 2)
+
+	_, err = merchService.Buy(ctx, "buyer", "Кепка", "", "", 2)
+	require.NoError(t, err)
+	orders, err := orderService.Orders(ctx, "buyer", nil)
+	require.NoError(t, err)
+	_, err = orderService.CancelOrder(ctx, "buyer", orders.Items[0].Id)
+	require.NoError(t, err)
+	assert.Len(t, notifications("fan"), 3)
+
+	// У мерча с вариантами считается остаток вариантов
+	hoodie, err := merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Худи", Price: 50, Stock: 0})
+	require.NoError(t, err)
+	_, err = notificationService.AddToWishlist(ctx, "fan", hoodie.Id, true)
+	require.NoError(t, err)
+
+	variant, err := merchService.CreateVariant(ctx, hoodie.Id, &models.VariantRequest{Sku: "HOODIE-L", Stock: 0})
+	require.NoError(t, err)
+	assert.Len(t, notifications("fan"), 3)
+
+	_, err = merchService.ReplaceVariant(ctx, hoodie.Id, variant.Id, &models.VariantRequest{Sku: "HOODIE-L", Stock: 4})
+	require.NoError(t, err)
+	assert.Len(t, notifications("fan"), 4)
+
+	stock = 10
+	_, err = merchService.PatchMerch(ctx, hoodie.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	assert.Len(t, notifications("fan"), 4)
+
+	_, err = merchService.Buy(ctx, "buyer", "Худи", "HOODIE-L", "", 4)
+	require.NoError(t, err)
+	wishlist, err = notificationService.Wishlist(ctx, "fan")
+	require.NoError(t, err)
+	require.Len(t, wishlist, 2)
+	assert.Equal(t, 0, wishlist[1].Stock)
+
+	orders, err = orderService.Orders(ctx, "buyer", nil)
+	require.NoError(t, err)
+	_, err = orderService.AdminCancelOrder(ctx, orders.Items[0].Id)
+	require.NoError(t, err)
+	notes = notifications("fan")
+	require.Len(t, notes, 5)
+	assert.Equal(t, hoodie.Id, notes[0].MerchId)
+
+	// Параллельные пополнения с нуля дают одно уведомление
+	stock = 0
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stock := i
+			_, err := merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, notifications("fan"), 6)
+	assert.Len(t, notifier.delivered(), 6)
+
+	// Ошибка внешнего канала не отменяет пополнение, уведомление остается в ящике
+	notifier.mu.Lock()
+	notifier.err = errors.New("вебхук недоступен")
+	notifier.mu.Unlock()
+
+	stock = 0
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	stock = 1
+	item, err := merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	assert.Equal(t, 1, item.Stock)
+	assert.Len(t, notifications("fan"), 7)
+	assert.Len(t, notifier.delivered(), 6)
+
+	// Недоставленное уведомление отправляется повторно, доставленные - нет
+	sent, err := restock.DeliverPending(ctx)
+	assert.Error(t, err)
+	assert.Zero(t, sent)
+
+	notifier.mu.Lock()
+	notifier.err = nil
+	notifier.mu.Unlock()
+
+	sent, err = restock.DeliverPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	delivered = notifier.delivered()
+	require.Len(t, delivered, 7)
+	assert.Equal(t, notifications("fan")[0].Id, delivered[6].Id)
+	sent, err = restock.DeliverPending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	// Прочитать уведомление может только владелец
+	notes = notifications("fan")
+	assert.ErrorIs(t, notificationService.ReadNotification(ctx, "watcher", notes[0].Id), models.ErrNotificationNotFound)
+	require.NoError(t, notificationService.ReadNotification(ctx, "fan", notes[0].Id))
+	require.NoError(t, notificationService.ReadNotification(ctx, "fan", notes[0].Id))
+	notes = notifications("fan")
+	assert.NotNil(t, notes[0].ReadAt)
+	assert.Nil(t, notes[1].ReadAt)
+
+	// Без подписки уведомлений нет
+	wishlist, err = notificationService.AddToWishlist(ctx, "fan", hat.Id, false)
+	require.NoError(t, err)
+	assert.False(t, wishlist[0].Notify)
+	stock = 0
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	stock = 2
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &stock})
+	require.NoError(t, err)
+	assert.Len(t, notifications("fan"), 7)
+
+	wishlist, err = notificationService.RemoveFromWishlist(ctx, "fan", hat.Id)
+	require.NoError(t, err)
+	require.Len(t, wishlist, 1)
+	assert.Equal(t, hoodie.Id, wishlist[0].MerchId)
+	_, err = notificationService.RemoveFromWishlist(ctx, "fan", hat.Id)
+	assert.ErrorIs(t, err, models.ErrWishlistItemNotFound)
+}
+
+// TestMerchServiceReservations - брони и предзаказы:
+// - удержание уменьшает доступный остаток, но не склад: другие не могут купить забронированное
+// - стоимость брони списывается сразу, подтверждение переводит ее в выручку заказом
+// - отмена и истечение (в том числе фоновой очисткой) возвращают монеты ровно один раз
+// - предзаказ оформляется без товара на складе, подтверждается после поступления
+// - параллельные удержания не бронируют больше остатка
+func TestMerchServiceReservations(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	reservationStorage := mock.NewMockReservationStorage()
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), reservationStorage, nil)
+
+	for _, login := range []string{"buyer", "other", "third"} {
+		require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: login, Password: "password"}))
+	}
+
+	balance := func(login string) int {
+		t.Helper()
+		user, err := userStorage.GetByLogin(ctx, login)
+		require.NoError(t, err)
+		return user.Coins
+	}
+
+	stock := func(id int) int {
+		t.Helper()
+		merch, err := merchStorage.Get(ctx, id)
+		require.NoError(t, err)
+		return merch.Stock
+	}
+
+	_, err := merchService.Reserve(ctx, "buyer", &models.ReservationRequest{Item: "Футболка"}, time.Hour)
+	assert.ErrorIs(t, err, models.ErrInvalidCount)
+	_, err = merchService.Reserve(ctx, "buyer", &models.ReservationRequest{Item: "Футболка", Count: 13}, time.Hour)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+	_, err = merchService.Reserve(ctx, "buyer", &models.ReservationRequest{Item: "ОченьДорогаяВещь", Count: 1}, time.Hour)
+	assert.ErrorIs(t, err, models.ErrNotEnoughCoins)
+
+	// Удержание 10 из 12 футболок: склад прежний, купить можно только 2
+	hold, err := merchService.Reserve(ctx, "buyer", &models.ReservationRequest{Item: "Футболка", Count: 10}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReservationHold, hold.Kind)
+	assert.Equal(t, models.ReservationActive, hold.Status)
+	assert.Equal(t, 1000, hold.Total)
+	assert.Equal(t, 0, balance("buyer"))
+	assert.Equal(t, 12, stock(1))
+
+	_, err = merchService.Buy(ctx, "other", "Футболка", "", "", 3)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+	_, err = merchService.Reserve(ctx, "other", &models.ReservationRequest{Item: "Футболка", Count: 3}, time.Hour)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+	_, err = merchService.Buy(ctx, "other", "Футболка", "", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 800, balance("other"))
+
+	// Подтвердить можно только свою бронь
+	_, err = merchService.ConfirmReservation(ctx, "other", hold.Id)
+	assert.ErrorIs(t, err, models.ErrReservationNotFound)
+	_, err = merchService.ConfirmReservation(ctx, "buyer", 999)
+	assert.ErrorIs(t, err, models.ErrReservationNotFound)
+
+	order, err := merchService.ConfirmReservation(ctx, "buyer", hold.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderPlaced, order.Status)
+	assert.Equal(t, 1000, order.Total)
+	require.Len(t, order.Lines, 1)
+	assert.Equal(t, 10, order.Lines[0].Count)
+	assert.Equal(t, 0, stock(1))
+	assert.Equal(t, 0, balance("buyer"))
+
+	_, err = merchService.ConfirmReservation(ctx, "buyer", hold.Id)
+	assert.ErrorIs(t, err, models.ErrReservationNotActive)
+	_, err = merchService.CancelReservation(ctx, "buyer", hold.Id)
+	assert.ErrorIs(t, err, models.ErrReservationNotActive)
+
+	page, err := merchService.Reservations(ctx, "buyer", nil)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.ReservationConfirmed, page.Items[0].Status)
+	assert.Equal(t, order.Id, page.Items[0].OrderId)
+
+	history, err := coinsStorage.Get(ctx, &models.User{Login: "buyer"})
+	require.NoError(t, err)
+	last := history[len(history)-1]
+	assert.Equal(t, models.ReasonReserve, last.Reason)
+	assert.Equal(t, hold.Id, last.ReservationId)
+	assert.Equal(t, 1000, last.CoinsBefore)
+	assert.Equal(t, 0, last.CoinsAfter)
+
+	// Отмена возвращает монеты
+	mugs, err := merchService.Reserve(ctx, "other", &models.ReservationRequest{Item: "Кружка", Count: 2}, 0)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(service.DefaultHoldTTL), mugs.ExpiresAt, time.Minute)
+	assert.Equal(t, 740, balance("other"))
+
+	_, err = merchService.CancelReservation(ctx, "third", mugs.Id)
+	assert.ErrorIs(t, err, models.ErrReservationNotFound)
+	cancelled, err := merchService.CancelReservation(ctx, "other", mugs.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReservationCancelled, cancelled.Status)
+	assert.Equal(t, 800, balance("other"))
+	_, err = merchService.CancelReservation(ctx, "other", mugs.Id)
+	assert.ErrorIs(t, err, models.ErrReservationNotActive)
+
+	// Истекшая бронь сразу перестает держать товар, очистка возвращает монеты один раз
+	mugs, err = merchService.Reserve(ctx, "other", &models.ReservationRequest{Item: "Кружка", Count: 5}, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 650, balance("other"))
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = merchService.ConfirmReservation(ctx, "other", mugs.Id)
+	assert.ErrorIs(t, err, models.ErrReservationNotActive)
+	_, err = merchService.Buy(ctx, "third", "Кружка", "", "", 1)
+	require.NoError(t, err)
+
+	expired, err := merchService.ExpireReservations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 800, balance("other"))
+	expired, err = merchService.ExpireReservations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+
+	res, err := reservationStorage.Get(ctx, mugs.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReservationExpired, res.Status)
+
+	// Фоновая очистка
+	mugs, err = merchService.Reserve(ctx, "other", &models.ReservationRequest{Item: "Кружка", Count: 1}, time.Millisecond)
+	require.NoError(t, err)
+
+	sweepCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		merchService.SweepReservations(sweepCtx, 5*time.Millisecond)
+	}()
+	assert.Eventually(t, func() bool {
+		res, err := reservationStorage.Get(ctx, mugs.Id)
+		return err == nil && res.Status == models.ReservationExpired
+	}, time.Second, 5*time.Millisecond)
+	stop()
+	<-done
+	assert.Equal(t, 800, balance("other"))
+
+	// Предзаказ товара, которого нет на складе
+	hat, err := merchService.CreateMerch(ctx, &models.MerchRequest{Name: "Кепка", Price: 10, Stock: 0})
+	require.NoError(t, err)
+
+	_, err = merchService.Reserve(ctx, "third", &models.ReservationRequest{Item: "Кепка", Count: 2}, time.Hour)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+	preorder, err := merchService.Reserve(ctx, "third", &models.ReservationRequest{Item: "Кепка", Count: 2, Preorder: true}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReservationPreorder, preorder.Kind)
+	assert.WithinDuration(t, time.Now().Add(service.DefaultPreorderTTL), preorder.ExpiresAt, time.Minute)
+	assert.Equal(t, 950, balance("third"))
+
+	_, err = merchService.ConfirmReservation(ctx, "third", preorder.Id)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+
+	// Предзаказы занимают поступивший товар: купить его в обход очереди нельзя
+	count := 2
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &count})
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "other", "Кепка", "", "", 1)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+
+	order, err = merchService.ConfirmReservation(ctx, "third", preorder.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 20, order.Total)
+	assert.Equal(t, 0, stock(hat.Id))
+	assert.Equal(t, 950, balance("third"))
+
+	// Параллельные удержания не превышают остаток
+	count = 3
+	_, err = merchService.PatchMerch(ctx, hat.Id, &models.MerchPatchRequest{Stock: &count})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := range 6 {
+		login := fmt.Sprintf("rush%d", i)
+		require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: login, Password: "password"}))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := merchService.Reserve(ctx, login, &models.ReservationRequest{Item: "Кепка", Count: 1}, time.Hour)
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+				return
+			}
+			assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 3, succeeded)
+	assert.Equal(t, 3, stock(hat.Id))
+
+	report, err := service.NewLedgerService(ledgerStorage, nil, nil, nil, nil).Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+// TestTransactionServiceSend - проверяет метод Send в TransactionService на следующщие сценарии:
+// - успешный перевод
+// - отправителя нет в базе данных
+// - недостаточно средств у отправителя
+// - перевод самому себе
+// - перевод отрицательной суммы
+// TestMerchServiceCart проверяет корзину и оформление заказа:
+// - корзина считается по текущим ценам
+// - при нехватке одного товара не покупается ничего: склад, монеты и корзина не меняются
+// - успешное оформление - один заказ и одна запись в истории кошелька
+// - строки удаленного из каталога товара убираются из корзины при оформлении
+func TestMerchServiceCart(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "buyer", Coins: 1000}))
+
+	_, err := merchService.Checkout(ctx, "buyer", "")
+	assert.ErrorIs(t, err, models.ErrEmptyCart)
+
+	_, err = merchService.AddToCart(ctx, "buyer", "Футболка", "", 0)
+	assert.ErrorIs(t, err, models.ErrInvalidCount)
+
+	_, err = merchService.AddToCart(ctx, "buyer", "Худи", "", 1)
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	_, err = merchService.AddToCart(ctx, "buyer", "Футболка", "", 2)
+	require.NoError(t, err)
+	cart, err := merchService.AddToCart(ctx, "buyer", "Кружка", "", 6)
+	require.NoError(t, err)
+	require.Len(t, cart.Lines, 2)
+	assert.Equal(t, 380, cart.Total)
+
+	// Кружек на складе 5: заказ не оформляется целиком
+	_, err = merchService.Checkout(ctx, "buyer", "")
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+
+	tshirt, err := merchStorage.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 12, tshirt.Stock)
+
+	user, err := userStorage.GetByLogin(ctx, "buyer")
+	require.NoError(t, err)
+	assert.Equal(t, 1000, user.Coins)
+	assert.Zero(t, orderStorage.Count())
+
+	cart, err = merchService.Cart(ctx, "buyer")
+	require.NoError(t, err)
+	assert.Len(t, cart.Lines, 2)
+
+	cart, err = merchService.SetCartItem(ctx, "buyer", 2, "", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 350, cart.Total)
+
+	_, err = merchService.SetCartItem(ctx, "buyer", 2, "", -1)
+	assert.ErrorIs(t, err, models.ErrInvalidCount)
+
+	_, err = merchService.RemoveFromCart(ctx, "buyer", 3, "")
+	assert.ErrorIs(t, err, models.ErrCartItemNotFound)
+
+	_, err = merchService.AddToCart(ctx, "buyer", "ОченьДорогаяВещь", "", 1)
+	require.NoError(t, err)
+	require.NoError(t, merchStorage.Delete(ctx, 3))
+
+	result, err := merchService.Checkout(ctx, "buyer", "")
+	require.NoError(t, err)
+	require.Len(t, result.Removed, 1)
+	assert.Equal(t, 3, result.Removed[0].MerchId)
+	assert.Equal(t, 650, result.Balance)
+	assert.Equal(t, 350, result.Order.Total)
+	require.Len(t, result.Order.Lines, 2)
+	assert.Equal(t, 30, result.Order.Lines[1].Price)
+	assert.Equal(t, 1, orderStorage.Count())
+
+	mug, err := merchStorage.Get(ctx, 2)
+	require.NoError(t, err)
+	assert.Zero(t, mug.Stock)
+
+	coinsHistory, err := coinsStorage.Get(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, coinsHistory, 1)
+	assert.Equal(t, 650, coinsHistory[0].CoinsAfter)
+
+	purchases, err := purchaseStorage.Get(ctx, user)
+	require.NoError(t, err)
+	assert.Len(t, purchases, 2)
+
+	cart, err = merchService.Cart(ctx, "buyer")
+	require.NoError(t, err)
+	assert.Empty(t, cart.Lines)
+}
+
+// TestOrderService проверяет статусы выдачи заказов:
+// - новый заказ в статусе placed, статус виден в истории покупок
+// - заказ двигается только вперед, выданный заказ не меняется
+// - чужой заказ пользователю не виден
+func TestOrderService(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, nil)
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "buyer", Coins: 1000}))
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "other", Coins: 1000}))
+
+	_, err := merchService.Buy(ctx, "buyer", "Футболка", "", "", 2)
+	require.NoError(t, err)
+	_, err = merchService.Buy(ctx, "other", "Кружка", "", "", 1)
+	require.NoError(t, err)
+
+	orders, err := orderService.Orders(ctx, "buyer", nil)
+	require.NoError(t, err)
+	require.Len(t, orders.Items, 1)
+	order := orders.Items[0]
+	assert.Equal(t, models.OrderPlaced, order.Status)
+	assert.Equal(t, 200, order.Total)
+	require.Len(t, order.Lines, 1)
+	assert.Equal(t, "Футболка", order.Lines[0].Name)
+
+	_, err = orderService.Order(ctx, "other", order.Id)
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+
+	placed, err := orderService.AllOrders(ctx, &models.OrderFilter{Status: models.OrderPlaced})
+	require.NoError(t, err)
+	assert.Len(t, placed.Items, 2)
+
+	_, err = orderService.AllOrders(ctx, &models.OrderFilter{Status: "lost"})
+	assert.ErrorIs(t, err, models.ErrInvalidOrderStatus)
+
+	order, err = orderService.AdvanceOrder(ctx, order.Id, models.OrderConfirmed)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderConfirmed, order.Status)
+
+	for _, status := range []models.OrderStatus{models.OrderPlaced, models.OrderConfirmed, models.OrderCancelled} {
+		_, err = orderService.AdvanceOrder(ctx, order.Id, status)
+		assert.ErrorIs(t, err, models.ErrOrderTransition, status)
+	}
+
+	_, err = orderService.AdvanceOrder(ctx, order.Id, "lost")
+	assert.ErrorIs(t, err, models.ErrInvalidOrderStatus)
+
+	// Промежуточный статус можно пропустить
+	order, err = orderService.AdvanceOrder(ctx, order.Id, models.OrderDelivered)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderDelivered, order.Status)
+
+	_, err = orderService.AdvanceOrder(ctx, order.Id, models.OrderDelivered)
+	assert.ErrorIs(t, err, models.ErrOrderTransition)
+
+	_, err = orderService.AdvanceOrder(ctx, 100, models.OrderConfirmed)
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+
+	purchases, err := userService.PurchaseHistory(ctx, "buyer", nil)
+	require.NoError(t, err)
+	require.Len(t, purchases.Items, 1)
+	assert.Equal(t, order.Id, purchases.Items[0].OrderId)
+	assert.Equal(t, models.OrderDelivered, purchases.Items[0].Status)
+}
+
+// TestOrderServiceCancel проверяет отмену заказов:
+// - монеты возвращаются покупателю записью refund со ссылкой на заказ, товар - на склад
+// - покупатель не может отменить выданный или чужой заказ, администратор - может выданный
+// - отмененный заказ повторно не отменяется, журнал после возвратов сходится
+func TestOrderServiceCancel(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, nil)
+
+	for _, login := range []string{"buyer", "other"} {
+		require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: login, Password: "password"}))
+	}
+
+	_, err := merchService.AddToCart(ctx, "buyer", "Футболка", "", 2)
+	require.NoError(t, err)
+	_, err = merchService.AddToCart(ctx, "buyer", "Кружка", "", 3)
+	require.NoError(t, err)
+	result, err := merchService.Checkout(ctx, "buyer", "")
+	require.NoError(t, err)
+	require.Equal(t, 710, result.Balance)
+	orderID := result.Order.Id
+
+	_, err = orderService.CancelOrder(ctx, "other", orderID)
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+
+	order, err := orderService.CancelOrder(ctx, "buyer", orderID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderCancelled, order.Status)
+
+	user, err := userStorage.GetByLogin(ctx, "buyer")
+	require.NoError(t, err)
+	assert.Equal(t, 1000, user.Coins)
+
+	for id, stock := range map[int]int{1: 12, 2: 5} {
+		merch, err := merchStorage.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, stock, merch.Stock)
+	}
+
+	coinsHistory, err := coinsStorage.Get(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, coinsHistory, 2)
+	refund := coinsHistory[1]
+	assert.Equal(t, models.ReasonRefund, refund.Reason)
+	assert.Equal(t, orderID, refund.OrderId)
+	assert.Equal(t, 710, refund.CoinsBefore)
+	assert.Equal(t, 1000, refund.CoinsAfter)
+
+	_, err = orderService.CancelOrder(ctx, "buyer", orderID)
+	assert.ErrorIs(t, err, models.ErrOrderTransition)
+	_, err = orderService.AdminCancelOrder(ctx, orderID)
+	assert.ErrorIs(t, err, models.ErrOrderTransition)
+
+	// Выданный заказ отменяет только администратор
+	_, err = merchService.Buy(ctx, "buyer", "Кружка", "", "", 1)
+	require.NoError(t, err)
+	orders, err := orderService.Orders(ctx, "buyer", nil)
+	require.NoError(t, err)
+	orderID = orders.Items[0].Id
+
+	_, err = orderService.AdvanceOrder(ctx, orderID, models.OrderDelivered)
+	require.NoError(t, err)
+
+	_, err = orderService.CancelOrder(ctx, "buyer", orderID)
+	assert.ErrorIs(t, err, models.ErrOrderTransition)
+
+	order, err = orderService.AdminCancelOrder(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderCancelled, order.Status)
+
+	user, err = userStorage.GetByLogin(ctx, "buyer")
+	require.NoError(t, err)
+	assert.Equal(t, 1000, user.Coins)
+
+	report, err := service.NewLedgerService(ledgerStorage, nil, nil, nil, nil).Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+// TestMerchServiceVariants - варианты мерча:
+// - мерч с вариантами покупается только по артикулу, чужой артикул не подходит
+// - у варианта свой остаток и своя цена (или цена мерча), склад мерча не меняется
+// - в каталоге остаток мерча с вариантами - сумма остатков вариантов
+// - корзина хранит варианты отдельными строками, отмена заказа возвращает вариант на склад
+func TestMerchServiceVariants(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, nil)
+
+	require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: "buyer", Password: "password"}))
+
+	xlPrice := 150
+	small, err := merchService.CreateVariant(ctx, 1, &models.VariantRequest{Sku: "TEE-S", Attributes: map[string]string{"size": "S"}, Stock: 2})
+	require.NoError(t, err)
+	xl, err := merchService.CreateVariant(ctx, 1, &models.VariantRequest{Sku: "TEE-XL", Attributes: map[string]string{"size": "XL"}, Price: &xlPrice, Stock: 3})
+	require.NoError(t, err)
+
+	_, err = merchService.CreateVariant(ctx, 2, &models.VariantRequest{Sku: "TEE-S"})
+	assert.ErrorIs(t, err, models.ErrVariantExists)
+	_, err = merchService.CreateVariant(ctx, 99, &models.VariantRequest{Sku: "NONE"})
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	// Без артикула мерч с вариантами не купить, мерч без вариантов покупается как раньше
+	_, err = merchService.Buy(ctx, "buyer", "Футболка", "", "", 1)
+	assert.ErrorIs(t, err, models.ErrVariantRequired)
+	_, err = merchService.Buy(ctx, "buyer", "Кружка", "TEE-S", "", 1)
+	assert.ErrorIs(t, err, models.ErrVariantNotFound)
+	_, err = merchService.Buy(ctx, "buyer", "Футболка", "TEE-S", "", 3)
+	assert.ErrorIs(t, err, models.ErrNotEnoughMerch)
+
+	balance, err := merchService.Buy(ctx, "buyer", "Футболка", "TEE-S", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 800, balance)
+	balance, err = merchService.Buy(ctx, "buyer", "Футболка", "TEE-XL", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 650, balance)
+	balance, err = merchService.Buy(ctx, "buyer", "Кружка", "", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 620, balance)
+
+	merch, err := merchStorage.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 12, merch.Stock)
+
+	page, err := merchService.MerchList(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 3)
+	assert.Equal(t, 2, page.Items[0].Stock)
+	require.Len(t, page.Items[0].Variants, 2)
+	assert.Equal(t, "TEE-S", page.Items[0].Variants[0].Sku)
+	assert.Equal(t, 0, page.Items[0].Variants[0].Stock)
+	assert.Empty(t, page.Items[1].Variants)
+	assert.Equal(t, 4, page.Items[1].Stock)
+
+	// Корзина: варианты - отдельные строки со своей ценой
+	_, err = merchService.AddToCart(ctx, "buyer", "Футболка", "", 1)
+	assert.ErrorIs(t, err, models.ErrVariantRequired)
+	_, err = merchService.AddToCart(ctx, "buyer", "Футболка", "TEE-XL", 1)
+	require.NoError(t, err)
+	cart, err := merchService.SetCartItem(ctx, "buyer", 1, "TEE-XL", 2)
+	require.NoError(t, err)
+	require.Len(t, cart.Lines, 1)
+	assert.Equal(t, xl.Id, cart.Lines[0].VariantId)
+	assert.Equal(t, 300, cart.Total)
+
+	result, err := merchService.Checkout(ctx, "buyer", "")
+	require.NoError(t, err)
+	assert.Equal(t, 320, result.Balance)
+	require.Len(t, result.Order.Lines, 1)
+	assert.Equal(t, "TEE-XL", result.Order.Lines[0].Variant)
+	assert.Equal(t, 150, result.Order.Lines[0].Price)
+
+	variant, err := variantStorage.Get(ctx, xl.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, variant.Stock)
+
+	_, err = orderService.CancelOrder(ctx, "buyer", result.Order.Id)
+	require.NoError(t, err)
+	variant, err = variantStorage.Get(ctx, xl.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, variant.Stock)
+
+	// Вариант другого мерча не отличается от несуществующего
+	_, err = merchService.ReplaceVariant(ctx, 2, small.Id, &models.VariantRequest{Sku: "TEE-S", Stock: 5})
+	assert.ErrorIs(t, err, models.ErrVariantNotFound)
+	assert.NoError(t, merchService.DeleteVariant(ctx, 1, small.Id))
+	_, err = merchService.Buy(ctx, "buyer", "Футболка", "TEE-S", "", 1)
+	assert.ErrorIs(t, err, models.ErrVariantNotFound)
+
+	report, err := service.NewLedgerService(ledgerStorage, nil, nil, nil, nil).Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+// testImage - PNG w x h, залитый одним цветом
+func testImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 200, G: 40, B: 40, A: 255}}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// TestMerchServicePromotions - распродажи и промокоды:
+// - идущая распродажа снижает цену в каталоге и при покупке, будущая - нет
+// - промокод применяется к цене распродажи без учета регистра, скидка записывается в заказ
+// - лимиты промокода на пользователя и всего, отмена заказа возвращает использование
+// - промокод на другой товар, истекший и несуществующий промокоды не принимаются
+// - невалидные скидка, срок, повторный код и несуществующий товар при создании
+func TestMerchServicePromotions(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	orderStorage := mock.NewMockOrderStorage(userStorage, purchaseStorage)
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	promoStorage := mock.NewMockPromoStorage()
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, orderStorage, mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), promoStorage, mock.NewMockReservationStorage(), nil)
+	orderService := service.NewOrderService(orderStorage, userStorage, merchStorage, coinsStorage, txManager, ledgerStorage, variantStorage, nil)
+	promoService := service.NewPromoService(promoStorage, merchStorage, orderStorage)
+
+	for _, login := range []string{"buyer", "other", "third"} {
+		require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: login, Password: "password"}))
+	}
+
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	sale, err := promoService.CreateSale(ctx, &models.SaleRequest{Name: " Осень ", Kind: models.DiscountPercent, Value: 10, MerchId: 1, StartsAt: past, EndsAt: future})
+	require.NoError(t, err)
+	assert.Equal(t, "Осень", sale.Name)
+	_, err = promoService.CreateSale(ctx, &models.SaleRequest{Name: "Зима", Kind: models.DiscountPercent, Value: 50, StartsAt: future, EndsAt: future.Add(time.Hour)})
+	require.NoError(t, err)
+
+	page, err := merchService.MerchList(ctx, nil)
+	require.NoError(t, err)
+	for _, item := range page.Items {
+		if item.Id == 1 {
+			require.NotNil(t, item.Sale)
+			assert.Equal(t, sale.Id, item.Sale.Id)
+			assert.Equal(t, 90, item.SalePrice)
+		} else {
+			assert.Nil(t, item.Sale, item.Name)
+		}
+	}
+
+	tee20, err := promoService.CreatePromo(ctx, &models.PromoCodeRequest{Code: " tee20 ", Kind: models.DiscountPercent, Value: 20, MaxUses: 2, MaxUsesPerUser: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "TEE20", tee20.Code)
+	_, err = promoService.CreatePromo(ctx, &models.PromoCodeRequest{Code: "MUG5", Kind: models.DiscountFixed, Value: 5, MerchId: 2})
+	require.NoError(t, err)
+	_, err = promoService.CreatePromo(ctx, &models.PromoCodeRequest{Code: "OLD", Kind: models.DiscountFixed, Value: 5, EndsAt: &past})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		req  models.PromoCodeRequest
+		want error
+	}{
+		{models.PromoCodeRequest{Code: "Tee20", Kind: models.DiscountFixed, Value: 1}, models.ErrPromoExists},
+		{models.PromoCodeRequest{Code: " ", Kind: models.DiscountFixed, Value: 1}, models.ErrEmptyPromoCode},
+		{models.PromoCodeRequest{Code: "BIG", Kind: models.DiscountPercent, Value: 150}, models.ErrInvalidDiscount},
+		{models.PromoCodeRequest{Code: "FREE", Kind: "gift", Value: 1}, models.ErrInvalidDiscount},
+		{models.PromoCodeRequest{Code: "LIM", Kind: models.DiscountFixed, Value: 1, MaxUses: -1}, models.ErrInvalidPromoLimit},
+		{models.PromoCodeRequest{Code: "WIN", Kind: models.DiscountFixed, Value: 1, StartsAt: &future, EndsAt: &past}, models.ErrInvalidPromoWindow},
+		{models.PromoCodeRequest{Code: "NOPE", Kind: models.DiscountFixed, Value: 1, MerchId: 100}, models.ErrMerchNotFound},
+	} {
+		_, err = promoService.CreatePromo(ctx, &tt.req)
+		assert.ErrorIs(t, err, tt.want, tt.req.Code)
+	}
+	_, err = promoService.CreateSale(ctx, &models.SaleRequest{Name: "Без срока", Kind: models.DiscountFixed, Value: 1})
+	assert.ErrorIs(t, err, models.ErrInvalidPromoWindow)
+
+	// Распродажа 10% и промокод 20%: 100 -> 90 -> 72 за штуку
+	balance, err := merchService.Buy(ctx, "buyer", "Футболка", "", " tee20", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 856, balance)
+
+	orders, err := orderService.Orders(ctx, "buyer", nil)
+	require.NoError(t, err)
+	require.Len(t, orders.Items, 1)
+	order := orders.Items[0]
+	assert.Equal(t, 144, order.Total)
+	assert.Equal(t, 56, order.Discount)
+	assert.Equal(t, "TEE20", order.PromoCode)
+	require.Len(t, order.Lines, 1)
+	assert.Equal(t, 100, order.Lines[0].ListPrice)
+	assert.Equal(t, 72, order.Lines[0].Price)
+	assert.Equal(t, sale.Id, order.Lines[0].SaleId)
+
+	purchases, err := userService.PurchaseHistory(ctx, "buyer", nil)
+	require.NoError(t, err)
+	require.Len(t, purchases.Items, 1)
+	assert.Equal(t, 72, purchases.Items[0].Price)
+	assert.Equal(t, 100, purchases.Items[0].ListPrice)
+	assert.Equal(t, "TEE20", purchases.Items[0].PromoCode)
+
+	_, err = merchService.Buy(ctx, "buyer", "Кружка", "", "TEE20", 1)
+	assert.ErrorIs(t, err, models.ErrPromoUserLimit)
+
+	balance, err = merchService.Buy(ctx, "other", "Кружка", "", "tee20", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 976, balance)
+
+	_, err = merchService.Buy(ctx, "third", "Кружка", "", "TEE20", 1)
+	assert.ErrorIs(t, err, models.ErrPromoExhausted)
+
+	promos, err := promoService.Promos(ctx)
+	require.NoError(t, err)
+	require.Len(t, promos, 3)
+	assert.Equal(t, 2, promos[0].Uses)
+
+	// Отмена заказа возвращает использование промокода
+	_, err = orderService.CancelOrder(ctx, "buyer", order.Id)
+	require.NoError(t, err)
+	balance, err = merchService.Buy(ctx, "third", "Кружка", "", "TEE20", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 976, balance)
+
+	_, err = merchService.Buy(ctx, "buyer", "Футболка", "", "MUG5", 1)
+	assert.ErrorIs(t, err, models.ErrPromoNotApplicable)
+	_, err = merchService.Buy(ctx, "buyer", "Кружка", "", "OLD", 1)
+	assert.ErrorIs(t, err, models.ErrPromoInactive)
+	_, err = merchService.Buy(ctx, "buyer", "Кружка", "", "UNKNOWN", 1)
+	assert.ErrorIs(t, err, models.ErrPromoNotFound)
+
+	// В корзине фиксированная скидка действует только на свой товар
+	cart, err := merchService.AddToCart(ctx, "buyer", "Футболка", "", 1)
+	require.NoError(t, err)
+	require.Len(t, cart.Lines, 1)
+	assert.Equal(t, 90, cart.Lines[0].Price)
+	assert.Equal(t, 100, cart.Lines[0].ListPrice)
+	_, err = merchService.AddToCart(ctx, "buyer", "Кружка", "", 2)
+	require.NoError(t, err)
+
+	result, err := merchService.Checkout(ctx, "buyer", "mug5")
+	require.NoError(t, err)
+	assert.Equal(t, 90+2*25, result.Order.Total)
+	assert.Equal(t, 10+2*5, result.Order.Discount)
+	assert.Equal(t, 1000-140, result.Balance)
+
+	require.NoError(t, promoService.DeletePromo(ctx, tee20.Id))
+	assert.ErrorIs(t, promoService.DeletePromo(ctx, tee20.Id), models.ErrPromoNotFound)
+	_, err = merchService.Buy(ctx, "buyer", "Кружка", "", "TEE20", 1)
+	assert.ErrorIs(t, err, models.ErrPromoNotFound)
+
+	require.NoError(t, promoService.DeleteSale(ctx, sale.Id))
+	assert.ErrorIs(t, promoService.DeleteSale(ctx, sale.Id), models.ErrSaleNotFound)
+	sales, err := promoService.Sales(ctx)
+	require.NoError(t, err)
+	assert.Len(t, sales, 1)
+
+	report, err := service.NewLedgerService(ledgerStorage, nil, nil, nil, nil).Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+// TestMediaServiceImages - проверяет изображения товаров в MediaService:
+// - загрузку оригинала и миниатюры, вписанной в квадрат ThumbnailSize
+// - изображения в каталоге MerchList
+// - отказ для неподдерживаемого типа, битого и слишком большого изображения,
+// повторной загрузки и несуществующего товара
+// - удаление изображения вместе с файлами
+func TestMediaServiceImages(t *testing.T) {
+	ctx := context.Background()
+	merchStorage := mock.NewMockMerchStorage()
+	userStorage := mock.NewMockUserStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	imageStorage := mock.NewMockImageStorage(merchStorage)
+	blobStorage := mock.NewMockBlobStorage()
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, mock.NewMockCoinsStorage(), mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, imageStorage, mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+	mediaService := service.NewMediaService(imageStorage, merchStorage, blobStorage)
+
+	data := testImage(t, 600, 300)
+	img, err := mediaService.UploadImage(ctx, 1, data)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+	assert.Equal(t, int64(len(data)), img.Size)
+	assert.Equal(t, 600, img.Width)
+	assert.Equal(t, 300, img.Height)
+	assert.True(t, strings.HasPrefix(img.URL, models.MediaPath+"merch/1/"))
+	assert.True(t, strings.HasSuffix(img.ThumbnailURL, "_thumb.png"))
+
+	file, _, err := mediaService.OpenMedia(ctx, img.ThumbKey)
+	require.NoError(t, err)
+	config, format, err := image.DecodeConfig(file)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, models.ThumbnailSize, config.Width)
+	assert.Equal(t, models.ThumbnailSize/2, config.Height)
+
+	_, err = mediaService.UploadImage(ctx, 1, data)
+	assert.ErrorIs(t, err, models.ErrImageExists)
+
+	// Маленькое изображение не увеличивается
+	small, err := mediaService.UploadImage(ctx, 1, testImage(t, 10, 20))
+	require.NoError(t, err)
+
+	page, err := merchService.MerchList(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Items[0].Images, 2)
+	assert.Equal(t, img.URL, page.Items[0].Images[0].URL)
+	assert.Equal(t, small.ThumbnailURL, page.Items[0].Images[1].ThumbnailURL)
+	assert.Empty(t, page.Items[1].Images)
+
+	_, err = mediaService.UploadImage(ctx, 1, []byte("просто текст, а не картинка"))
+	assert.ErrorIs(t, err, models.ErrUnsupportedImageType)
+
+	_, err = mediaService.UploadImage(ctx, 1, data[:len(data)/2])
+	assert.ErrorIs(t, err, models.ErrInvalidImage)
+
+	_, err = mediaService.UploadImage(ctx, 1, testImage(t, models.MaxImageDimension+1, 1))
+	assert.ErrorIs(t, err, models.ErrImageTooLarge)
+
+	_, err = mediaService.UploadImage(ctx, 1, append(data, make([]byte, models.MaxImageSize)...))
+	assert.ErrorIs(t, err, models.ErrImageTooLarge)
+
+	_, err = mediaService.UploadImage(ctx, 100, data)
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	assert.Len(t, blobStorage.Keys(), 4, "отклоненные загрузки не оставляют файлов")
+
+	err = mediaService.DeleteImage(ctx, 2, img.Id)
+	assert.ErrorIs(t, err, models.ErrImageNotFound, "изображение другого товара")
+
+	require.NoError(t, mediaService.DeleteImage(ctx, 1, img.Id))
+	assert.ElementsMatch(t, []string{small.Key, small.ThumbKey}, blobStorage.Keys())
+
+	_, _, err = mediaService.OpenMedia(ctx, img.Key)
+	assert.ErrorIs(t, err, models.ErrBlobNotFound)
+
+	err = mediaService.DeleteImage(ctx, 1, img.Id)
+	assert.ErrorIs(t, err, models.ErrImageNotFound)
+}
+
+func TestTransactionServiceSend(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name              string
+		senderLogin       string
+		receiverLogin     string
+		amount            int
+		prepare           func(*mock.MockUserStorage)
+		wantErr           error
+		wantSenderCoins   int // -1 означает не проверять
+		wantReceiverCoins int // -1 означает не проверять
+	}{
+		{
+			name:          "Успешный перевод",
+			senderLogin:   "sender",
+			receiverLogin: "receiver",
+			amount:        300,
+			prepare: func(us *mock.MockUserStorage) {
+				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
+				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
+			},
+			wantErr:           nil,
+			wantSenderCoins:   700,
+			wantReceiverCoins: 800,
+		},
+		{
+			name:          "Отправитель не найден",
+			senderLogin:   "unknown",
+			receiverLogin: "receiver",
+			amount:        100,
+			prepare: func(us *mock.MockUserStorage) {
+				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
+			},
+			wantErr:           models.ErrUserNotFound,
+			wantSenderCoins:   -1,
+			wantReceiverCoins: 500,
+		},
+		{
+			name:          "Недостаточно средств",
+			senderLogin:   "sender",
+			receiverLogin: "receiver",
+			amount:        1500,
+			prepare: func(us *mock.MockUserStorage) {
+				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
+				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
+			},
+			wantErr:           models.ErrNotEnoughCoins,
+			wantSenderCoins:   1000,
+			wantReceiverCoins: 500,
+		},
+		{
+			name:          "Перевод самому себе",
+			senderLogin:   "sender",
+			receiverLogin: "sender",
+			amount:        100,
+			prepare: func(us *mock.MockUserStorage) {
+				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
+			},
+			wantErr:           models.ErrSameSenderReceiver,
+			wantSenderCoins:   1000,
+			wantReceiverCoins: 1000,
+		},
+		{
+			name:          "Отрицательная сумма",
+			senderLogin:   "sender",
+			receiverLogin: "receiver",
+			amount:        -100,
+			prepare: func(us *mock.MockUserStorage) {
+				us.Create(ctx, &models.User{Login: "sender", Coins: 1000})
+				us.Create(ctx, &models.User{Login: "receiver", Coins: 500})
+			},
+			wantErr:           models.ErrInvalidAmount,
+			wantSenderCoins:   1000,
+			wantReceiverCoins: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userStorage := mock.NewMockUserStorage()
+			transactionStorage := mock.NewMockTransactionStorage()
+			coinsStorage := mock.NewMockCoinsStorage()
+			service := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, mock.NewMockTxManager(), mock.NewMockLedgerStorage(userStorage), mock.NewMockScheduleStorage(), mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+
+			if tc.prepare != nil {
+				tc.prepare(userStorage)
+			}
+
+			err := service.Send(ctx, tc.senderLogin, tc.receiverLogin, tc.amount, models.TransferNote{})
+
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if tc.wantSenderCoins > 0 {
+				sender, err := userStorage.GetByLogin(ctx, tc.senderLogin)
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantSenderCoins, sender.Coins)
+			}
+
+			if tc.wantReceiverCoins > 0 {
+				receiver, err := userStorage.GetByLogin(ctx, tc.receiverLogin)
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantReceiverCoins, receiver.Coins)
+			}
+
+			if tc.wantErr == nil {
+				sender, err := userStorage.GetByLogin(ctx, tc.senderLogin)
+				require.NoError(t, err)
+
+				senderHistory, err := coinsStorage.Get(ctx, sender)
+				require.NoError(t, err)
+				require.Len(t, senderHistory, 1)
+				assert.Equal(t, sender.Coins+tc.amount, senderHistory[0].CoinsBefore)
+				assert.Equal(t, sender.Coins, senderHistory[0].CoinsAfter)
+
+				receiver, err := userStorage.GetByLogin(ctx, tc.receiverLogin)
+				require.NoError(t, err)
+
+				receiverHistory, err := coinsStorage.Get(ctx, receiver)
+				require.NoError(t, err)
+				require.Len(t, receiverHistory, 1)
+				assert.Equal(t, receiver.Coins-tc.amount, receiverHistory[0].CoinsBefore)
+				assert.Equal(t, receiver.Coins, receiverHistory[0].CoinsAfter)
+			}
+		})
+	}
+}
+
+// TestLedgerService - проверяет журнал монет:
+// - регистрация начисляет SignupBonus записью журнала
+// - покупка и перевод меняют балансы только через журнал
+// - сверка сходится после всех операций и замечает баланс, измененный в обход журнала
+// - несбалансированная запись и неизвестный счет отклоняются
+func TestLedgerService(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	transactionStorage := mock.NewMockTransactionStorage()
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, transactionStorage, txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockScheduleStorage(), mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+	ledgerService := service.NewLedgerService(ledgerStorage, userStorage, coinsStorage, txManager, mock.NewMockGrantStorage())
+
+	for _, login := range []string{"alice", "bob"} {
+		require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: login, Password: "password"}))
+	}
+
+	alice, err := userStorage.GetByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus, alice.Coins)
+
+	balance, err := merchService.Buy(ctx, "alice", "Футболка", "", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus-200, balance)
+
+	require.NoError(t, transactionService.Send(ctx, "alice", "bob", 300, models.TransferNote{}))
+	assert.ErrorIs(t, transactionService.Send(ctx, "alice", "bob", 10000, models.TransferNote{}), models.ErrNotEnoughCoins)
+
+	ledgerBalance, err := ledgerStorage.Balance(ctx, alice.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus-500, ledgerBalance)
+
+	bob, err := userStorage.GetByLogin(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus+300, bob.Coins)
+
+	report, err := ledgerService.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+
+	bob.Coins += 50
+	require.NoError(t, userStorage.Update(ctx, bob))
+
+	report, err = ledgerService.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Zero(t, report.Total)
+	assert.Equal(t, []models.BalanceMismatch{{UserId: bob.Id, Login: "bob", Cached: bob.Coins, Ledger: bob.Coins - 50}}, report.BalanceMismatches)
+
+	err = ledgerStorage.Post(ctx, &models.JournalEntry{
+		Reason:   models.ReasonTransfer,
+		Postings: []models.Posting{{UserId: alice.Id, Amount: -10}, {UserId: bob.Id, Amount: 20}},
+	})
+	assert.ErrorIs(t, err, models.ErrUnbalancedEntry)
+
+	err = ledgerStorage.Post(ctx, models.NewTransferEntry(models.ReasonTransfer, 0,
+		models.Posting{System: "casino"},
+		models.Posting{UserId: alice.Id},
+		10,
+	))
+	assert.ErrorIs(t, err, models.ErrAccountNotFound)
+}
+
+// TestLedgerServiceGrants - проверяет начисления из казны:
+// - CSV с заголовком разбирается, ошибочные строки получают ошибку и номер строки
+// - пробный запуск возвращает отчет и ничего не начисляет
+// - пакет с ошибочной строкой не начисляется целиком
+// - корректный пакет начисляется одной записью журнала казны и пишется в историю кошелька
+// - сверка журнала после начисления сходится
+// - пустой и слишком большой пакеты отклоняются
+func TestLedgerServiceGrants(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+
+	userService := service.NewUserService(userStorage, mock.NewMockPurchaseStorage(), coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	ledgerService := service.NewLedgerService(ledgerStorage, userStorage, coinsStorage, txManager, mock.NewMockGrantStorage())
+
+	for _, login := range []string{"admin", "alice", "bob"} {
+		require.NoError(t, userService.Register(ctx, &models.LoginRequest{Login: login, Password: "password"}))
+	}
+
+	rows, err := models.ParseGrantCSV(strings.NewReader("login,amount,reason\nalice,100,Премия за квартал\n\nbob,abc,Ошибка\nbob,50\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, models.GrantRow{Line: 2, Login: "alice", Amount: 100, Reason: "Премия за квартал"}, rows[0])
+	assert.Equal(t, 4, rows[1].Line)
+	assert.Equal(t, models.ErrInvalidGrantAmount.Error(), rows[1].Error)
+	assert.Equal(t, 5, rows[2].Line)
+	assert.Equal(t, models.ErrInvalidGrantRow.Error(), rows[2].Error)
+
+	_, err = models.ParseGrantCSV(strings.NewReader("alice,\"100,reason\n"))
+	assert.ErrorIs(t, err, models.ErrInvalidGrantCSV)
+
+	req := models.GrantRequest{Rows: []models.GrantRow{
+		{Login: " alice ", Amount: 100, Reason: "Премия"},
+		{Login: "bob", Amount: 250, Reason: "Хакатон", Error: "пришло в запросе"},
+		{Login: "alice", Amount: 30, Reason: "Доклад"},
+	}}
+
+	report, err := ledgerService.Grant(ctx, "admin", req.Numbered(), true)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.False(t, report.Applied)
+	assert.Zero(t, report.Id)
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, 380, report.Total)
+	assert.Equal(t, "alice", report.Rows[0].Login)
+
+	alice, err := userStorage.GetByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus, alice.Coins)
+
+	invalid := append(req.Numbered(),
+		models.GrantRow{Line: 4, Login: "nobody", Amount: 10, Reason: "Нет такого"},
+		models.GrantRow{Line: 5, Login: "bob", Amount: 0, Reason: "Ноль"},
+		models.GrantRow{Line: 6, Login: "bob", Amount: 10, Reason: "   "},
+	)
+	report, err = ledgerService.Grant(ctx, "admin", invalid, false)
+	assert.ErrorIs(t, err, models.ErrInvalidGrantBatch)
+	require.NotNil(t, report)
+	assert.False(t, report.Applied)
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, 3, report.Invalid)
+	assert.Equal(t, models.ErrUserNotFound.Error(), report.Rows[3].Error)
+	assert.Equal(t, models.ErrInvalidGrantAmount.Error(), report.Rows[4].Error)
+	assert.Equal(t, models.ErrInvalidGrantReason.Error(), report.Rows[5].Error)
+
+	alice, err = userStorage.GetByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus, alice.Coins)
+
+	report, err = ledgerService.Grant(ctx, "admin", req.Numbered(), false)
+	require.NoError(t, err)
+	assert.True(t, report.Applied)
+	assert.Equal(t, 1, report.Id)
+	assert.Equal(t, 380, report.Total)
+
+	alice, err = userStorage.GetByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus+130, alice.Coins)
+	bob, err := userStorage.GetByLogin(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignupBonus+250, bob.Coins)
+
+	history, err := coinsStorage.Get(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, models.ReasonGrant, history[0].Reason)
+	assert.Equal(t, 1, history[0].GrantId)
+	assert.Equal(t, "Премия", history[0].Message)
+	assert.Equal(t, models.SignupBonus, history[0].CoinsBefore)
+	assert.Equal(t, models.SignupBonus+100, history[0].CoinsAfter)
+	assert.Equal(t, models.SignupBonus+130, history[1].CoinsAfter)
+
+	ledgerReport, err := ledgerService.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.True(t, ledgerReport.OK(), "%+v", ledgerReport)
+
+	_, err = ledgerService.Grant(ctx, "admin", nil, false)
+	assert.ErrorIs(t, err, models.ErrEmptyGrantBatch)
+
+	_, err = ledgerService.Grant(ctx, "admin", make([]models.GrantRow, models.MaxGrantRows+1), true)
+	assert.ErrorIs(t, err, models.ErrGrantBatchTooLarge)
+}
+
+// TestIdempotencyService - проверяет ключи идемпотентности:
+// - невалидный ключ и неизвестный пользователь
+// - повтор до завершения первого запроса получает ErrIdempotencyInProgress
+// - повтор после завершения получает сохраненный ответ
+// - ключ с другим запросом отклоняется
+// - снятый и истекший ключи можно использовать снова
+func TestIdempotencyService(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	idempotencyService := service.NewIdempotencyService(mock.NewMockIdempotencyStorage(), userStorage)
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "buyer", Password: "password"}))
+
+	hash := service.HashRequest("POST", "/merch/buy", []byte(`{"name":"Футболка","count":1}`))
+	otherHash := service.HashRequest("POST", "/merch/buy", []byte(`{"name":"Футболка","count":2}`))
+	assert.NotEqual(t, hash, otherHash)
+
+	for _, key := range []string{"", "с пробелом и кириллицей", strings.Repeat("k", 256)} {
+		_, err := idempotencyService.Begin(ctx, "buyer", key, hash, time.Hour)
+		assert.ErrorIs(t, err, models.ErrInvalidIdempotencyKey)
+	}
+
+	_, err := idempotencyService.Begin(ctx, "nobody", "key-1", hash, time.Hour)
+	assert.ErrorIs(t, err, models.ErrUserNotFound)
+
+	record, err := idempotencyService.Begin(ctx, "buyer", "key-1", hash, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, record.Completed())
+
+	_, err = idempotencyService.Begin(ctx, "buyer", "key-1", hash, time.Hour)
+	assert.ErrorIs(t, err, models.ErrIdempotencyInProgress)
+
+	require.NoError(t, idempotencyService.Complete(ctx, record, 200, []byte(`{"error_code":200}`)))
+
+	replay, err := idempotencyService.Begin(ctx, "buyer", "key-1", hash, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, replay.Completed())
+	assert.Equal(t, 200, replay.StatusCode)
+	assert.JSONEq(t, `{"error_code":200}`, string(replay.Response))
+
+	_, err = idempotencyService.Begin(ctx, "buyer", "key-1", otherHash, time.Hour)
+	assert.ErrorIs(t, err, models.ErrIdempotencyKeyReused)
+
+	record, err = idempotencyService.Begin(ctx, "buyer", "key-2", hash, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, idempotencyService.Abort(ctx, record))
+	record, err = idempotencyService.Begin(ctx, "buyer", "key-2", otherHash, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, record.Completed())
+
+	record, err = idempotencyService.Begin(ctx, "buyer", "key-3", hash, time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, idempotencyService.Complete(ctx, record, 200, []byte(`{}`)))
+	time.Sleep(5 * time.Millisecond)
+
+	record, err = idempotencyService.Begin(ctx, "buyer", "key-3", otherHash, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, record.Completed())
+
+	// Ключ, брошенный упавшим запросом, занимает повтор после истечения аренды
+	idempotencyService.Lease = time.Millisecond
+	_, err = idempotencyService.Begin(ctx, "buyer", "key-4", hash, time.Hour)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = idempotencyService.Begin(ctx, "buyer", "key-4", otherHash, time.Hour)
+	assert.ErrorIs(t, err, models.ErrIdempotencyKeyReused)
+
+	idempotencyService.Lease = time.Hour
+	record, err = idempotencyService.Begin(ctx, "buyer", "key-4", hash, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, record.Completed())
+
+	_, err = idempotencyService.Begin(ctx, "buyer", "key-4", hash, time.Hour)
+	assert.ErrorIs(t, err, models.ErrIdempotencyInProgress)
+
+	require.NoError(t, idempotencyService.Complete(ctx, record, 200, []byte(`{}`)))
+	replay, err = idempotencyService.Begin(ctx, "buyer", "key-4", hash, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, replay.Completed())
+}
+
+// TestUserServiceTransferHistory - проверяет метод TransferHistory в UserService:
+// - отправленные и полученные переводы с логином второй стороны
+// - фильтр по направлению
+// - фильтр по периоду
+// - невалидные фильтры
+func TestUserServiceTransferHistory(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	transactionStorage := mock.NewMockTransactionStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	userService := service.NewUserService(userStorage, mock.NewMockPurchaseStorage(), coinsStorage, transactionStorage, txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockScheduleStorage(), mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+
+	for _, login := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	require.NoError(t, transactionService.Send(ctx, "alice", "bob", 100, models.TransferNote{}))
+	require.NoError(t, transactionService.Send(ctx, "bob", "alice", 30, models.TransferNote{}))
+	require.NoError(t, transactionService.Send(ctx, "carol", "bob", 5, models.TransferNote{}))
+
+	all, err := userService.TransferHistory(ctx, "bob", nil)
+	require.NoError(t, err)
+	require.Len(t, all.Items, 3)
+	assert.Equal(t, "carol", all.Items[0].Counterpart)
+	assert.Equal(t, models.TransferReceived, all.Items[0].Direction)
+	assert.Equal(t, "alice", all.Items[1].Counterpart)
+	assert.Equal(t, models.TransferSent, all.Items[1].Direction)
+	assert.Equal(t, 30, all.Items[1].Amount)
+
+	sent, err := userService.TransferHistory(ctx, "bob", &models.TransferFilter{Direction: models.TransferSent})
+	require.NoError(t, err)
+	require.Len(t, sent.Items, 1)
+	assert.Equal(t, "alice", sent.Items[0].Counterpart)
+
+	received, err := userService.TransferHistory(ctx, "bob", &models.TransferFilter{Direction: models.TransferReceived})
+	require.NoError(t, err)
+	assert.Len(t, received.Items, 2)
+
+	future, err := userService.TransferHistory(ctx, "bob", &models.TransferFilter{
+		PageQuery: models.PageQuery{From: time.Now().Add(time.Hour)},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, future.Items)
+
+	past, err := userService.TransferHistory(ctx, "bob", &models.TransferFilter{
+		PageQuery: models.PageQuery{To: time.Now().Add(time.Hour)},
+	})
+	require.NoError(t, err)
+	assert.Len(t, past.Items, 3)
+
+	_, err = userService.TransferHistory(ctx, "bob", &models.TransferFilter{Direction: "sideways"})
+	assert.ErrorIs(t, err, models.ErrInvalidDirection)
+
+	_, err = userService.TransferHistory(ctx, "bob", &models.TransferFilter{
+		PageQuery: models.PageQuery{From: time.Now(), To: time.Now().Add(-time.Hour)},
+	})
+	assert.ErrorIs(t, err, models.ErrInvalidDateRange)
+
+	_, err = userService.TransferHistory(ctx, "nobody", nil)
+	assert.ErrorIs(t, err, models.ErrUserNotFound)
+}
+
+// TestTransactionServiceNotes - сообщения, поводы, реакции и ответы к переводам:
+// - сообщение очищается от управляющих символов, длинное сообщение и неизвестный повод отклоняются
+// - повод и сообщение видны обеим сторонам в истории переводов и в истории кошелька
+// - реагировать и отвечать может только получатель, ответить можно один раз
+func TestTransactionServiceNotes(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	transactionStorage := mock.NewMockTransactionStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	userService := service.NewUserService(userStorage, mock.NewMockPurchaseStorage(), coinsStorage, transactionStorage, txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockScheduleStorage(), mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+
+	for _, login := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+		wantErr error
+	}{
+		{name: "обычный текст", message: "Спасибо за ревью!", want: "Спасибо за ревью!"},
+		{name: "пробелы по краям", message: "  \tспасибо \n\n\n\n за помощь  ", want: "спасибо\n\nза помощь"},
+		{name: "управляющие символы", message: "при\x00вет\u202e\u200b", want: "привет"},
+		{name: "ровно 280 символов", message: strings.Repeat("ы", models.MaxTransferMessageLen), want: strings.Repeat("ы", models.MaxTransferMessageLen)},
+		{name: "длиннее 280 символов", message: strings.Repeat("ы", models.MaxTransferMessageLen+1), wantErr: models.ErrTransferMessageTooLong},
+		{name: "не UTF-8", message: "\xff\xfe", wantErr: models.ErrInvalidTransferMessage},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := models.SanitizeMessage(tc.message)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	err := transactionService.Send(ctx, "alice", "bob", 10, models.TransferNote{Category: "bribe"})
+	assert.ErrorIs(t, err, models.ErrInvalidTransferCategory)
+	err = transactionService.Send(ctx, "alice", "bob", 10, models.TransferNote{Message: strings.Repeat("a", 281)})
+	assert.ErrorIs(t, err, models.ErrTransferMessageTooLong)
+
+	// Отклоненный перевод не списывает монеты
+	alice, err := userStorage.GetByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1000, alice.Coins)
+
+	note := models.TransferNote{Category: models.TransferThanks, Message: " Спасибо за\x07 ревью! "}
+	require.NoError(t, transactionService.Send(ctx, "alice", "bob", 50, note))
+	require.NoError(t, transactionService.Send(ctx, "carol", "bob", 5, models.TransferNote{}))
+
+	history, err := userService.TransferHistory(ctx, "bob", nil)
+	require.NoError(t, err)
+	require.Len(t, history.Items, 2)
+	assert.Empty(t, history.Items[0].Category)
+	assert.Empty(t, history.Items[0].Message)
+	thanks := history.Items[1]
+	assert.Equal(t, models.TransferThanks, thanks.Category)
+	assert.Equal(t, "Спасибо за ревью!", thanks.Message)
+
+	sent, err := userService.TransferHistory(ctx, "alice", nil)
+	require.NoError(t, err)
+	require.Len(t, sent.Items, 1)
+	assert.Equal(t, "Спасибо за ревью!", sent.Items[0].Message)
+
+	coins, err := userService.CoinsHistory(ctx, "bob", nil)
+	require.NoError(t, err)
+	require.Len(t, coins.Items, 2)
+	assert.Equal(t, models.ReasonTransfer, coins.Items[1].Reason)
+	assert.Equal(t, thanks.Id, coins.Items[1].TransferId)
+	assert.Equal(t, models.TransferThanks, coins.Items[1].Category)
+	assert.Equal(t, "Спасибо за ревью!", coins.Items[1].Message)
+
+	// Реакция только от получателя
+	_, err = transactionService.React(ctx, "alice", thanks.Id, models.ReactionHeart)
+	assert.ErrorIs(t, err, models.ErrTransferNotFound)
+	_, err = transactionService.React(ctx, "carol", thanks.Id, models.ReactionHeart)
+	assert.ErrorIs(t, err, models.ErrTransferNotFound)
+	_, err = transactionService.React(ctx, "bob", thanks.Id, "🔥")
+	assert.ErrorIs(t, err, models.ErrInvalidTransferReaction)
+
+	entry, err := transactionService.React(ctx, "bob", thanks.Id, models.ReactionHeart)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReactionHeart, entry.Reaction)
+	assert.Equal(t, models.TransferReceived, entry.Direction)
+
+	entry, err = transactionService.React(ctx, "bob", thanks.Id, models.ReactionNone)
+	require.NoError(t, err)
+	assert.Empty(t, entry.Reaction)
+	_, err = transactionService.React(ctx, "bob", thanks.Id, models.ReactionParty)
+	require.NoError(t, err)
+
+	// Ответ только от получателя и только один раз
+	_, err = transactionService.Reply(ctx, "alice", thanks.Id, "не за что")
+	assert.ErrorIs(t, err, models.ErrTransferNotFound)
+	_, err = transactionService.Reply(ctx, "bob", thanks.Id, " \u200b ")
+	assert.ErrorIs(t, err, models.ErrEmptyTransferReply)
+
+	entry, err = transactionService.Reply(ctx, "bob", thanks.Id, "Всегда рад помочь  ")
+	require.NoError(t, err)
+	assert.Equal(t, "Всегда рад помочь", entry.Reply)
+	require.NotNil(t, entry.RepliedAt)
+
+	_, err = transactionService.Reply(ctx, "bob", thanks.Id, "еще раз")
+	assert.ErrorIs(t, err, models.ErrTransferReplied)
+
+	// Отправитель видит реакцию и ответ
+	sent, err = userService.TransferHistory(ctx, "alice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReactionParty, sent.Items[0].Reaction)
+	assert.Equal(t, "Всегда рад помочь", sent.Items[0].Reply)
+
+	// Параллельные ответы: сохраняется ровно один
+	require.NoError(t, transactionService.Send(ctx, "carol", "alice", 1, models.TransferNote{Category: models.TransferBet}))
+	gift, err := userService.TransferHistory(ctx, "alice", &models.TransferFilter{Direction: models.TransferReceived})
+	require.NoError(t, err)
+	id := gift.Items[0].Id
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	replied := 0
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := transactionService.Reply(ctx, "alice", id, fmt.Sprintf("ответ %d", i))
+			if err == nil {
+				mu.Lock()
+				replied++
+				mu.Unlock()
+				return
+			}
+			assert.ErrorIs(t, err, models.ErrTransferReplied)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, replied)
+}
+
+// TestTransactionServiceSchedules - запланированные переводы:
+// - правила once, weekly и monthly считают следующее срабатывание, в том числе в коротком месяце,
+// по дням UTC
+// - срабатывание выполняется один раз, даже если планировщики работают параллельно
+// - нехватка монет пропускает срабатывание с причиной, пропущенные за простой схлопываются в одно
+// - видеть срабатывания и отменять перевод может только отправитель
+func TestTransactionServiceSchedules(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	transactionStorage := mock.NewMockTransactionStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	scheduleStorage := mock.NewMockScheduleStorage()
+
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	transactionService := service.NewTransactionService(transactionStorage, userStorage, coinsStorage, txManager, ledgerStorage, scheduleStorage, mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+
+	for _, login := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	rules := []struct {
+		name   string
+		rule   models.ScheduleRule
+		start  time.Time
+		after  time.Time
+		want   time.Time
+		wantOk bool
+	}{
+		{name: "разовый впереди", rule: models.ScheduleOnce, start: date(3, 1, 9), after: date(2, 1, 0), want: date(3, 1, 9), wantOk: true},
+		{name: "разовый выполнен", rule: models.ScheduleOnce, start: date(3, 1, 9), after: date(3, 1, 9)},
+		{name: "еженедельный до начала", rule: models.ScheduleWeekly, start: date(3, 3, 9), after: date(3, 1, 0), want: date(3, 3, 9), wantOk: true},
+		{name: "еженедельный в момент срабатывания", rule: models.ScheduleWeekly, start: date(3, 3, 9), after: date(3, 3, 9), want: date(3, 10, 9), wantOk: true},
+		{name: "еженедельный после простоя", rule: models.ScheduleWeekly, start: date(3, 3, 9), after: date(3, 30, 0), want: date(3, 31, 9), wantOk: true},
+		{name: "ежемесячный 31 числа в феврале", rule: models.ScheduleMonthly, start: date(1, 31, 9), after: date(2, 1, 0), want: date(2, 28, 9), wantOk: true},
+		{name: "ежемесячный 31 числа после февраля", rule: models.ScheduleMonthly, start: date(1, 31, 9), after: date(2, 28, 9), want: date(3, 31, 9), wantOk: true},
+		{name: "ежемесячный в тот же день позже", rule: models.ScheduleMonthly, start: date(1, 15, 9), after: date(6, 15, 8), want: date(6, 15, 9), wantOk: true},
+		{name: "ежемесячный через год", rule: models.ScheduleMonthly, start: date(12, 1, 9), after: time.Date(2026, 12, 1, 9, 0, 0, 0, time.UTC), want: time.Date(2027, 1, 1, 9, 0, 0, 0, time.UTC), wantOk: true},
+		{name: "ежемесячный с часовым поясом по дням UTC", rule: models.ScheduleMonthly, start: time.Date(2025, 2, 1, 1, 0, 0, 0, time.FixedZone("MSK", 3*60*60)), after: date(2, 1, 0), want: date(2, 28, 22), wantOk: true},
+	}
+
+	for _, tc := range rules {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.rule.Next(tc.start, tc.after)
+			assert.Equal(t, tc.wantOk, ok)
+			if tc.wantOk {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+
+	now := time.Now()
+	start := now.Add(time.Hour)
+	schedule := func(sender, receiver string, amount int, rule models.ScheduleRule, at time.Time) (*models.ScheduledTransfer, error) {
+		return transactionService.Schedule(ctx, sender, &models.ScheduleRequest{
+			Reciever:     receiver,
+			Amount:       amount,
+			Rule:         rule,
+			StartAt:      at,
+			TransferNote: models.TransferNote{Category: models.TransferThanks, Message: " Премия "},
+		})
+	}
+
+	_, err := schedule("alice", "bob", 100, models.ScheduleWeekly, now.Add(-time.Minute))
+	assert.ErrorIs(t, err, models.ErrScheduleInPast)
+	_, err = schedule("alice", "bob", 100, "daily", start)
+	assert.ErrorIs(t, err, models.ErrInvalidScheduleRule)
+	_, err = schedule("alice", "bob", 0, models.ScheduleWeekly, start)
+	assert.ErrorIs(t, err, models.ErrInvalidAmount)
+	_, err = schedule("alice", "alice", 100, models.ScheduleWeekly, start)
+	assert.ErrorIs(t, err, models.ErrSameSenderReceiver)
+	_, err = schedule("alice", "nobody", 100, models.ScheduleWeekly, start)
+	assert.ErrorIs(t, err, models.ErrUserNotFound)
+
+	weekly, err := schedule("alice", "bob", 100, models.ScheduleWeekly, start)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleActive, weekly.Status)
+	assert.Equal(t, "Премия", weekly.Message)
+	require.NotNil(t, weekly.NextRunAt)
+	assert.True(t, weekly.NextRunAt.Equal(start))
+
+	monthly, err := schedule("carol", "bob", 5000, models.ScheduleMonthly, start)
+	require.NoError(t, err)
+	once, err := schedule("alice", "carol", 10, models.ScheduleOnce, start)
+	require.NoError(t, err)
+
+	balance := func(login string) int {
+		user, err := userStorage.GetByLogin(ctx, login)
+		require.NoError(t, err)
+		return user.Coins
+	}
+
+	// Срабатывания еще не наступили
+	n, err := transactionService.RunSchedules(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	first := start.Add(time.Minute)
+	n, err = transactionService.RunSchedules(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	assert.Equal(t, 890, balance("alice"))
+	assert.Equal(t, 1100, balance("bob"))
+	assert.Equal(t, 1010, balance("carol"))
+
+	// Повторный проход в тот же момент ничего не переводит
+	n, err = transactionService.RunSchedules(ctx, first)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, 890, balance("alice"))
+
+	got, err := scheduleStorage.Get(ctx, weekly.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleActive, got.Status)
+	require.NotNil(t, got.NextRunAt)
+	assert.True(t, got.NextRunAt.Equal(start.Add(7*24*time.Hour)))
+	require.NotNil(t, got.LastRun)
+	assert.Equal(t, models.ScheduleRunExecuted, got.LastRun.Status)
+	assert.NotZero(t, got.LastRun.TransferId)
+
+	got, err = scheduleStorage.Get(ctx, once.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleCompleted, got.Status)
+	assert.Nil(t, got.NextRunAt)
+
+	// Нехватка монет пропускает срабатывание с причиной, но не отменяет перевод
+	got, err = scheduleStorage.Get(ctx, monthly.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleActive, got.Status)
+	require.NotNil(t, got.LastRun)
+	assert.Equal(t, models.ScheduleRunSkipped, got.LastRun.Status)
+	assert.Equal(t, models.SkipNotEnoughCoins, got.LastRun.Reason)
+	assert.Zero(t, got.LastRun.TransferId)
+
+	// Перевод с сообщением виден в истории получателя
+	bob, err := userStorage.GetByLogin(ctx, "bob")
+	require.NoError(t, err)
+	history, err := transactionStorage.List(ctx, bob, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, history.Items)
+	assert.Equal(t, models.TransferNote{Category: models.TransferThanks, Message: "Премия"}, history.Items[0].TransferNote)
+
+	// Пропущенные за месяц простоя недельные срабатывания схлопываются в одно
+	n, err = transactionService.RunSchedules(ctx, start.Add(35*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 790, balance("alice"))
+
+	runs, err := transactionService.ScheduleRuns(ctx, "alice", weekly.Id, nil)
+	require.NoError(t, err)
+	assert.Len(t, runs.Items, 2)
+	_, err = transactionService.ScheduleRuns(ctx, "bob", weekly.Id, nil)
+	assert.ErrorIs(t, err, models.ErrScheduleNotFound)
+
+	list, err := transactionService.Schedules(ctx, "alice", nil)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	assert.Equal(t, once.Id, list.Items[0].Id)
+
+	_, err = transactionService.CancelSchedule(ctx, "bob", weekly.Id)
+	assert.ErrorIs(t, err, models.ErrScheduleNotFound)
+	cancelled, err := transactionService.CancelSchedule(ctx, "alice", weekly.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleCancelled, cancelled.Status)
+	assert.Nil(t, cancelled.NextRunAt)
+	_, err = transactionService.CancelSchedule(ctx, "alice", weekly.Id)
+	assert.ErrorIs(t, err, models.ErrScheduleNotActive)
+	_, err = transactionService.CancelSchedule(ctx, "alice", once.Id)
+	assert.ErrorIs(t, err, models.ErrScheduleNotActive)
+
+	// Параллельные планировщики выполняют срабатывание один раз
+	require.NoError(t, transactionService.Send(ctx, "alice", "carol", 500, models.TransferNote{}))
+	carolBefore := balance("carol")
+
+	var (
+		wg    sync.WaitGroup
+		total atomic.Int64
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := transactionService.RunSchedules(ctx, start.Add(70*24*time.Hour))
+			assert.NoError(t, err)
+			total.Add(int64(n))
+		}()
+	}
+	wg.Wait()
+
+	// У carol все еще не хватает монет, срабатывание пропущено
+	assert.EqualValues(t, 1, total.Load())
+	assert.Equal(t, carolBefore, balance("carol"))
+
+	runs, err = transactionService.ScheduleRuns(ctx, "carol", monthly.Id, nil)
+	require.NoError(t, err)
+	assert.Len(t, runs.Items, 3)
+}
+
+// TestHistoryPagination - проверяет постраничный обход истории:
+// - страницы не пересекаются и вместе дают всю историю от новых к старым
+// - у последней страницы нет next_cursor
+// - невалидные размер страницы и курсор
+func TestHistoryPagination(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	merchStorage := mock.NewMockMerchStorage()
+	purchaseStorage := mock.NewMockPurchaseStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+
+	userService := service.NewUserService(userStorage, purchaseStorage, coinsStorage, mock.NewMockTransactionStorage(), txManager, ledgerStorage, mock.NewMockSessionStorage(), mock.NewMockRefreshTokenStorage())
+	variantStorage := mock.NewMockVariantStorage(merchStorage)
+	merchService := service.NewMerchService(merchStorage, userStorage, purchaseStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockOrderStorage(userStorage, purchaseStorage), mock.NewMockCartStorage(merchStorage, variantStorage), variantStorage, mock.NewMockImageStorage(merchStorage), mock.NewMockPromoStorage(), mock.NewMockReservationStorage(), nil)
+
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "buyer", Coins: 1000}))
+
+	const buys = 7
+	for range buys {
+		_, err := merchService.Buy(ctx, "buyer", "Футболка", "", "", 1)
+		require.NoError(t, err)
+	}
+
+	var (
+		seen  []int
+		query = &models.PageQuery{Limit: 3}
+		pages int
+	)
+	for {
+		page, err := userService.CoinsHistory(ctx, "buyer", query)
+		require.NoError(t, err)
+		pages++
+
+		for _, entry := range page.Items {
+			seen = append(seen, entry.CoinsAfter)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.After, err = models.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, pages)
+	require.Len(t, seen, buys)
+	for i := range seen {
+		// От новых к старым: баланс после покупки растет
+		assert.Equal(t, 1000-100*(buys-i), seen[i])
+	}
+
+	purchases, err := userService.PurchaseHistory(ctx, "buyer", &models.PageQuery{Limit: buys})
+	require.NoError(t, err)
+	assert.Len(t, purchases.Items, buys)
+	assert.Empty(t, purchases.NextCursor)
+
+	merch, err := merchService.MerchList(ctx, &models.MerchFilter{PageQuery: models.PageQuery{Limit: 2}})
+	require.NoError(t, err)
+	require.Len(t, merch.Items, 2)
+	assert.Equal(t, 1, merch.Items[0].Id)
+	require.NotEmpty(t, merch.NextCursor)
+
+	after, err := models.DecodeCursor(merch.NextCursor)
+	require.NoError(t, err)
+	merch, err = merchService.MerchList(ctx, &models.MerchFilter{PageQuery: models.PageQuery{Limit: 2, After: after}})
+	require.NoError(t, err)
+	require.Len(t, merch.Items, 1)
+	assert.Equal(t, 3, merch.Items[0].Id)
+	assert.Empty(t, merch.NextCursor)
+
+	_, err = userService.CoinsHistory(ctx, "buyer", &models.PageQuery{Limit: models.MaxPageLimit + 1})
+	assert.ErrorIs(t, err, models.ErrInvalidLimit)
+
+	_, err = models.DecodeCursor("не курсор")
+	assert.ErrorIs(t, err, models.ErrInvalidCursor)
+}
+
+// TestTransactionServiceCoinRequests - запросы монет:
+// - запрос проверяет сумму, получателя, повод и сообщение
+// - плательщик видит ожидающий запрос, а принятие переводит монеты с поводом и сообщением запроса
+// - принять можно только один раз и только плательщику, даже при параллельных попытках
+// - при нехватке монет запрос остается ожидающим, отклоненный запрос можно повторить
+// - истекший запрос нельзя принять, он виден со статусом expired
+// - к одному плательщику ждет ответа один запрос, всего ожидающих не больше MaxPendingCoinRequests
+func TestTransactionServiceCoinRequests(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	transactionService := service.NewTransactionService(mock.NewMockTransactionStorage(), userStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockScheduleStorage(), mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+
+	for _, login := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	invalid := []struct {
+		req *models.AskCoinsRequest
+		err error
+	}{
+		{req: &models.AskCoinsRequest{Payer: "bob", Amount: 0}, err: models.ErrInvalidAmount},
+		{req: &models.AskCoinsRequest{Payer: "alice", Amount: 10}, err: models.ErrSameSenderReceiver},
+		{req: &models.AskCoinsRequest{Payer: "nobody", Amount: 10}, err: models.ErrUserNotFound},
+		{req: &models.AskCoinsRequest{Payer: "bob", Amount: 10, TransferNote: models.TransferNote{Category: "bribe"}}, err: models.ErrInvalidTransferCategory},
+	}
+	for _, tt := range invalid {
+		_, err := transactionService.RequestCoins(ctx, "alice", tt.req, 0)
+		assert.ErrorIs(t, err, tt.err)
+	}
+
+	note := models.TransferNote{Category: models.TransferBet, Message: "  Проиграл спор  "}
+	cr, err := transactionService.RequestCoins(ctx, "alice", &models.AskCoinsRequest{Payer: "bob", Amount: 300, TransferNote: note}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestPending, cr.Status)
+	assert.Equal(t, "Проиграл спор", cr.Message)
+	assert.WithinDuration(t, time.Now().Add(service.DefaultCoinRequestTTL), cr.ExpiresAt, time.Minute)
+
+	_, err = transactionService.RequestCoins(ctx, "alice", &models.AskCoinsRequest{Payer: "bob", Amount: 10}, 0)
+	assert.ErrorIs(t, err, models.ErrCoinRequestExists)
+
+	page, err := transactionService.CoinRequests(ctx, "bob", &models.CoinRequestFilter{Direction: models.TransferReceived, Status: models.CoinRequestPending})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "alice", page.Items[0].Requester)
+
+	page, err = transactionService.CoinRequests(ctx, "bob", &models.CoinRequestFilter{Direction: models.TransferSent})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	_, err = transactionService.CoinRequests(ctx, "bob", &models.CoinRequestFilter{Status: "paid"})
+	assert.ErrorIs(t, err, models.ErrInvalidCoinRequestStatus)
+
+	_, err = transactionService.CoinRequest(ctx, "carol", cr.Id)
+	assert.ErrorIs(t, err, models.ErrCoinRequestNotFound)
+	_, err = transactionService.AcceptCoinRequest(ctx, "alice", cr.Id)
+	assert.ErrorIs(t, err, models.ErrCoinRequestNotFound)
+
+	var (
+		wg       sync.WaitGroup
+		accepted atomic.Int32
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := transactionService.AcceptCoinRequest(ctx, "bob", cr.Id)
+			if err == nil {
+				accepted.Add(1)
+				return
+			}
+			assert.ErrorIs(t, err, models.ErrCoinRequestNotPending)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), accepted.Load())
+
+	cr, err = transactionService.CoinRequest(ctx, "alice", cr.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestAccepted, cr.Status)
+	assert.NotZero(t, cr.TransferId)
+	assert.NotNil(t, cr.ResolvedAt)
+
+	alice, err := userStorage.GetByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1300, alice.Coins)
+	bob, err := userStorage.GetByLogin(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 700, bob.Coins)
+
+	history, err := coinsStorage.Get(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, cr.TransferId, history[0].TransferId)
+	assert.Equal(t, models.TransferNote{Category: models.TransferBet, Message: "Проиграл спор"}, history[0].TransferNote)
+
+	big, err := transactionService.RequestCoins(ctx, "alice", &models.AskCoinsRequest{Payer: "bob", Amount: 5000}, 0)
+	require.NoError(t, err)
+	_, err = transactionService.AcceptCoinRequest(ctx, "bob", big.Id)
+	assert.ErrorIs(t, err, models.ErrNotEnoughCoins)
+
+	big, err = transactionService.DeclineCoinRequest(ctx, "bob", big.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestDeclined, big.Status)
+	assert.Zero(t, big.TransferId)
+
+	_, err = transactionService.RequestCoins(ctx, "alice", &models.AskCoinsRequest{Payer: "bob", Amount: 50}, 0)
+	require.NoError(t, err)
+
+	short, err := transactionService.RequestCoins(ctx, "carol", &models.AskCoinsRequest{Payer: "bob", Amount: 10}, 10*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = transactionService.AcceptCoinRequest(ctx, "bob", short.Id)
+	assert.ErrorIs(t, err, models.ErrCoinRequestNotPending)
+
+	short, err = transactionService.CoinRequest(ctx, "carol", short.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestExpired, short.Status)
+
+	page, err = transactionService.CoinRequests(ctx, "carol", &models.CoinRequestFilter{Status: models.CoinRequestExpired})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, short.Id, page.Items[0].Id)
+
+	_, err = transactionService.RequestCoins(ctx, "carol", &models.AskCoinsRequest{Payer: "bob", Amount: 10}, 0)
+	require.NoError(t, err)
+
+	for i := range models.MaxPendingCoinRequests {
+		login := fmt.Sprintf("payer%d", i)
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+		_, err := transactionService.RequestCoins(ctx, "carol", &models.AskCoinsRequest{Payer: login, Amount: 1}, 0)
+		if i < models.MaxPendingCoinRequests-1 {
+			require.NoError(t, err)
+			continue
+		}
+		assert.ErrorIs(t, err, models.ErrTooManyCoinRequests)
+	}
+}
+
+// TestTransactionServiceLimits - лимиты исходящих переводов:
+// - новый аккаунт не может отправлять монеты до конца NewAccountCooldown
+// - перевод больше MaxAmount отклоняется, монеты не списываются
+// - сумма за сутки считается вместе с новым переводом, даже при параллельных переводах
+// - лимит за неделю проверяется отдельно от суточного
+// - лимиты действуют и на принятие запроса монет, запрос остается ожидающим
+// - запланированный перевод сверх лимита пропускается с причиной transfer_limit
+func TestTransactionServiceLimits(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	scheduleStorage := mock.NewMockScheduleStorage()
+	transactionService := service.NewTransactionService(mock.NewMockTransactionStorage(), userStorage, coinsStorage, txManager, ledgerStorage, scheduleStorage, mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+	transactionService.Limits = models.TransferLimits{
+		MaxAmount:          300,
+		Daily:              500,
+		NewAccountCooldown: 3600,
+	}
+
+	month := time.Now().Add(-30 * 24 * time.Hour)
+	for _, login := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000, CreatedAt: month}))
+	}
+	require.NoError(t, userStorage.Create(ctx, &models.User{Login: "newbie", Coins: 1000}))
+
+	err := transactionService.Send(ctx, "newbie", "alice", 10, models.TransferNote{})
+	assert.ErrorIs(t, err, models.ErrNewAccountCooldown)
+
+	err = transactionService.Send(ctx, "alice", "newbie", 10, models.TransferNote{})
+	assert.NoError(t, err)
+
+	err = transactionService.Send(ctx, "bob", "alice", 301, models.TransferNote{})
+	assert.ErrorIs(t, err, models.ErrTransferTooLarge)
+
+	bob, err := userStorage.GetByLogin(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 1000, bob.Coins)
+
+	var (
+		wg   sync.WaitGroup
+		sent atomic.Int32
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := transactionService.Send(ctx, "bob", "carol", 100, models.TransferNote{})
+			if err == nil {
+				sent.Add(1)
+				return
+			}
+			assert.ErrorIs(t, err, models.ErrDailyTransferLimit)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), sent.Load())
+
+	bob, err = userStorage.GetByLogin(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 500, bob.Coins)
+
+	cr, err := transactionService.RequestCoins(ctx, "alice", &models.AskCoinsRequest{Payer: "bob", Amount: 50}, 0)
+	require.NoError(t, err)
+	_, err = transactionService.AcceptCoinRequest(ctx, "bob", cr.Id)
+	assert.ErrorIs(t, err, models.ErrDailyTransferLimit)
+
+	cr, err = transactionService.CoinRequest(ctx, "bob", cr.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestPending, cr.Status)
+
+	st, err := transactionService.Schedule(ctx, "bob", &models.ScheduleRequest{Reciever: "carol", Amount: 100, Rule: models.ScheduleOnce, StartAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	_, err = transactionService.RunSchedules(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+
+	runs, err := transactionService.ScheduleRuns(ctx, "bob", st.Id, nil)
+	require.NoError(t, err)
+	require.Len(t, runs.Items, 1)
+	assert.Equal(t, models.ScheduleRunSkipped, runs.Items[0].Status)
+	assert.Equal(t, models.SkipTransferLimit, runs.Items[0].Reason)
+
+	transactionService.Limits = models.TransferLimits{Weekly: 150}
+	require.NoError(t, transactionService.Send(ctx, "carol", "alice", 100, models.TransferNote{}))
+	err = transactionService.Send(ctx, "carol", "alice", 100, models.TransferNote{})
+	assert.ErrorIs(t, err, models.ErrWeeklyTransferLimit)
+}
+
+// TestTransactionServiceTransferFlags - подозрительные переводы:
+// - перевод, который возвращает монеты отправителю по цепочке, помечается как circular
+// - серия мелких переводов одному получателю помечается, начиная с SmallCount-го
+// - помеченный перевод выполняется, а с BlockSuspicious - отклоняется без списания монет
+// - администратор видит открытые пометки и закрывает каждую один раз
+func TestTransactionServiceTransferFlags(t *testing.T) {
+	ctx := context.Background()
+	userStorage := mock.NewMockUserStorage()
+	coinsStorage := mock.NewMockCoinsStorage()
+	txManager := mock.NewMockTxManager()
+	ledgerStorage := mock.NewMockLedgerStorage(userStorage)
+	transactionService := service.NewTransactionService(mock.NewMockTransactionStorage(), userStorage, coinsStorage, txManager, ledgerStorage, mock.NewMockScheduleStorage(), mock.NewMockCoinRequestStorage(), mock.NewMockTransferFlagStorage(userStorage))
+	transactionService.Limits = models.TransferLimits{
+		CircularWindow: 86400,
+		SmallAmount:    10,
+		SmallCount:     3,
+	}
+
+	for _, login := range []string{"alice", "bob", "carol", "dave", "admin"} {
+		require.NoError(t, userStorage.Create(ctx, &models.User{Login: login, Coins: 1000}))
+	}
+
+	require.NoError(t, transactionService.Send(ctx, "alice", "bob", 100, models.TransferNote{}))
+	require.NoError(t, transactionService.Send(ctx, "bob", "carol", 100, models.TransferNote{}))
+
+	page, err := transactionService.TransferFlags(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	require.NoError(t, transactionService.Send(ctx, "carol", "alice", 100, models.TransferNote{}))
+
+	page, err = transactionService.TransferFlags(ctx, &models.TransferFlagFilter{Status: models.FlagOpen})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	circular := page.Items[0]
+	assert.Equal(t, "carol", circular.Sender)
+	assert.Equal(t, "alice", circular.Receiver)
+	assert.Equal(t, []models.TransferFlagReason{models.FlagCircular}, circular.Reasons)
+
+	for range 2 {
+		require.NoError(t, transactionService.Send(ctx, "dave", "bob", 5, models.TransferNote{}))
+	}
+	require.NoError(t, transactionService.Send(ctx, "dave", "bob", 50, models.TransferNote{}))
+
+	page, err = transactionService.TransferFlags(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	require.NoError(t, transactionService.Send(ctx, "dave", "bob", 5, models.TransferNote{}))
+
+	page, err = transactionService.TransferFlags(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "dave", page.Items[0].Sender)
+	assert.Equal(t, []models.TransferFlagReason{models.FlagSmallTransfers}, page.Items[0].Reasons)
+
+	dave, err := userStorage.GetByLogin(ctx, "dave")
+	require.NoError(t, err)
+	assert.Equal(t, 935, dave.Coins)
+
+	transactionService.Limits.BlockSuspicious = true
+	err = transactionService.Send(ctx, "dave", "bob", 5, models.TransferNote{})
+	assert.ErrorIs(t, err, models.ErrSuspiciousTransfer)
+
+	dave, err = userStorage.GetByLogin(ctx, "dave")
+	require.NoError(t, err)
+	assert.Equal(t, 935, dave.Coins)
+
+	_, err = transactionService.ReviewTransferFlag(ctx, "admin", circular.Id, models.FlagOpen)
+	assert.ErrorIs(t, err, models.ErrInvalidTransferFlagStatus)
+
+	flag, err := transactionService.ReviewTransferFlag(ctx, "admin", circular.Id, models.FlagDismissed)
+	require.NoError(t, err)
+	assert.Equal(t, models.FlagDismissed, flag.Status)
+	assert.Equal(t, "admin", flag.Reviewer)
+	assert.NotNil(t, flag.ReviewedAt)
+
+	_, err = transactionService.ReviewTransferFlag(ctx, "admin", circular.Id, models.FlagConfirmed)
+	assert.ErrorIs(t, err, models.ErrTransferFlagClosed)
+
+	_, err = transactionService.ReviewTransferFlag(ctx, "admin", 100, models.FlagConfirmed)
+	assert.ErrorIs(t, err, models.ErrTransferFlagNotFound)
+
+	page, err = transactionService.TransferFlags(ctx, &models.TransferFlagFilter{Status: models.FlagOpen})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "dave", page.Items[0].Sender)
+
+	_, err = transactionService.TransferFlags(ctx, &models.TransferFlagFilter{Status: "closed"})
+	assert.ErrorIs(t, err, models.ErrInvalidTransferFlagStatus)
+}
@@ -0,0 +1,52 @@
+package storagetest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"merch_service/internal/models"
+	"merch_service/internal/storage/local"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlobFS - проверяет хранилище файлов в локальной файловой системе:
+// запись с заменой, чтение, удаление и отказ для ключей вне каталога
+func TestBlobFS(t *testing.T) {
+	ctx := context.Background()
+	blobs := local.NewBlobStorage(t.TempDir())
+
+	read := func(key string) string {
+		file, _, err := blobs.Open(ctx, key)
+		require.NoError(t, err)
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	require.NoError(t, blobs.Put(ctx, "merch/1/a.png", strings.NewReader("первый")))
+	assert.Equal(t, "первый", read("merch/1/a.png"))
+
+	require.NoError(t, blobs.Put(ctx, "merch/1/a.png", strings.NewReader("второй")))
+	assert.Equal(t, "второй", read("merch/1/a.png"))
+
+	_, _, err := blobs.Open(ctx, "merch/1")
+	assert.ErrorIs(t, err, models.ErrBlobNotFound, "каталог - не файл")
+
+	require.NoError(t, blobs.Delete(ctx, "merch/1/a.png"))
+	require.NoError(t, blobs.Delete(ctx, "merch/1/a.png"))
+
+	_, _, err = blobs.Open(ctx, "merch/1/a.png")
+	assert.ErrorIs(t, err, models.ErrBlobNotFound)
+
+	for _, key := range []string{"", "../a.png", "merch/../../a.png", "/etc/passwd", `merch\..\a.png`} {
+		assert.ErrorIs(t, blobs.Put(ctx, key, strings.NewReader("x")), models.ErrInvalidBlobKey, key)
+		_, _, err = blobs.Open(ctx, key)
+		assert.ErrorIs(t, err, models.ErrInvalidBlobKey, key)
+	}
+}
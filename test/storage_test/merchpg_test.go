@@ -2,11 +2,15 @@ package storagetest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"merch_service/internal/models"
+	"merch_service/internal/service"
 	"merch_service/internal/storage"
 	"merch_service/internal/storage/postgres"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
@@ -473,3 +477,494 @@ func (s *TestMerchPG) TestMerchPGGetList() {
 		assert.True(t, found, "Item with ID %d not found in list", expected.Id)
 	}
 }
+
+// TestMerchPGDelete - тестирует мягкое удаление у MerchPG:
+// - удаленный мерч не находится и не попадает в список
+// - покупки удаленного мерча остаются в БД
+// - повторное удаление возвращает ErrMerchNotFound
+// - имя удаленного мерча можно занять снова, а имя существующего - нет
+func (s *TestMerchPG) TestMerchPGDelete() {
+	t := s.T()
+
+	merch := &models.Item{Name: "Deleted Item", Price: 10, Stock: 10}
+	require.NoError(t, s.merchStorage.Create(s.ctx, merch))
+
+	var userID int
+	err := s.pool.QueryRow(s.ctx,
+		"INSERT INTO merchshop.users (login, password) VALUES ('buyer', 'pass') RETURNING user_id").Scan(&userID)
+	require.NoError(t, err)
+
+	var orderID int
+	err = s.pool.QueryRow(s.ctx,
+		"INSERT INTO merchshop.orders (user_id, total) VALUES ($1, 10) RETURNING order_id", userID).Scan(&orderID)
+	require.NoError(t, err)
+
+	_, err = s.pool.Exec(s.ctx,
+		"INSERT INTO merchshop.purchases (user_id, merch_id, count, order_id, price) VALUES ($1, $2, 1, $3, 10)",
+		userID, merch.Id, orderID)
+	require.NoError(t, err)
+
+	require.NoError(t, s.merchStorage.Delete(s.ctx, merch.Id))
+
+	_, err = s.merchStorage.Get(s.ctx, merch.Id)
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	_, err = s.merchStorage.GetByName(s.ctx, merch.Name)
+	assert.ErrorIs(t, err, models.ErrMerchNotFound)
+
+	items, err := s.merchStorage.GetList(s.ctx)
+	require.NoError(t, err)
+	for _, item := range items {
+		assert.NotEqual(t, merch.Id, item.Id)
+	}
+
+	var purchases int
+	err = s.pool.QueryRow(s.ctx,
+		"SELECT COUNT(*) FROM merchshop.purchases WHERE merch_id = $1", merch.Id).Scan(&purchases)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purchases)
+
+	assert.ErrorIs(t, s.merchStorage.Delete(s.ctx, merch.Id), models.ErrMerchNotFound)
+
+	again := &models.Item{Name: "Deleted Item", Price: 20, Stock: 1}
+	require.NoError(t, s.merchStorage.Create(s.ctx, again))
+
+	duplicate := &models.Item{Name: "Deleted Item", Price: 30, Stock: 1}
+	assert.ErrorIs(t, s.merchStorage.Create(s.ctx, duplicate), models.ErrMerchExists)
+}
+
+// TestMerchPGList - тестирует постраничный обход каталога у MerchPG
+func (s *TestMerchPG) TestMerchPGList() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.merch CASCADE")
+	require.NoError(t, err)
+
+	for i := range 5 {
+		item := &models.Item{Name: fmt.Sprintf("Page Item %d", i), Price: 10, Stock: 1}
+		require.NoError(t, s.merchStorage.Create(s.ctx, item))
+	}
+
+	var (
+		ids   []int
+		query = &models.MerchFilter{PageQuery: models.PageQuery{Limit: 2}}
+	)
+	for {
+		page, err := s.merchStorage.List(s.ctx, query)
+		require.NoError(t, err)
+
+		for _, item := range page.Items {
+			ids = append(ids, item.Id)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.After, err = models.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, ids, 5)
+	assert.IsIncreasing(t, ids)
+}
+
+// TestMerchPGListFilter - тестирует фильтры и порядки каталога у MerchPG:
+// - поиск по имени и тегам без учета регистра кириллицы и разницы е/ё
+// - RefreshSearchText исправляет текст для поиска, заполненный не приложением
+// - категория, тег, диапазон цены и наличие
+// - популярность по строкам неотмененных заказов и обход страниц с порядком по цене
+func (s *TestMerchPG) TestMerchPGListFilter() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.merch CASCADE")
+	require.NoError(t, err)
+
+	tee := &models.Item{Name: "Футболка Ёжик", Price: 100, Stock: 3, Category: "одежда", Tags: []string{"хлопок"}}
+	hoodie := &models.Item{Name: "Худи", Price: 300, Stock: 0, Category: "одежда", Tags: []string{"тепло", "хлопок"}}
+	mug := &models.Item{Name: "Кружка", Price: 30, Stock: 5, Category: "посуда"}
+	for _, item := range []*models.Item{tee, hoodie, mug} {
+		require.NoError(t, s.merchStorage.Create(s.ctx, item))
+	}
+
+	ids := func(filter *models.MerchFilter) []int {
+		require.NoError(t, filter.Normalize())
+		page, err := s.merchStorage.List(s.ctx, filter)
+		require.NoError(t, err)
+
+		result := make([]int, 0, len(page.Items))
+		for _, item := range page.Items {
+			result = append(result, item.Id)
+		}
+		return result
+	}
+
+	assert.Equal(t, []int{tee.Id}, ids(&models.MerchFilter{Search: "ЕЖИК"}))
+	assert.Equal(t, []int{tee.Id, hoodie.Id}, ids(&models.MerchFilter{Search: "хлоп"}))
+	assert.Empty(t, ids(&models.MerchFilter{Search: "%"}))
+
+	// Так заполнила бы search_text миграция в БД, где lower() не знает кириллицу
+	_, err = s.pool.Exec(s.ctx, "UPDATE merchshop.merch SET search_text = name WHERE merch_id = $1", tee.Id)
+	require.NoError(t, err)
+	assert.Empty(t, ids(&models.MerchFilter{Search: "ежик"}))
+	refreshed, err := s.merchStorage.RefreshSearchText(s.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, refreshed)
+	assert.Equal(t, []int{tee.Id}, ids(&models.MerchFilter{Search: "ежик"}))
+	refreshed, err = s.merchStorage.RefreshSearchText(s.ctx)
+	require.NoError(t, err)
+	assert.Zero(t, refreshed)
+	assert.Equal(t, []int{tee.Id, hoodie.Id}, ids(&models.MerchFilter{Category: "Одежда"}))
+	assert.Equal(t, []int{hoodie.Id}, ids(&models.MerchFilter{Tag: "тепло"}))
+
+	minPrice, maxPrice := 50, 300
+	assert.Equal(t, []int{tee.Id, hoodie.Id}, ids(&models.MerchFilter{MinPrice: &minPrice, MaxPrice: &maxPrice}))
+	assert.Equal(t, []int{tee.Id, mug.Id}, ids(&models.MerchFilter{InStock: true}))
+	assert.Equal(t, []int{hoodie.Id, tee.Id, mug.Id}, ids(&models.MerchFilter{Sort: models.MerchSortPriceDesc}))
+
+	var userID int
+	err = s.pool.QueryRow(s.ctx,
+		"INSERT INTO merchshop.users (login, password) VALUES ('buyer', 'pass') RETURNING user_id").Scan(&userID)
+	require.NoError(t, err)
+
+	// Кружку купили больше всего, но заказ с худи отменен
+	for _, sale := range []struct {
+		merch  *models.Item
+		count  int
+		status models.OrderStatus
+	}{
+		{mug, 5, models.OrderPlaced},
+		{tee, 2, models.OrderDelivered},
+		{hoodie, 10, models.OrderCancelled},
+	} {
+		var orderID int
+		err = s.pool.QueryRow(s.ctx,
+			"INSERT INTO merchshop.orders (user_id, total, status) VALUES ($1, 0, $2) RETURNING order_id",
+			userID, sale.status).Scan(&orderID)
+		require.NoError(t, err)
+
+		_, err = s.pool.Exec(s.ctx,
+			"INSERT INTO merchshop.purchases (user_id, merch_id, count, order_id, price) VALUES ($1, $2, $3, $4, 0)",
+			userID, sale.merch.Id, sale.count, orderID)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []int{mug.Id, tee.Id, hoodie.Id}, ids(&models.MerchFilter{Sort: models.MerchSortPopular}))
+
+	var (
+		seen  []int
+		query = &models.MerchFilter{Sort: models.MerchSortPrice, PageQuery: models.PageQuery{Limit: 1}}
+	)
+	for {
+		page, err := s.merchStorage.List(s.ctx, query)
+		require.NoError(t, err)
+
+		for _, item := range page.Items {
+			seen = append(seen, item.Id)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.After, err = models.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{mug.Id, tee.Id, hoodie.Id}, seen)
+}
+
+func (s *TestMerchPG) TestMerchPGLimits() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.merch CASCADE")
+	require.NoError(t, err)
+
+	phone := &models.Item{Name: "КиберТелефон", Price: 100, Stock: 15, Limits: models.PurchaseLimits{PerUser: 3, PerMonth: 2}}
+	require.NoError(t, s.merchStorage.Create(s.ctx, phone))
+
+	stored, err := s.merchStorage.Get(s.ctx, phone.Id)
+	require.NoError(t, err)
+	assert.Equal(t, phone.Limits, stored.Limits)
+
+	stored.Limits.PerOrder = -1
+	assert.ErrorIs(t, s.merchStorage.Update(s.ctx, stored), models.ErrInvalidLimits)
+
+	var userID int
+	err = s.pool.QueryRow(s.ctx,
+		"INSERT INTO merchshop.users (login, password) VALUES ('buyer', 'pass') RETURNING user_id").Scan(&userID)
+	require.NoError(t, err)
+
+	// Прошлый месяц, текущий месяц и отмененный заказ
+	for _, order := range []struct {
+		count  int
+		status models.OrderStatus
+		age    string
+	}{
+		{2, models.OrderDelivered, "2 months"},
+		{1, models.OrderPlaced, "0 days"},
+		{5, models.OrderCancelled, "0 days"},
+	} {
+		var orderID int
+		err = s.pool.QueryRow(s.ctx, `
+			INSERT INTO merchshop.orders (user_id, total, status, created_at)
+			VALUES ($1, 0, $2, CURRENT_TIMESTAMP - $3::interval) RETURNING order_id`,
+			userID, order.status, order.age).Scan(&orderID)
+		require.NoError(t, err)
+
+		_, err = s.pool.Exec(s.ctx,
+			"INSERT INTO merchshop.purchases (user_id, merch_id, count, order_id, price) VALUES ($1, $2, $3, $4, 0)",
+			userID, phone.Id, order.count, orderID)
+		require.NoError(t, err)
+	}
+
+	total, month, err := postgres.NewOrderStorage(s.pool).MerchBought(s.ctx, userID, phone.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 1, month)
+}
+
+// TestMerchPGWishlistNotifications - тестирует WishlistPG и NotificationPG:
+// - добавление, смена подписки и удаление из списка желаний, удаленный мерч
+// - уведомления получают только подписчики, прочитать их может только владелец
+// - Undelivered возвращает уведомления до отметки MarkDelivered
+func (s *TestMerchPG) TestMerchPGWishlistNotifications() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.merch CASCADE")
+	require.NoError(t, err)
+
+	wishlist := postgres.NewWishlistStorage(s.pool)
+	notifications := postgres.NewNotificationStorage(s.pool)
+
+	hat := &models.Item{Name: "Кепка", Price: 10}
+	require.NoError(t, s.merchStorage.Create(s.ctx, hat))
+	mug := &models.Item{Name: "Кружка", Price: 30, Stock: 2}
+	require.NoError(t, s.merchStorage.Create(s.ctx, mug))
+
+	users := make(map[string]int)
+	for _, login := range []string{"fan", "watcher"} {
+		var id int
+		err = s.pool.QueryRow(s.ctx,
+			"INSERT INTO merchshop.users (login, password) VALUES ($1, 'pass') RETURNING user_id", login).Scan(&id)
+		require.NoError(t, err)
+		users[login] = id
+	}
+
+	assert.ErrorIs(t, wishlist.Add(s.ctx, users["fan"], hat.Id+mug.Id, true), models.ErrMerchNotFound)
+	require.NoError(t, wishlist.Add(s.ctx, users["fan"], hat.Id, false))
+	require.NoError(t, wishlist.Add(s.ctx, users["fan"], hat.Id, true))
+	require.NoError(t, wishlist.Add(s.ctx, users["fan"], mug.Id, true))
+	require.NoError(t, wishlist.Add(s.ctx, users["watcher"], hat.Id, false))
+
+	items, err := wishlist.List(s.ctx, users["fan"])
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "Кепка", items[0].Name)
+	assert.True(t, items[0].Notify)
+	assert.Equal(t, 2, items[1].Stock)
+
+	created, err := notifications.CreateBackInStock(s.ctx, hat.Id)
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.Equal(t, "fan", created[0].Login)
+	assert.Equal(t, "Кепка", created[0].MerchName)
+	assert.Equal(t, models.NotificationBackInStock, created[0].Kind)
+
+	page, err := notifications.List(s.ctx, users["fan"], &models.PageQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, created[0].Id, page.Items[0].Id)
+	assert.Nil(t, page.Items[0].ReadAt)
+
+	page, err = notifications.List(s.ctx, users["watcher"], &models.PageQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	pending, err := notifications.Undelivered(s.ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, created[0].Id, pending[0].Id)
+	assert.Equal(t, "fan", pending[0].Login)
+	require.NoError(t, notifications.MarkDelivered(s.ctx, []int{created[0].Id}, time.Now()))
+	pending, err = notifications.Undelivered(s.ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	assert.ErrorIs(t, notifications.MarkRead(s.ctx, users["watcher"], created[0].Id), models.ErrNotificationNotFound)
+	require.NoError(t, notifications.MarkRead(s.ctx, users["fan"], created[0].Id))
+	page, err = notifications.List(s.ctx, users["fan"], &models.PageQuery{})
+	require.NoError(t, err)
+	assert.NotNil(t, page.Items[0].ReadAt)
+
+	require.NoError(t, s.merchStorage.Delete(s.ctx, mug.Id))
+	items, err = wishlist.List(s.ctx, users["fan"])
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	require.NoError(t, wishlist.Remove(s.ctx, users["fan"], hat.Id))
+	assert.ErrorIs(t, wishlist.Remove(s.ctx, users["fan"], hat.Id), models.ErrWishlistItemNotFound)
+}
+
+// TestMerchPGReservations - тестирует ReservationPG:
+// - Reserved считает только активные неистекшие брони своего мерча по видам
+// - Expired возвращает истекшие активные брони, UpdateStatus закрывает их
+// - List возвращает брони пользователя от новых к старым
+func (s *TestMerchPG) TestMerchPGReservations() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.merch CASCADE")
+	require.NoError(t, err)
+
+	reservations := postgres.NewReservationStorage(s.pool)
+
+	mug := &models.Item{Name: "Кружка", Price: 30, Stock: 5}
+	require.NoError(t, s.merchStorage.Create(s.ctx, mug))
+
+	var userID int
+	err = s.pool.QueryRow(s.ctx,
+		"INSERT INTO merchshop.users (login, password) VALUES ('buyer', 'pass') RETURNING user_id").Scan(&userID)
+	require.NoError(t, err)
+
+	now := time.Now()
+	hold := &models.Reservation{UserId: userID, Kind: models.ReservationHold, MerchId: mug.Id, Count: 2, Price: 30, ListPrice: 30, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, reservations.Create(s.ctx, hold))
+	assert.Equal(t, models.ReservationActive, hold.Status)
+	assert.Equal(t, 60, hold.Total)
+
+	preorder := &models.Reservation{UserId: userID, Kind: models.ReservationPreorder, MerchId: mug.Id, Count: 3, Price: 30, ListPrice: 30, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, reservations.Create(s.ctx, preorder))
+
+	stale := &models.Reservation{UserId: userID, Kind: models.ReservationHold, MerchId: mug.Id, Count: 1, Price: 30, ListPrice: 30, ExpiresAt: now.Add(-time.Minute)}
+	require.NoError(t, reservations.Create(s.ctx, stale))
+
+	assert.ErrorIs(t, reservations.Create(s.ctx, &models.Reservation{UserId: userID, MerchId: mug.Id}), models.ErrInvalidCount)
+
+	reserved, err := reservations.Reserved(s.ctx, mug.Id, 0, now)
+	require.NoError(t, err)
+	assert.Equal(t, models.Reserved{Holds: 2, Preorders: 3}, reserved)
+
+	expired, err := reservations.Expired(s.ctx, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int{stale.Id}, expired)
+
+	require.NoError(t, reservations.UpdateStatus(s.ctx, stale.Id, models.ReservationExpired, 0))
+	expired, err = reservations.Expired(s.ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+
+	assert.ErrorIs(t, reservations.UpdateStatus(s.ctx, stale.Id, "lost", 0), models.ErrInvalidReservationStatus)
+	assert.ErrorIs(t, reservations.UpdateStatus(s.ctx, stale.Id+100, models.ReservationCancelled, 0), models.ErrReservationNotFound)
+
+	got, err := reservations.GetForUpdate(s.ctx, hold.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Кружка", got.Name)
+	assert.Equal(t, 60, got.Total)
+	_, err = reservations.Get(s.ctx, stale.Id+100)
+	assert.ErrorIs(t, err, models.ErrReservationNotFound)
+
+	page, err := reservations.List(s.ctx, userID, &models.PageQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 3)
+	assert.Equal(t, stale.Id, page.Items[0].Id)
+	assert.Equal(t, models.ReservationExpired, page.Items[0].Status)
+}
+
+// TestMerchPGBuyConcurrent - параллельные покупки одного товара через MerchService
+// с хранилищами PostgreSQL. Проверяет блокировки строк (SELECT ... FOR UPDATE):
+// - товар не продается сверх остатка на складе
+// - монеты списаны ровно за совершенные покупки, баланс не уходит в минус
+// - журнал монет сходится с балансами
+func (s *TestMerchPG) TestMerchPGBuyConcurrent() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.merch CASCADE")
+	require.NoError(t, err)
+
+	const (
+		usersCount    = 10
+		buysPerUser   = 5
+		startingCoins = 100
+		price         = 30
+		startStock    = 7
+	)
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	ledger := postgres.NewLedgerStorage(s.pool)
+	merchService := service.NewMerchService(
+		s.merchStorage,
+		userStorage,
+		postgres.NewPurchaseStorage(s.pool),
+		postgres.NewCoinsStorage(s.pool),
+		postgres.NewTxManager(s.pool),
+		ledger,
+		postgres.NewOrderStorage(s.pool),
+		postgres.NewCartStorage(s.pool),
+		postgres.NewVariantStorage(s.pool),
+		postgres.NewImageStorage(s.pool),
+		postgres.NewPromoStorage(s.pool),
+		postgres.NewReservationStorage(s.pool),
+		nil,
+	)
+
+	mug := &models.Item{Name: "Кружка", Price: price, Stock: startStock}
+	require.NoError(t, s.merchStorage.Create(s.ctx, mug))
+
+	for i := range usersCount {
+		require.NoError(t, userStorage.Create(s.ctx, &models.User{
+			Login:    fmt.Sprintf("racer%d", i),
+			Password: "password",
+			Coins:    startingCoins,
+		}))
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded = make(map[string]int)
+	)
+
+	for i := range usersCount {
+		login := fmt.Sprintf("racer%d", i)
+		for range buysPerUser {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := merchService.Buy(s.ctx, login, mug.Name, "", "", 1)
+				if err != nil {
+					assert.True(t,
+						errors.Is(err, models.ErrNotEnoughMerch) || errors.Is(err, models.ErrNotEnoughCoins),
+						"неожиданная ошибка: %v", err)
+					return
+				}
+
+				mu.Lock()
+				succeeded[login]++
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+
+	totalBought := 0
+	for _, n := range succeeded {
+		totalBought += n
+	}
+	assert.Equal(t, startStock, totalBought, "должен быть продан весь склад и не больше")
+
+	got, err := s.merchStorage.Get(s.ctx, mug.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, got.Stock)
+
+	for i := range usersCount {
+		login := fmt.Sprintf("racer%d", i)
+		user, err := userStorage.GetByLogin(s.ctx, login)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, user.Coins, 0)
+		assert.Equal(t, startingCoins-succeeded[login]*price, user.Coins)
+	}
+
+	report, err := ledger.Reconcile(s.ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
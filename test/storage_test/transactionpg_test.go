@@ -7,6 +7,7 @@ import (
 	"merch_service/internal/storage"
 	"merch_service/internal/storage/postgres"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
@@ -146,7 +147,6 @@ func TestTestTransactionPG(t *testing.T) {
 
 // TestTransactionPGCreateTransaction - проверяет метод Create у TransactionPG:
 // - успешная транзакция
-// - нехватка деняг
 // - невалидное значение
 // - самому себе
 // - отправитель не найден
@@ -180,7 +180,7 @@ func (s *TestTransactionPG) TestTransactionPGCreateTransaction() {
 		amount      int
 		wantErr     bool
 		expectedErr error
-		checkResult func(*testing.T, *models.User, *models.User)
+		checkResult func(*testing.T, int, *models.User, *models.User)
 	}{
 		{
 			name:     "успешная транзакция",
@@ -188,31 +188,24 @@ func (s *TestTransactionPG) TestTransactionPGCreateTransaction() {
 			receiver: receiver,
 			amount:   200,
 			wantErr:  false,
-			checkResult: func(t *testing.T, u *models.User, r *models.User) {
+			checkResult: func(t *testing.T, id int, u *models.User, r *models.User) {
+				// Балансы меняет журнал (LedgerPG.Post), а не запись перевода
 				updatedSender, err := userStorage.Get(s.ctx, u.Id)
 				require.NoError(t, err)
-				assert.Equal(t, 800, updatedSender.Coins)
+				assert.Equal(t, 1000, updatedSender.Coins)
 
 				updatedReceiver, err := userStorage.Get(s.ctx, r.Id)
 				require.NoError(t, err)
-				assert.Equal(t, 700, updatedReceiver.Coins)
+				assert.Equal(t, 500, updatedReceiver.Coins)
 
 				var txCount int
 				err = s.pool.QueryRow(s.ctx,
-					"SELECT COUNT(*) FROM merchshop.transactions WHERE sender_id = $1 AND receiver_id = $2 AND amount = $3",
-					u.Id, r.Id, 200).Scan(&txCount)
+					"SELECT COUNT(*) FROM merchshop.transactions WHERE transaction_id = $1 AND sender_id = $2 AND receiver_id = $3 AND amount = $4",
+					id, u.Id, r.Id, 200).Scan(&txCount)
 				require.NoError(t, err)
 				assert.Equal(t, 1, txCount)
 			},
 		},
-		{
-			name:        "нехватка деняг",
-			sender:      sender,
-			receiver:    receiver,
-			amount:      2000,
-			wantErr:     true,
-			expectedErr: models.ErrInsufficientCoins,
-		},
 		{
 			name:        "невалидное значение",
 			sender:      sender,
@@ -278,7 +271,7 @@ func (s *TestTransactionPG) TestTransactionPGCreateTransaction() {
 				require.NoError(t, err)
 			}
 
-			err := s.transactionStorage.Create(s.ctx, tc.sender, tc.receiver, tc.amount)
+			id, err := s.transactionStorage.Create(s.ctx, tc.sender, tc.receiver, tc.amount, models.TransferNote{})
 
 			if tc.wantErr {
 				assert.Error(t, err)
@@ -286,9 +279,518 @@ func (s *TestTransactionPG) TestTransactionPGCreateTransaction() {
 			} else {
 				assert.NoError(t, err)
 				if tc.checkResult != nil {
-					tc.checkResult(t, tc.sender, tc.receiver)
+					tc.checkResult(t, id, tc.sender, tc.receiver)
 				}
 			}
 		})
 	}
 }
+
+// TestTransactionPGList - проверяет метод List у TransactionPG:
+// - логин второй стороны и направление
+// - фильтр по направлению
+// - фильтр по периоду
+func (s *TestTransactionPG) TestTransactionPGList() {
+	t := s.T()
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	alice := &models.User{Login: "list_alice", Password: "pass", Coins: 1000}
+	bob := &models.User{Login: "list_bob", Password: "pass", Coins: 1000}
+	require.NoError(t, userStorage.Create(s.ctx, alice))
+	require.NoError(t, userStorage.Create(s.ctx, bob))
+
+	_, err := s.transactionStorage.Create(s.ctx, alice, bob, 100, models.TransferNote{})
+	require.NoError(t, err)
+	_, err = s.transactionStorage.Create(s.ctx, bob, alice, 30, models.TransferNote{})
+	require.NoError(t, err)
+
+	all, err := s.transactionStorage.List(s.ctx, bob, &models.TransferFilter{Direction: models.TransferAll})
+	require.NoError(t, err)
+	require.Len(t, all.Items, 2)
+	assert.Equal(t, models.TransferSent, all.Items[0].Direction)
+	assert.Equal(t, alice.Login, all.Items[0].Counterpart)
+	assert.Equal(t, 30, all.Items[0].Amount)
+	assert.Equal(t, models.TransferReceived, all.Items[1].Direction)
+	assert.Equal(t, 100, all.Items[1].Amount)
+
+	received, err := s.transactionStorage.List(s.ctx, bob, &models.TransferFilter{Direction: models.TransferReceived})
+	require.NoError(t, err)
+	require.Len(t, received.Items, 1)
+	assert.Equal(t, 100, received.Items[0].Amount)
+
+	// Отсчитываем от даты самого перевода, чтобы не зависеть от часового пояса БД
+	from := all.Items[0].Date.Add(time.Second)
+	none, err := s.transactionStorage.List(s.ctx, bob, &models.TransferFilter{
+		PageQuery: models.PageQuery{From: from},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, none.Items)
+
+	to := all.Items[1].Date.Add(time.Second)
+	upTo, err := s.transactionStorage.List(s.ctx, bob, &models.TransferFilter{
+		PageQuery: models.PageQuery{To: to},
+	})
+	require.NoError(t, err)
+	assert.Len(t, upTo.Items, 2)
+
+	first, err := s.transactionStorage.List(s.ctx, bob, &models.TransferFilter{
+		PageQuery: models.PageQuery{Limit: 1},
+	})
+	require.NoError(t, err)
+	require.Len(t, first.Items, 1)
+	require.NotEmpty(t, first.NextCursor)
+
+	after, err := models.DecodeCursor(first.NextCursor)
+	require.NoError(t, err)
+	second, err := s.transactionStorage.List(s.ctx, bob, &models.TransferFilter{
+		PageQuery: models.PageQuery{Limit: 1, After: after},
+	})
+	require.NoError(t, err)
+	require.Len(t, second.Items, 1)
+	assert.Equal(t, all.Items[1].Id, second.Items[0].Id)
+	assert.Empty(t, second.NextCursor)
+}
+
+// TestTransactionPGNotes - повод, сообщение, реакция и ответ перевода:
+// - повод и сообщение видны обеим сторонам и в истории кошелька
+// - реагирует и отвечает только получатель, ответить можно один раз
+func (s *TestTransactionPG) TestTransactionPGNotes() {
+	t := s.T()
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	coinsStorage := postgres.NewCoinsStorage(s.pool)
+	alice := &models.User{Login: "note_alice", Password: "pass", Coins: 1000}
+	bob := &models.User{Login: "note_bob", Password: "pass", Coins: 1000}
+	require.NoError(t, userStorage.Create(s.ctx, alice))
+	require.NoError(t, userStorage.Create(s.ctx, bob))
+
+	_, err := s.transactionStorage.Create(s.ctx, alice, bob, 10, models.TransferNote{Category: "bribe"})
+	assert.ErrorIs(t, err, models.ErrInvalidTransferCategory)
+
+	note := models.TransferNote{Category: models.TransferThanks, Message: "Спасибо!"}
+	id, err := s.transactionStorage.Create(s.ctx, alice, bob, 10, note)
+	require.NoError(t, err)
+
+	bob.Coins += 10
+	require.NoError(t, coinsStorage.CreateForTransfer(s.ctx, bob, 1000, id, note))
+
+	got, err := s.transactionStorage.Get(s.ctx, alice, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.TransferSent, got.Direction)
+	assert.Equal(t, note, got.TransferNote)
+	assert.Empty(t, got.Reaction)
+	assert.Nil(t, got.RepliedAt)
+
+	coins, err := coinsStorage.List(s.ctx, bob, &models.PageQuery{})
+	require.NoError(t, err)
+	require.Len(t, coins.Items, 1)
+	assert.Equal(t, models.ReasonTransfer, coins.Items[0].Reason)
+	assert.Equal(t, id, coins.Items[0].TransferId)
+	assert.Equal(t, note, coins.Items[0].TransferNote)
+
+	assert.ErrorIs(t, s.transactionStorage.React(s.ctx, id, alice.Id, models.ReactionLike), models.ErrTransferNotFound)
+	assert.ErrorIs(t, s.transactionStorage.React(s.ctx, id, bob.Id, "fire"), models.ErrInvalidTransferReaction)
+	require.NoError(t, s.transactionStorage.React(s.ctx, id, bob.Id, models.ReactionLike))
+
+	assert.ErrorIs(t, s.transactionStorage.Reply(s.ctx, id, alice.Id, "ответ"), models.ErrTransferNotFound)
+	require.NoError(t, s.transactionStorage.Reply(s.ctx, id, bob.Id, "Не за что"))
+	assert.ErrorIs(t, s.transactionStorage.Reply(s.ctx, id, bob.Id, "еще"), models.ErrTransferReplied)
+
+	page, err := s.transactionStorage.List(s.ctx, alice, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.ReactionLike, page.Items[0].Reaction)
+	assert.Equal(t, "Не за что", page.Items[0].Reply)
+	assert.NotNil(t, page.Items[0].RepliedAt)
+
+	carol := &models.User{Id: bob.Id + alice.Id + 100}
+	_, err = s.transactionStorage.Get(s.ctx, carol, id)
+	assert.ErrorIs(t, err, models.ErrTransferNotFound)
+}
+
+// TestLedgerPGPost - проверяет журнал монет LedgerPG:
+// - проводки меняют кешированные балансы, баланс по журналу совпадает с ними
+// - несбалансированная запись и уход баланса в минус отклоняются
+// - записи журнала нельзя изменить
+// - сверка сходится, пока балансы меняются только через журнал
+func (s *TestTransactionPG) TestLedgerPGPost() {
+	t := s.T()
+
+	// Пользователи других тестов созданы с монетами в обход журнала
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.users CASCADE")
+	require.NoError(t, err)
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	ledger := postgres.NewLedgerStorage(s.pool)
+
+	alice := &models.User{Login: "ledger_alice", Password: "pass"}
+	bob := &models.User{Login: "ledger_bob", Password: "pass"}
+	for _, user := range []*models.User{alice, bob} {
+		require.NoError(t, userStorage.Create(s.ctx, user))
+		require.NoError(t, ledger.Post(s.ctx, models.NewTransferEntry(models.ReasonSignup, user.Id,
+			models.Posting{System: models.AccountGrants},
+			models.Posting{UserId: user.Id},
+			models.SignupBonus,
+		)))
+	}
+
+	transferID, err := s.transactionStorage.Create(s.ctx, alice, bob, 300, models.TransferNote{})
+	require.NoError(t, err)
+
+	entry := models.NewTransferEntry(models.ReasonTransfer, transferID,
+		models.Posting{UserId: alice.Id},
+		models.Posting{UserId: bob.Id},
+		300,
+	)
+	require.NoError(t, ledger.Post(s.ctx, entry))
+	assert.Positive(t, entry.Id)
+
+	gotAlice, err := userStorage.Get(s.ctx, alice.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 700, gotAlice.Coins)
+
+	balance, err := ledger.Balance(s.ctx, bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1300, balance)
+
+	err = ledger.Post(s.ctx, &models.JournalEntry{
+		Reason:   models.ReasonTransfer,
+		Postings: []models.Posting{{UserId: alice.Id, Amount: -10}, {UserId: bob.Id, Amount: 20}},
+	})
+	assert.ErrorIs(t, err, models.ErrUnbalancedEntry)
+
+	err = ledger.Post(s.ctx, models.NewTransferEntry(models.ReasonTransfer, transferID,
+		models.Posting{UserId: alice.Id},
+		models.Posting{UserId: bob.Id},
+		1000,
+	))
+	assert.ErrorIs(t, err, models.ErrNegativeUserCoins)
+
+	_, err = s.pool.Exec(s.ctx, "UPDATE merchshop.postings SET amount = 1 WHERE entry_id = $1", entry.Id)
+	assert.Error(t, err)
+
+	report, err := ledger.Reconcile(s.ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+
+	// Баланс, измененный в обход журнала, попадает в отчет сверки
+	gotAlice.Coins += 50
+	require.NoError(t, userStorage.Update(s.ctx, gotAlice))
+
+	report, err = ledger.Reconcile(s.ctx)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Zero(t, report.Total)
+	require.Len(t, report.BalanceMismatches, 1)
+	assert.Equal(t, models.BalanceMismatch{UserId: alice.Id, Login: alice.Login, Cached: 750, Ledger: 700}, report.BalanceMismatches[0])
+}
+
+// TestSchedulePG - запланированные переводы SchedulePG:
+// - Create проверяет перевод и назначает первое срабатывание на StartAt
+// - Due возвращает только активные переводы, срабатывание которых наступило
+// - CreateRun записывает срабатывание один раз, Get показывает последнее
+// - UpdateStatus сдвигает или снимает следующее срабатывание
+func (s *TestTransactionPG) TestSchedulePG() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.users CASCADE")
+	require.NoError(t, err)
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	schedules := postgres.NewScheduleStorage(s.pool)
+
+	alice := &models.User{Login: "sched_alice", Password: "pass", Coins: 1000}
+	bob := &models.User{Login: "sched_bob", Password: "pass", Coins: 1000}
+	require.NoError(t, userStorage.Create(s.ctx, alice))
+	require.NoError(t, userStorage.Create(s.ctx, bob))
+
+	assert.ErrorIs(t, schedules.Create(s.ctx, &models.ScheduledTransfer{SenderId: alice.Id, ReceiverId: alice.Id, Amount: 10, Rule: models.ScheduleOnce}), models.ErrSameSenderReceiver)
+	assert.ErrorIs(t, schedules.Create(s.ctx, &models.ScheduledTransfer{SenderId: alice.Id, ReceiverId: bob.Id, Amount: 10, Rule: "daily"}), models.ErrInvalidScheduleRule)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	weekly := &models.ScheduledTransfer{
+		SenderId:     alice.Id,
+		ReceiverId:   bob.Id,
+		Amount:       100,
+		TransferNote: models.TransferNote{Category: models.TransferThanks, Message: "Премия"},
+		Rule:         models.ScheduleWeekly,
+		StartAt:      now.Add(-time.Minute),
+	}
+	require.NoError(t, schedules.Create(s.ctx, weekly))
+	assert.Equal(t, models.ScheduleActive, weekly.Status)
+	require.NotNil(t, weekly.NextRunAt)
+
+	later := &models.ScheduledTransfer{SenderId: alice.Id, ReceiverId: bob.Id, Amount: 5, Rule: models.ScheduleOnce, StartAt: now.Add(time.Hour)}
+	require.NoError(t, schedules.Create(s.ctx, later))
+
+	due, err := schedules.Due(s.ctx, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int{weekly.Id}, due)
+
+	got, err := schedules.Get(s.ctx, weekly.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "sched_bob", got.Receiver)
+	assert.Equal(t, weekly.TransferNote, got.TransferNote)
+	assert.Nil(t, got.LastRun)
+
+	run := &models.ScheduleRun{ScheduleId: weekly.Id, ScheduledFor: *weekly.NextRunAt, Status: models.ScheduleRunSkipped, Reason: models.SkipNotEnoughCoins}
+	require.NoError(t, schedules.CreateRun(s.ctx, run))
+	assert.NotZero(t, run.Id)
+
+	again := &models.ScheduleRun{ScheduleId: weekly.Id, ScheduledFor: *weekly.NextRunAt, Status: models.ScheduleRunExecuted}
+	assert.ErrorIs(t, schedules.CreateRun(s.ctx, again), models.ErrScheduleRunExists)
+
+	next := weekly.NextRunAt.Add(7 * 24 * time.Hour)
+	require.NoError(t, schedules.UpdateStatus(s.ctx, weekly.Id, models.ScheduleActive, &next))
+
+	got, err = schedules.Get(s.ctx, weekly.Id)
+	require.NoError(t, err)
+	require.NotNil(t, got.NextRunAt)
+	assert.True(t, got.NextRunAt.Equal(next))
+	require.NotNil(t, got.LastRun)
+	assert.Equal(t, models.SkipNotEnoughCoins, got.LastRun.Reason)
+
+	due, err = schedules.Due(s.ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	require.NoError(t, schedules.UpdateStatus(s.ctx, later.Id, models.ScheduleCancelled, nil))
+	assert.ErrorIs(t, schedules.UpdateStatus(s.ctx, later.Id, "paused", nil), models.ErrInvalidScheduleStatus)
+	assert.ErrorIs(t, schedules.UpdateStatus(s.ctx, later.Id+100, models.ScheduleCancelled, nil), models.ErrScheduleNotFound)
+
+	list, err := schedules.List(s.ctx, alice.Id, nil)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	assert.Equal(t, later.Id, list.Items[0].Id)
+	assert.Equal(t, models.ScheduleCancelled, list.Items[0].Status)
+	assert.Nil(t, list.Items[0].NextRunAt)
+
+	runs, err := schedules.Runs(s.ctx, weekly.Id, nil)
+	require.NoError(t, err)
+	require.Len(t, runs.Items, 1)
+	assert.Equal(t, models.ScheduleRunSkipped, runs.Items[0].Status)
+}
+
+// TestGrantPG - пакеты начислений GrantPG:
+// - Create сохраняет пакет и строки, назначая им id
+// - пакет без строк и строка без пользователя отклоняются
+// - начисление из казны попадает в журнал и историю кошелька с причиной
+func (s *TestTransactionPG) TestGrantPG() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.users CASCADE")
+	require.NoError(t, err)
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	coinsStorage := postgres.NewCoinsStorage(s.pool)
+	ledger := postgres.NewLedgerStorage(s.pool)
+	grants := postgres.NewGrantStorage(s.pool)
+
+	admin := &models.User{Login: "grant_admin", Password: "pass"}
+	alice := &models.User{Login: "grant_alice", Password: "pass"}
+	require.NoError(t, userStorage.Create(s.ctx, admin))
+	require.NoError(t, userStorage.Create(s.ctx, alice))
+
+	assert.ErrorIs(t, grants.Create(s.ctx, &models.GrantBatch{AdminId: admin.Id}), models.ErrEmptyGrantBatch)
+	assert.ErrorIs(t, grants.Create(s.ctx, &models.GrantBatch{AdminId: admin.Id, Total: 10, Rows: []*models.GrantRow{{Line: 1, Amount: 10, Reason: "Без пользователя"}}}), models.ErrInvalidUserID)
+
+	row := &models.GrantRow{Line: 1, Login: alice.Login, UserId: alice.Id, Amount: 150, Reason: "Премия за квартал"}
+	batch := &models.GrantBatch{AdminId: admin.Id, Total: 150, Rows: []*models.GrantRow{row}}
+	require.NoError(t, grants.Create(s.ctx, batch))
+	assert.Positive(t, batch.Id)
+	assert.Positive(t, row.Id)
+	assert.False(t, batch.CreatedAt.IsZero())
+
+	require.NoError(t, ledger.Post(s.ctx, models.NewTransferEntry(models.ReasonGrant, batch.Id,
+		models.Posting{System: models.AccountTreasury},
+		models.Posting{UserId: alice.Id},
+		150,
+	)))
+
+	alice.Coins += 150
+	require.NoError(t, coinsStorage.CreateForGrant(s.ctx, alice, 0, batch.Id, row))
+
+	gotAlice, err := userStorage.Get(s.ctx, alice.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 150, gotAlice.Coins)
+
+	coins, err := coinsStorage.List(s.ctx, alice, &models.PageQuery{})
+	require.NoError(t, err)
+	require.Len(t, coins.Items, 1)
+	assert.Equal(t, models.ReasonGrant, coins.Items[0].Reason)
+	assert.Equal(t, batch.Id, coins.Items[0].GrantId)
+	assert.Equal(t, "Премия за квартал", coins.Items[0].Message)
+	assert.Equal(t, 150, coins.Items[0].CoinsAfter)
+
+	report, err := ledger.Reconcile(s.ctx)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+// TestCoinRequestPG - запросы монет CoinRequestPG:
+// - Create проверяет запрос и сохраняет его ожидающим
+// - List фильтрует по направлению и статусу, истекший запрос виден как expired
+// - Counts считает ожидающие запросы, в том числе к одному плательщику, и созданные за окно
+// - Resolve закрывает запрос со ссылкой на перевод
+func (s *TestTransactionPG) TestCoinRequestPG() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.users CASCADE")
+	require.NoError(t, err)
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	requests := postgres.NewCoinRequestStorage(s.pool)
+
+	alice := &models.User{Login: "req_alice", Password: "pass", Coins: 1000}
+	bob := &models.User{Login: "req_bob", Password: "pass", Coins: 1000}
+	require.NoError(t, userStorage.Create(s.ctx, alice))
+	require.NoError(t, userStorage.Create(s.ctx, bob))
+
+	now := time.Now().UTC()
+	assert.ErrorIs(t, requests.Create(s.ctx, &models.CoinRequest{RequesterId: alice.Id, PayerId: alice.Id, Amount: 10, ExpiresAt: now.Add(time.Hour)}), models.ErrSameSenderReceiver)
+	assert.ErrorIs(t, requests.Create(s.ctx, &models.CoinRequest{RequesterId: alice.Id, PayerId: bob.Id, Amount: 0, ExpiresAt: now.Add(time.Hour)}), models.ErrInvalidAmount)
+
+	cr := &models.CoinRequest{
+		RequesterId:  alice.Id,
+		PayerId:      bob.Id,
+		Amount:       300,
+		TransferNote: models.TransferNote{Category: models.TransferBet, Message: "Спор"},
+		ExpiresAt:    now.Add(time.Hour),
+	}
+	require.NoError(t, requests.Create(s.ctx, cr))
+	assert.Positive(t, cr.Id)
+	assert.Equal(t, models.CoinRequestPending, cr.Status)
+
+	expired := &models.CoinRequest{RequesterId: bob.Id, PayerId: alice.Id, Amount: 5, ExpiresAt: now.Add(-time.Minute)}
+	require.NoError(t, requests.Create(s.ctx, expired))
+
+	got, err := requests.Get(s.ctx, cr.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "req_alice", got.Requester)
+	assert.Equal(t, "req_bob", got.Payer)
+	assert.Equal(t, cr.TransferNote, got.TransferNote)
+
+	page, err := requests.List(s.ctx, bob.Id, &models.CoinRequestFilter{Direction: models.TransferReceived, Status: models.CoinRequestPending}, now)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, cr.Id, page.Items[0].Id)
+
+	page, err = requests.List(s.ctx, bob.Id, &models.CoinRequestFilter{Status: models.CoinRequestExpired}, now)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, expired.Id, page.Items[0].Id)
+	assert.Equal(t, models.CoinRequestExpired, page.Items[0].Status)
+
+	counts, err := requests.Counts(s.ctx, alice.Id, bob.Id, now, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestCounts{Pending: 1, PendingToPayer: 1, Created: 1}, counts)
+
+	transferID, err := s.transactionStorage.Create(s.ctx, bob, alice, cr.Amount, cr.TransferNote)
+	require.NoError(t, err)
+	require.NoError(t, requests.Resolve(s.ctx, cr.Id, models.CoinRequestAccepted, transferID))
+	assert.ErrorIs(t, requests.Resolve(s.ctx, cr.Id, models.CoinRequestPending, 0), models.ErrInvalidCoinRequestStatus)
+	assert.ErrorIs(t, requests.Resolve(s.ctx, cr.Id+100, models.CoinRequestDeclined, 0), models.ErrCoinRequestNotFound)
+
+	got, err = requests.Get(s.ctx, cr.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestAccepted, got.Status)
+	assert.Equal(t, transferID, got.TransferId)
+	assert.NotNil(t, got.ResolvedAt)
+
+	counts, err = requests.Counts(s.ctx, alice.Id, bob.Id, now, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, models.CoinRequestCounts{Created: 1}, counts)
+}
+
+func (s *TestTransactionPG) TestTransferLimitsPG() {
+	t := s.T()
+
+	_, err := s.pool.Exec(s.ctx, "TRUNCATE TABLE merchshop.users CASCADE")
+	require.NoError(t, err)
+
+	userStorage := postgres.NewUserStorage(s.pool)
+	flags := postgres.NewTransferFlagStorage(s.pool)
+
+	alice := &models.User{Login: "lim_alice", Password: "pass", Coins: 1000}
+	bob := &models.User{Login: "lim_bob", Password: "pass", Coins: 1000}
+	carol := &models.User{Login: "lim_carol", Password: "pass", Coins: 1000}
+	for _, u := range []*models.User{alice, bob, carol} {
+		require.NoError(t, userStorage.Create(s.ctx, u))
+		assert.False(t, u.CreatedAt.IsZero())
+	}
+
+	got, err := userStorage.GetByLogin(s.ctx, alice.Login)
+	require.NoError(t, err)
+	assert.WithinDuration(t, alice.CreatedAt, got.CreatedAt, time.Second)
+
+	for _, tr := range []struct {
+		send, recv *models.User
+		amount     int
+	}{
+		{alice, bob, 100},
+		{alice, bob, 5},
+		{bob, carol, 50},
+		{carol, alice, 10},
+	} {
+		_, err := s.transactionStorage.Create(s.ctx, tr.send, tr.recv, tr.amount, models.TransferNote{})
+		require.NoError(t, err)
+	}
+
+	now := time.Now().UTC()
+	v, err := s.transactionStorage.Velocity(s.ctx, alice.Id, bob.Id, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.TransferVelocity{Daily: 105, Weekly: 105, SmallToReceiver: 1}, v)
+
+	v, err = s.transactionStorage.Velocity(s.ctx, alice.Id, bob.Id, now.Add(8*24*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.TransferVelocity{}, v)
+
+	hour := now.Add(-time.Hour)
+	found, err := s.transactionStorage.HasChain(s.ctx, bob.Id, alice.Id, hour, models.MaxCircularDepth)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = s.transactionStorage.HasChain(s.ctx, bob.Id, alice.Id, hour, 1)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	found, err = s.transactionStorage.HasChain(s.ctx, bob.Id, alice.Id, now.Add(time.Hour), models.MaxCircularDepth)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	transferID, err := s.transactionStorage.Create(s.ctx, bob, alice, 20, models.TransferNote{})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, flags.Create(s.ctx, &models.TransferFlag{TransferId: transferID}), models.ErrEmptyTransferFlag)
+
+	flag := &models.TransferFlag{TransferId: transferID, Reasons: []models.TransferFlagReason{models.FlagCircular, models.FlagSmallTransfers}}
+	require.NoError(t, flags.Create(s.ctx, flag))
+	assert.Positive(t, flag.Id)
+	assert.Equal(t, models.FlagOpen, flag.Status)
+
+	stored, err := flags.Get(s.ctx, flag.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "lim_bob", stored.Sender)
+	assert.Equal(t, "lim_alice", stored.Receiver)
+	assert.Equal(t, 20, stored.Amount)
+	assert.Equal(t, flag.Reasons, stored.Reasons)
+
+	page, err := flags.List(s.ctx, &models.TransferFlagFilter{Status: models.FlagOpen})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+
+	require.NoError(t, flags.Review(s.ctx, flag.Id, models.FlagConfirmed, carol.Id))
+	assert.ErrorIs(t, flags.Review(s.ctx, flag.Id, models.FlagDismissed, carol.Id), models.ErrTransferFlagClosed)
+	assert.ErrorIs(t, flags.Review(s.ctx, flag.Id+100, models.FlagDismissed, carol.Id), models.ErrTransferFlagNotFound)
+	assert.ErrorIs(t, flags.Review(s.ctx, flag.Id, models.FlagOpen, carol.Id), models.ErrInvalidTransferFlagStatus)
+
+	stored, err = flags.Get(s.ctx, flag.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.FlagConfirmed, stored.Status)
+	assert.Equal(t, "lim_carol", stored.Reviewer)
+	assert.NotNil(t, stored.ReviewedAt)
+
+	page, err = flags.List(s.ctx, &models.TransferFlagFilter{Status: models.FlagOpen})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+}
@@ -7,6 +7,7 @@ import (
 	"merch_service/internal/storage"
 	"merch_service/internal/storage/postgres"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
@@ -427,3 +428,60 @@ func (s *TestUserPG) TestUserPGGetByLogin() {
 		})
 	}
 }
+
+// TestUserPGCountByRoleForUpdate - CountByRoleForUpdate считает пользователей с ролью
+func (s *TestUserPG) TestUserPGCountByRoleForUpdate() {
+	t := s.T()
+
+	for i, role := range []models.Role{models.RoleAdmin, models.RoleAdmin, models.RoleUser} {
+		require.NoError(t, s.userStorage.Create(s.ctx, &models.User{Login: fmt.Sprintf("role_user%d", i), Password: "pass", Role: role}))
+	}
+
+	admins, err := s.userStorage.CountByRoleForUpdate(s.ctx, models.RoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, 2, admins)
+
+	users, err := s.userStorage.CountByRoleForUpdate(s.ctx, models.RoleUser)
+	require.NoError(t, err)
+	assert.Equal(t, 1, users)
+}
+
+// TestIdempotencyPG - ключи идемпотентности IdempotencyPG:
+// - ключ резервируется один раз, повторный Create возвращает ErrIdempotencyKeyExists
+// - сохраненный ответ возвращается байт в байт (порядок ключей и пробелы)
+// - Takeover занимает только незавершенный ключ с истекшей арендой
+// - истекший ключ не виден и освобождается при следующем Create
+func (s *TestUserPG) TestIdempotencyPG() {
+	t := s.T()
+
+	keys := postgres.NewIdempotencyStorage(s.pool)
+
+	user := &models.User{Login: "idem_user", Password: "pass"}
+	require.NoError(t, s.userStorage.Create(s.ctx, user))
+
+	now := time.Now().UTC()
+	record := &models.IdempotencyRecord{Key: "buy-1", UserId: user.Id, RequestHash: "hash", LockedUntil: now.Add(time.Minute), ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, keys.Create(s.ctx, record, now))
+
+	assert.ErrorIs(t, keys.Takeover(s.ctx, record, now), models.ErrIdempotencyInProgress)
+	leaseEnd := now.Add(2 * time.Minute)
+	record.LockedUntil = leaseEnd.Add(time.Minute)
+	require.NoError(t, keys.Takeover(s.ctx, record, leaseEnd))
+	assert.ErrorIs(t, keys.Takeover(s.ctx, record, leaseEnd), models.ErrIdempotencyInProgress)
+	assert.ErrorIs(t, keys.Create(s.ctx, &models.IdempotencyRecord{Key: "buy-1", UserId: user.Id, RequestHash: "hash", ExpiresAt: now.Add(time.Hour)}, now), models.ErrIdempotencyKeyExists)
+
+	body := []byte(`{"message":"ok",  "error_code":200,"data":{"z":1,"a":2}}` + "\n")
+	record.StatusCode, record.Response = 200, body
+	require.NoError(t, keys.Complete(s.ctx, record))
+
+	stored, err := keys.Get(s.ctx, user.Id, "buy-1", now)
+	require.NoError(t, err)
+	assert.True(t, stored.Completed())
+	assert.Equal(t, body, stored.Response)
+	assert.ErrorIs(t, keys.Takeover(s.ctx, record, now.Add(30*time.Minute)), models.ErrIdempotencyInProgress)
+
+	later := now.Add(2 * time.Hour)
+	_, err = keys.Get(s.ctx, user.Id, "buy-1", later)
+	assert.ErrorIs(t, err, models.ErrIdempotencyNotFound)
+	require.NoError(t, keys.Create(s.ctx, &models.IdempotencyRecord{Key: "buy-1", UserId: user.Id, RequestHash: "other", ExpiresAt: later.Add(time.Hour)}, later))
+}
@@ -0,0 +1,59 @@
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"merch_service/internal/models"
+	"merch_service/internal/notify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWebhook - проверяет вебхук уведомлений: JSON тело {"notifications": [...]}
+// с ключом идемпотентности у каждого уведомления, пустая рассылка без запроса
+// и ошибка на ответ не 2xx
+func TestWebhook(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		requests int
+		received struct {
+			Notifications []struct {
+				models.Notification
+				IdempotencyKey string `json:"idempotency_key"`
+			} `json:"notifications"`
+		}
+		status = http.StatusNoContent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := notify.NewWebhook(server.URL)
+
+	require.NoError(t, webhook.Notify(ctx, nil))
+	assert.Equal(t, 0, requests)
+
+	notes := []*models.Notification{
+		{Id: 1, Login: "fan", Kind: models.NotificationBackInStock, MerchId: 2, MerchName: "Кружка"},
+	}
+	require.NoError(t, webhook.Notify(ctx, notes))
+	assert.Equal(t, 1, requests)
+	require.Len(t, received.Notifications, 1)
+	assert.Equal(t, "fan", received.Notifications[0].Login)
+	assert.Equal(t, "Кружка", received.Notifications[0].MerchName)
+	assert.Equal(t, "notification-1", received.Notifications[0].IdempotencyKey)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, webhook.Notify(ctx, notes))
+}